@@ -4,6 +4,9 @@ import (
 	"context"
 	coreosalicloud "github.com/gardener/gardener-extensions/controllers/os-coreos-alicloud/cmd/gardener-extension-os-coreos-alicloud/app"
 	coreos "github.com/gardener/gardener-extensions/controllers/os-coreos/cmd/gardener-extension-os-coreos/app"
+	flatcar "github.com/gardener/gardener-extensions/controllers/os-flatcar/cmd/gardener-extension-os-flatcar/app"
+	susechost "github.com/gardener/gardener-extensions/controllers/os-suse-chost/cmd/gardener-extension-os-suse-chost/app"
+	ubuntu "github.com/gardener/gardener-extensions/controllers/os-ubuntu/cmd/gardener-extension-os-ubuntu/app"
 	"github.com/spf13/cobra"
 )
 
@@ -16,6 +19,9 @@ func NewHyperCommand(ctx context.Context) *cobra.Command {
 	cmd.AddCommand(
 		coreos.NewControllerCommand(ctx),
 		coreosalicloud.NewControllerCommand(ctx),
+		flatcar.NewControllerCommand(ctx),
+		ubuntu.NewControllerCommand(ctx),
+		susechost.NewControllerCommand(ctx),
 	)
 
 	return cmd
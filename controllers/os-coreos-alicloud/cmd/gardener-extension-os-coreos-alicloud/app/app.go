@@ -18,17 +18,82 @@ import (
 	"context"
 	"fmt"
 	"github.com/gardener/gardener-extensions/controllers/os-coreos-alicloud/pkg/coreos-alicloud"
+	"github.com/gardener/gardener-extensions/pkg/cloudinit/bash"
 	"github.com/gardener/gardener-extensions/pkg/controller/operatingsystemconfig"
 	"github.com/spf13/cobra"
 	"os"
+	"sigs.k8s.io/yaml"
 )
 
 // Name is the name of the CoreOS Alicloud controller.
 const Name = "os-coreos-alicloud"
 
+// Configuration is the actuator specific section of the controller configuration file.
+type Configuration struct {
+	// BaseProfile is the base profile applied to the machines at bootstrap. Fields that are not
+	// set keep their default values.
+	BaseProfile *operatingsystemconfig.BaseProfile `json:"baseProfile,omitempty"`
+	// ContainerRuntime is the container runtime of the machines. Fields that are not set keep
+	// their default values.
+	ContainerRuntime *operatingsystemconfig.ContainerRuntimeConfiguration `json:"containerRuntime,omitempty"`
+	// ReloadCommand is the command nodes use to run the cloud-init script, the quoted path of the
+	// script is appended as last argument. Defaults to coreos.DefaultReloadCommand.
+	ReloadCommand string `json:"reloadCommand,omitempty"`
+	// TemplatePath is the path of a file or a directory with templates overriding the embedded
+	// templates of the cloud-init script. See coreos.Options.TemplatePath.
+	TemplatePath string `json:"templatePath,omitempty"`
+	// MetadataService is the name of the metadata service the machines discover their provider id
+	// from. Defaults to bash.MetadataServiceAlicloud.
+	MetadataService string `json:"metadataService,omitempty"`
+	// MetadataEndpoint overrides the endpoint of the metadata service.
+	MetadataEndpoint string `json:"metadataEndpoint,omitempty"`
+}
+
 // ActuatorFactory is the factory to create a CoreOS Alicloud Actuator.
 func ActuatorFactory(args *operatingsystemconfig.ActuatorArgs) (operatingsystemconfig.Actuator, error) {
-	return coreos.NewActuator(args.Log), nil
+	config := &Configuration{}
+	if len(args.Config) > 0 {
+		if err := yaml.UnmarshalStrict(args.Config, config); err != nil {
+			return nil, fmt.Errorf("could not decode actuator configuration: %v", err)
+		}
+	}
+	if config.BaseProfile != nil {
+		if err := config.BaseProfile.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if config.ContainerRuntime != nil {
+		if err := config.ContainerRuntime.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if config.TemplatePath != "" {
+		if err := coreos.ValidateTemplatePath(config.TemplatePath); err != nil {
+			return nil, fmt.Errorf("invalid templates at %q: %v", config.TemplatePath, err)
+		}
+	}
+
+	if config.MetadataService == "" {
+		config.MetadataService = bash.MetadataServiceAlicloud
+	}
+	metadata, err := bash.MetadataServiceFor(config.MetadataService)
+	if err != nil {
+		return nil, err
+	}
+	if config.MetadataEndpoint != "" {
+		metadata.Endpoint = config.MetadataEndpoint
+	}
+	if err := metadata.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid metadata service %q: %v", config.MetadataService, err)
+	}
+
+	return coreos.NewActuator(args.Log, coreos.Options{
+		BaseProfile:      coreos.DefaultBaseProfile().Merge(config.BaseProfile),
+		ContainerRuntime: coreos.DefaultContainerRuntimeConfiguration().Merge(config.ContainerRuntime),
+		ReloadCommand:    config.ReloadCommand,
+		TemplatePath:     config.TemplatePath,
+		MetadataService:  metadata,
+	}), nil
 }
 
 // NewControllerCommand creates a new command for running a CoreOS Alicloud controller.
@@ -59,5 +124,7 @@ func NewControllerCommand(ctx context.Context) *cobra.Command {
 		fs.AddFlagSet(f)
 	}
 
+	cmd.AddCommand(operatingsystemconfig.NewRenderCommand(ctx, opts.Controller))
+
 	return cmd
 }
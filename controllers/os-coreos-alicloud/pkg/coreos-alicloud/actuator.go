@@ -16,171 +16,186 @@ package coreos
 
 import (
 	"context"
-	"fmt"
-	"github.com/gardener/gardener-extensions/controllers/os-coreos-alicloud/pkg/coreos-alicloud/internal"
-	"github.com/gardener/gardener-extensions/controllers/os-coreos-alicloud/pkg/coreos-alicloud/internal/cloudinit"
-	"github.com/gardener/gardener-extensions/pkg/controller"
+	"github.com/gardener/gardener-extensions/pkg/cloudinit/bash"
 	"github.com/gardener/gardener-extensions/pkg/controller/operatingsystemconfig"
 	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
 	"github.com/go-logr/logr"
-	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
-	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"time"
 )
 
-// Type is the type of operating system configs the CoreOS Alicloud controller monitors.
-const Type = "coreos-alicloud"
+const (
+	// Type is the type of operating system configs the CoreOS Alicloud controller monitors.
+	Type = "coreos-alicloud"
 
-type actuator struct {
-	scheme *runtime.Scheme
-	client client.Client
-	logger logr.Logger
-}
+	// DefaultReloadCommand is the default command used to run the cloud-init script on a node. The
+	// script is run with bash while holding a lock, so concurrent reloads do not interleave.
+	DefaultReloadCommand = "/usr/bin/flock --wait 300 /run/lock/gardener-cloud-init.lock /bin/bash"
 
-// NewActuator creates a new actuator with the given logger.
-func NewActuator(logger logr.Logger) operatingsystemconfig.Actuator {
-	return &actuator{
-		logger: logger,
-	}
-}
-
-func (a *actuator) InjectScheme(scheme *runtime.Scheme) error {
-	a.scheme = scheme
-	return nil
-}
+	// TemplateReloadInterval is the interval in which the templates at the template path are
+	// checked for changes.
+	TemplateReloadInterval = 30 * time.Second
+)
 
-func (a *actuator) InjectClient(client client.Client) error {
-	a.client = client
-	return nil
+// DefaultBaseProfile returns the default base profile of CoreOS machines on Alicloud. It disables
+// locksmithd and fixes the mis-configuration of the docker unit of the Alicloud CoreOS image. As
+// the script runs in strict mode, the fix is a no-op on images without the runtime docker unit.
+func DefaultBaseProfile() *operatingsystemconfig.BaseProfile {
+	return &operatingsystemconfig.BaseProfile{
+		DisabledUnits: []string{"locksmithd.service"},
+		Commands: []string{
+			`sed -i '/Environment=DOCKER_SELINUX=--selinux-enabled=true/s/^/#/g' /run/systemd/system/docker.service 2>/dev/null || true`,
+		},
+	}
 }
 
-func (a *actuator) Exists(ctx context.Context, config *extensionsv1alpha1.OperatingSystemConfig) (bool, error) {
-	return config.Status.CloudConfig != nil, nil
+// DefaultContainerRuntimeConfiguration returns the default container runtime configuration of
+// CoreOS machines on Alicloud. The docker storage driver of the Alicloud CoreOS image does not
+// work, devicemapper is used instead.
+func DefaultContainerRuntimeConfiguration() *operatingsystemconfig.ContainerRuntimeConfiguration {
+	return &operatingsystemconfig.ContainerRuntimeConfiguration{
+		Name:          operatingsystemconfig.ContainerRuntimeDocker,
+		StorageDriver: "devicemapper",
+	}
 }
 
-func (a *actuator) Create(ctx context.Context, config *extensionsv1alpha1.OperatingSystemConfig) error {
-	return a.reconcile(ctx, config)
+// Options are options for the creation of the CoreOS Alicloud actuator.
+type Options struct {
+	// BaseProfile is the base profile applied to the machines at bootstrap. Defaults to DefaultBaseProfile.
+	BaseProfile *operatingsystemconfig.BaseProfile
+	// ContainerRuntime is the container runtime of the machines, it can be overridden per config
+	// with the operatingsystemconfig.ContainerRuntimeAnnotation. Defaults to
+	// DefaultContainerRuntimeConfiguration.
+	ContainerRuntime *operatingsystemconfig.ContainerRuntimeConfiguration
+	// ReloadCommand is the command the quoted path of the reload config file is appended to as last
+	// argument in order to compute the command of the status. Defaults to DefaultReloadCommand.
+	ReloadCommand string
+	// TemplatePath is the path of a file or a directory, e.g. a mounted ConfigMap, with templates
+	// overriding the embedded templates of the cloud-init script. The templates are reloaded when
+	// they change and all configs are rendered again. If empty, the embedded templates are used.
+	TemplatePath string
+	// MetadataService is the metadata service bootstrap scripts discover the provider ID from.
+	// Defaults to the one of Alicloud.
+	MetadataService *bash.MetadataService
 }
 
-func (a *actuator) Update(ctx context.Context, config *extensionsv1alpha1.OperatingSystemConfig) error {
-	return a.reconcile(ctx, config)
+type actuator struct {
+	*operatingsystemconfig.ResultActuator
+	templates *bash.TemplateLoader
 }
 
-func (a *actuator) Delete(ctx context.Context, config *extensionsv1alpha1.OperatingSystemConfig) error {
-	return a.delete(ctx, config)
+type renderer struct {
+	baseProfile *operatingsystemconfig.BaseProfile
+	templates   *bash.TemplateLoader
+	metadata    *bash.MetadataService
 }
 
-func (a *actuator) reconcile(ctx context.Context, config *extensionsv1alpha1.OperatingSystemConfig) error {
-	cloudConfig, err := a.cloudConfigFromOperatingSystemConfig(ctx, config)
-	if err != nil {
-		config.Status.ObservedGeneration = config.Generation
-		config.Status.LastOperation, config.Status.LastError = controller.ReconcileError(extensionsv1alpha1.LastOperationTypeReconcile, fmt.Sprintf("Could not generate cloud config: %v", err), 50)
-		if err := a.client.Status().Update(ctx, config); err != nil {
-			a.logger.Error(err, "Could not update operating system config status after update error", "osc", config.Name)
-		}
-		return err
+// NewActuator creates a new actuator with the given logger and options.
+func NewActuator(logger logr.Logger, opts Options) operatingsystemconfig.Actuator {
+	r := &renderer{
+		baseProfile: opts.BaseProfile,
+		metadata:    opts.MetadataService,
 	}
-
-	secret := &corev1.Secret{
-		ObjectMeta: secretObjectMetaForConfig(config),
+	if r.baseProfile == nil {
+		r.baseProfile = DefaultBaseProfile()
+	}
+	if r.metadata == nil {
+		r.metadata, _ = bash.MetadataServiceFor(bash.MetadataServiceAlicloud)
+	}
+	if opts.TemplatePath != "" {
+		r.templates = bash.NewTemplateLoader(logger, bash.DefaultTemplate(), opts.TemplatePath, TemplateReloadInterval)
 	}
 
-	if err := controller.CreateOrUpdate(ctx, a.client, secret, func() error {
-		if secret.Data == nil {
-			secret.Data = make(map[string][]byte)
-		}
-		secret.Data[extensionsv1alpha1.OperatingSystemConfigSecretDataKey] = []byte(cloudConfig)
-
-		return controllerutil.SetControllerReference(config, secret, a.scheme)
-	}); err != nil {
-		config.Status.ObservedGeneration = config.Generation
-		config.Status.LastOperation, config.Status.LastError = controller.ReconcileError(extensionsv1alpha1.LastOperationTypeReconcile, fmt.Sprintf("Could not apply secret for generated cloud config: %v", err), 50)
-		if err := a.client.Status().Update(ctx, config); err != nil {
-			a.logger.Error(err, "Could not update operating system config status after reconcile error", "osc", config.Name)
-		}
-		return err
+	runtime := opts.ContainerRuntime
+	if runtime == nil {
+		runtime = DefaultContainerRuntimeConfiguration()
+	}
+	reloadCommand := opts.ReloadCommand
+	if reloadCommand == "" {
+		reloadCommand = DefaultReloadCommand
 	}
 
-	config.Status.CloudConfig = &extensionsv1alpha1.CloudConfig{
-		SecretRef: corev1.SecretReference{
-			Name:      secret.Name,
-			Namespace: secret.Namespace,
-		},
+	return &actuator{
+		ResultActuator: operatingsystemconfig.NewResultActuator(logger, r, operatingsystemconfig.ResultActuatorOptions{
+			ContainerRuntime: runtime,
+			ReloadCommand:    operatingsystemconfig.QuotedPathReloadCommand(reloadCommand),
+		}),
+		templates: r.templates,
 	}
-	config.Status.ObservedGeneration = config.Generation
-	config.Status.LastOperation, config.Status.LastError = controller.ReconcileSucceeded(extensionsv1alpha1.LastOperationTypeReconcile, "Successfully generated cloud config")
-	return a.client.Status().Update(ctx, config)
 }
 
-func (a *actuator) delete(ctx context.Context, config *extensionsv1alpha1.OperatingSystemConfig) error {
-	config.Status.ObservedGeneration = config.Generation
-	config.Status.LastOperation, config.Status.LastError = controller.ReconcileSucceeded(extensionsv1alpha1.LastOperationTypeDelete, "Successfully deleted cloud config")
-	if err := a.client.Status().Update(ctx, config); err != nil {
-		a.logger.Error(err, "Could not update operating system config status for deletion", "osc", config.Name)
-		return err
+// WatchInputs reloads the templates at the template path when they change, so that all configs
+// are rendered again with them.
+func (a *actuator) WatchInputs(stop <-chan struct{}, changed func()) {
+	if a.templates == nil {
+		<-stop
+		return
 	}
-	return nil
+	a.templates.Watch(stop, changed)
 }
 
-func secretObjectMetaForConfig(config *extensionsv1alpha1.OperatingSystemConfig) metav1.ObjectMeta {
-	var (
-		name      = fmt.Sprintf("osc-result-%s", config.Name)
-		namespace = config.Namespace
-	)
-
-	if cloudConfig := config.Status.CloudConfig; cloudConfig != nil {
-		name = cloudConfig.SecretRef.Name
-		namespace = cloudConfig.SecretRef.Namespace
+func (r *renderer) Render(_ context.Context, config *extensionsv1alpha1.OperatingSystemConfig, files map[string][]byte) ([]byte, error) {
+	gen, err := r.generator()
+	if err != nil {
+		return nil, err
 	}
+	return cloudConfigFromOperatingSystemConfig(gen, config, files, r.baseProfile)
+}
 
-	return metav1.ObjectMeta{
-		Name:      name,
-		Namespace: namespace,
-	}
+// ValidateTemplatePath returns an error if the templates at the given path cannot be loaded, see
+// Options.TemplatePath.
+func ValidateTemplatePath(path string) error {
+	_, err := bash.LoadTemplate(bash.DefaultTemplate(), path)
+	return err
 }
 
-func (a *actuator) dataForFileContent(ctx context.Context, namespace string, content *extensionsv1alpha1.FileContent) ([]byte, error) {
-	if inline := content.Inline; inline != nil {
-		if len(inline.Encoding) == 0 {
-			return []byte(inline.Data), nil
-		}
-		return cloudinit.Decode(inline.Encoding, []byte(inline.Data))
+// generator returns the generator of cloud-init scripts with the current templates. Bootstrap
+// scripts discover the provider ID from the metadata service.
+func (r *renderer) generator() (*bash.Generator, error) {
+	snippet, err := r.metadata.ProviderIDSnippet()
+	if err != nil {
+		return nil, err
 	}
 
-	key := client.ObjectKey{Namespace: namespace, Name: content.SecretRef.Name}
-	secret := &corev1.Secret{}
-	if err := a.client.Get(ctx, key, secret); err != nil {
-		return nil, err
+	tmpl := bash.DefaultTemplate()
+	if r.templates != nil {
+		tmpl = r.templates.Template()
 	}
-	return secret.Data[content.SecretRef.DataKey], nil
+	return bash.NewGenerator(bash.DefaultUnitsPath, tmpl, snippet), nil
 }
 
-func (a *actuator) cloudConfigFromOperatingSystemConfig(ctx context.Context, config *extensionsv1alpha1.OperatingSystemConfig) ([]byte, error) {
-	files := make([]*internal.File, 0, len(config.Spec.Files))
+func cloudConfigFromOperatingSystemConfig(gen *bash.Generator, config *extensionsv1alpha1.OperatingSystemConfig, filesData map[string][]byte, baseProfile *operatingsystemconfig.BaseProfile) ([]byte, error) {
+	files := make([]*bash.File, 0, len(config.Spec.Files))
 	for _, file := range config.Spec.Files {
-		data, err := a.dataForFileContent(ctx, config.Namespace, &file.Content)
-		if err != nil {
-			return nil, err
-		}
-
-		files = append(files, &internal.File{Path: file.Path, Content: data, Permissions: file.Permissions})
+		files = append(files, &bash.File{Path: file.Path, Content: filesData[file.Path], Permissions: file.Permissions})
 	}
 
-	units := make([]*internal.Unit, 0, len(config.Spec.Units))
+	units := make([]*bash.Unit, 0, len(config.Spec.Units))
 	for _, unit := range config.Spec.Units {
 		var content []byte
 		if unit.Content != nil {
 			content = []byte(*unit.Content)
 		}
 
-		dropIns := make([]*internal.DropIn, 0, len(unit.DropIns))
+		dropIns := make([]*bash.DropIn, 0, len(unit.DropIns))
 		for _, dropIn := range unit.DropIns {
-			dropIns = append(dropIns, &internal.DropIn{Name: dropIn.Name, Content: []byte(dropIn.Content)})
+			dropIns = append(dropIns, &bash.DropIn{Name: dropIn.Name, Content: []byte(dropIn.Content)})
 		}
-		units = append(units, &internal.Unit{Name: unit.Name, Content: content, DropIns: dropIns})
+		units = append(units, &bash.Unit{Name: unit.Name, Content: content, DropIns: dropIns, Enable: unit.Enable, Command: unit.Command})
+	}
+
+	profile := &bash.Profile{
+		MaskedUnits:   baseProfile.MaskedUnits,
+		DisabledUnits: baseProfile.DisabledUnits,
+		Commands:      baseProfile.Commands,
+	}
+	for _, file := range baseProfile.Files {
+		profile.Files = append(profile.Files, &bash.File{Path: file.Path, Content: []byte(file.Content), Permissions: file.Permissions})
 	}
 
-	return internal.NewCloudInitGenerator(internal.DefaultUnitsPath).Generate(&internal.OperatingSystemConfig{Files: files, Units: units})
+	return gen.Generate(&bash.OperatingSystemConfig{
+		Files:     files,
+		Units:     units,
+		Bootstrap: config.Spec.Purpose == extensionsv1alpha1.OperatingSystemConfigPurposeProvision,
+		Profile:   profile,
+	})
 }
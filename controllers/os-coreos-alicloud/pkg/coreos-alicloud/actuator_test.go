@@ -0,0 +1,251 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package coreos_test
+
+import (
+	"context"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/gardener/gardener-extensions/controllers/os-coreos-alicloud/pkg/coreos-alicloud"
+	"github.com/gardener/gardener-extensions/pkg/cloudinit/bash"
+	"github.com/gardener/gardener-extensions/pkg/controller/memoryclient"
+	"github.com/gardener/gardener-extensions/pkg/controller/operatingsystemconfig"
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/inject"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+var updateGolden = flag.Bool("update-golden", false, "Update the golden files of the rendered results.")
+
+func strPtr(s string) *string {
+	return &s
+}
+
+var _ = Describe("Actuator", func() {
+	var (
+		ctx  = context.TODO()
+		osc  *extensionsv1alpha1.OperatingSystemConfig
+		opts coreos.Options
+	)
+
+	BeforeEach(func() {
+		opts = coreos.Options{}
+		osc = &extensionsv1alpha1.OperatingSystemConfig{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "shoot--foo--bar", Name: "pool"},
+			Spec: extensionsv1alpha1.OperatingSystemConfigSpec{
+				DefaultSpec: extensionsv1alpha1.DefaultSpec{Type: coreos.Type},
+				Purpose:     extensionsv1alpha1.OperatingSystemConfigPurposeProvision,
+				Units: []extensionsv1alpha1.Unit{
+					{Name: "kubelet.service", Content: strPtr("[Unit]\nDescription=kubelet\n")},
+				},
+				Files: []extensionsv1alpha1.File{
+					{
+						Path: "/etc/sysctl.d/99-k8s-general.conf",
+						Content: extensionsv1alpha1.FileContent{
+							Inline: &extensionsv1alpha1.FileContentInline{Data: "vm.max_map_count = 135217728\n"},
+						},
+					},
+				},
+			},
+		}
+	})
+
+	render := func() []byte {
+		c, err := memoryclient.New(operatingsystemconfig.ExtensionsScheme, osc)
+		Expect(err).NotTo(HaveOccurred())
+
+		actuator := coreos.NewActuator(log.Log, opts)
+		_, err = inject.SchemeInto(operatingsystemconfig.ExtensionsScheme, actuator)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = inject.ClientInto(c, actuator)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(actuator.Create(ctx, osc)).To(Succeed())
+
+		secret := &corev1.Secret{}
+		ref := osc.Status.CloudConfig.SecretRef
+		Expect(c.Get(ctx, client.ObjectKey{Namespace: ref.Namespace, Name: ref.Name}, secret)).To(Succeed())
+		return secret.Data[extensionsv1alpha1.OperatingSystemConfigSecretDataKey]
+	}
+
+	expectGolden := func(name string, actual []byte) {
+		path := filepath.Join("testdata", name)
+		if *updateGolden {
+			Expect(ioutil.WriteFile(path, actual, 0644)).To(Succeed())
+		}
+
+		expected, err := ioutil.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(actual)).To(Equal(string(expected)))
+	}
+
+	It("should configure docker with the devicemapper storage driver by default", func() {
+		expectGolden("cloud-init-docker.sh", render())
+	})
+
+	It("should run the commands of the units when reconciling", func() {
+		var (
+			enable  = true
+			disable = false
+		)
+		osc.Spec.Purpose = extensionsv1alpha1.OperatingSystemConfigPurposeReconcile
+		osc.Spec.Units = []extensionsv1alpha1.Unit{
+			{Name: "kubelet.service", Enable: &enable, Command: strPtr("restart"), Content: strPtr("[Unit]\nDescription=kubelet\n")},
+			{Name: "update-engine.service", Enable: &disable, Command: strPtr("stop")},
+		}
+
+		expectGolden("cloud-init-reconcile.sh", render())
+	})
+
+	It("should configure containerd if selected by the annotation", func() {
+		opts.ContainerRuntime = &operatingsystemconfig.ContainerRuntimeConfiguration{
+			Name:            operatingsystemconfig.ContainerRuntimeDocker,
+			RegistryMirrors: []operatingsystemconfig.RegistryMirror{{Registry: "docker.io", Endpoints: []string{"https://mirror.example.com"}}},
+		}
+		osc.Annotations = map[string]string{operatingsystemconfig.ContainerRuntimeAnnotation: "containerd"}
+
+		expectGolden("cloud-init-containerd.sh", render())
+		Expect(osc.Status.Units).To(Equal([]string{"kubelet.service", "containerd.service"}))
+	})
+
+	It("should discover the provider id from the Alicloud metadata service by default", func() {
+		Expect(string(render())).To(ContainSubstring("META_EP='http://100.100.100.200/latest/meta-data'"))
+	})
+
+	It("should discover the provider id from the configured metadata service", func() {
+		metadata, err := bash.MetadataServiceFor(bash.MetadataServiceAWS)
+		Expect(err).NotTo(HaveOccurred())
+		metadata.Endpoint = "http://127.0.0.1:8080/latest/meta-data"
+		opts.MetadataService = metadata
+
+		script := string(render())
+		Expect(script).To(ContainSubstring("META_EP='http://127.0.0.1:8080/latest/meta-data'"))
+		Expect(script).To(ContainSubstring(`PROVIDER_ID="aws:///`))
+	})
+
+	It("should render with the templates at the template path", func() {
+		dir, err := ioutil.TempDir("", "templates")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		Expect(ioutil.WriteFile(filepath.Join(dir, "put-content.template"), []byte("cat << 'EOF' | base64 -d > {{ quote .Path }}\n{{ .Content }}\nEOF"), 0644)).To(Succeed())
+
+		Expect(coreos.ValidateTemplatePath(dir)).To(Succeed())
+		opts.TemplatePath = dir
+		Expect(string(render())).To(ContainSubstring("cat << 'EOF' | base64 -d > '/etc/sysctl.d/99-k8s-general.conf'"))
+	})
+
+	It("should stop watching its inputs when stopped", func() {
+		watcher, ok := coreos.NewActuator(log.Log, opts).(operatingsystemconfig.InputWatcher)
+		Expect(ok).To(BeTrue())
+
+		stop := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			watcher.WatchInputs(stop, func() {})
+		}()
+		Consistently(done).ShouldNot(BeClosed())
+		close(stop)
+		Eventually(done).Should(BeClosed())
+	})
+
+	It("should reject invalid template paths", func() {
+		Expect(coreos.ValidateTemplatePath("/does/not/exist")).NotTo(Succeed())
+	})
+
+	Describe("status", func() {
+		var (
+			c        client.Client
+			actuator operatingsystemconfig.Actuator
+		)
+
+		reconcile := func() {
+			Expect(actuator.Update(ctx, osc)).To(Succeed())
+		}
+
+		BeforeEach(func() {
+			osc.Spec.ReloadConfigFilePath = strPtr("/var/lib/cloud-config-downloader/cloud-init.sh")
+
+			var err error
+			c, err = memoryclient.New(operatingsystemconfig.ExtensionsScheme, osc)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		JustBeforeEach(func() {
+			actuator = coreos.NewActuator(log.Log, opts)
+			_, err := inject.SchemeInto(operatingsystemconfig.ExtensionsScheme, actuator)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = inject.ClientInto(c, actuator)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should contain the command running the script with locking", func() {
+			reconcile()
+			Expect(osc.Status.Command).To(Equal("/usr/bin/flock --wait 300 /run/lock/gardener-cloud-init.lock /bin/bash '/var/lib/cloud-config-downloader/cloud-init.sh'"))
+		})
+
+		Context("with a custom reload command", func() {
+			BeforeEach(func() {
+				opts.ReloadCommand = "/opt/bin/run-script"
+			})
+
+			It("should append the quoted path to the reload command", func() {
+				osc.Spec.ReloadConfigFilePath = strPtr("/var/lib/it's here.sh")
+				reconcile()
+				Expect(osc.Status.Command).To(Equal(`/opt/bin/run-script '/var/lib/it'\''s here.sh'`))
+			})
+		})
+
+		It("should not contain a command without reload config file path", func() {
+			osc.Spec.ReloadConfigFilePath = nil
+			reconcile()
+			Expect(osc.Status.Command).To(BeEmpty())
+		})
+
+		It("should contain the units whose configuration changed", func() {
+			reconcile()
+			Expect(osc.Status.Units).To(Equal([]string{"kubelet.service", "docker.service"}))
+
+			reconcile()
+			Expect(osc.Status.Units).To(Equal([]string{"kubelet.service", "docker.service"}), "an unchanged result keeps the units")
+
+			osc.Spec.Units[0].Content = strPtr("[Unit]\nDescription=kubelet 2\n")
+			reconcile()
+			Expect(osc.Status.Units).To(Equal([]string{"kubelet.service"}))
+		})
+	})
+
+	It("should fail for invalid annotations", func() {
+		osc.Annotations = map[string]string{operatingsystemconfig.ContainerRuntimeAnnotation: "rkt"}
+
+		c, err := memoryclient.New(operatingsystemconfig.ExtensionsScheme, osc)
+		Expect(err).NotTo(HaveOccurred())
+		actuator := coreos.NewActuator(log.Log, opts)
+		_, err = inject.ClientInto(c, actuator)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(actuator.Create(ctx, osc)).NotTo(Succeed())
+		Expect(osc.Status.LastError).NotTo(BeNil())
+	})
+})
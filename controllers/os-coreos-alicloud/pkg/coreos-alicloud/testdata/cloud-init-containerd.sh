@@ -0,0 +1,43 @@
+#!/bin/bash
+set -euo pipefail
+systemctl disable 'locksmithd.service'
+systemctl stop 'locksmithd.service'
+sed -i '/Environment=DOCKER_SELINUX=--selinux-enabled=true/s/^/#/g' /run/systemd/system/docker.service 2>/dev/null || true
+
+mkdir -p '/etc/containerd'
+echo 'b7bc882321c60768fb6b4915f38806e11f553ac49d2b6425ed3d3b379640f461  /etc/containerd/config.toml' | sha256sum --check --status 2>/dev/null || cat << EOF | base64 -d > '/etc/containerd/.config.toml.gardener-tmp'
+ZGlzYWJsZWRfcGx1Z2lucyA9IFtdCgpbcGx1Z2lucy5jcmkucmVnaXN0cnkubWlycm9ycy4iZG9ja2VyLmlvIl0KICBlbmRwb2ludCA9IFsiaHR0cHM6Ly9taXJyb3IuZXhhbXBsZS5jb20iXQo=
+EOF
+echo 'b7bc882321c60768fb6b4915f38806e11f553ac49d2b6425ed3d3b379640f461  /etc/containerd/config.toml' | sha256sum --check --status 2>/dev/null || mv -f '/etc/containerd/.config.toml.gardener-tmp' '/etc/containerd/config.toml'
+
+mkdir -p '/etc/sysctl.d'
+echo 'f7a3cf37dd7685496f123dacd02f95dee08450b216051912ce6934cbe84958a2  /etc/sysctl.d/99-k8s-general.conf' | sha256sum --check --status 2>/dev/null || cat << EOF | base64 -d > '/etc/sysctl.d/.99-k8s-general.conf.gardener-tmp'
+dm0ubWF4X21hcF9jb3VudCA9IDEzNTIxNzcyOAo=
+EOF
+echo 'f7a3cf37dd7685496f123dacd02f95dee08450b216051912ce6934cbe84958a2  /etc/sysctl.d/99-k8s-general.conf' | sha256sum --check --status 2>/dev/null || mv -f '/etc/sysctl.d/.99-k8s-general.conf.gardener-tmp' '/etc/sysctl.d/99-k8s-general.conf'
+
+mkdir -p '/etc/systemd/system/containerd.service.d'
+echo 'db4d802707eed4889e22f79c05d841c2565ddd5748832cdd8476c9bbb4c2358f  /etc/systemd/system/containerd.service.d/10-gardener-config.conf' | sha256sum --check --status 2>/dev/null || cat << EOF | base64 -d > '/etc/systemd/system/containerd.service.d/.10-gardener-config.conf.gardener-tmp'
+W1NlcnZpY2VdCkV4ZWNTdGFydD0KRXhlY1N0YXJ0PS91c3IvYmluL2NvbnRhaW5lcmQgLS1jb25maWcgL2V0Yy9jb250YWluZXJkL2NvbmZpZy50b21sCg==
+EOF
+echo 'db4d802707eed4889e22f79c05d841c2565ddd5748832cdd8476c9bbb4c2358f  /etc/systemd/system/containerd.service.d/10-gardener-config.conf' | sha256sum --check --status 2>/dev/null || mv -f '/etc/systemd/system/containerd.service.d/.10-gardener-config.conf.gardener-tmp' '/etc/systemd/system/containerd.service.d/10-gardener-config.conf'
+
+echo 'b5f741dc5a2783604ed054b7e3fbe942136a58f379b7ea7f4ae14d3604a95a4d  /etc/systemd/system/kubelet.service' | sha256sum --check --status 2>/dev/null || cat << EOF | base64 -d > '/etc/systemd/system/.kubelet.service.gardener-tmp'
+W1VuaXRdCkRlc2NyaXB0aW9uPWt1YmVsZXQK
+EOF
+echo 'b5f741dc5a2783604ed054b7e3fbe942136a58f379b7ea7f4ae14d3604a95a4d  /etc/systemd/system/kubelet.service' | sha256sum --check --status 2>/dev/null || mv -f '/etc/systemd/system/.kubelet.service.gardener-tmp' '/etc/systemd/system/kubelet.service'
+
+META_EP='http://100.100.100.200/latest/meta-data'
+REGION_ID=$(curl --silent --show-error --fail --retry 5 --retry-delay 2 --retry-connrefused --connect-timeout 5 --max-time 10 "$META_EP"/'region-id')
+INSTANCE_ID=$(curl --silent --show-error --fail --retry 5 --retry-delay 2 --retry-connrefused --connect-timeout 5 --max-time 10 "$META_EP"/'instance-id')
+PROVIDER_ID="${REGION_ID:?could not determine REGION_ID}.${INSTANCE_ID:?could not determine INSTANCE_ID}"
+DOWNLOAD_MAIN_PATH="${DOWNLOAD_MAIN_PATH:-/var/lib/cloud-config-downloader}"
+mkdir -p "$DOWNLOAD_MAIN_PATH"
+echo "PROVIDER_ID=$PROVIDER_ID" > "$DOWNLOAD_MAIN_PATH/provider-id"
+touch /etc/environment
+sed -i '/^PROVIDER_ID=/d' /etc/environment
+echo "PROVIDER_ID=$PROVIDER_ID" >> /etc/environment
+
+systemctl daemon-reload
+systemctl 'enable' 'containerd.service'
+systemctl 'start' 'containerd.service'
\ No newline at end of file
@@ -0,0 +1,27 @@
+#!/bin/bash
+set -euo pipefail
+
+mkdir -p '/etc/docker'
+echo '1f29312105bb4b0b9845f38306a7a463febe259f66cfb860038ba5f74aedc1b5  /etc/docker/daemon.json' | sha256sum --check --status 2>/dev/null || cat << EOF | base64 -d > '/etc/docker/.daemon.json.gardener-tmp'
+ewogICJzdG9yYWdlLWRyaXZlciI6ICJkZXZpY2VtYXBwZXIiCn0K
+EOF
+echo '1f29312105bb4b0b9845f38306a7a463febe259f66cfb860038ba5f74aedc1b5  /etc/docker/daemon.json' | sha256sum --check --status 2>/dev/null || mv -f '/etc/docker/.daemon.json.gardener-tmp' '/etc/docker/daemon.json'
+
+mkdir -p '/etc/sysctl.d'
+echo 'f7a3cf37dd7685496f123dacd02f95dee08450b216051912ce6934cbe84958a2  /etc/sysctl.d/99-k8s-general.conf' | sha256sum --check --status 2>/dev/null || cat << EOF | base64 -d > '/etc/sysctl.d/.99-k8s-general.conf.gardener-tmp'
+dm0ubWF4X21hcF9jb3VudCA9IDEzNTIxNzcyOAo=
+EOF
+echo 'f7a3cf37dd7685496f123dacd02f95dee08450b216051912ce6934cbe84958a2  /etc/sysctl.d/99-k8s-general.conf' | sha256sum --check --status 2>/dev/null || mv -f '/etc/sysctl.d/.99-k8s-general.conf.gardener-tmp' '/etc/sysctl.d/99-k8s-general.conf'
+
+echo 'b5f741dc5a2783604ed054b7e3fbe942136a58f379b7ea7f4ae14d3604a95a4d  /etc/systemd/system/kubelet.service' | sha256sum --check --status 2>/dev/null || cat << EOF | base64 -d > '/etc/systemd/system/.kubelet.service.gardener-tmp'
+W1VuaXRdCkRlc2NyaXB0aW9uPWt1YmVsZXQK
+EOF
+echo 'b5f741dc5a2783604ed054b7e3fbe942136a58f379b7ea7f4ae14d3604a95a4d  /etc/systemd/system/kubelet.service' | sha256sum --check --status 2>/dev/null || mv -f '/etc/systemd/system/.kubelet.service.gardener-tmp' '/etc/systemd/system/kubelet.service'
+
+systemctl daemon-reload
+systemctl 'enable' 'docker.service'
+systemctl 'start' 'docker.service'
+systemctl 'enable' 'kubelet.service'
+systemctl 'restart' 'kubelet.service'
+systemctl 'disable' 'update-engine.service'
+systemctl 'stop' 'update-engine.service'
\ No newline at end of file
@@ -18,22 +18,67 @@ import (
 	"context"
 	"fmt"
 	"github.com/gardener/gardener-extensions/controllers/os-coreos/pkg/coreos"
+	coreosconfig "github.com/gardener/gardener-extensions/pkg/cloudinit/coreos"
 	"github.com/gardener/gardener-extensions/pkg/controller/operatingsystemconfig"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"os"
 )
 
 // Name is the name of the CoreOS controller.
 const Name = "os-coreos"
 
+// ActuatorOptions are options for the creation of a CoreOS operating system config actuator.
+type ActuatorOptions struct {
+	// ReloadCommand is the command prefix used to reload the cloud config on a node.
+	ReloadCommand string
+	// Format is the format of the result for OperatingSystemConfigs of type `coreos`.
+	Format string
+}
+
+// NewActuatorOptions creates new ActuatorOptions with default values.
+func NewActuatorOptions() *ActuatorOptions {
+	return &ActuatorOptions{
+		ReloadCommand: coreosconfig.DefaultReloadCommand,
+		Format:        string(coreosconfig.FormatCloudConfig),
+	}
+}
+
+// AddFlags adds all ActuatorOptions relevant flags to the given FlagSet.
+func (a *ActuatorOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&a.ReloadCommand, "reload-command", a.ReloadCommand, fmt.Sprintf("The command prefix nodes use to reload the cloud config, the path of the cloud config file is appended. Use %q to reload with the os-config-applier.", coreosconfig.ApplierReloadCommand))
+	fs.StringVar(&a.Format, "format", a.Format, fmt.Sprintf("The format of the result for operating system configs of type %q, either %q or %q. Configs of type %q are always rendered as %q.", coreos.Type, coreosconfig.FormatCloudConfig, coreosconfig.FormatIgnition, coreos.TypeIgnition, coreosconfig.FormatIgnition))
+}
+
 // ActuatorFactory creates a new CoreOS operating system config actuator.
-func ActuatorFactory(args *operatingsystemconfig.ActuatorArgs) (operatingsystemconfig.Actuator, error) {
-	return coreos.NewActuator(args.Log), nil
+func (a *ActuatorOptions) ActuatorFactory(args *operatingsystemconfig.ActuatorArgs) (operatingsystemconfig.Actuator, error) {
+	switch format := coreosconfig.Format(a.Format); format {
+	case coreosconfig.FormatCloudConfig, coreosconfig.FormatIgnition:
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+
+	config, err := coreosconfig.ParseConfiguration(args.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	return coreos.NewActuator(args.Log, coreos.Options{
+		ReloadCommand:    a.ReloadCommand,
+		Format:           coreosconfig.Format(a.Format),
+		Update:           coreosconfig.DefaultUpdateConfiguration().Merge(config.Update),
+		BaseProfile:      coreosconfig.DefaultBaseProfile().Merge(config.BaseProfile),
+		ContainerRuntime: operatingsystemconfig.DefaultContainerRuntimeConfiguration().Merge(config.ContainerRuntime),
+	}), nil
 }
 
 // NewControllerCommand creates a new CoreOS controller command.
 func NewControllerCommand(ctx context.Context) *cobra.Command {
-	opts := operatingsystemconfig.NewCommandOptions(Name, coreos.Type, ActuatorFactory)
+	actuatorOpts := NewActuatorOptions()
+	opts := operatingsystemconfig.NewCommandOptions(Name, coreos.Type, actuatorOpts.ActuatorFactory)
+	opts.Controller.AdditionalTypes = []string{coreos.TypeIgnition}
+	opts.Mapper.AdditionalTypes = []string{coreos.TypeIgnition}
+	opts.Controller.Annotations = append(opts.Controller.Annotations, coreosconfig.UpdateAnnotation)
 	opts.Manager.LeaderElection = true
 	opts.Manager.LeaderElectionNamespace = os.Getenv("LEADER_ELECTION_NAMESPACE")
 
@@ -54,10 +99,17 @@ func NewControllerCommand(ctx context.Context) *cobra.Command {
 		},
 	}
 
+	fss := opts.Flags()
+	actuatorOpts.AddFlags(fss.FlagSet("coreos"))
+
 	fs := cmd.Flags()
-	for _, f := range opts.Flags().FlagSets {
+	for _, f := range fss.FlagSets {
 		fs.AddFlagSet(f)
 	}
 
+	render := operatingsystemconfig.NewRenderCommand(ctx, opts.Controller)
+	render.Flags().AddFlagSet(fss.FlagSet("coreos"))
+	cmd.AddCommand(render)
+
 	return cmd
 }
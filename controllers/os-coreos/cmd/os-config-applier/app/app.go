@@ -0,0 +1,152 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/gardener/gardener-extensions/controllers/os-coreos/pkg/applier"
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+// Options are the options of the os-config-applier command.
+type Options struct {
+	// FromFile is the path of a file containing the cloud config.
+	FromFile string
+	// Kubeconfig is the path of a kubeconfig used to read the cloud config from a secret.
+	Kubeconfig string
+	// SecretName is the name of the secret containing the cloud config.
+	SecretName string
+	// SecretNamespace is the namespace of the secret containing the cloud config.
+	SecretNamespace string
+	// Root is the directory all paths of the cloud config are relative to.
+	Root string
+	// UnitsPath is the path units are written to.
+	UnitsPath string
+	// SystemctlCommand is the command used to control systemd.
+	SystemctlCommand string
+}
+
+// NewOptions creates new Options with default values.
+func NewOptions() *Options {
+	return &Options{
+		SecretNamespace:  metav1.NamespaceSystem,
+		Root:             "/",
+		UnitsPath:        applier.DefaultUnitsPath,
+		SystemctlCommand: "systemctl",
+	}
+}
+
+// AddFlags adds all Options relevant flags to the given FlagSet.
+func (o *Options) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.FromFile, "from-file", o.FromFile, "Path of a file containing the cloud config.")
+	fs.StringVar(&o.Kubeconfig, "kubeconfig", o.Kubeconfig, "Path of a kubeconfig used to read the cloud config from a secret.")
+	fs.StringVar(&o.SecretName, "secret-name", o.SecretName, "Name of the secret containing the cloud config.")
+	fs.StringVar(&o.SecretNamespace, "secret-namespace", o.SecretNamespace, "Namespace of the secret containing the cloud config.")
+	fs.StringVar(&o.Root, "root", o.Root, "Directory all paths of the cloud config are relative to.")
+	fs.StringVar(&o.UnitsPath, "units-path", o.UnitsPath, "Path systemd units are written to.")
+	fs.StringVar(&o.SystemctlCommand, "systemctl-command", o.SystemctlCommand, "Command used to control systemd, may contain leading arguments.")
+}
+
+// Validate validates the Options.
+func (o *Options) Validate() error {
+	if (o.FromFile == "") == (o.SecretName == "") {
+		return fmt.Errorf("exactly one of --from-file and --secret-name has to be specified")
+	}
+	if len(strings.Fields(o.SystemctlCommand)) == 0 {
+		return fmt.Errorf("--systemctl-command must not be empty")
+	}
+	return nil
+}
+
+// Read reads the cloud config either from the file or from the secret.
+func (o *Options) Read() ([]byte, error) {
+	if o.FromFile != "" {
+		return ioutil.ReadFile(o.FromFile)
+	}
+
+	restConfig, err := clientcmd.BuildConfigFromFlags("", o.Kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := clientset.CoreV1().Secrets(o.SecretNamespace).Get(o.SecretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	data, ok := secret.Data[extensionsv1alpha1.OperatingSystemConfigSecretDataKey]
+	if !ok {
+		return nil, fmt.Errorf("could not find key %q in data of secret %s/%s", extensionsv1alpha1.OperatingSystemConfigSecretDataKey, o.SecretNamespace, o.SecretName)
+	}
+	return data, nil
+}
+
+// Run reads and applies the cloud config.
+func (o *Options) Run(ctx context.Context) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	data, err := o.Read()
+	if err != nil {
+		return err
+	}
+
+	logger := log.Log.WithName("os-config-applier")
+	result, err := applier.New(logger, applier.Options{
+		Root:             o.Root,
+		UnitsPath:        o.UnitsPath,
+		SystemctlCommand: strings.Fields(o.SystemctlCommand),
+	}).Apply(ctx, data)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Applied cloud config", "files", len(result.Files), "units", result.Units)
+	return nil
+}
+
+// NewApplierCommand creates a new os-config-applier command.
+func NewApplierCommand(ctx context.Context) *cobra.Command {
+	opts := NewOptions()
+
+	cmd := &cobra.Command{
+		Use:   "os-config-applier",
+		Short: "Applies a CoreOS cloud config rendered by the os-coreos extension to the node.",
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return opts.Run(ctx)
+		},
+		SilenceUsage: true,
+	}
+
+	opts.AddFlags(cmd.Flags())
+	return cmd
+}
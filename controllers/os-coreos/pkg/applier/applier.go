@@ -0,0 +1,447 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package applier applies CoreOS cloud configs and Ignition configs rendered by the CoreOS
+// actuator on a node. It is a replacement for the deprecated `coreos-cloudinit --from-file` that
+// only touches files and units whose content actually changed.
+package applier
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gardener/gardener-extensions/pkg/cloudinit/coreos"
+
+	"github.com/go-logr/logr"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	// DefaultUnitsPath is the default path units are written to.
+	DefaultUnitsPath = "/etc/systemd/system"
+	// RuntimeUnitsPath is the path runtime units are written to.
+	RuntimeUnitsPath = "/run/systemd/system"
+	// UpdateConfPath is the path of the Container Linux update configuration.
+	UpdateConfPath = "/etc/coreos/update.conf"
+
+	cloudConfigHeader = "#cloud-config"
+)
+
+// CommandRunner runs the given command.
+type CommandRunner func(ctx context.Context, name string, args ...string) error
+
+// ExecCommandRunner runs the given command using os/exec.
+func ExecCommandRunner(ctx context.Context, name string, args ...string) error {
+	out, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %v: %s", name, strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// Options are options for an Applier.
+type Options struct {
+	// Root is the directory all paths are relative to. Defaults to `/`.
+	Root string
+	// UnitsPath is the path units are written to. Defaults to DefaultUnitsPath.
+	UnitsPath string
+	// SystemctlCommand is the command (with optional leading arguments) used to control systemd.
+	// Defaults to `systemctl`.
+	SystemctlCommand []string
+	// Run runs commands. Defaults to ExecCommandRunner.
+	Run CommandRunner
+}
+
+// Result describes the changes an Applier made.
+type Result struct {
+	// Files are the paths of all written files, including unit files and drop-ins.
+	Files []string
+	// Units are the names of all units whose unit file, drop-ins or mask changed.
+	Units []string
+}
+
+// Applier applies cloud configs.
+type Applier struct {
+	logger           logr.Logger
+	root             string
+	unitsPath        string
+	systemctlCommand []string
+	run              CommandRunner
+}
+
+// New creates a new Applier with the given logger and options.
+func New(logger logr.Logger, opts Options) *Applier {
+	a := &Applier{
+		logger:           logger,
+		root:             opts.Root,
+		unitsPath:        opts.UnitsPath,
+		systemctlCommand: opts.SystemctlCommand,
+		run:              opts.Run,
+	}
+	if a.root == "" {
+		a.root = "/"
+	}
+	if a.unitsPath == "" {
+		a.unitsPath = DefaultUnitsPath
+	}
+	if len(a.systemctlCommand) == 0 {
+		a.systemctlCommand = []string{"systemctl"}
+	}
+	if a.run == nil {
+		a.run = ExecCommandRunner
+	}
+	return a
+}
+
+// Parse parses the given cloud config document. Ignition configs are converted to cloud configs.
+func Parse(data []byte) (*coreos.CloudConfig, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		ignition, err := coreos.ParseIgnition(data)
+		if err != nil {
+			return nil, err
+		}
+		return cloudConfigFromIgnition(ignition)
+	}
+
+	if !bytes.HasPrefix(data, []byte(cloudConfigHeader)) {
+		return nil, fmt.Errorf("cloud config does not start with %q", cloudConfigHeader)
+	}
+
+	config := &coreos.CloudConfig{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// cloudConfigFromIgnition converts the given Ignition config to a cloud config. As Ignition has
+// no notion of unit commands, enabled units are (re)started when they change.
+func cloudConfigFromIgnition(ignition *coreos.IgnitionConfig) (*coreos.CloudConfig, error) {
+	config := &coreos.CloudConfig{}
+
+	for _, file := range ignition.Storage.Files {
+		if file.Filesystem != coreos.IgnitionRootFilesystem {
+			return nil, fmt.Errorf("file %q is on unsupported filesystem %q", file.Path, file.Filesystem)
+		}
+
+		data, err := coreos.DecodeDataURL(file.Contents.Source)
+		if err != nil {
+			return nil, fmt.Errorf("file %q: %v", file.Path, err)
+		}
+
+		mode := 0644
+		if file.Mode != nil {
+			mode = *file.Mode
+		}
+
+		config.WriteFiles = append(config.WriteFiles, coreos.File{
+			Path:               file.Path,
+			Encoding:           "b64",
+			Content:            base64.StdEncoding.EncodeToString(data),
+			RawFilePermissions: strconv.FormatInt(int64(mode), 8),
+		})
+	}
+
+	for _, unit := range ignition.Systemd.Units {
+		u := coreos.Unit{
+			Name:    unit.Name,
+			Mask:    unit.Mask,
+			Content: unit.Contents,
+		}
+		if unit.Enabled != nil && *unit.Enabled {
+			u.Enable = true
+			u.Command = "start"
+		}
+		for _, dropin := range unit.Dropins {
+			u.DropIns = append(u.DropIns, coreos.UnitDropIn{Name: dropin.Name, Content: dropin.Contents})
+		}
+		config.CoreOS.Units = append(config.CoreOS.Units, u)
+	}
+
+	return config, nil
+}
+
+// Apply parses and applies the given cloud config document.
+func (a *Applier) Apply(ctx context.Context, data []byte) (*Result, error) {
+	config, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return a.ApplyCloudConfig(ctx, config)
+}
+
+// ApplyCloudConfig applies the given cloud config. Files are written atomically. Afterwards,
+// units and drop-ins are written or masked. If any unit changed, systemd is reloaded and the
+// changed units are enabled and (re)started according to their `enable` and `command` fields.
+// Units that did not change are only enabled, started or stopped if systemd reports that they
+// are not in the requested state yet.
+func (a *Applier) ApplyCloudConfig(ctx context.Context, config *coreos.CloudConfig) (*Result, error) {
+	result := &Result{}
+
+	for _, file := range config.WriteFiles {
+		data, err := coreos.DecodeContent(file.Encoding, file.Content)
+		if err != nil {
+			return result, fmt.Errorf("could not decode content of file %q: %v", file.Path, err)
+		}
+
+		perm, err := parsePermissions(file.RawFilePermissions)
+		if err != nil {
+			return result, fmt.Errorf("file %q: %v", file.Path, err)
+		}
+
+		changed, err := a.writeFile(file.Path, data, perm)
+		if err != nil {
+			return result, err
+		}
+		if changed {
+			a.logger.Info("Wrote file", "path", file.Path)
+			result.Files = append(result.Files, file.Path)
+		}
+	}
+
+	if updateConf := config.CoreOS.UpdateConf(); len(updateConf) > 0 {
+		changed, err := a.writeFile(UpdateConfPath, updateConf, 0644)
+		if err != nil {
+			return result, err
+		}
+		if changed {
+			result.Files = append(result.Files, UpdateConfPath)
+		}
+	}
+
+	changedUnits := make(map[string]bool, len(config.CoreOS.Units))
+	for _, unit := range config.CoreOS.Units {
+		changed, err := a.applyUnit(unit, result)
+		if err != nil {
+			return result, err
+		}
+		if changed {
+			a.logger.Info("Unit changed", "unit", unit.Name)
+			changedUnits[unit.Name] = true
+			result.Units = append(result.Units, unit.Name)
+		}
+	}
+
+	if len(changedUnits) > 0 {
+		if err := a.systemctl(ctx, "daemon-reload"); err != nil {
+			return result, err
+		}
+	}
+
+	for _, unit := range config.CoreOS.Units {
+		if unit.Mask {
+			continue
+		}
+
+		changed := changedUnits[unit.Name]
+		if unit.Enable && (changed || !a.unitIs(ctx, "is-enabled", unit.Name)) {
+			if err := a.systemctl(ctx, "enable", unit.Name); err != nil {
+				return result, err
+			}
+		}
+		if command := a.unitCommand(ctx, unit, changed); command != "" {
+			if err := a.systemctl(ctx, command, unit.Name); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// unitCommand returns the systemctl command to execute for the given unit. Changed units that
+// are supposed to be started are restarted so that they pick up their new configuration.
+// Unchanged units are only started or stopped if they are not in the requested state yet.
+func (a *Applier) unitCommand(ctx context.Context, unit coreos.Unit, changed bool) string {
+	switch {
+	case changed && unit.Command == "start":
+		return "restart"
+	case changed:
+		return unit.Command
+	case unit.Command == "start" && !a.unitIs(ctx, "is-active", unit.Name):
+		return "start"
+	case unit.Command == "stop" && a.unitIs(ctx, "is-active", unit.Name):
+		return "stop"
+	}
+	return ""
+}
+
+// unitIs reports whether the given systemctl query, e.g. `is-active`, succeeds for the given unit.
+func (a *Applier) unitIs(ctx context.Context, query, name string) bool {
+	return a.systemctl(ctx, query, "--quiet", name) == nil
+}
+
+func (a *Applier) systemctl(ctx context.Context, args ...string) error {
+	cmd := append(append([]string{}, a.systemctlCommand[1:]...), args...)
+	return a.run(ctx, a.systemctlCommand[0], cmd...)
+}
+
+func (a *Applier) applyUnit(unit coreos.Unit, result *Result) (bool, error) {
+	if unit.Name == "" {
+		return false, fmt.Errorf("unit without name")
+	}
+
+	unitsPath := a.unitsPath
+	if unit.Runtime {
+		unitsPath = RuntimeUnitsPath
+	}
+	unitPath := path.Join(unitsPath, unit.Name)
+
+	if unit.Mask {
+		return a.mask(unitPath)
+	}
+
+	var changed bool
+	if unit.Content != "" {
+		c, err := a.writeFile(unitPath, []byte(unit.Content), 0644)
+		if err != nil {
+			return false, err
+		}
+		if c {
+			result.Files = append(result.Files, unitPath)
+		}
+		changed = changed || c
+	}
+
+	for _, dropIn := range unit.DropIns {
+		if dropIn.Name == "" {
+			return false, fmt.Errorf("drop-in of unit %q without name", unit.Name)
+		}
+
+		dropInPath := path.Join(unitsPath, unit.Name+".d", dropIn.Name)
+		c, err := a.writeFile(dropInPath, []byte(dropIn.Content), 0644)
+		if err != nil {
+			return false, err
+		}
+		if c {
+			result.Files = append(result.Files, dropInPath)
+		}
+		changed = changed || c
+	}
+
+	return changed, nil
+}
+
+func (a *Applier) hostPath(p string) string {
+	return filepath.Join(a.root, filepath.FromSlash(path.Clean("/"+p)))
+}
+
+// permissionBits are the bits of an os.FileMode set by parsePermissions.
+const permissionBits = os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+
+// writeFile atomically writes the given data with the given permissions to the given path
+// unless the file already has this content and these permissions. It returns whether the file
+// has been written.
+func (a *Applier) writeFile(p string, data []byte, perm os.FileMode) (bool, error) {
+	hostPath := a.hostPath(p)
+
+	if info, err := os.Lstat(hostPath); err == nil && info.Mode().IsRegular() && info.Mode()&permissionBits == perm {
+		existing, err := ioutil.ReadFile(hostPath)
+		if err != nil {
+			return false, err
+		}
+		if bytes.Equal(existing, data) {
+			return false, nil
+		}
+	}
+
+	dir := filepath.Dir(hostPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return false, err
+	}
+
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(hostPath)+".tmp")
+	if err != nil {
+		return false, err
+	}
+	tmpName := tmp.Name()
+	defer func() { _ = os.Remove(tmpName) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return false, err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		_ = tmp.Close()
+		return false, err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return false, err
+	}
+	if err := tmp.Close(); err != nil {
+		return false, err
+	}
+
+	if err := os.Rename(tmpName, hostPath); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// mask masks the unit at the given path by atomically replacing it with a symlink to /dev/null.
+func (a *Applier) mask(unitPath string) (bool, error) {
+	hostPath := a.hostPath(unitPath)
+	if target, err := os.Readlink(hostPath); err == nil && target == os.DevNull {
+		return false, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(hostPath), 0755); err != nil {
+		return false, err
+	}
+
+	tmpName := filepath.Join(filepath.Dir(hostPath), "."+filepath.Base(hostPath)+".mask")
+	_ = os.Remove(tmpName)
+	if err := os.Symlink(os.DevNull, tmpName); err != nil {
+		return false, err
+	}
+	if err := os.Rename(tmpName, hostPath); err != nil {
+		_ = os.Remove(tmpName)
+		return false, err
+	}
+	return true, nil
+}
+
+// parsePermissions parses the given octal permissions. The setuid, setgid and sticky bits are
+// mapped to the corresponding os.FileMode bits.
+func parsePermissions(raw string) (os.FileMode, error) {
+	if raw == "" {
+		return 0644, nil
+	}
+	perm, err := strconv.ParseUint(raw, 8, 32)
+	if err != nil || perm > 07777 {
+		return 0, fmt.Errorf("invalid permissions %q", raw)
+	}
+
+	mode := os.FileMode(perm) & os.ModePerm
+	if perm&04000 != 0 {
+		mode |= os.ModeSetuid
+	}
+	if perm&02000 != 0 {
+		mode |= os.ModeSetgid
+	}
+	if perm&01000 != 0 {
+		mode |= os.ModeSticky
+	}
+	return mode, nil
+}
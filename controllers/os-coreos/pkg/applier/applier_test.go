@@ -0,0 +1,281 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package applier_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/gardener/gardener-extensions/controllers/os-coreos/pkg/applier"
+	"github.com/gardener/gardener-extensions/pkg/cloudinit/coreos"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+var _ = Describe("Applier", func() {
+	var (
+		ctx      = context.TODO()
+		root     string
+		commands []string
+		enabled  map[string]bool
+		active   map[string]bool
+		applier  *Applier
+	)
+
+	BeforeEach(func() {
+		var err error
+		root, err = ioutil.TempDir("", "applier")
+		Expect(err).NotTo(HaveOccurred())
+
+		commands = nil
+		enabled, active = map[string]bool{}, map[string]bool{}
+		applier = New(log.Log, Options{
+			Root:             root,
+			SystemctlCommand: []string{"systemctl", "--no-block"},
+			Run: func(_ context.Context, name string, args ...string) error {
+				// Queries are answered from the unit states and not recorded.
+				verb, unit := args[1], args[len(args)-1]
+				switch verb {
+				case "is-enabled", "is-active":
+					if (verb == "is-enabled" && enabled[unit]) || (verb == "is-active" && active[unit]) {
+						return nil
+					}
+					return fmt.Errorf("unit %s is not in state %s", unit, verb)
+				case "enable":
+					enabled[unit] = true
+				case "start", "restart":
+					active[unit] = true
+				case "stop":
+					active[unit] = false
+				}
+
+				commands = append(commands, strings.Join(append([]string{name}, args...), " "))
+				return nil
+			},
+		})
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(root)).To(Succeed())
+	})
+
+	readFile := func(p string) (string, os.FileMode) {
+		hostPath := filepath.Join(root, p)
+		data, err := ioutil.ReadFile(hostPath)
+		Expect(err).NotTo(HaveOccurred())
+		info, err := os.Stat(hostPath)
+		Expect(err).NotTo(HaveOccurred())
+		return string(data), info.Mode().Perm()
+	}
+
+	config := func() *coreos.CloudConfig {
+		return &coreos.CloudConfig{
+			CoreOS: coreos.Config{
+				Update: coreos.Update{RebootStrategy: "off"},
+				Units: []coreos.Unit{
+					{Name: "locksmithd.service", Mask: true},
+					{
+						Name:    "docker.service",
+						Enable:  true,
+						Command: "start",
+						DropIns: []coreos.UnitDropIn{{Name: "10-opts.conf", Content: "[Service]"}},
+					},
+					{Name: "kubelet.service", Command: "start", Content: "[Unit]"},
+				},
+			},
+			WriteFiles: []coreos.File{
+				{Path: "/etc/foo", Content: "foo", RawFilePermissions: "600"},
+				{Path: "/opt/bin/bar", Content: "YmFy", Encoding: "b64", RawFilePermissions: "755"},
+			},
+		}
+	}
+
+	It("should write files, units and drop-ins and control the changed units", func() {
+		data, err := config().String()
+		Expect(err).NotTo(HaveOccurred())
+
+		result, err := applier.Apply(ctx, []byte(data))
+		Expect(err).NotTo(HaveOccurred())
+
+		content, perm := readFile("/etc/foo")
+		Expect(content).To(Equal("foo"))
+		Expect(perm).To(Equal(os.FileMode(0600)))
+		content, perm = readFile("/opt/bin/bar")
+		Expect(content).To(Equal("bar"))
+		Expect(perm).To(Equal(os.FileMode(0755)))
+		content, _ = readFile("/etc/coreos/update.conf")
+		Expect(content).To(Equal("REBOOT_STRATEGY=off\n"))
+		content, _ = readFile("/etc/systemd/system/docker.service.d/10-opts.conf")
+		Expect(content).To(Equal("[Service]"))
+		content, _ = readFile("/etc/systemd/system/kubelet.service")
+		Expect(content).To(Equal("[Unit]"))
+
+		target, err := os.Readlink(filepath.Join(root, "/etc/systemd/system/locksmithd.service"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(target).To(Equal(os.DevNull))
+
+		Expect(result.Units).To(Equal([]string{"locksmithd.service", "docker.service", "kubelet.service"}))
+		Expect(commands).To(Equal([]string{
+			"systemctl --no-block daemon-reload",
+			"systemctl --no-block enable docker.service",
+			"systemctl --no-block restart docker.service",
+			"systemctl --no-block restart kubelet.service",
+		}))
+	})
+
+	It("should only touch changed files and units when applied again", func() {
+		_, err := applier.ApplyCloudConfig(ctx, config())
+		Expect(err).NotTo(HaveOccurred())
+		commands = nil
+
+		changed := config()
+		changed.CoreOS.Units[2].Content = "[Unit]\nDescription=kubelet"
+		result, err := applier.ApplyCloudConfig(ctx, changed)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(result.Files).To(Equal([]string{"/etc/systemd/system/kubelet.service"}))
+		Expect(result.Units).To(Equal([]string{"kubelet.service"}))
+		Expect(commands).To(Equal([]string{
+			"systemctl --no-block daemon-reload",
+			"systemctl --no-block restart kubelet.service",
+		}))
+
+		commands = nil
+		result, err = applier.ApplyCloudConfig(ctx, changed)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Files).To(BeEmpty())
+		Expect(result.Units).To(BeEmpty())
+		Expect(commands).To(BeEmpty())
+	})
+
+	It("should rewrite files whose permissions changed", func() {
+		_, err := applier.ApplyCloudConfig(ctx, config())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chmod(filepath.Join(root, "/etc/foo"), 0644)).To(Succeed())
+
+		result, err := applier.ApplyCloudConfig(ctx, config())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Files).To(Equal([]string{"/etc/foo"}))
+
+		_, perm := readFile("/etc/foo")
+		Expect(perm).To(Equal(os.FileMode(0600)))
+	})
+
+	It("should bring units into the requested state even if they did not change", func() {
+		c := &coreos.CloudConfig{CoreOS: coreos.Config{Units: []coreos.Unit{
+			{Name: "docker.service", Enable: true, Command: "start"},
+			{Name: "update-engine.service", Command: "stop"},
+		}}}
+		active["update-engine.service"] = true
+
+		result, err := applier.ApplyCloudConfig(ctx, c)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Units).To(BeEmpty())
+		Expect(commands).To(Equal([]string{
+			"systemctl --no-block enable docker.service",
+			"systemctl --no-block start docker.service",
+			"systemctl --no-block stop update-engine.service",
+		}))
+
+		commands = nil
+		_, err = applier.ApplyCloudConfig(ctx, c)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(commands).To(BeEmpty())
+
+		active["docker.service"] = false
+		_, err = applier.ApplyCloudConfig(ctx, c)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(commands).To(Equal([]string{"systemctl --no-block start docker.service"}))
+	})
+
+	It("should write the setuid, setgid and sticky bits", func() {
+		c := &coreos.CloudConfig{WriteFiles: []coreos.File{
+			{Path: "/opt/bin/setuid", Content: "foo", RawFilePermissions: "4755"},
+			{Path: "/opt/bin/setgid", Content: "foo", RawFilePermissions: "2755"},
+			{Path: "/var/lib/sticky", Content: "foo", RawFilePermissions: "1644"},
+		}}
+
+		_, err := applier.ApplyCloudConfig(ctx, c)
+		Expect(err).NotTo(HaveOccurred())
+
+		mode := func(p string) os.FileMode {
+			info, err := os.Stat(filepath.Join(root, p))
+			Expect(err).NotTo(HaveOccurred())
+			return info.Mode()
+		}
+		Expect(mode("/opt/bin/setuid")).To(Equal(os.ModeSetuid | 0755))
+		Expect(mode("/opt/bin/setgid")).To(Equal(os.ModeSetgid | 0755))
+		Expect(mode("/var/lib/sticky")).To(Equal(os.ModeSticky | 0644))
+
+		result, err := applier.ApplyCloudConfig(ctx, c)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Files).To(BeEmpty())
+	})
+
+	It("should reject invalid permissions", func() {
+		c := config()
+		c.WriteFiles[0].RawFilePermissions = "17777"
+		_, err := applier.ApplyCloudConfig(ctx, c)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should apply Ignition configs", func() {
+		mode := 0600
+		ignition := &coreos.IgnitionConfig{
+			Ignition: coreos.Ignition{Version: coreos.IgnitionVersion},
+			Storage: coreos.IgnitionStorage{Files: []coreos.IgnitionFile{
+				{Filesystem: "root", Path: "/etc/foo", Contents: coreos.IgnitionFileContents{Source: coreos.DataURL([]byte("foo"))}, Mode: &mode},
+			}},
+			Systemd: coreos.IgnitionSystemd{Units: []coreos.IgnitionUnit{
+				{Name: "locksmithd.service", Mask: true},
+				{Name: "kubelet.service", Enabled: func(b bool) *bool { return &b }(true), Contents: "[Unit]"},
+			}},
+		}
+		data, err := ignition.String()
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = applier.Apply(ctx, []byte(data))
+		Expect(err).NotTo(HaveOccurred())
+
+		content, perm := readFile("/etc/foo")
+		Expect(content).To(Equal("foo"))
+		Expect(perm).To(Equal(os.FileMode(0600)))
+		content, _ = readFile("/etc/systemd/system/kubelet.service")
+		Expect(content).To(Equal("[Unit]"))
+		Expect(commands).To(Equal([]string{
+			"systemctl --no-block daemon-reload",
+			"systemctl --no-block enable kubelet.service",
+			"systemctl --no-block restart kubelet.service",
+		}))
+	})
+
+	It("should reject documents without cloud config header", func() {
+		_, err := applier.Apply(ctx, []byte("coreos: {}\n"))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should reject unsupported encodings", func() {
+		c := config()
+		c.WriteFiles[0].Encoding = "rot13"
+		_, err := applier.ApplyCloudConfig(ctx, c)
+		Expect(err).To(HaveOccurred())
+	})
+})
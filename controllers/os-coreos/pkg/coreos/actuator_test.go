@@ -15,33 +15,196 @@
 package coreos_test
 
 import (
+	"context"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+
 	"github.com/gardener/gardener-extensions/controllers/os-coreos/pkg/coreos"
+	coreosconfig "github.com/gardener/gardener-extensions/pkg/cloudinit/coreos"
+	"github.com/gardener/gardener-extensions/pkg/controller/memoryclient"
+	"github.com/gardener/gardener-extensions/pkg/controller/operatingsystemconfig"
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/inject"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/log"
 )
 
-var _ = Describe("CloudConfig", func() {
-	var cloudConfig *coreos.CloudConfig
+var updateGolden = flag.Bool("update-golden", false, "Update the golden files of the rendered results.")
 
-	BeforeEach(func() {
-		cloudConfig = &coreos.CloudConfig{}
-	})
+func strPtr(s string) *string {
+	return &s
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
 
-	Describe("#String", func() {
-		It("should return the string representation with correct header", func() {
-			cloudConfig.CoreOS = coreos.Config{
-				Update: coreos.Update{
-					RebootStrategy: "off",
+var _ = Describe("Actuator", func() {
+	Describe("golden files", func() {
+		var (
+			ctx  = context.TODO()
+			osc  *extensionsv1alpha1.OperatingSystemConfig
+			opts coreos.Options
+		)
+
+		BeforeEach(func() {
+			opts = coreos.Options{}
+			osc = &extensionsv1alpha1.OperatingSystemConfig{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "shoot--foo--bar", Name: "pool"},
+				Spec: extensionsv1alpha1.OperatingSystemConfigSpec{
+					DefaultSpec: extensionsv1alpha1.DefaultSpec{Type: coreos.TypeIgnition},
+					Purpose:     extensionsv1alpha1.OperatingSystemConfigPurposeProvision,
+					Units: []extensionsv1alpha1.Unit{
+						{
+							Name:    "kubelet.service",
+							Enable:  boolPtr(true),
+							Command: strPtr("start"),
+							Content: strPtr("[Unit]\nDescription=kubelet\n"),
+						},
+						{
+							Name:    "docker.service",
+							DropIns: []extensionsv1alpha1.DropIn{{Name: "10-opts.conf", Content: "[Service]\n"}},
+						},
+					},
+					Files: []extensionsv1alpha1.File{
+						{
+							Path:        "/opt/bin/health-monitor",
+							Permissions: int32Ptr(0755),
+							Content: extensionsv1alpha1.FileContent{
+								Inline: &extensionsv1alpha1.FileContentInline{Encoding: "b64", Data: "IyEvYmluL2Jhc2gK"},
+							},
+						},
+						{
+							Path: "/var/lib/kubelet/ca.crt",
+							Content: extensionsv1alpha1.FileContent{
+								SecretRef: &extensionsv1alpha1.FileContentSecretRef{Name: "ca", DataKey: "ca.crt"},
+							},
+						},
+					},
 				},
 			}
+		})
+
+		newActuator := func() (operatingsystemconfig.Actuator, client.Client) {
+			c, err := memoryclient.New(operatingsystemconfig.ExtensionsScheme, osc, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: osc.Namespace, Name: "ca"},
+				Data:       map[string][]byte{"ca.crt": []byte("certificate")},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			actuator := coreos.NewActuator(log.Log, opts)
+			_, err = inject.SchemeInto(operatingsystemconfig.ExtensionsScheme, actuator)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = inject.ClientInto(c, actuator)
+			Expect(err).NotTo(HaveOccurred())
+			return actuator, c
+		}
+
+		render := func() []byte {
+			actuator, c := newActuator()
+			Expect(actuator.Create(ctx, osc)).To(Succeed())
+
+			secret := &corev1.Secret{}
+			ref := osc.Status.CloudConfig.SecretRef
+			Expect(c.Get(ctx, client.ObjectKey{Namespace: ref.Namespace, Name: ref.Name}, secret)).To(Succeed())
+			return secret.Data[extensionsv1alpha1.OperatingSystemConfigSecretDataKey]
+		}
+
+		expectGolden := func(name string, actual []byte) {
+			path := filepath.Join("testdata", name)
+			if *updateGolden {
+				Expect(ioutil.WriteFile(path, actual, 0644)).To(Succeed())
+			}
 
-			expected := `#cloud-config
+			expected, err := ioutil.ReadFile(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(actual)).To(Equal(string(expected)))
+		}
 
-coreos:
-  update:
-    reboot_strategy: "off"
-`
-			Expect(cloudConfig.String()).To(Equal(expected))
+		It("should render configs of type coreos-ignition as Ignition", func() {
+			expectGolden("ignition.json", render())
+		})
+
+		It("should enable units started by their command and disable stopped units", func() {
+			osc.Spec.Units[0].Enable = nil
+			osc.Spec.Units[0].Command = strPtr("restart")
+			osc.Spec.Units = append(osc.Spec.Units, extensionsv1alpha1.Unit{Name: "foo.service", Command: strPtr("stop")})
+
+			config, err := coreosconfig.ParseIgnition(render())
+			Expect(err).NotTo(HaveOccurred())
+
+			enabled := map[string]*bool{}
+			for _, unit := range config.Systemd.Units {
+				enabled[unit.Name] = unit.Enabled
+			}
+			Expect(enabled).To(HaveKeyWithValue("kubelet.service", boolPtr(true)))
+			Expect(enabled).To(HaveKeyWithValue("foo.service", boolPtr(false)))
+		})
+
+		It("should reject unit commands that cannot be expressed in Ignition", func() {
+			for _, unit := range []extensionsv1alpha1.Unit{
+				{Name: "kubelet.service", Command: strPtr("try-restart")},
+				{Name: "kubelet.service", Command: strPtr("start"), Enable: boolPtr(false)},
+				{Name: "kubelet.service", Command: strPtr("stop"), Enable: boolPtr(true)},
+			} {
+				osc.Spec.Units = []extensionsv1alpha1.Unit{unit}
+				actuator, _ := newActuator()
+				Expect(actuator.Create(ctx, osc)).NotTo(Succeed(), *unit.Command)
+			}
+		})
+
+		It("should render configs of type coreos as cloud config", func() {
+			osc.Spec.Type = coreos.Type
+			expectGolden("cloud-config.yaml", render())
+		})
+
+		It("should render the configuration of the docker runtime", func() {
+			opts.ContainerRuntime = &operatingsystemconfig.ContainerRuntimeConfiguration{
+				Name:            operatingsystemconfig.ContainerRuntimeDocker,
+				RegistryMirrors: []operatingsystemconfig.RegistryMirror{{Registry: "docker.io", Endpoints: []string{"https://mirror.example.com"}}},
+			}
+			expectGolden("ignition-docker.json", render())
+		})
+
+		It("should render the containerd runtime selected by the annotation", func() {
+			opts.ContainerRuntime = &operatingsystemconfig.ContainerRuntimeConfiguration{
+				Name:            operatingsystemconfig.ContainerRuntimeDocker,
+				RegistryMirrors: []operatingsystemconfig.RegistryMirror{{Registry: "docker.io", Endpoints: []string{"https://mirror.example.com"}}},
+			}
+			osc.Annotations = map[string]string{operatingsystemconfig.ContainerRuntimeAnnotation: "containerd"}
+			expectGolden("ignition-containerd.json", render())
+			Expect(osc.Status.Units).To(Equal([]string{"kubelet.service", "docker.service", "containerd.service"}))
+		})
+
+		It("should render the base profile into provisioning configs", func() {
+			osc.Spec.Type = coreos.Type
+			opts.BaseProfile = &operatingsystemconfig.BaseProfile{
+				MaskedUnits:   []string{"update-engine.service"},
+				DisabledUnits: []string{"locksmithd.service"},
+				Files:         []operatingsystemconfig.BaseProfileFile{{Path: "/etc/docker/daemon.json", Content: "{}\n"}},
+				Commands:      []string{"echo done"},
+			}
+			expectGolden("cloud-config-profile.yaml", render())
+		})
+
+		It("should only mask the units of the base profile in reconcile configs", func() {
+			osc.Spec.Type = coreos.Type
+			osc.Spec.Purpose = extensionsv1alpha1.OperatingSystemConfigPurposeReconcile
+			opts.BaseProfile = &operatingsystemconfig.BaseProfile{
+				MaskedUnits: []string{"update-engine.service"},
+				Commands:    []string{"echo done"},
+			}
+			expectGolden("cloud-config-profile-reconcile.yaml", render())
 		})
 	})
 })
@@ -14,5 +14,9 @@
 
 package coreos
 
-// Type is the type of OperatingSystemConfigs the coreos actuator / predicate are built for.
-const Type = "coreos"
+const (
+	// Type is the type of OperatingSystemConfigs the coreos actuator / predicate are built for.
+	Type = "coreos"
+	// TypeIgnition is the type of OperatingSystemConfigs the coreos actuator always renders as Ignition config.
+	TypeIgnition = "coreos-ignition"
+)
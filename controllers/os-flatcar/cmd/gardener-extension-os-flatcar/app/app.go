@@ -0,0 +1,113 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"github.com/gardener/gardener-extensions/controllers/os-flatcar/pkg/flatcar"
+	"github.com/gardener/gardener-extensions/pkg/cloudinit/coreos"
+	"github.com/gardener/gardener-extensions/pkg/controller/operatingsystemconfig"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"os"
+)
+
+// Name is the name of the Flatcar controller.
+const Name = "os-flatcar"
+
+// ActuatorOptions are options for the creation of a Flatcar operating system config actuator.
+type ActuatorOptions struct {
+	// ReloadCommand is the command prefix used to reload the config on a node.
+	ReloadCommand string
+	// Format is the format of the result.
+	Format string
+}
+
+// NewActuatorOptions creates new ActuatorOptions with default values.
+func NewActuatorOptions() *ActuatorOptions {
+	return &ActuatorOptions{
+		ReloadCommand: flatcar.DefaultReloadCommand,
+		Format:        string(coreos.FormatIgnition),
+	}
+}
+
+// AddFlags adds all ActuatorOptions relevant flags to the given FlagSet.
+func (a *ActuatorOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&a.ReloadCommand, "reload-command", a.ReloadCommand, fmt.Sprintf("The command prefix nodes use to reload the config, the path of the config file is appended. Use %q to reload cloud configs with coreos-cloudinit.", coreos.DefaultReloadCommand))
+	fs.StringVar(&a.Format, "format", a.Format, fmt.Sprintf("The format of the result, either %q or %q.", coreos.FormatIgnition, coreos.FormatCloudConfig))
+}
+
+// ActuatorFactory creates a new Flatcar operating system config actuator.
+func (a *ActuatorOptions) ActuatorFactory(args *operatingsystemconfig.ActuatorArgs) (operatingsystemconfig.Actuator, error) {
+	switch format := coreos.Format(a.Format); format {
+	case coreos.FormatCloudConfig, coreos.FormatIgnition:
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+
+	config, err := coreos.ParseConfiguration(args.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	return flatcar.NewActuator(args.Log, flatcar.Options{
+		ReloadCommand:    a.ReloadCommand,
+		Format:           coreos.Format(a.Format),
+		Update:           config.Update,
+		BaseProfile:      config.BaseProfile,
+		ContainerRuntime: config.ContainerRuntime,
+	}), nil
+}
+
+// NewControllerCommand creates a new Flatcar controller command.
+func NewControllerCommand(ctx context.Context) *cobra.Command {
+	actuatorOpts := NewActuatorOptions()
+	opts := operatingsystemconfig.NewCommandOptions(Name, flatcar.Type, actuatorOpts.ActuatorFactory)
+	opts.Controller.Annotations = append(opts.Controller.Annotations, coreos.UpdateAnnotation)
+	opts.Manager.LeaderElection = true
+	opts.Manager.LeaderElectionNamespace = os.Getenv("LEADER_ELECTION_NAMESPACE")
+
+	cmd := &cobra.Command{
+		Use: "os-flatcar-controller-manager",
+
+		Run: func(cmd *cobra.Command, args []string) {
+			c, err := opts.Config()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				os.Exit(1)
+			}
+
+			if err := operatingsystemconfig.Run(ctx, c.Complete()); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	fss := opts.Flags()
+	actuatorOpts.AddFlags(fss.FlagSet("flatcar"))
+
+	fs := cmd.Flags()
+	for _, f := range fss.FlagSets {
+		fs.AddFlagSet(f)
+	}
+
+	render := operatingsystemconfig.NewRenderCommand(ctx, opts.Controller)
+	render.Flags().AddFlagSet(fss.FlagSet("flatcar"))
+	cmd.AddCommand(render)
+
+	return cmd
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flatcar
+
+import (
+	"github.com/gardener/gardener-extensions/pkg/cloudinit/coreos"
+	"github.com/gardener/gardener-extensions/pkg/controller/operatingsystemconfig"
+
+	"github.com/go-logr/logr"
+)
+
+const (
+	// Type is the type of OperatingSystemConfigs the Flatcar actuator / predicate are built for.
+	Type = "flatcar"
+
+	// DefaultReloadCommand is the default command prefix used to reload the config on a Flatcar node.
+	// Contrary to coreos-cloudinit, the os-config-applier understands Ignition configs.
+	DefaultReloadCommand = coreos.ApplierReloadCommand
+	// UpdateConfPath is the path of the Flatcar update configuration.
+	UpdateConfPath = "/etc/flatcar/update.conf"
+)
+
+// DefaultUpdateConfiguration returns the default update configuration of Flatcar machines. Machines
+// are not updated in-place but replaced, hence updates and reboots are disabled. Configurations
+// enabling updates have to set the update server as well, e.g. to the public Flatcar update server.
+func DefaultUpdateConfiguration() *coreos.UpdateConfiguration {
+	update := coreos.DefaultUpdateConfiguration()
+	update.Server = "disabled"
+	return update
+}
+
+// Options are options for the creation of the Flatcar actuator.
+type Options struct {
+	// ReloadCommand is the command prefix the path of the reload config file is appended to in
+	// order to compute the command of the status. Defaults to DefaultReloadCommand.
+	ReloadCommand string
+	// Format is the format of the result. Defaults to coreos.FormatIgnition.
+	Format coreos.Format
+	// Update overrides fields of DefaultUpdateConfiguration.
+	Update *coreos.UpdateConfiguration
+	// BaseProfile overrides fields of coreos.DefaultBaseProfile.
+	BaseProfile *operatingsystemconfig.BaseProfile
+	// ContainerRuntime overrides fields of operatingsystemconfig.DefaultContainerRuntimeConfiguration.
+	ContainerRuntime *operatingsystemconfig.ContainerRuntimeConfiguration
+}
+
+// NewActuator creates a new Actuator that renders OperatingSystemConfigs for Flatcar Container Linux.
+// It reuses the CoreOS renderers with Flatcar specific defaults.
+func NewActuator(logger logr.Logger, opts Options) operatingsystemconfig.Actuator {
+	if opts.ReloadCommand == "" {
+		opts.ReloadCommand = DefaultReloadCommand
+	}
+	if opts.Format == "" {
+		opts.Format = coreos.FormatIgnition
+	}
+
+	renderer := coreos.NewRenderer(coreos.RendererOptions{
+		Format:         opts.Format,
+		Update:         DefaultUpdateConfiguration().Merge(opts.Update),
+		UpdateConfPath: UpdateConfPath,
+		BaseProfile:    coreos.DefaultBaseProfile().Merge(opts.BaseProfile),
+	})
+
+	return operatingsystemconfig.NewResultActuator(logger, renderer, operatingsystemconfig.ResultActuatorOptions{
+		ContainerRuntime: operatingsystemconfig.DefaultContainerRuntimeConfiguration().Merge(opts.ContainerRuntime),
+		ReloadCommand: func(path string) string {
+			return opts.ReloadCommand + path
+		},
+	})
+}
@@ -0,0 +1,37 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flatcar_test
+
+import (
+	"github.com/gardener/gardener-extensions/controllers/os-flatcar/pkg/flatcar"
+	"github.com/gardener/gardener-extensions/pkg/cloudinit/coreos"
+	"github.com/gardener/gardener-extensions/pkg/controller/operatingsystemconfig"
+	"github.com/gardener/gardener-extensions/pkg/controller/operatingsystemconfig/conformance"
+	"github.com/gardener/gardener-extensions/pkg/simulator"
+
+	"sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+var _ = conformance.DescribeActuator(flatcar.Type, func() operatingsystemconfig.Actuator {
+	return flatcar.NewActuator(log.Log, flatcar.Options{})
+}, &conformance.Options{
+	Format: simulator.FormatIgnition,
+})
+
+var _ = conformance.DescribeActuator(flatcar.Type, func() operatingsystemconfig.Actuator {
+	return flatcar.NewActuator(log.Log, flatcar.Options{Format: coreos.FormatCloudConfig})
+}, &conformance.Options{
+	Format: simulator.FormatCloudConfig,
+})
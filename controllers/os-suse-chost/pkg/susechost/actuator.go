@@ -0,0 +1,94 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package susechost
+
+import (
+	"path"
+
+	"github.com/gardener/gardener-extensions/pkg/cloudinit/cloudconfig"
+	"github.com/gardener/gardener-extensions/pkg/controller/operatingsystemconfig"
+
+	"github.com/go-logr/logr"
+)
+
+const (
+	// Type is the type of OperatingSystemConfigs the SUSE CHost actuator is built for.
+	Type = "suse-chost"
+
+	// DefaultReloadCommand is the default command used to apply the cloud-config on a node. It is
+	// the reload script every rendered cloud-config writes to cloudconfig.ReloadScriptPath.
+	DefaultReloadCommand = cloudconfig.ReloadScriptPath
+	// RuntimeDropInName is the name of the drop-in lifting the limits of the container runtime units.
+	RuntimeDropInName = "20-gardener-limits.conf"
+)
+
+// runtimeLimits lifts the task and file limits systemd imposes on the container runtime units.
+const runtimeLimits = `[Service]
+TasksMax=infinity
+LimitNOFILE=1048576
+`
+
+// DefaultBaseProfile returns the default base profile of SUSE CHost machines. Machines are not
+// updated in-place but replaced, hence the automatic online updates of zypper, transactional
+// updates and the reboots scheduled by them are disabled. The container runtime units get drop-ins
+// lifting the limits of their tasks and open files.
+func DefaultBaseProfile() *operatingsystemconfig.BaseProfile {
+	profile := &operatingsystemconfig.BaseProfile{
+		DisabledUnits: []string{"transactional-update.timer", "rebootmgr.service"},
+		Commands: []string{
+			"rm -f /etc/cron.daily/opensuse.org-online_update /etc/cron.weekly/opensuse.org-online_update /etc/cron.monthly/opensuse.org-online_update",
+		},
+	}
+	for _, unit := range []string{"docker.service", "containerd.service"} {
+		profile.Files = append(profile.Files, operatingsystemconfig.BaseProfileFile{
+			Path:    path.Join(cloudconfig.UnitsPath, unit+".d", RuntimeDropInName),
+			Content: runtimeLimits,
+		})
+	}
+	return profile
+}
+
+// Options are options for the creation of the SUSE CHost actuator.
+type Options struct {
+	// BaseProfile overrides fields of DefaultBaseProfile.
+	BaseProfile *operatingsystemconfig.BaseProfile
+	// ContainerRuntime overrides fields of operatingsystemconfig.DefaultContainerRuntimeConfiguration.
+	ContainerRuntime *operatingsystemconfig.ContainerRuntimeConfiguration
+	// ReloadCommand is the command the quoted path of the reload config file is appended to as last
+	// argument in order to compute the command of the status. Defaults to DefaultReloadCommand.
+	ReloadCommand string
+	// Multipart renders the provisioning configs as multipart user-data if set.
+	Multipart *cloudconfig.MultipartConfiguration
+}
+
+// NewActuator creates a new Actuator that renders OperatingSystemConfigs for SUSE CHost. The images
+// are bootstrapped with cloud-init, hence they are rendered as standard `#cloud-config` documents
+// or multipart user-data with SUSE specific defaults.
+func NewActuator(logger logr.Logger, opts Options) operatingsystemconfig.Actuator {
+	reloadCommand := opts.ReloadCommand
+	if reloadCommand == "" {
+		reloadCommand = DefaultReloadCommand
+	}
+
+	renderer := cloudconfig.NewRenderer(cloudconfig.RendererOptions{
+		BaseProfile: DefaultBaseProfile().Merge(opts.BaseProfile),
+		Multipart:   opts.Multipart,
+	})
+
+	return operatingsystemconfig.NewResultActuator(logger, renderer, operatingsystemconfig.ResultActuatorOptions{
+		ContainerRuntime: operatingsystemconfig.DefaultContainerRuntimeConfiguration().Merge(opts.ContainerRuntime),
+		ReloadCommand:    operatingsystemconfig.QuotedPathReloadCommand(reloadCommand),
+	})
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package susechost_test
+
+import (
+	"context"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/gardener/gardener-extensions/controllers/os-suse-chost/pkg/susechost"
+	"github.com/gardener/gardener-extensions/pkg/controller/memoryclient"
+	"github.com/gardener/gardener-extensions/pkg/controller/operatingsystemconfig"
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/inject"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+var updateGolden = flag.Bool("update-golden", false, "Update the golden files of the rendered results.")
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+var _ = Describe("Actuator", func() {
+	var (
+		ctx = context.TODO()
+		osc *extensionsv1alpha1.OperatingSystemConfig
+	)
+
+	BeforeEach(func() {
+		osc = &extensionsv1alpha1.OperatingSystemConfig{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "shoot--foo--bar", Name: "pool"},
+			Spec: extensionsv1alpha1.OperatingSystemConfigSpec{
+				DefaultSpec:          extensionsv1alpha1.DefaultSpec{Type: susechost.Type},
+				Purpose:              extensionsv1alpha1.OperatingSystemConfigPurposeProvision,
+				ReloadConfigFilePath: strPtr("/var/lib/cloud-config-downloader/cloud-config.yaml"),
+				Units: []extensionsv1alpha1.Unit{
+					{
+						Name:    "kubelet.service",
+						Enable:  boolPtr(true),
+						Command: strPtr("start"),
+						Content: strPtr("[Unit]\nDescription=kubelet\n[Install]\nWantedBy=multi-user.target\n[Service]\nExecStart=/opt/bin/kubelet\n"),
+					},
+				},
+				Files: []extensionsv1alpha1.File{
+					{
+						Path: "/etc/sysctl.d/99-k8s-general.conf",
+						Content: extensionsv1alpha1.FileContent{
+							Inline: &extensionsv1alpha1.FileContentInline{Data: "vm.max_map_count = 135217728\n"},
+						},
+					},
+				},
+			},
+		}
+	})
+
+	render := func(opts susechost.Options) []byte {
+		c, err := memoryclient.New(operatingsystemconfig.ExtensionsScheme, osc)
+		Expect(err).NotTo(HaveOccurred())
+
+		actuator := susechost.NewActuator(log.Log, opts)
+		_, err = inject.SchemeInto(operatingsystemconfig.ExtensionsScheme, actuator)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = inject.ClientInto(c, actuator)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(actuator.Create(ctx, osc)).To(Succeed())
+
+		secret := &corev1.Secret{}
+		ref := osc.Status.CloudConfig.SecretRef
+		Expect(c.Get(ctx, client.ObjectKey{Namespace: ref.Namespace, Name: ref.Name}, secret)).To(Succeed())
+		return secret.Data[extensionsv1alpha1.OperatingSystemConfigSecretDataKey]
+	}
+
+	expectGolden := func(name string, actual []byte) {
+		path := filepath.Join("testdata", name)
+		if *updateGolden {
+			Expect(ioutil.WriteFile(path, actual, 0644)).To(Succeed())
+		}
+
+		expected, err := ioutil.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(actual)).To(Equal(string(expected)))
+	}
+
+	It("should render cloud-configs disabling SUSE updates when provisioning", func() {
+		expectGolden("cloud-config-provision.yaml", render(susechost.Options{}))
+
+		Expect(osc.Status.Command).To(Equal("/usr/local/sbin/gardener-reload-cloud-config '/var/lib/cloud-config-downloader/cloud-config.yaml'"))
+		Expect(osc.Status.Units).To(Equal([]string{"kubelet.service", "docker.service"}))
+	})
+
+	It("should not apply the base profile when reconciling", func() {
+		osc.Spec.Purpose = extensionsv1alpha1.OperatingSystemConfigPurposeReconcile
+
+		result := string(render(susechost.Options{}))
+		Expect(result).NotTo(ContainSubstring("transactional-update"))
+		Expect(result).NotTo(ContainSubstring(susechost.RuntimeDropInName))
+	})
+
+	It("should configure containerd if selected by the annotation", func() {
+		osc.Annotations = map[string]string{operatingsystemconfig.ContainerRuntimeAnnotation: "containerd"}
+
+		expectGolden("cloud-config-containerd.yaml", render(susechost.Options{}))
+		Expect(osc.Status.Units).To(Equal([]string{"kubelet.service", "containerd.service"}))
+	})
+
+	It("should only replace the configured fields of the base profile", func() {
+		result := string(render(susechost.Options{
+			BaseProfile: &operatingsystemconfig.BaseProfile{DisabledUnits: []string{}},
+		}))
+
+		Expect(result).NotTo(ContainSubstring("transactional-update.timer"))
+		Expect(result).To(ContainSubstring("/etc/systemd/system/docker.service.d/" + susechost.RuntimeDropInName))
+		Expect(result).To(ContainSubstring("opensuse.org-online_update"))
+	})
+})
@@ -0,0 +1,44 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+
+	"github.com/gardener/gardener-extensions/controllers/os-ubuntu/pkg/ubuntu"
+	"github.com/gardener/gardener-extensions/pkg/cloudinit/cloudconfig"
+	"github.com/gardener/gardener-extensions/pkg/controller/operatingsystemconfig"
+
+	"github.com/go-logr/logr"
+	"github.com/spf13/cobra"
+)
+
+// Name is the name of the Ubuntu controller.
+const Name = "os-ubuntu"
+
+// newActuator creates an Ubuntu Actuator with the given configuration.
+func newActuator(logger logr.Logger, config *cloudconfig.Configuration) operatingsystemconfig.Actuator {
+	return ubuntu.NewActuator(logger, ubuntu.Options{
+		BaseProfile:      config.BaseProfile,
+		ContainerRuntime: config.ContainerRuntime,
+		ReloadCommand:    config.ReloadCommand,
+		Multipart:        config.Multipart,
+	})
+}
+
+// NewControllerCommand creates a new command for running an Ubuntu controller.
+func NewControllerCommand(ctx context.Context) *cobra.Command {
+	return cloudconfig.NewControllerCommand(ctx, Name, ubuntu.Type, newActuator)
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ubuntu
+
+import (
+	"github.com/gardener/gardener-extensions/pkg/cloudinit/cloudconfig"
+	"github.com/gardener/gardener-extensions/pkg/controller/operatingsystemconfig"
+
+	"github.com/go-logr/logr"
+)
+
+const (
+	// Type is the type of OperatingSystemConfigs the Ubuntu actuator is built for.
+	Type = "ubuntu"
+
+	// DefaultReloadCommand is the default command used to apply the cloud-config on a node. It is
+	// the reload script every rendered cloud-config writes to cloudconfig.ReloadScriptPath.
+	DefaultReloadCommand = cloudconfig.ReloadScriptPath
+)
+
+// DefaultBaseProfile returns the default base profile of Ubuntu machines. Machines are not updated
+// in-place but replaced, hence the automatic package upgrades are disabled.
+func DefaultBaseProfile() *operatingsystemconfig.BaseProfile {
+	return &operatingsystemconfig.BaseProfile{
+		DisabledUnits: []string{"apt-daily.timer", "apt-daily-upgrade.timer", "unattended-upgrades.service"},
+	}
+}
+
+// Options are options for the creation of the Ubuntu actuator.
+type Options struct {
+	// BaseProfile overrides fields of DefaultBaseProfile.
+	BaseProfile *operatingsystemconfig.BaseProfile
+	// ContainerRuntime overrides fields of operatingsystemconfig.DefaultContainerRuntimeConfiguration,
+	// it can be overridden per config with the operatingsystemconfig.ContainerRuntimeAnnotation.
+	ContainerRuntime *operatingsystemconfig.ContainerRuntimeConfiguration
+	// ReloadCommand is the command the quoted path of the reload config file is appended to as last
+	// argument in order to compute the command of the status. Defaults to DefaultReloadCommand.
+	ReloadCommand string
+	// Multipart renders the provisioning configs as multipart user-data if set.
+	Multipart *cloudconfig.MultipartConfiguration
+}
+
+// NewActuator creates a new Actuator that renders OperatingSystemConfigs for Ubuntu as standard
+// cloud-init `#cloud-config` documents or multipart user-data.
+func NewActuator(logger logr.Logger, opts Options) operatingsystemconfig.Actuator {
+	reloadCommand := opts.ReloadCommand
+	if reloadCommand == "" {
+		reloadCommand = DefaultReloadCommand
+	}
+
+	renderer := cloudconfig.NewRenderer(cloudconfig.RendererOptions{
+		BaseProfile: DefaultBaseProfile().Merge(opts.BaseProfile),
+		Multipart:   opts.Multipart,
+	})
+
+	return operatingsystemconfig.NewResultActuator(logger, renderer, operatingsystemconfig.ResultActuatorOptions{
+		ContainerRuntime: operatingsystemconfig.DefaultContainerRuntimeConfiguration().Merge(opts.ContainerRuntime),
+		ReloadCommand:    operatingsystemconfig.QuotedPathReloadCommand(reloadCommand),
+	})
+}
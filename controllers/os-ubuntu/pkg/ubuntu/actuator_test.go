@@ -0,0 +1,189 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ubuntu_test
+
+import (
+	"context"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/gardener/gardener-extensions/controllers/os-ubuntu/pkg/ubuntu"
+	"github.com/gardener/gardener-extensions/pkg/controller/memoryclient"
+	"github.com/gardener/gardener-extensions/pkg/controller/operatingsystemconfig"
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/inject"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+var updateGolden = flag.Bool("update-golden", false, "Update the golden files of the rendered results.")
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+var _ = Describe("Actuator", func() {
+	var (
+		ctx  = context.TODO()
+		osc  *extensionsv1alpha1.OperatingSystemConfig
+		opts ubuntu.Options
+		c    *memoryclient.Client
+	)
+
+	BeforeEach(func() {
+		opts = ubuntu.Options{}
+		permissions := int32(0600)
+		osc = &extensionsv1alpha1.OperatingSystemConfig{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "shoot--foo--bar", Name: "pool"},
+			Spec: extensionsv1alpha1.OperatingSystemConfigSpec{
+				DefaultSpec: extensionsv1alpha1.DefaultSpec{Type: ubuntu.Type},
+				Purpose:     extensionsv1alpha1.OperatingSystemConfigPurposeProvision,
+				Units: []extensionsv1alpha1.Unit{
+					{
+						Name:    "kubelet.service",
+						Enable:  boolPtr(true),
+						Command: strPtr("start"),
+						Content: strPtr("[Unit]\nDescription=kubelet\n[Install]\nWantedBy=multi-user.target\n[Service]\nExecStart=/opt/bin/kubelet\n"),
+						DropIns: []extensionsv1alpha1.DropIn{{Name: "10-opts.conf", Content: "[Service]\nEnvironment=KUBELET_OPTS=--v=2\n"}},
+					},
+				},
+				Files: []extensionsv1alpha1.File{
+					{
+						Path: "/etc/sysctl.d/99-k8s-general.conf",
+						Content: extensionsv1alpha1.FileContent{
+							Inline: &extensionsv1alpha1.FileContentInline{Data: "vm.max_map_count = 135217728\n"},
+						},
+					},
+					{
+						Path:        "/var/lib/kubelet/ca.crt",
+						Permissions: &permissions,
+						Content: extensionsv1alpha1.FileContent{
+							SecretRef: &extensionsv1alpha1.FileContentSecretRef{Name: "ca", DataKey: "ca.crt"},
+						},
+					},
+				},
+			},
+		}
+	})
+
+	newActuator := func() operatingsystemconfig.Actuator {
+		var err error
+		c, err = memoryclient.New(operatingsystemconfig.ExtensionsScheme, osc, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "shoot--foo--bar", Name: "ca"},
+			Data:       map[string][]byte{"ca.crt": {0xca, 0xfe}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		actuator := ubuntu.NewActuator(log.Log, opts)
+		_, err = inject.SchemeInto(operatingsystemconfig.ExtensionsScheme, actuator)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = inject.ClientInto(c, actuator)
+		Expect(err).NotTo(HaveOccurred())
+		return actuator
+	}
+
+	render := func() []byte {
+		Expect(newActuator().Create(ctx, osc)).To(Succeed())
+
+		secret := &corev1.Secret{}
+		ref := osc.Status.CloudConfig.SecretRef
+		Expect(c.Get(ctx, client.ObjectKey{Namespace: ref.Namespace, Name: ref.Name}, secret)).To(Succeed())
+		return secret.Data[extensionsv1alpha1.OperatingSystemConfigSecretDataKey]
+	}
+
+	expectGolden := func(name string, actual []byte) {
+		path := filepath.Join("testdata", name)
+		if *updateGolden {
+			Expect(ioutil.WriteFile(path, actual, 0644)).To(Succeed())
+		}
+
+		expected, err := ioutil.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(actual)).To(Equal(string(expected)))
+	}
+
+	It("should render the files, units and the base profile when provisioning", func() {
+		expectGolden("cloud-config-provision.yaml", render())
+	})
+
+	It("should not apply the base profile when reconciling", func() {
+		osc.Spec.Purpose = extensionsv1alpha1.OperatingSystemConfigPurposeReconcile
+		osc.Spec.Units = append(osc.Spec.Units, extensionsv1alpha1.Unit{Name: "update-engine.service", Enable: boolPtr(false), Command: strPtr("stop")})
+
+		expectGolden("cloud-config-reconcile.yaml", render())
+	})
+
+	It("should configure containerd if selected by the annotation", func() {
+		osc.Annotations = map[string]string{operatingsystemconfig.ContainerRuntimeAnnotation: "containerd"}
+
+		expectGolden("cloud-config-containerd.yaml", render())
+		Expect(osc.Status.Units).To(Equal([]string{"kubelet.service", "containerd.service"}))
+	})
+
+	It("should merge the configured base profile into the default base profile", func() {
+		opts.BaseProfile = &operatingsystemconfig.BaseProfile{
+			MaskedUnits: []string{"snapd.service"},
+			Files:       []operatingsystemconfig.BaseProfileFile{{Path: "/etc/motd", Content: "gardener\n"}},
+			Commands:    []string{"sysctl --system"},
+		}
+
+		result := string(render())
+		Expect(result).To(ContainSubstring("- path: /etc/motd\n  permissions: \"0644\"\n  content: |\n    gardener\n"))
+		Expect(result).To(ContainSubstring("runcmd:\n- systemctl mask 'snapd.service'\n- systemctl disable 'apt-daily.timer'\n"))
+		Expect(result).To(ContainSubstring("- systemctl stop 'unattended-upgrades.service'\n- sysctl --system\n- systemctl daemon-reload\n"))
+	})
+
+	It("should fail for unsupported unit commands", func() {
+		osc.Spec.Units[0].Command = strPtr("kill")
+
+		Expect(newActuator().Create(ctx, osc)).To(MatchError(ContainSubstring(`unsupported command "kill"`)))
+		Expect(osc.Status.LastError).NotTo(BeNil())
+	})
+
+	It("should fail for missing secret keys", func() {
+		osc.Spec.Files[1].Content.SecretRef.DataKey = "missing"
+
+		Expect(newActuator().Create(ctx, osc)).To(MatchError(ContainSubstring(`could not find key "missing"`)))
+	})
+
+	Describe("status", func() {
+		BeforeEach(func() {
+			osc.Spec.ReloadConfigFilePath = strPtr("/var/lib/cloud-config-downloader/cloud-config.yaml")
+		})
+
+		It("should contain the command running the reload script", func() {
+			Expect(newActuator().Create(ctx, osc)).To(Succeed())
+			Expect(osc.Status.Command).To(Equal("/usr/local/sbin/gardener-reload-cloud-config '/var/lib/cloud-config-downloader/cloud-config.yaml'"))
+		})
+
+		It("should append the quoted path to a custom reload command", func() {
+			opts.ReloadCommand = "/opt/bin/apply"
+			osc.Spec.ReloadConfigFilePath = strPtr("/var/lib/it's here.yaml")
+
+			Expect(newActuator().Create(ctx, osc)).To(Succeed())
+			Expect(osc.Status.Command).To(Equal(`/opt/bin/apply '/var/lib/it'\''s here.yaml'`))
+		})
+	})
+})
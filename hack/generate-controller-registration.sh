@@ -24,7 +24,7 @@ generate-controller-registration <name> <kind> <type> <chart-dir> <dest>
 
     <name>          Name of the controller registration to generate.
     <kind>          Kind of the controller registration.
-    <type>          Type of the controller registration.
+    <type>          Type of the controller registration, multiple types can be separated by commas.
     <chart-dir>     Location of the chart directory.
     <dest>          The destination file to write the registration YAML to.
 EOM
@@ -65,6 +65,13 @@ chart="$(tar -c --mtime='2019-01-01' -C "$temp_extract_dir" "$(basename "$temp_e
 
 mkdir -p "$(dirname "$DEST")"
 
+resources=""
+for type in ${TYPE//,/ }; do
+    resources+="
+  - kind: $KIND
+    type: $type"
+done
+
 cat <<EOM > "$DEST"
 ---
 apiVersion: core.gardener.cloud/v1alpha1
@@ -72,9 +79,7 @@ kind: ControllerRegistration
 metadata:
   name: $NAME
 spec:
-  resources:
-  - kind: $KIND
-    type: $TYPE
+  resources:${resources}
   deployment:
     type: helm
     providerConfig:
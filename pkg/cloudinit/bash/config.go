@@ -12,7 +12,11 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package internal
+// Package bash renders OperatingSystemConfigs as bash scripts that write files and units and
+// apply them with systemctl. The scripts run in strict mode and write files atomically. The
+// templates of the scripts can be overridden, and provider-specific bootstrap snippets, e.g. the
+// discovery of the provider ID, can be injected.
+package bash
 
 // File is a file to be stored during the cloud init script.
 type File struct {
@@ -26,6 +30,11 @@ type Unit struct {
 	Name    string
 	Content []byte
 	DropIns []*DropIn
+	// Enable enables the unit if true and disables it if false. The unit is left as is if nil.
+	Enable *bool
+	// Command is the systemctl command run for the unit, e.g. `start` or `restart`. No command is
+	// run if nil.
+	Command *string
 }
 
 // DropIn is a drop in of a Unit.
@@ -34,9 +43,18 @@ type DropIn struct {
 	Content []byte
 }
 
+// Profile contains the operating system tweaks applied at bootstrap.
+type Profile struct {
+	MaskedUnits   []string
+	DisabledUnits []string
+	Files         []*File
+	Commands      []string
+}
+
 // OperatingSystemConfig is the data required to create a cloud init script.
 type OperatingSystemConfig struct {
 	Files     []*File
 	Units     []*Unit
 	Bootstrap bool
+	Profile   *Profile
 }
@@ -0,0 +1,265 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:generate packr2
+
+package bash
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/gardener/gardener-extensions/pkg/shell"
+	"github.com/gardener/gardener-extensions/pkg/systemd"
+
+	"github.com/gobuffalo/packr/v2"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+var defaultTemplate *template.Template
+
+const (
+	// DefaultUnitsPath is the default path where to store units at.
+	DefaultUnitsPath = "/etc/systemd/system"
+
+	// MainTemplateName is the name of the template rendering the cloud-init script.
+	MainTemplateName = "cloud-init.sh"
+)
+
+// unitCommands are the systemctl commands that may be run for units.
+var unitCommands = sets.NewString("start", "stop", "restart", "reload", "try-restart", "reload-or-restart")
+
+func init() {
+	box := packr.New("bash-templates", "./templates")
+
+	templateString, err := box.FindString("cloud-init.sh.template")
+	runtime.Must(err)
+
+	// All values interpolated into the script must be passed through quote.
+	defaultTemplate, err = template.New(MainTemplateName).Funcs(template.FuncMap{"quote": shell.Quote}).Parse(templateString)
+	runtime.Must(err)
+}
+
+// DefaultTemplate returns the default template. It must not be modified, use Clone or LoadTemplate
+// to override parts of it.
+func DefaultTemplate() *template.Template {
+	return defaultTemplate
+}
+
+// contentData describes content that is written atomically to Path by writing it to TempPath
+// first. The write is skipped if the SHA-256 checksum of Path already matches ChecksumLine.
+type contentData struct {
+	Path         string
+	TempPath     string
+	ChecksumLine string
+	Content      string
+	Permissions  string
+}
+
+type fileData struct {
+	contentData
+	Dirname string
+}
+
+type unitData struct {
+	Path          string
+	Name          string
+	Content       *contentData
+	DropIns       *dropInsData
+	EnableCommand string
+	Command       string
+}
+
+type dropInsData struct {
+	Path  string
+	Items []*dropInData
+}
+
+type dropInData struct {
+	contentData
+}
+
+type profileData struct {
+	MaskedUnits   []string
+	DisabledUnits []string
+	Files         []*fileData
+	Commands      []string
+}
+
+type initScriptData struct {
+	Files             []*fileData
+	Units             []*unitData
+	Bootstrap         bool
+	Profile           *profileData
+	BootstrapSnippets []string
+}
+
+// Generator generates bash scripts.
+type Generator struct {
+	unitsPath         string
+	template          *template.Template
+	bootstrapSnippets []string
+}
+
+func newContentData(p string, content []byte) contentData {
+	dir, name := path.Split(p)
+	checksum := sha256.Sum256(content)
+	return contentData{
+		Path:         p,
+		TempPath:     path.Join(dir, fmt.Sprintf(".%s.gardener-tmp", name)),
+		ChecksumLine: fmt.Sprintf("%s  %s", hex.EncodeToString(checksum[:]), p),
+		Content:      base64.StdEncoding.EncodeToString(content),
+	}
+}
+
+func newFilesData(files []*File) []*fileData {
+	var tFiles []*fileData
+	for _, file := range files {
+		tFile := &fileData{
+			contentData: newContentData(file.Path, file.Content),
+			Dirname:     path.Dir(file.Path),
+		}
+		if file.Permissions != nil {
+			tFile.Permissions = fmt.Sprintf("%04o", *file.Permissions)
+		}
+		tFiles = append(tFiles, tFile)
+	}
+	return tFiles
+}
+
+// validFilePath returns whether the given path is an absolute and clean path of a file, i.e. not
+// of the root directory, without control characters.
+func validFilePath(p string) bool {
+	return path.IsAbs(p) && path.Clean(p) == p && p != "/" && strings.IndexFunc(p, unicode.IsControl) < 0
+}
+
+// validate returns an error if the given OperatingSystemConfig contains names of units or drop-ins
+// that are not accepted by systemd, unsupported unit commands or file paths that are not absolute
+// and clean or contain control characters.
+func validate(data *OperatingSystemConfig) error {
+	files := data.Files
+	if profile := data.Profile; profile != nil {
+		files = append(append([]*File{}, files...), profile.Files...)
+		for _, name := range append(append([]string{}, profile.MaskedUnits...), profile.DisabledUnits...) {
+			if err := systemd.ValidateUnitName(name); err != nil {
+				return err
+			}
+		}
+	}
+	for _, file := range files {
+		if !validFilePath(file.Path) {
+			return fmt.Errorf("invalid file path %q", file.Path)
+		}
+	}
+
+	for _, unit := range data.Units {
+		if err := systemd.ValidateUnitName(unit.Name); err != nil {
+			return err
+		}
+		if unit.Command != nil && !unitCommands.Has(*unit.Command) {
+			return fmt.Errorf("unit %q: unsupported command %q", unit.Name, *unit.Command)
+		}
+		for _, dropIn := range unit.DropIns {
+			if err := systemd.ValidateDropInName(dropIn.Name); err != nil {
+				return fmt.Errorf("unit %q: %v", unit.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Generate generates a cloud-init script from the given OperatingSystemConfig. It returns an
+// error if the config contains invalid unit names or file paths.
+func (t *Generator) Generate(data *OperatingSystemConfig) ([]byte, error) {
+	if err := validate(data); err != nil {
+		return nil, err
+	}
+
+	tFiles := newFilesData(data.Files)
+
+	tProfile := &profileData{}
+	if profile := data.Profile; profile != nil {
+		tProfile = &profileData{
+			MaskedUnits:   profile.MaskedUnits,
+			DisabledUnits: profile.DisabledUnits,
+			Files:         newFilesData(profile.Files),
+			Commands:      profile.Commands,
+		}
+	}
+
+	var tUnits []*unitData
+	for _, unit := range data.Units {
+		unitPath := path.Join(t.unitsPath, unit.Name)
+		tUnit := &unitData{
+			Name: unit.Name,
+			Path: unitPath,
+		}
+		if unit.Content != nil {
+			content := newContentData(unitPath, unit.Content)
+			tUnit.Content = &content
+		}
+		if unit.Enable != nil {
+			tUnit.EnableCommand = "disable"
+			if *unit.Enable {
+				tUnit.EnableCommand = "enable"
+			}
+		}
+		if unit.Command != nil {
+			tUnit.Command = *unit.Command
+		}
+		if len(unit.DropIns) != 0 {
+			dropInPath := path.Join(t.unitsPath, fmt.Sprintf("%s.d", unit.Name))
+
+			var items []*dropInData
+			for _, dropIn := range unit.DropIns {
+				items = append(items, &dropInData{newContentData(path.Join(dropInPath, dropIn.Name), dropIn.Content)})
+			}
+			tUnit.DropIns = &dropInsData{
+				Path:  dropInPath,
+				Items: items,
+			}
+		}
+
+		tUnits = append(tUnits, tUnit)
+	}
+
+	var buf bytes.Buffer
+	if err := t.template.ExecuteTemplate(&buf, MainTemplateName, &initScriptData{
+		Files:             tFiles,
+		Units:             tUnits,
+		Bootstrap:         data.Bootstrap,
+		Profile:           tProfile,
+		BootstrapSnippets: t.bootstrapSnippets,
+	}); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// NewGenerator creates a new Generator with the given units path and template, e.g. the
+// DefaultTemplate or one returned by LoadTemplate. The given bootstrap snippets are bash code
+// that is added verbatim to bootstrap scripts after all files and units are written, e.g. to
+// discover the provider ID.
+func NewGenerator(unitsPath string, tmpl *template.Template, bootstrapSnippets ...string) *Generator {
+	return &Generator{unitsPath, tmpl, bootstrapSnippets}
+}
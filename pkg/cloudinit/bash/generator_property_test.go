@@ -0,0 +1,199 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bash_test
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"path"
+	"reflect"
+	"strings"
+	"testing/quick"
+	"unicode"
+
+	. "github.com/gardener/gardener-extensions/pkg/cloudinit/bash"
+	"github.com/gardener/gardener-extensions/pkg/simulator"
+	"github.com/gardener/gardener-extensions/pkg/systemd"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+var (
+	// shellAlphabet contains the characters that have a special meaning in shell scripts, besides
+	// some ordinary and non-ASCII ones.
+	shellAlphabet = []rune("ab09 \t\n'\"\\$`(){}[]|&;<>*?~!#=%^-/.:,@_\u00e4\u2603")
+	// filePathAlphabet contains the characters of the shellAlphabet besides control characters.
+	filePathAlphabet = []rune("ab09 '\"\\$`(){}[]|&;<>*?~!#=%^-/.:,@_\u00e4\u2603")
+	// unitNameAlphabet contains the characters systemd accepts in unit names.
+	unitNameAlphabet = []rune("ab09:-_.\\@")
+
+	unitCommands = sets.NewString("start", "stop", "restart", "reload", "try-restart", "reload-or-restart")
+)
+
+func randomString(r *rand.Rand, alphabet []rune, size int) string {
+	runes := make([]rune, r.Intn(size+1))
+	for i := range runes {
+		runes[i] = alphabet[r.Intn(len(alphabet))]
+	}
+	return string(runes)
+}
+
+// generatorInput is a random config consisting of a file and a unit with a drop-in.
+type generatorInput struct {
+	FilePath, UnitName, DropInName, Command string
+	FileContent, UnitContent, DropInContent []byte
+	Enable                                  bool
+}
+
+// Generate returns a random generatorInput. The contents are made of characters of the
+// shellAlphabet. At most one of the file path, the unit and drop-in names and the command is made
+// of characters of the shellAlphabet as well, the others are likely to be accepted.
+func (generatorInput) Generate(r *rand.Rand, size int) reflect.Value {
+	in := generatorInput{
+		FilePath:      "/" + randomString(r, filePathAlphabet, size),
+		UnitName:      randomString(r, unitNameAlphabet, size) + ".service",
+		DropInName:    randomString(r, unitNameAlphabet, size) + ".conf",
+		FileContent:   []byte(randomString(r, shellAlphabet, size)),
+		UnitContent:   []byte(randomString(r, shellAlphabet, size)),
+		DropInContent: []byte(randomString(r, shellAlphabet, size)),
+		Enable:        r.Intn(2) == 0,
+	}
+	if r.Intn(2) == 0 {
+		in.Command = unitCommands.List()[r.Intn(unitCommands.Len())]
+	}
+
+	switch r.Intn(5) {
+	case 1:
+		in.FilePath = randomString(r, shellAlphabet, size)
+	case 2:
+		in.UnitName = randomString(r, shellAlphabet, size) + ".service"
+	case 3:
+		in.DropInName = randomString(r, shellAlphabet, size) + ".conf"
+	case 4:
+		in.Command = randomString(r, shellAlphabet, size)
+	}
+	return reflect.ValueOf(in)
+}
+
+func (in generatorInput) valid() bool {
+	return path.IsAbs(in.FilePath) && path.Clean(in.FilePath) == in.FilePath && in.FilePath != "/" &&
+		strings.IndexFunc(in.FilePath, unicode.IsControl) < 0 &&
+		systemd.ValidateUnitName(in.UnitName) == nil && systemd.ValidateDropInName(in.DropInName) == nil &&
+		(in.Command == "" || unitCommands.Has(in.Command))
+}
+
+// putContentCommands returns the commands that atomically write the given content to the given
+// path unless its checksum already matches.
+func putContentCommands(filePath string, content []byte, permissions string) []simulator.Command {
+	var (
+		checksum = sha256.Sum256(content)
+		tempPath = path.Join(path.Dir(filePath), "."+path.Base(filePath)+".gardener-tmp")
+		check    = []simulator.Command{
+			{Args: []string{"echo", hex.EncodeToString(checksum[:]) + "  " + filePath}},
+			{Args: []string{"sha256sum", "--check", "--status"}, Redirects: []string{"2> /dev/null"}},
+		}
+		commands []simulator.Command
+	)
+	commands = append(commands, check...)
+	commands = append(commands,
+		simulator.Command{Args: []string{"cat"}, Redirects: []string{"<< EOF"}, HereDoc: base64.StdEncoding.EncodeToString(content) + "\n"},
+		simulator.Command{Args: []string{"base64", "-d"}, Redirects: []string{"> " + tempPath}},
+	)
+	if permissions != "" {
+		commands = append(commands, check...)
+		commands = append(commands, simulator.Command{Args: []string{"chmod", permissions, tempPath}})
+	}
+	commands = append(commands, check...)
+	return append(commands, simulator.Command{Args: []string{"mv", "-f", tempPath, filePath}})
+}
+
+// expectedCommands returns the commands of the script writing and activating the file and unit
+// of the given input.
+func expectedCommands(in generatorInput) []simulator.Command {
+	var (
+		unitPath   = path.Join(DefaultUnitsPath, in.UnitName)
+		dropInPath = unitPath + ".d"
+		want       []simulator.Command
+	)
+	want = append(want, simulator.Command{Args: []string{"set", "-euo", "pipefail"}})
+	want = append(want, simulator.Command{Args: []string{"mkdir", "-p", path.Dir(in.FilePath)}})
+	want = append(want, putContentCommands(in.FilePath, in.FileContent, "0644")...)
+	want = append(want, simulator.Command{Args: []string{"chmod", "0644", in.FilePath}})
+	want = append(want, putContentCommands(unitPath, in.UnitContent, "")...)
+	want = append(want, simulator.Command{Args: []string{"mkdir", "-p", dropInPath}})
+	want = append(want, putContentCommands(path.Join(dropInPath, in.DropInName), in.DropInContent, "")...)
+	want = append(want, simulator.Command{Args: []string{"systemctl", "daemon-reload"}})
+	if in.Enable {
+		want = append(want, simulator.Command{Args: []string{"systemctl", "enable", in.UnitName}})
+	} else {
+		want = append(want, simulator.Command{Args: []string{"systemctl", "disable", in.UnitName}})
+	}
+	if in.Command != "" {
+		want = append(want, simulator.Command{Args: []string{"systemctl", in.Command, in.UnitName}})
+	}
+	return want
+}
+
+func commandLines(commands []simulator.Command) string {
+	var lines []string
+	for _, command := range commands {
+		lines = append(lines, fmt.Sprintf("%q (expands: %t)", command.String(), command.Expands))
+	}
+	return strings.Join(lines, "\n")
+}
+
+var _ = Describe("Generator properties", func() {
+	It("should generate exactly the commands writing and activating the files and units or reject them", func() {
+		var (
+			gen      = NewGenerator(DefaultUnitsPath, DefaultTemplate())
+			config   = &quick.Config{MaxCount: 1000, Rand: rand.New(rand.NewSource(1))}
+			accepted int
+		)
+
+		Expect(quick.Check(func(in generatorInput) bool {
+			permissions := int32(0644)
+			unit := &Unit{Name: in.UnitName, Content: in.UnitContent, DropIns: []*DropIn{{Name: in.DropInName, Content: in.DropInContent}}, Enable: &in.Enable}
+			if in.Command != "" {
+				command := in.Command
+				unit.Command = &command
+			}
+
+			script, err := gen.Generate(&OperatingSystemConfig{
+				Files: []*File{{Path: in.FilePath, Content: in.FileContent, Permissions: &permissions}},
+				Units: []*Unit{unit},
+			})
+			if err != nil {
+				Expect(in.valid()).To(BeFalse(), "valid config rejected: %v", err)
+				return true
+			}
+			Expect(in.valid()).To(BeTrue(), "invalid config accepted: %#v", in)
+			accepted++
+
+			got, err := simulator.ParseScript(script)
+			Expect(err).NotTo(HaveOccurred(), string(script))
+			want := expectedCommands(in)
+			Expect(got).To(Equal(want), "script parsed as\n%s\nwant\n%s", commandLines(got), commandLines(want))
+			return true
+		}, config)).To(Succeed())
+
+		// Make sure the property has not only been checked for rejected configs.
+		Expect(accepted).To(BeNumerically(">", 100))
+	})
+})
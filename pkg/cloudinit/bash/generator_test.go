@@ -0,0 +1,319 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bash_test
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/gardener/gardener-extensions/pkg/cloudinit/bash"
+	"github.com/gardener/gardener-extensions/pkg/simulator"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var updateGolden = flag.Bool("update-golden", false, "Update the golden files of the rendered results.")
+
+var (
+	onlyOwnerPerm = int32(0600)
+	enable        = true
+	disable       = false
+	start         = "start"
+	stop          = "stop"
+	restart       = "restart"
+)
+
+var _ = Describe("Generator", func() {
+	expectGolden := func(name string, actual []byte) {
+		path := filepath.Join("testfiles", name)
+		if *updateGolden {
+			Expect(ioutil.WriteFile(path, actual, 0644)).To(Succeed())
+		}
+
+		expected, err := ioutil.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(actual)).To(Equal(string(expected)))
+	}
+
+	It("should render correctly", func() {
+		gen := NewGenerator(DefaultUnitsPath, DefaultTemplate(), `echo "bootstrapped" > /var/lib/bootstrapped`)
+
+		cloudInit, err := gen.Generate(&OperatingSystemConfig{
+			Files: []*File{
+				{
+					Path:        "/foo",
+					Content:     []byte("bar"),
+					Permissions: &onlyOwnerPerm,
+				},
+			},
+
+			Units: []*Unit{
+				{
+					Name:    "docker.service",
+					Content: []byte("unit"),
+					DropIns: []*DropIn{
+						{
+							Name:    "10-docker-opts.conf",
+							Content: []byte("override"),
+						},
+					},
+					Enable:  &enable,
+					Command: &restart,
+				},
+			},
+			Bootstrap: true,
+			Profile: &Profile{
+				DisabledUnits: []string{"locksmithd.service"},
+				Files: []*File{
+					{
+						Path:    "/etc/docker/daemon.json",
+						Content: []byte(`{ "storage-driver": "devicemapper" }` + "\n"),
+					},
+				},
+				Commands: []string{
+					`sed -i '/Environment=DOCKER_SELINUX=--selinux-enabled=true/s/^/#/g' /run/systemd/system/docker.service`,
+				},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		expectGolden("cloud-init.sh", cloudInit)
+	})
+
+	It("should render the bootstrap preamble of the given profile", func() {
+		gen := NewGenerator(DefaultUnitsPath, DefaultTemplate())
+
+		cloudInit, err := gen.Generate(&OperatingSystemConfig{
+			Bootstrap: true,
+			Profile: &Profile{
+				MaskedUnits: []string{"update-engine.service"},
+				Files: []*File{
+					{
+						Path:        "/etc/docker/daemon.json",
+						Content:     []byte(`{ "storage-driver": "overlay2" }` + "\n"),
+						Permissions: &onlyOwnerPerm,
+					},
+				},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		expectGolden("cloud-init-profile.sh", cloudInit)
+	})
+
+	It("should run the commands of the units", func() {
+		gen := NewGenerator(DefaultUnitsPath, DefaultTemplate())
+
+		cloudInit, err := gen.Generate(&OperatingSystemConfig{
+			Units: []*Unit{
+				{Name: "kubelet.service", Content: []byte("unit"), Enable: &enable, Command: &start},
+				{Name: "update-engine.service", Enable: &disable, Command: &stop},
+				{Name: "docker.service", Command: &restart},
+				{Name: "foo.service", DropIns: []*DropIn{{Name: "10-foo.conf", Content: []byte("override")}}},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		expectGolden("cloud-init-reconcile.sh", cloudInit)
+	})
+
+	It("should reject unsupported unit commands", func() {
+		gen := NewGenerator(DefaultUnitsPath, DefaultTemplate())
+
+		command := "kill"
+		_, err := gen.Generate(&OperatingSystemConfig{Units: []*Unit{{Name: "foo.service", Command: &command}}})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should not render the bootstrap preamble and snippets if not bootstrapping", func() {
+		gen := NewGenerator(DefaultUnitsPath, DefaultTemplate(), "echo bootstrapped")
+
+		cloudInit, err := gen.Generate(&OperatingSystemConfig{
+			Profile: &Profile{MaskedUnits: []string{"update-engine.service"}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(cloudInit)).NotTo(ContainSubstring("update-engine.service"))
+		Expect(string(cloudInit)).NotTo(ContainSubstring("bootstrapped"))
+	})
+
+	It("should render a script that writes all files and units", func() {
+		gen := NewGenerator(DefaultUnitsPath, DefaultTemplate())
+
+		cloudInit, err := gen.Generate(&OperatingSystemConfig{
+			Files: []*File{
+				{
+					Path:        "/foo",
+					Content:     []byte("bar"),
+					Permissions: &onlyOwnerPerm,
+				},
+			},
+
+			Units: []*Unit{
+				{
+					Name:    "docker.service",
+					Content: []byte("unit"),
+					DropIns: []*DropIn{
+						{
+							Name:    "10-docker-opts.conf",
+							Content: []byte("override"),
+						},
+					},
+					Enable:  &enable,
+					Command: &restart,
+				},
+			},
+			Bootstrap: true,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		root, err := ioutil.TempDir("", "cloud-init")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(root)
+
+		sim := simulator.New(root)
+		Expect(sim.ApplyScript(cloudInit)).To(Succeed())
+
+		manifest, err := sim.Manifest()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(manifest.Files).To(HaveKeyWithValue("/foo", simulator.File{Content: "bar", Permissions: 0600}))
+		Expect(manifest.Units).To(HaveKey("docker.service"))
+		Expect(*manifest.Units["docker.service"].Content).To(Equal("unit"))
+		Expect(manifest.Units["docker.service"].DropIns).To(Equal(map[string]string{"10-docker-opts.conf": "override"}))
+		Expect(manifest.Units["docker.service"].Enabled).To(BeTrue())
+		Expect(manifest.Units["docker.service"].Active).To(BeTrue())
+	})
+
+	Context("on a simulated machine", func() {
+		var (
+			root string
+			sim  *simulator.Simulator
+			data = &OperatingSystemConfig{
+				Files: []*File{{Path: "/foo", Content: []byte("bar"), Permissions: &onlyOwnerPerm}},
+				Units: []*Unit{{
+					Name:    "docker.service",
+					Content: []byte("unit"),
+					DropIns: []*DropIn{{Name: "10-docker-opts.conf", Content: []byte("override")}},
+				}},
+				Bootstrap: true,
+			}
+		)
+
+		BeforeEach(func() {
+			var err error
+			root, err = ioutil.TempDir("", "cloud-init")
+			Expect(err).NotTo(HaveOccurred())
+
+			sim = simulator.New(root)
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(root)).To(Succeed())
+		})
+
+		generate := func(bootstrapSnippets ...string) []byte {
+			cloudInit, err := NewGenerator(DefaultUnitsPath, DefaultTemplate(), bootstrapSnippets...).Generate(data)
+			Expect(err).NotTo(HaveOccurred())
+			return cloudInit
+		}
+
+		It("should run the bootstrap snippets after writing all files", func() {
+			Expect(sim.ApplyScript(generate(`cat /foo > /bootstrapped`, `echo "$(cat /bootstrapped)" >> /bootstrapped`))).To(Succeed())
+
+			manifest, err := sim.Manifest()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(manifest.Files).To(HaveKeyWithValue("/bootstrapped", simulator.File{Content: "barbar\n", Permissions: 0644}))
+		})
+
+		It("should exit with an error if a bootstrap snippet fails", func() {
+			Expect(sim.ApplyScript(generate(`false`, `echo unreachable > /unreachable`))).To(MatchError("script exited with status 1"))
+
+			manifest, err := sim.Manifest()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(manifest.Files).NotTo(HaveKey("/unreachable"))
+		})
+
+		It("should not leave temporary files behind", func() {
+			Expect(sim.ApplyScript(generate())).To(Succeed())
+
+			Expect(filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(info.Name()).NotTo(HaveSuffix(".gardener-tmp"))
+				return nil
+			})).To(Succeed())
+		})
+
+		It("should not rewrite unchanged files", func() {
+			cloudInit := generate()
+			Expect(sim.ApplyScript(cloudInit)).To(Succeed())
+
+			past := time.Now().Add(-time.Hour).Truncate(time.Second)
+			for _, p := range []string{"/foo", "/etc/systemd/system/docker.service", "/etc/systemd/system/docker.service.d/10-docker-opts.conf"} {
+				Expect(os.Chtimes(filepath.Join(root, p), past, past)).To(Succeed())
+			}
+			Expect(ioutil.WriteFile(filepath.Join(root, "/foo"), []byte("changed"), 0600)).To(Succeed())
+
+			Expect(sim.ApplyScript(cloudInit)).To(Succeed())
+
+			for _, p := range []string{"/etc/systemd/system/docker.service", "/etc/systemd/system/docker.service.d/10-docker-opts.conf"} {
+				info, err := os.Stat(filepath.Join(root, p))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(info.ModTime()).To(Equal(past), p)
+			}
+			content, err := ioutil.ReadFile(filepath.Join(root, "/foo"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal("bar"))
+		})
+	})
+
+	It("should quote paths", func() {
+		gen := NewGenerator(DefaultUnitsPath, DefaultTemplate())
+
+		cloudInit, err := gen.Generate(&OperatingSystemConfig{
+			Files: []*File{{Path: "/it's $(reboot)/foo", Content: []byte("bar"), Permissions: &onlyOwnerPerm}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		root, err := ioutil.TempDir("", "cloud-init")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(root)
+
+		sim := simulator.New(root)
+		Expect(sim.ApplyScript(cloudInit)).To(Succeed())
+
+		manifest, err := sim.Manifest()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(manifest.Files).To(HaveKeyWithValue("/it's $(reboot)/foo", simulator.File{Content: "bar", Permissions: 0600}))
+	})
+
+	It("should reject unsafe unit names and file paths", func() {
+		gen := NewGenerator(DefaultUnitsPath, DefaultTemplate())
+
+		for _, config := range []*OperatingSystemConfig{
+			{Units: []*Unit{{Name: "foo'$(reboot).service"}}},
+			{Units: []*Unit{{Name: "docker.service", DropIns: []*DropIn{{Name: "../10-foo.conf"}}}}},
+			{Files: []*File{{Path: "/foo\nreboot"}}},
+			{Files: []*File{{Path: "foo"}}},
+			{Files: []*File{{Path: "/etc/foo/"}}},
+			{Files: []*File{{Path: "/etc/../foo"}}},
+			{Files: []*File{{Path: "/"}}},
+			{Bootstrap: true, Profile: &Profile{MaskedUnits: []string{"$(reboot).service"}}},
+		} {
+			_, err := gen.Generate(config)
+			Expect(err).To(HaveOccurred())
+		}
+	})
+})
@@ -0,0 +1,229 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bash
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/gardener/gardener-extensions/pkg/shell"
+)
+
+const (
+	// MetadataServiceAlicloud is the name of the metadata service of Alicloud.
+	MetadataServiceAlicloud = "alicloud"
+	// MetadataServiceAWS is the name of the metadata service of AWS. Only IMDSv1 is supported.
+	MetadataServiceAWS = "aws"
+	// MetadataServiceAzure is the name of the metadata service of Azure.
+	MetadataServiceAzure = "azure"
+	// MetadataServiceGCP is the name of the metadata service of GCP.
+	MetadataServiceGCP = "gcp"
+	// MetadataServiceOpenStack is the name of the metadata service of OpenStack.
+	MetadataServiceOpenStack = "openstack"
+)
+
+// MetadataField is a value read from a metadata service into a variable of the script.
+type MetadataField struct {
+	// Variable is the name of the shell variable the value is stored in.
+	Variable string
+	// Path is the path of the value relative to the endpoint of the metadata service.
+	Path string
+	// Extract is an extended regular expression matching whole lines of the response with one
+	// group. If set, the value is the group of the matching line instead of the whole response,
+	// e.g. to extract a field of a JSON document. It must not contain escaped slashes.
+	Extract string
+}
+
+// MetadataService describes how bootstrap scripts discover the provider ID of a machine from the
+// metadata service of its cloud.
+type MetadataService struct {
+	// Endpoint is the base URL of the metadata service.
+	Endpoint string
+	// Headers are the headers sent with every request, e.g. `Metadata-Flavor: Google`.
+	Headers []string
+	// Fields are the values read from the metadata service.
+	Fields []MetadataField
+	// ProviderID is the format of the provider ID. It references the variables of the fields as
+	// `${NAME}`, all other characters must be alphanumeric or one of `-._:/`.
+	ProviderID string
+}
+
+var metadataServices = map[string]MetadataService{
+	MetadataServiceAlicloud: {
+		Endpoint: "http://100.100.100.200/latest/meta-data",
+		Fields: []MetadataField{
+			{Variable: "REGION_ID", Path: "region-id"},
+			{Variable: "INSTANCE_ID", Path: "instance-id"},
+		},
+		ProviderID: "${REGION_ID}.${INSTANCE_ID}",
+	},
+	MetadataServiceAWS: {
+		Endpoint: "http://169.254.169.254/latest/meta-data",
+		Fields: []MetadataField{
+			{Variable: "ZONE", Path: "placement/availability-zone"},
+			{Variable: "INSTANCE_ID", Path: "instance-id"},
+		},
+		ProviderID: "aws:///${ZONE}/${INSTANCE_ID}",
+	},
+	MetadataServiceAzure: {
+		Endpoint: "http://169.254.169.254/metadata/instance/compute",
+		Headers:  []string{"Metadata: true"},
+		Fields: []MetadataField{
+			{Variable: "SUBSCRIPTION_ID", Path: "subscriptionId?api-version=2019-03-11&format=text"},
+			{Variable: "RESOURCE_GROUP", Path: "resourceGroupName?api-version=2019-03-11&format=text"},
+			{Variable: "VM_NAME", Path: "name?api-version=2019-03-11&format=text"},
+		},
+		ProviderID: "azure:///subscriptions/${SUBSCRIPTION_ID}/resourceGroups/${RESOURCE_GROUP}/providers/Microsoft.Compute/virtualMachines/${VM_NAME}",
+	},
+	MetadataServiceGCP: {
+		Endpoint: "http://metadata.google.internal/computeMetadata/v1",
+		Headers:  []string{"Metadata-Flavor: Google"},
+		Fields: []MetadataField{
+			{Variable: "PROJECT_ID", Path: "project/project-id"},
+			// The zone is returned as `projects/<number>/zones/<zone>`.
+			{Variable: "ZONE", Path: "instance/zone", Extract: `.*/([^/]+)`},
+			{Variable: "INSTANCE_NAME", Path: "instance/name"},
+		},
+		ProviderID: "gce://${PROJECT_ID}/${ZONE}/${INSTANCE_NAME}",
+	},
+	MetadataServiceOpenStack: {
+		Endpoint: "http://169.254.169.254/openstack/latest",
+		Fields: []MetadataField{
+			{Variable: "INSTANCE_ID", Path: "meta_data.json", Extract: `.*"uuid": *"([^"]+)".*`},
+		},
+		ProviderID: "openstack:///${INSTANCE_ID}",
+	},
+}
+
+// MetadataServiceNames returns the sorted names of the known metadata services.
+func MetadataServiceNames() []string {
+	names := make([]string, 0, len(metadataServices))
+	for name := range metadataServices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// MetadataServiceFor returns a copy of the known metadata service with the given name.
+func MetadataServiceFor(name string) (*MetadataService, error) {
+	service, ok := metadataServices[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown metadata service %q, must be one of %s", name, strings.Join(MetadataServiceNames(), ", "))
+	}
+	service.Headers = append([]string(nil), service.Headers...)
+	service.Fields = append([]MetadataField(nil), service.Fields...)
+	return &service, nil
+}
+
+var (
+	variableName      = regexp.MustCompile(`^[A-Z_][A-Z0-9_]*$`)
+	providerIDElement = regexp.MustCompile(`\$\{([A-Z_][A-Z0-9_]*)\}|[A-Za-z0-9._:/-]+`)
+)
+
+// Validate returns an error if the fields of the metadata service are invalid or if its provider
+// ID references unknown variables.
+func (m *MetadataService) Validate() error {
+	if m.Endpoint == "" {
+		return fmt.Errorf("metadata service endpoint must not be empty")
+	}
+	for _, header := range m.Headers {
+		if strings.IndexFunc(header, unicode.IsControl) >= 0 {
+			return fmt.Errorf("invalid metadata service header %q", header)
+		}
+	}
+
+	variables := map[string]bool{}
+	for _, field := range m.Fields {
+		if !variableName.MatchString(field.Variable) {
+			return fmt.Errorf("invalid metadata field variable %q", field.Variable)
+		}
+		if field.Path == "" || strings.IndexFunc(field.Path, unicode.IsControl) >= 0 {
+			return fmt.Errorf("metadata field %s: invalid path %q", field.Variable, field.Path)
+		}
+		if field.Extract != "" {
+			re, err := regexp.Compile(field.Extract)
+			if err != nil || re.NumSubexp() != 1 || strings.Contains(field.Extract, `\/`) {
+				return fmt.Errorf("metadata field %s: invalid extract expression %q", field.Variable, field.Extract)
+			}
+		}
+		variables[field.Variable] = true
+	}
+
+	var unknown []string
+	rest := providerIDElement.ReplaceAllStringFunc(m.ProviderID, func(element string) string {
+		if name := strings.TrimSuffix(strings.TrimPrefix(element, "${"), "}"); name != element && !variables[name] {
+			unknown = append(unknown, name)
+		}
+		return ""
+	})
+	if m.ProviderID == "" || rest != "" {
+		return fmt.Errorf("invalid provider id %q", m.ProviderID)
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("provider id %q references unknown variables %s", m.ProviderID, strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+var providerIDSnippetTemplate = template.Must(template.New("provider-id").Funcs(template.FuncMap{
+	"quote": shell.Quote,
+	"extract": func(expr string) string {
+		return fmt.Sprintf(`s/^%s$/\1/p`, strings.Replace(expr, "/", `\/`, -1))
+	},
+	"required": func(providerID string) string {
+		return providerIDElement.ReplaceAllStringFunc(providerID, func(element string) string {
+			if !strings.HasPrefix(element, "${") {
+				return element
+			}
+			name := element[2 : len(element)-1]
+			return fmt.Sprintf("${%s:?could not determine %s}", name, name)
+		})
+	},
+}).Parse(`META_EP={{ quote .Endpoint }}
+{{- range $_, $field := .Fields }}
+{{ $field.Variable }}=$(curl --silent --show-error --fail --retry 5 --retry-delay 2 --retry-connrefused --connect-timeout 5 --max-time 10
+{{- range $_, $header := $.Headers }} --header {{ quote $header }}{{ end }} "$META_EP"/{{ quote $field.Path }}
+{{- if $field.Extract }} | sed -n -E {{ quote (extract $field.Extract) }}{{ end }})
+{{- end }}
+PROVIDER_ID="{{ required .ProviderID }}"
+DOWNLOAD_MAIN_PATH="${DOWNLOAD_MAIN_PATH:-/var/lib/cloud-config-downloader}"
+mkdir -p "$DOWNLOAD_MAIN_PATH"
+echo "PROVIDER_ID=$PROVIDER_ID" > "$DOWNLOAD_MAIN_PATH/provider-id"
+touch /etc/environment
+sed -i '/^PROVIDER_ID=/d' /etc/environment
+echo "PROVIDER_ID=$PROVIDER_ID" >> /etc/environment`))
+
+// ProviderIDSnippet returns a bootstrap snippet that reads the fields from the metadata service,
+// with retries, and stores the provider ID in the download directory of the
+// cloud-config-downloader and in /etc/environment, replacing the provider ID stored by earlier
+// runs. The script exits with an error if a field
+// cannot be read or is empty. It returns an error if the metadata service is invalid.
+func (m *MetadataService) ProviderIDSnippet() (string, error) {
+	if err := m.Validate(); err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := providerIDSnippetTemplate.Execute(&buf, m); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,301 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bash_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/gardener/gardener-extensions/pkg/cloudinit/bash"
+	"github.com/gardener/gardener-extensions/pkg/simulator"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// metadataStub is the content of a stubbed metadata service, keyed by path.
+type metadataStub struct {
+	headers    map[string]string
+	responses  map[string]string
+	providerID string
+}
+
+var metadataStubs = map[string]metadataStub{
+	MetadataServiceAlicloud: {
+		responses:  map[string]string{"/region-id": "cn-beijing", "/instance-id": "i-2zeabc"},
+		providerID: "cn-beijing.i-2zeabc",
+	},
+	MetadataServiceAWS: {
+		responses:  map[string]string{"/placement/availability-zone": "eu-west-1a", "/instance-id": "i-0abc"},
+		providerID: "aws:///eu-west-1a/i-0abc",
+	},
+	MetadataServiceAzure: {
+		headers: map[string]string{"Metadata": "true"},
+		responses: map[string]string{
+			"/subscriptionId?api-version=2019-03-11&format=text":    "00000000-0000-0000-0000-000000000000",
+			"/resourceGroupName?api-version=2019-03-11&format=text": "shoot--foo--bar",
+			"/name?api-version=2019-03-11&format=text":              "shoot--foo--bar-worker-z1-0",
+		},
+		providerID: "azure:///subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/shoot--foo--bar/providers/Microsoft.Compute/virtualMachines/shoot--foo--bar-worker-z1-0",
+	},
+	MetadataServiceGCP: {
+		headers: map[string]string{"Metadata-Flavor": "Google"},
+		responses: map[string]string{
+			"/project/project-id": "my-project",
+			"/instance/zone":      "projects/123456789/zones/europe-west1-b",
+			"/instance/name":      "shoot--foo--bar-worker-z1-0",
+		},
+		providerID: "gce://my-project/europe-west1-b/shoot--foo--bar-worker-z1-0",
+	},
+	MetadataServiceOpenStack: {
+		responses:  map[string]string{"/meta_data.json": `{"uuid": "d8e02d56-2648-49a3-bf97-6be8f1204f38", "name": "shoot--foo--bar-worker-z1-0"}`},
+		providerID: "openstack:///d8e02d56-2648-49a3-bf97-6be8f1204f38",
+	},
+}
+
+var _ = Describe("MetadataService", func() {
+	Describe("#MetadataServiceFor", func() {
+		It("should return copies of the known metadata services", func() {
+			service, err := MetadataServiceFor(MetadataServiceGCP)
+			Expect(err).NotTo(HaveOccurred())
+			service.Headers[0] = "Foo: bar"
+
+			service, err = MetadataServiceFor(MetadataServiceGCP)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(service.Headers).To(Equal([]string{"Metadata-Flavor: Google"}))
+		})
+
+		It("should fail for unknown metadata services", func() {
+			_, err := MetadataServiceFor("foo")
+			Expect(err).To(MatchError(ContainSubstring("must be one of alicloud, aws, azure, gcp, openstack")))
+		})
+	})
+
+	Describe("#Validate", func() {
+		valid := func() *MetadataService {
+			return &MetadataService{
+				Endpoint:   "http://169.254.169.254",
+				Fields:     []MetadataField{{Variable: "ID", Path: "id"}},
+				ProviderID: "foo:///${ID}",
+			}
+		}
+
+		It("should accept valid metadata services", func() {
+			Expect(valid().Validate()).To(Succeed())
+		})
+
+		It("should reject invalid metadata services", func() {
+			for _, mutate := range []func(*MetadataService){
+				func(m *MetadataService) { m.Endpoint = "" },
+				func(m *MetadataService) { m.Headers = []string{"Foo: bar\nBaz: qux"} },
+				func(m *MetadataService) { m.Fields[0].Variable = "$(reboot)" },
+				func(m *MetadataService) { m.Fields[0].Path = "" },
+				func(m *MetadataService) { m.Fields[0].Extract = "(a)(b)" },
+				func(m *MetadataService) { m.Fields[0].Extract = `a\/(b)` },
+				func(m *MetadataService) { m.ProviderID = "" },
+				func(m *MetadataService) { m.ProviderID = "${UNKNOWN}" },
+				func(m *MetadataService) { m.ProviderID = "$(reboot)" },
+				func(m *MetadataService) { m.ProviderID = `"${ID}` },
+			} {
+				service := valid()
+				mutate(service)
+				Expect(service.Validate()).NotTo(Succeed(), "%+v", service)
+			}
+		})
+	})
+
+	Describe("#ProviderIDSnippet", func() {
+		var root string
+
+		BeforeEach(func() {
+			var err error
+			root, err = ioutil.TempDir("", "metadata")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(root)).To(Succeed())
+		})
+
+		It("should render the snippets of the known metadata services", func() {
+			for _, name := range MetadataServiceNames() {
+				service, err := MetadataServiceFor(name)
+				Expect(err).NotTo(HaveOccurred())
+				snippet, err := service.ProviderIDSnippet()
+				Expect(err).NotTo(HaveOccurred())
+
+				path := filepath.Join("testfiles", fmt.Sprintf("provider-id-%s.sh", name))
+				if *updateGolden {
+					Expect(ioutil.WriteFile(path, []byte(snippet+"\n"), 0644)).To(Succeed())
+				}
+				expected, err := ioutil.ReadFile(path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(snippet+"\n").To(Equal(string(expected)), name)
+			}
+		})
+
+		It("should discover the provider id from a stubbed metadata service", func() {
+			Expect(MetadataServiceNames()).To(HaveLen(len(metadataStubs)))
+
+			for _, name := range MetadataServiceNames() {
+				stub := metadataStubs[name]
+				server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					for key, value := range stub.headers {
+						if r.Header.Get(key) != value {
+							w.WriteHeader(http.StatusBadRequest)
+							return
+						}
+					}
+					response, ok := stub.responses[strings.TrimPrefix(r.URL.RequestURI(), "/"+name)]
+					if !ok {
+						w.WriteHeader(http.StatusNotFound)
+						return
+					}
+					fmt.Fprint(w, response)
+				}))
+
+				service, err := MetadataServiceFor(name)
+				Expect(err).NotTo(HaveOccurred())
+				service.Endpoint = server.URL + "/" + name
+				snippet, err := service.ProviderIDSnippet()
+				Expect(err).NotTo(HaveOccurred())
+				script, err := NewGenerator(DefaultUnitsPath, DefaultTemplate(), snippet).Generate(&OperatingSystemConfig{Bootstrap: true})
+				Expect(err).NotTo(HaveOccurred())
+
+				sim := simulator.New(filepath.Join(root, name))
+				sim.HTTPClient = server.Client()
+				err = sim.ApplyScript(script)
+				server.Close()
+				Expect(err).NotTo(HaveOccurred(), name)
+
+				manifest, err := sim.Manifest()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(manifest.Files).To(HaveKeyWithValue("/var/lib/cloud-config-downloader/provider-id", simulator.File{Content: "PROVIDER_ID=" + stub.providerID + "\n", Permissions: 0644}), name)
+			}
+		})
+
+		Context("on a simulated machine", func() {
+			var (
+				sim    *simulator.Simulator
+				script []byte
+			)
+
+			BeforeEach(func() {
+				sim = simulator.New(root)
+
+				service, err := MetadataServiceFor(MetadataServiceAlicloud)
+				Expect(err).NotTo(HaveOccurred())
+				snippet, err := service.ProviderIDSnippet()
+				Expect(err).NotTo(HaveOccurred())
+				script, err = NewGenerator(DefaultUnitsPath, DefaultTemplate(), snippet).Generate(&OperatingSystemConfig{Bootstrap: true})
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("should store the provider id in the download directory", func() {
+				sim.Env["DOWNLOAD_MAIN_PATH"] = "/var/lib/downloader"
+				sim.HTTPGet = func(url string) ([]byte, error) {
+					return []byte(filepath.Base(url)), nil
+				}
+
+				Expect(sim.ApplyScript(script)).To(Succeed())
+
+				manifest, err := sim.Manifest()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(manifest.Files).To(HaveKeyWithValue("/var/lib/downloader/provider-id", simulator.File{Content: "PROVIDER_ID=region-id.instance-id\n", Permissions: 0644}))
+				Expect(manifest.Files).To(HaveKeyWithValue("/etc/environment", simulator.File{Content: "PROVIDER_ID=region-id.instance-id\n", Permissions: 0644}))
+			})
+
+			It("should replace the provider id in /etc/environment when run again", func() {
+				Expect(sim.ApplyScript([]byte("mkdir -p /etc\necho 'PATH=/usr/bin' > /etc/environment\n"))).To(Succeed())
+				instance := "instance-id"
+				sim.HTTPGet = func(url string) ([]byte, error) {
+					if filepath.Base(url) == "instance-id" {
+						return []byte(instance), nil
+					}
+					return []byte(filepath.Base(url)), nil
+				}
+
+				Expect(sim.ApplyScript(script)).To(Succeed())
+				instance = "other-instance-id"
+				Expect(sim.ApplyScript(script)).To(Succeed())
+
+				manifest, err := sim.Manifest()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(manifest.Files).To(HaveKeyWithValue("/etc/environment", simulator.File{Content: "PATH=/usr/bin\nPROVIDER_ID=region-id.other-instance-id\n", Permissions: 0644}))
+			})
+
+			It("should retry transient failures", func() {
+				calls := 0
+				sim.HTTPGet = func(url string) ([]byte, error) {
+					if calls++; calls%3 != 0 {
+						return nil, fmt.Errorf("connection refused")
+					}
+					return []byte(filepath.Base(url)), nil
+				}
+
+				Expect(sim.ApplyScript(script)).To(Succeed())
+
+				manifest, err := sim.Manifest()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(manifest.Files).To(HaveKeyWithValue("/var/lib/cloud-config-downloader/provider-id", simulator.File{Content: "PROVIDER_ID=region-id.instance-id\n", Permissions: 0644}))
+			})
+
+			It("should exit with an error if the metadata service is unavailable", func() {
+				sim.HTTPGet = func(url string) ([]byte, error) {
+					return nil, fmt.Errorf("connection refused")
+				}
+
+				Expect(sim.ApplyScript(script)).To(MatchError("script exited with status 22"))
+
+				manifest, err := sim.Manifest()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(manifest.Files).NotTo(HaveKey("/var/lib/cloud-config-downloader/provider-id"))
+				Expect(manifest.Files).NotTo(HaveKey("/etc/environment"))
+			})
+
+			It("should exit with an error if the metadata service returns an empty field", func() {
+				sim.HTTPGet = func(url string) ([]byte, error) {
+					return nil, nil
+				}
+
+				Expect(sim.ApplyScript(script)).To(MatchError(ContainSubstring("could not determine REGION_ID")))
+			})
+		})
+
+		It("should exit with an error if a field is missing", func() {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, `{"name": "foo"}`)
+			}))
+			defer server.Close()
+
+			service, err := MetadataServiceFor(MetadataServiceOpenStack)
+			Expect(err).NotTo(HaveOccurred())
+			service.Endpoint = server.URL
+			snippet, err := service.ProviderIDSnippet()
+			Expect(err).NotTo(HaveOccurred())
+			script, err := NewGenerator(DefaultUnitsPath, DefaultTemplate(), snippet).Generate(&OperatingSystemConfig{Bootstrap: true})
+			Expect(err).NotTo(HaveOccurred())
+
+			sim := simulator.New(root)
+			sim.HTTPClient = server.Client()
+			Expect(sim.ApplyScript(script)).To(MatchError(ContainSubstring("could not determine INSTANCE_ID")))
+		})
+	})
+})
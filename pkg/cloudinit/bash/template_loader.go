@@ -0,0 +1,305 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bash
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// templateSuffix is the suffix of the files overriding templates.
+const templateSuffix = ".template"
+
+// readTemplateFiles reads the templates at the given path and returns their content keyed by the
+// names of the templates they override. A file overrides the main template, the files
+// `<name>.template` of a directory override the templates `<name>`. Hidden files, e.g. the
+// internal directories of mounted ConfigMaps, are ignored.
+func readTemplateFiles(path string) (map[string]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]string{MainTemplateName: string(data)}, nil
+	}
+
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	files := map[string]string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") || !strings.HasSuffix(name, templateSuffix) {
+			continue
+		}
+		// ConfigMap keys are symlinks, so the entry is stat'ed again to follow them.
+		filePath := filepath.Join(path, name)
+		if info, err := os.Stat(filePath); err != nil || info.IsDir() {
+			continue
+		}
+		data, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			return nil, err
+		}
+		files[strings.TrimSuffix(name, templateSuffix)] = string(data)
+	}
+	return files, nil
+}
+
+// parseTemplate returns a copy of the given base template in which the templates with the given
+// names are overridden by the given contents.
+func parseTemplate(base *template.Template, files map[string]string) (*template.Template, error) {
+	tmpl, err := base.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, err := tmpl.New(name).Parse(files[name]); err != nil {
+			return nil, fmt.Errorf("could not parse template %q: %v", name, err)
+		}
+	}
+	return tmpl, nil
+}
+
+// LoadTemplate returns a copy of the given base template, e.g. the DefaultTemplate, in which the
+// templates found at the given path are overridden, see NewTemplateLoader. The result is
+// validated with ValidateTemplate.
+func LoadTemplate(base *template.Template, path string) (*template.Template, error) {
+	files, err := readTemplateFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := parseTemplate(base, files)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateTemplate(tmpl); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+var (
+	canaryPermissions = int32(0644)
+	canaryEnable      = true
+	canaryCommand     = "restart"
+
+	// canarySnippet is the bootstrap snippet of the canary config.
+	canarySnippet = "# gardener-canary-bootstrap"
+
+	// canaryConfig is rendered by ValidateTemplate. It exercises all parts of the template.
+	canaryConfig = &OperatingSystemConfig{
+		Files: []*File{{Path: "/var/lib/gardener-canary/file", Content: []byte("canary\n"), Permissions: &canaryPermissions}},
+		Units: []*Unit{{
+			Name:    "gardener-canary.service",
+			Content: []byte("[Service]\nExecStart=/bin/true\n"),
+			DropIns: []*DropIn{{Name: "10-gardener-canary.conf", Content: []byte("[Service]\nType=oneshot\n")}},
+			Enable:  &canaryEnable,
+			Command: &canaryCommand,
+		}},
+		Profile: &Profile{
+			MaskedUnits:   []string{"gardener-canary-masked.service"},
+			DisabledUnits: []string{"gardener-canary-disabled.service"},
+			Files:         []*File{{Path: "/var/lib/gardener-canary/profile", Content: []byte("canary\n")}},
+			Commands:      []string{"true"},
+		},
+	}
+)
+
+// ValidateTemplate renders a canary config with the given template, both for bootstrapping and
+// reconciling machines. It returns an error if rendering fails, if a script does not write all
+// files and units of the canary config or if a bootstrap script lacks the bootstrap snippets.
+func ValidateTemplate(tmpl *template.Template) error {
+	gen := NewGenerator(DefaultUnitsPath, tmpl, canarySnippet)
+	for _, bootstrap := range []bool{true, false} {
+		config := *canaryConfig
+		config.Bootstrap = bootstrap
+
+		script, err := gen.Generate(&config)
+		if err != nil {
+			return fmt.Errorf("could not render canary config (bootstrap: %t): %v", bootstrap, err)
+		}
+
+		unit := canaryConfig.Units[0]
+		paths := []string{
+			canaryConfig.Files[0].Path,
+			filepath.Join(DefaultUnitsPath, unit.Name),
+			filepath.Join(DefaultUnitsPath, unit.Name+".d", unit.DropIns[0].Name),
+		}
+		for _, p := range paths {
+			if !bytes.Contains(script, []byte(p)) {
+				return fmt.Errorf("script of canary config (bootstrap: %t) does not write %q", bootstrap, p)
+			}
+		}
+		if bootstrap && !bytes.Contains(script, []byte(canarySnippet)) {
+			return fmt.Errorf("script of canary config (bootstrap: %t) does not contain the bootstrap snippets", bootstrap)
+		}
+	}
+	return nil
+}
+
+// TemplateLoader loads the template of a Generator from a path and reloads it when the
+// files at the path change. A file overrides the main template, the files `<name>.template` of a
+// directory, e.g. a mounted ConfigMap, override the templates `<name>`: `cloud-init.sh.template`
+// the main template and `put-content.template` the template writing files. Templates that are not
+// overridden keep the ones of the base template.
+//
+// Templates that cannot be parsed or fail ValidateTemplate are rejected, the last accepted
+// template stays in use. Initially, this is the base template.
+type TemplateLoader struct {
+	logger   logr.Logger
+	base     *template.Template
+	path     string
+	interval time.Duration
+
+	lock      sync.Mutex
+	template  *template.Template
+	checksum  string
+	lastCheck time.Time
+}
+
+// NewTemplateLoader creates a new TemplateLoader overriding the given base template with the
+// templates at the given path. The files at the path are checked for changes at most once per the
+// given interval.
+func NewTemplateLoader(logger logr.Logger, base *template.Template, path string, interval time.Duration) *TemplateLoader {
+	return &TemplateLoader{
+		logger:   logger,
+		base:     base,
+		path:     path,
+		interval: interval,
+		template: base,
+		// The base template is used as long as no files override it.
+		checksum: templateChecksum(nil),
+	}
+}
+
+// Template returns the current template. If the interval has passed since the last check, the
+// template is reloaded first if the files at the path changed. See Watch for reloading templates
+// without rendering configs.
+func (l *TemplateLoader) Template() *template.Template {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if time.Since(l.lastCheck) >= l.interval {
+		if err := l.reload(); err != nil {
+			l.logger.Error(err, "Could not reload cloud-init template, keeping the current one", "path", l.path)
+		}
+	}
+	return l.template
+}
+
+// Watch checks the files at the path for changes once per interval until the given stop channel
+// is closed. The given function is called whenever another template has been accepted since the
+// last call, also if it was reloaded by Template. Callers use it to render all configs again, as
+// Template only reloads the template when a config is rendered.
+func (l *TemplateLoader) Watch(stop <-chan struct{}, changed func()) {
+	l.lock.Lock()
+	notified := l.template
+	l.lock.Unlock()
+
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		l.lock.Lock()
+		if err := l.reload(); err != nil {
+			l.logger.Error(err, "Could not reload cloud-init template, keeping the current one", "path", l.path)
+		}
+		current := l.template
+		l.lock.Unlock()
+
+		if current != notified {
+			notified = current
+			changed()
+		}
+	}
+}
+
+// Reload reloads the template if the files at the path changed. It returns an error if the new
+// template was rejected.
+func (l *TemplateLoader) Reload() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.reload()
+}
+
+func (l *TemplateLoader) reload() error {
+	l.lastCheck = time.Now()
+
+	files, err := readTemplateFiles(l.path)
+	if err != nil {
+		return err
+	}
+	checksum := templateChecksum(files)
+	if checksum == l.checksum {
+		return nil
+	}
+
+	tmpl, err := parseTemplate(l.base, files)
+	if err == nil {
+		err = ValidateTemplate(tmpl)
+	}
+	if err != nil {
+		// The files are not checked again until they change.
+		l.checksum = checksum
+		return err
+	}
+
+	l.logger.Info("Loaded cloud-init template", "path", l.path, "checksum", checksum)
+	l.template, l.checksum = tmpl, checksum
+	return nil
+}
+
+func templateChecksum(files map[string]string) string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(h, "%s\x00%d\x00%s", name, len(files[name]), files[name])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
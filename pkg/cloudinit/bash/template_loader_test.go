@@ -0,0 +1,229 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bash_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/gardener/gardener-extensions/pkg/cloudinit/bash"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+const quotedPutContent = `cat << 'EOF' | base64 -d > {{ quote .Path }}
+{{ .Content }}
+EOF`
+
+var _ = Describe("TemplateLoader", func() {
+	var (
+		dir    string
+		config *OperatingSystemConfig
+	)
+
+	writeFile := func(name, content string) {
+		Expect(ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "templates")
+		Expect(err).NotTo(HaveOccurred())
+
+		config = &OperatingSystemConfig{Files: []*File{{Path: "/foo", Content: []byte("bar")}}}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	Describe("#LoadTemplate", func() {
+		It("should override named templates with the files of a directory", func() {
+			writeFile("put-content.template", quotedPutContent)
+			writeFile("README.md", "ignored")
+
+			tmpl, err := LoadTemplate(DefaultTemplate(), dir)
+			Expect(err).NotTo(HaveOccurred())
+
+			script, err := NewGenerator(DefaultUnitsPath, tmpl).Generate(config)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(script)).To(ContainSubstring("cat << 'EOF' | base64 -d > '/foo'\nYmFy\nEOF"))
+		})
+
+		It("should not change the default template", func() {
+			writeFile("put-content.template", quotedPutContent)
+			_, err := LoadTemplate(DefaultTemplate(), dir)
+			Expect(err).NotTo(HaveOccurred())
+
+			script, err := NewGenerator(DefaultUnitsPath, DefaultTemplate()).Generate(config)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(script)).To(ContainSubstring("cat << EOF | base64 -d > '/.foo.gardener-tmp'"))
+		})
+
+		It("should override the main template with a file", func() {
+			path := filepath.Join(dir, "main")
+			Expect(ioutil.WriteFile(path, []byte(`#!/bin/bash
+{{- range .Files }}
+# custom
+{{ template "put-content" . }}
+{{- end }}
+{{- range .Units }}
+{{- if .Content }}
+{{ template "put-content" .Content }}
+{{- end }}
+{{- if .DropIns }}
+{{- range .DropIns.Items }}
+{{ template "put-content" . }}
+{{- end }}
+{{- end }}
+{{- end }}
+{{- if .Bootstrap }}
+{{- range .BootstrapSnippets }}
+{{ . }}
+{{- end }}
+{{- end }}
+`), 0644)).To(Succeed())
+
+			tmpl, err := LoadTemplate(DefaultTemplate(), path)
+			Expect(err).NotTo(HaveOccurred())
+
+			script, err := NewGenerator(DefaultUnitsPath, tmpl).Generate(config)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(script)).To(HavePrefix("#!/bin/bash\n# custom\n"))
+			Expect(string(script)).To(HaveSuffix("mv -f '/.foo.gardener-tmp' '/foo'\n"))
+		})
+
+		It("should load the keys of mounted ConfigMaps", func() {
+			Expect(os.Mkdir(filepath.Join(dir, "..2019_01_01"), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(dir, "..2019_01_01", "put-content.template"), []byte(quotedPutContent), 0644)).To(Succeed())
+			Expect(os.Symlink("..2019_01_01", filepath.Join(dir, "..data"))).To(Succeed())
+			Expect(os.Symlink(filepath.Join("..data", "put-content.template"), filepath.Join(dir, "put-content.template"))).To(Succeed())
+
+			tmpl, err := LoadTemplate(DefaultTemplate(), dir)
+			Expect(err).NotTo(HaveOccurred())
+
+			script, err := NewGenerator(DefaultUnitsPath, tmpl).Generate(config)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(script)).To(ContainSubstring("cat << 'EOF'"))
+		})
+
+		It("should reject templates that cannot be parsed", func() {
+			writeFile("put-content.template", "{{ .Path ")
+			_, err := LoadTemplate(DefaultTemplate(), dir)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should reject templates that fail to render the canary config", func() {
+			writeFile("put-content.template", "{{ .Unknown }}")
+			_, err := LoadTemplate(DefaultTemplate(), dir)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should reject templates that do not write all units", func() {
+			writeFile("cloud-init.sh.template", "#!/bin/bash\n{{ range .Files }}{{ template \"put-content\" . }}{{ end }}\n")
+			_, err := LoadTemplate(DefaultTemplate(), dir)
+			Expect(err).To(MatchError(ContainSubstring("does not write")))
+		})
+
+		It("should reject templates that drop the bootstrap snippets", func() {
+			writeFile("cloud-init.sh.template", `#!/bin/bash
+{{- range .Files }}
+{{ template "put-content" . }}
+{{- end }}
+{{- range .Units }}
+{{- if .Content }}
+{{ template "put-content" .Content }}
+{{- end }}
+{{- if .DropIns }}
+{{- range .DropIns.Items }}
+{{ template "put-content" . }}
+{{- end }}
+{{- end }}
+{{- end }}
+`)
+			_, err := LoadTemplate(DefaultTemplate(), dir)
+			Expect(err).To(MatchError(ContainSubstring("does not contain the bootstrap snippets")))
+		})
+
+		It("should fail for missing paths", func() {
+			_, err := LoadTemplate(DefaultTemplate(), filepath.Join(dir, "missing"))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("#Template", func() {
+		var loader *TemplateLoader
+
+		render := func() string {
+			script, err := NewGenerator(DefaultUnitsPath, loader.Template()).Generate(config)
+			Expect(err).NotTo(HaveOccurred())
+			return string(script)
+		}
+
+		BeforeEach(func() {
+			loader = NewTemplateLoader(log.Log, DefaultTemplate(), dir, 0)
+		})
+
+		It("should reload changed templates and keep the last accepted one", func() {
+			Expect(render()).To(ContainSubstring("cat << EOF"))
+
+			writeFile("put-content.template", quotedPutContent)
+			Expect(render()).To(ContainSubstring("cat << 'EOF'"))
+
+			writeFile("put-content.template", "{{ .Unknown }}")
+			Expect(loader.Reload()).NotTo(Succeed())
+			Expect(render()).To(ContainSubstring("cat << 'EOF'"))
+
+			Expect(os.Remove(filepath.Join(dir, "put-content.template"))).To(Succeed())
+			Expect(render()).To(ContainSubstring("cat << EOF"))
+		})
+
+		It("should not reload before the interval passed", func() {
+			loader = NewTemplateLoader(log.Log, DefaultTemplate(), dir, time.Hour)
+			Expect(render()).To(ContainSubstring("cat << EOF"))
+
+			writeFile("put-content.template", quotedPutContent)
+			Expect(render()).To(ContainSubstring("cat << EOF"))
+			Expect(loader.Reload()).To(Succeed())
+			Expect(render()).To(ContainSubstring("cat << 'EOF'"))
+		})
+
+		It("should notify watchers about accepted templates", func() {
+			loader = NewTemplateLoader(log.Log, DefaultTemplate(), dir, 10*time.Millisecond)
+			changes := make(chan struct{}, 10)
+			stop := make(chan struct{})
+			defer close(stop)
+			go loader.Watch(stop, func() { changes <- struct{}{} })
+
+			Consistently(changes, 50*time.Millisecond).ShouldNot(Receive())
+
+			writeFile("put-content.template", quotedPutContent)
+			Eventually(changes).Should(Receive())
+			Expect(render()).To(ContainSubstring("cat << 'EOF'"))
+
+			writeFile("put-content.template", "{{ .Unknown }}")
+			Consistently(changes, 50*time.Millisecond).ShouldNot(Receive())
+		})
+
+		It("should keep the default template if the path is missing", func() {
+			loader = NewTemplateLoader(log.Log, DefaultTemplate(), filepath.Join(dir, "missing"), 0)
+			Expect(render()).To(ContainSubstring("cat << EOF"))
+		})
+	})
+})
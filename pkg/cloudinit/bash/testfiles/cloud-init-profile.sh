@@ -0,0 +1,10 @@
+#!/bin/bash
+set -euo pipefail
+systemctl mask 'update-engine.service'
+mkdir -p '/etc/docker'
+echo 'dcd803a8f84666229055745daa9b3782a2ea013463913fe2791a608898f63b6c  /etc/docker/daemon.json' | sha256sum --check --status 2>/dev/null || cat << EOF | base64 -d > '/etc/docker/.daemon.json.gardener-tmp'
+eyAic3RvcmFnZS1kcml2ZXIiOiAib3ZlcmxheTIiIH0K
+EOF
+echo 'dcd803a8f84666229055745daa9b3782a2ea013463913fe2791a608898f63b6c  /etc/docker/daemon.json' | sha256sum --check --status 2>/dev/null || chmod '0600' '/etc/docker/.daemon.json.gardener-tmp'
+echo 'dcd803a8f84666229055745daa9b3782a2ea013463913fe2791a608898f63b6c  /etc/docker/daemon.json' | sha256sum --check --status 2>/dev/null || mv -f '/etc/docker/.daemon.json.gardener-tmp' '/etc/docker/daemon.json'
+chmod '0600' '/etc/docker/daemon.json'
\ No newline at end of file
@@ -0,0 +1,20 @@
+#!/bin/bash
+set -euo pipefail
+
+echo '385cfdbc00ec32031699460779c15099b2bba3cad0e440fffb08e10df0acb9e1  /etc/systemd/system/kubelet.service' | sha256sum --check --status 2>/dev/null || cat << EOF | base64 -d > '/etc/systemd/system/.kubelet.service.gardener-tmp'
+dW5pdA==
+EOF
+echo '385cfdbc00ec32031699460779c15099b2bba3cad0e440fffb08e10df0acb9e1  /etc/systemd/system/kubelet.service' | sha256sum --check --status 2>/dev/null || mv -f '/etc/systemd/system/.kubelet.service.gardener-tmp' '/etc/systemd/system/kubelet.service'
+
+mkdir -p '/etc/systemd/system/foo.service.d'
+echo 'ce603774135699e9abdfd65eb1f2733774da58af91782528e82ef5f9efdb8fba  /etc/systemd/system/foo.service.d/10-foo.conf' | sha256sum --check --status 2>/dev/null || cat << EOF | base64 -d > '/etc/systemd/system/foo.service.d/.10-foo.conf.gardener-tmp'
+b3ZlcnJpZGU=
+EOF
+echo 'ce603774135699e9abdfd65eb1f2733774da58af91782528e82ef5f9efdb8fba  /etc/systemd/system/foo.service.d/10-foo.conf' | sha256sum --check --status 2>/dev/null || mv -f '/etc/systemd/system/foo.service.d/.10-foo.conf.gardener-tmp' '/etc/systemd/system/foo.service.d/10-foo.conf'
+
+systemctl daemon-reload
+systemctl 'enable' 'kubelet.service'
+systemctl 'start' 'kubelet.service'
+systemctl 'disable' 'update-engine.service'
+systemctl 'stop' 'update-engine.service'
+systemctl 'restart' 'docker.service'
\ No newline at end of file
@@ -0,0 +1,35 @@
+#!/bin/bash
+set -euo pipefail
+systemctl disable 'locksmithd.service'
+systemctl stop 'locksmithd.service'
+mkdir -p '/etc/docker'
+echo '63fda6c4d7c448f2eb6cfa0adfb1c03f510f2a471c9a51b2870a481f56ab6783  /etc/docker/daemon.json' | sha256sum --check --status 2>/dev/null || cat << EOF | base64 -d > '/etc/docker/.daemon.json.gardener-tmp'
+eyAic3RvcmFnZS1kcml2ZXIiOiAiZGV2aWNlbWFwcGVyIiB9Cg==
+EOF
+echo '63fda6c4d7c448f2eb6cfa0adfb1c03f510f2a471c9a51b2870a481f56ab6783  /etc/docker/daemon.json' | sha256sum --check --status 2>/dev/null || mv -f '/etc/docker/.daemon.json.gardener-tmp' '/etc/docker/daemon.json'
+sed -i '/Environment=DOCKER_SELINUX=--selinux-enabled=true/s/^/#/g' /run/systemd/system/docker.service
+
+mkdir -p '/'
+echo 'fcde2b2edba56bf408601fb721fe9b5c338d10ee429ea04fae5511b68fbf8fb9  /foo' | sha256sum --check --status 2>/dev/null || cat << EOF | base64 -d > '/.foo.gardener-tmp'
+YmFy
+EOF
+echo 'fcde2b2edba56bf408601fb721fe9b5c338d10ee429ea04fae5511b68fbf8fb9  /foo' | sha256sum --check --status 2>/dev/null || chmod '0600' '/.foo.gardener-tmp'
+echo 'fcde2b2edba56bf408601fb721fe9b5c338d10ee429ea04fae5511b68fbf8fb9  /foo' | sha256sum --check --status 2>/dev/null || mv -f '/.foo.gardener-tmp' '/foo'
+chmod '0600' '/foo'
+
+echo '385cfdbc00ec32031699460779c15099b2bba3cad0e440fffb08e10df0acb9e1  /etc/systemd/system/docker.service' | sha256sum --check --status 2>/dev/null || cat << EOF | base64 -d > '/etc/systemd/system/.docker.service.gardener-tmp'
+dW5pdA==
+EOF
+echo '385cfdbc00ec32031699460779c15099b2bba3cad0e440fffb08e10df0acb9e1  /etc/systemd/system/docker.service' | sha256sum --check --status 2>/dev/null || mv -f '/etc/systemd/system/.docker.service.gardener-tmp' '/etc/systemd/system/docker.service'
+
+mkdir -p '/etc/systemd/system/docker.service.d'
+echo 'ce603774135699e9abdfd65eb1f2733774da58af91782528e82ef5f9efdb8fba  /etc/systemd/system/docker.service.d/10-docker-opts.conf' | sha256sum --check --status 2>/dev/null || cat << EOF | base64 -d > '/etc/systemd/system/docker.service.d/.10-docker-opts.conf.gardener-tmp'
+b3ZlcnJpZGU=
+EOF
+echo 'ce603774135699e9abdfd65eb1f2733774da58af91782528e82ef5f9efdb8fba  /etc/systemd/system/docker.service.d/10-docker-opts.conf' | sha256sum --check --status 2>/dev/null || mv -f '/etc/systemd/system/docker.service.d/.10-docker-opts.conf.gardener-tmp' '/etc/systemd/system/docker.service.d/10-docker-opts.conf'
+
+echo "bootstrapped" > /var/lib/bootstrapped
+
+systemctl daemon-reload
+systemctl 'enable' 'docker.service'
+systemctl 'restart' 'docker.service'
\ No newline at end of file
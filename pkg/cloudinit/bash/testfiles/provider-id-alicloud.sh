@@ -0,0 +1,10 @@
+META_EP='http://100.100.100.200/latest/meta-data'
+REGION_ID=$(curl --silent --show-error --fail --retry 5 --retry-delay 2 --retry-connrefused --connect-timeout 5 --max-time 10 "$META_EP"/'region-id')
+INSTANCE_ID=$(curl --silent --show-error --fail --retry 5 --retry-delay 2 --retry-connrefused --connect-timeout 5 --max-time 10 "$META_EP"/'instance-id')
+PROVIDER_ID="${REGION_ID:?could not determine REGION_ID}.${INSTANCE_ID:?could not determine INSTANCE_ID}"
+DOWNLOAD_MAIN_PATH="${DOWNLOAD_MAIN_PATH:-/var/lib/cloud-config-downloader}"
+mkdir -p "$DOWNLOAD_MAIN_PATH"
+echo "PROVIDER_ID=$PROVIDER_ID" > "$DOWNLOAD_MAIN_PATH/provider-id"
+touch /etc/environment
+sed -i '/^PROVIDER_ID=/d' /etc/environment
+echo "PROVIDER_ID=$PROVIDER_ID" >> /etc/environment
@@ -0,0 +1,10 @@
+META_EP='http://169.254.169.254/latest/meta-data'
+ZONE=$(curl --silent --show-error --fail --retry 5 --retry-delay 2 --retry-connrefused --connect-timeout 5 --max-time 10 "$META_EP"/'placement/availability-zone')
+INSTANCE_ID=$(curl --silent --show-error --fail --retry 5 --retry-delay 2 --retry-connrefused --connect-timeout 5 --max-time 10 "$META_EP"/'instance-id')
+PROVIDER_ID="aws:///${ZONE:?could not determine ZONE}/${INSTANCE_ID:?could not determine INSTANCE_ID}"
+DOWNLOAD_MAIN_PATH="${DOWNLOAD_MAIN_PATH:-/var/lib/cloud-config-downloader}"
+mkdir -p "$DOWNLOAD_MAIN_PATH"
+echo "PROVIDER_ID=$PROVIDER_ID" > "$DOWNLOAD_MAIN_PATH/provider-id"
+touch /etc/environment
+sed -i '/^PROVIDER_ID=/d' /etc/environment
+echo "PROVIDER_ID=$PROVIDER_ID" >> /etc/environment
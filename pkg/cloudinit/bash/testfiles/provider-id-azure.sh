@@ -0,0 +1,11 @@
+META_EP='http://169.254.169.254/metadata/instance/compute'
+SUBSCRIPTION_ID=$(curl --silent --show-error --fail --retry 5 --retry-delay 2 --retry-connrefused --connect-timeout 5 --max-time 10 --header 'Metadata: true' "$META_EP"/'subscriptionId?api-version=2019-03-11&format=text')
+RESOURCE_GROUP=$(curl --silent --show-error --fail --retry 5 --retry-delay 2 --retry-connrefused --connect-timeout 5 --max-time 10 --header 'Metadata: true' "$META_EP"/'resourceGroupName?api-version=2019-03-11&format=text')
+VM_NAME=$(curl --silent --show-error --fail --retry 5 --retry-delay 2 --retry-connrefused --connect-timeout 5 --max-time 10 --header 'Metadata: true' "$META_EP"/'name?api-version=2019-03-11&format=text')
+PROVIDER_ID="azure:///subscriptions/${SUBSCRIPTION_ID:?could not determine SUBSCRIPTION_ID}/resourceGroups/${RESOURCE_GROUP:?could not determine RESOURCE_GROUP}/providers/Microsoft.Compute/virtualMachines/${VM_NAME:?could not determine VM_NAME}"
+DOWNLOAD_MAIN_PATH="${DOWNLOAD_MAIN_PATH:-/var/lib/cloud-config-downloader}"
+mkdir -p "$DOWNLOAD_MAIN_PATH"
+echo "PROVIDER_ID=$PROVIDER_ID" > "$DOWNLOAD_MAIN_PATH/provider-id"
+touch /etc/environment
+sed -i '/^PROVIDER_ID=/d' /etc/environment
+echo "PROVIDER_ID=$PROVIDER_ID" >> /etc/environment
@@ -0,0 +1,11 @@
+META_EP='http://metadata.google.internal/computeMetadata/v1'
+PROJECT_ID=$(curl --silent --show-error --fail --retry 5 --retry-delay 2 --retry-connrefused --connect-timeout 5 --max-time 10 --header 'Metadata-Flavor: Google' "$META_EP"/'project/project-id')
+ZONE=$(curl --silent --show-error --fail --retry 5 --retry-delay 2 --retry-connrefused --connect-timeout 5 --max-time 10 --header 'Metadata-Flavor: Google' "$META_EP"/'instance/zone' | sed -n -E 's/^.*\/([^\/]+)$/\1/p')
+INSTANCE_NAME=$(curl --silent --show-error --fail --retry 5 --retry-delay 2 --retry-connrefused --connect-timeout 5 --max-time 10 --header 'Metadata-Flavor: Google' "$META_EP"/'instance/name')
+PROVIDER_ID="gce://${PROJECT_ID:?could not determine PROJECT_ID}/${ZONE:?could not determine ZONE}/${INSTANCE_NAME:?could not determine INSTANCE_NAME}"
+DOWNLOAD_MAIN_PATH="${DOWNLOAD_MAIN_PATH:-/var/lib/cloud-config-downloader}"
+mkdir -p "$DOWNLOAD_MAIN_PATH"
+echo "PROVIDER_ID=$PROVIDER_ID" > "$DOWNLOAD_MAIN_PATH/provider-id"
+touch /etc/environment
+sed -i '/^PROVIDER_ID=/d' /etc/environment
+echo "PROVIDER_ID=$PROVIDER_ID" >> /etc/environment
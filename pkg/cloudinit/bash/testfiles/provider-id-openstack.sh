@@ -0,0 +1,9 @@
+META_EP='http://169.254.169.254/openstack/latest'
+INSTANCE_ID=$(curl --silent --show-error --fail --retry 5 --retry-delay 2 --retry-connrefused --connect-timeout 5 --max-time 10 "$META_EP"/'meta_data.json' | sed -n -E 's/^.*"uuid": *"([^"]+)".*$/\1/p')
+PROVIDER_ID="openstack:///${INSTANCE_ID:?could not determine INSTANCE_ID}"
+DOWNLOAD_MAIN_PATH="${DOWNLOAD_MAIN_PATH:-/var/lib/cloud-config-downloader}"
+mkdir -p "$DOWNLOAD_MAIN_PATH"
+echo "PROVIDER_ID=$PROVIDER_ID" > "$DOWNLOAD_MAIN_PATH/provider-id"
+touch /etc/environment
+sed -i '/^PROVIDER_ID=/d' /etc/environment
+echo "PROVIDER_ID=$PROVIDER_ID" >> /etc/environment
@@ -0,0 +1,342 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cloudconfig renders OperatingSystemConfigs as standard cloud-init `#cloud-config`
+// documents or multipart user-data, as understood by the cloud-init of Ubuntu or SUSE CHost images.
+package cloudconfig
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gardener/gardener-extensions/pkg/cloudinit"
+	"github.com/gardener/gardener-extensions/pkg/controller/operatingsystemconfig"
+	"github.com/gardener/gardener-extensions/pkg/shell"
+	"github.com/gardener/gardener-extensions/pkg/systemd"
+
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+	yaml "gopkg.in/yaml.v2"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// UnitsPath is the path units and their drop-ins are written to.
+	UnitsPath = "/etc/systemd/system"
+	// ReloadScriptPath is the path of the script applying a cloud-config on a running machine.
+	ReloadScriptPath = "/usr/local/sbin/gardener-reload-cloud-config"
+	// CompressionThreshold is the size in bytes above which file contents are compressed.
+	CompressionThreshold = 4 * 1024
+)
+
+// reloadScript applies the cloud-config at the given path with the modules of cloud-init. Files
+// given with `--file` take precedence over the user-data of the instance. The script is wrapped
+// in a function, so bash has read it completely before it is overwritten by `write_files`.
+const reloadScript = `#!/bin/bash
+set -euo pipefail
+
+reload() {
+  exec 9>/run/lock/gardener-reload-cloud-config.lock
+  flock --wait 300 9
+
+  cloud-init --file "$1" single --name write_files --frequency always
+  cloud-init --file "$1" single --name runcmd --frequency always
+  /var/lib/cloud/instance/scripts/runcmd
+}
+
+reload "$@"; exit
+`
+
+// unitCommands are the systemctl commands that may be run for units.
+var unitCommands = sets.NewString("start", "stop", "restart", "reload", "try-restart", "reload-or-restart")
+
+// CloudConfig is a standard cloud-init `#cloud-config` document. It can be marshalled to YAML.
+type CloudConfig struct {
+	// WriteFiles are the files cloud-init writes.
+	WriteFiles []File `yaml:"write_files,omitempty"`
+	// RunCmd are the shell commands cloud-init runs after all files have been written.
+	RunCmd []string `yaml:"runcmd,omitempty"`
+}
+
+// File is a file of a CloudConfig.
+type File struct {
+	// Path is the absolute path of the file.
+	Path string `yaml:"path"`
+	// Permissions are the octal permissions of the file, e.g. `0644`.
+	Permissions string `yaml:"permissions,omitempty"`
+	// Encoding is the encoding of the content, e.g. `b64` or `gzip+base64`.
+	Encoding string `yaml:"encoding,omitempty"`
+	// Content is the encoded content of the file.
+	Content string `yaml:"content"`
+}
+
+// String returns the `#cloud-config` document of the CloudConfig.
+func (c *CloudConfig) String() (string, error) {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return "#cloud-config\n" + string(data), nil
+}
+
+// addFile adds a file with the given content and permissions. Contents larger than the
+// CompressionThreshold are compressed (`gzip+base64`), other contents are kept as they are if they
+// are valid UTF-8 and base64 encoded (`b64`) otherwise.
+func (c *CloudConfig) addFile(p string, data []byte, permissions int32) error {
+	if !path.IsAbs(p) || strings.IndexFunc(p, unicode.IsControl) >= 0 {
+		return fmt.Errorf("invalid file path %q", p)
+	}
+
+	file := File{Path: p, Permissions: fmt.Sprintf("%#o", permissions)}
+	switch {
+	case len(data) > CompressionThreshold:
+		encoded, err := cloudinit.GZIPB64FileCodec.Encode(data)
+		if err != nil {
+			return err
+		}
+		file.Encoding, file.Content = "gzip+base64", string(encoded)
+	case utf8.Valid(data):
+		file.Content = string(data)
+	default:
+		encoded, err := cloudinit.B64FileCodec.Encode(data)
+		if err != nil {
+			return err
+		}
+		file.Encoding, file.Content = "b64", string(encoded)
+	}
+	c.WriteFiles = append(c.WriteFiles, file)
+	return nil
+}
+
+// unitCommand returns the systemctl command with the given verb for the given unit.
+func unitCommand(verb, unit string) string {
+	return fmt.Sprintf("systemctl %s %s", verb, shell.Quote(unit))
+}
+
+func filePermissions(permissions *int32) int32 {
+	if permissions != nil {
+		return *permissions
+	}
+	return extensionsv1alpha1.OperatingSystemConfigDefaultFilePermission
+}
+
+// MultipartConfiguration configures the rendering of provisioning configs as multipart user-data.
+type MultipartConfiguration struct {
+	// MaxPartSize is the maximum size in bytes of the `#cloud-config` parts. Larger configs are
+	// split with cloudinit.SplitCloudConfig. Zero keeps the config in a single part.
+	MaxPartSize int `json:"maxPartSize,omitempty"`
+	// Gzip compresses the multipart user-data.
+	Gzip bool `json:"gzip,omitempty"`
+}
+
+// Validate validates the MultipartConfiguration.
+func (m *MultipartConfiguration) Validate() error {
+	if m.MaxPartSize < 0 {
+		return fmt.Errorf("maxPartSize must not be negative")
+	}
+	return nil
+}
+
+// RendererOptions are options for the creation of a Renderer.
+type RendererOptions struct {
+	// BaseProfile is the base profile applied when provisioning machines. Defaults to an empty profile.
+	BaseProfile *operatingsystemconfig.BaseProfile
+	// Multipart renders provisioning configs as multipart user-data if set. Reconcile configs are
+	// always rendered as single `#cloud-config` document, as the reload script applies them with
+	// `cloud-init --file`.
+	Multipart *MultipartConfiguration
+}
+
+// Renderer is an operatingsystemconfig.Renderer rendering standard cloud-init `#cloud-config`
+// documents or multipart user-data.
+type Renderer struct {
+	baseProfile *operatingsystemconfig.BaseProfile
+	multipart   *MultipartConfiguration
+}
+
+var _ operatingsystemconfig.Renderer = &Renderer{}
+
+// NewRenderer creates a new Renderer with the given options.
+func NewRenderer(opts RendererOptions) *Renderer {
+	baseProfile := opts.BaseProfile
+	if baseProfile == nil {
+		baseProfile = &operatingsystemconfig.BaseProfile{}
+	}
+	return &Renderer{baseProfile: baseProfile, multipart: opts.Multipart}
+}
+
+// Render implements operatingsystemconfig.Renderer.
+func (r *Renderer) Render(_ context.Context, config *extensionsv1alpha1.OperatingSystemConfig, files map[string][]byte) ([]byte, error) {
+	doc, err := documentFromOperatingSystemConfig(config, files, r.baseProfile)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.multipart != nil && config.Spec.Purpose == extensionsv1alpha1.OperatingSystemConfigPurposeProvision {
+		return doc.multipart(r.multipart)
+	}
+	return doc.cloudConfig()
+}
+
+// document is a rendered OperatingSystemConfig before it is assembled into user-data.
+type document struct {
+	// files is a cloud-config only writing the files.
+	files *CloudConfig
+	// bootCommands are the commands of the base profile that mask and disable units.
+	bootCommands []string
+	// commands are the commands run after all files have been written.
+	commands []string
+}
+
+// cloudConfig assembles the document into a single `#cloud-config` document that runs all commands
+// with `runcmd`.
+func (d *document) cloudConfig() ([]byte, error) {
+	cloudConfig := *d.files
+	cloudConfig.RunCmd = append(append([]string{}, d.bootCommands...), d.commands...)
+
+	data, err := cloudConfig.String()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(data), nil
+}
+
+// multipart assembles the document into multipart user-data. The boot commands are run by a boothook
+// before the units start, the files are written by `#cloud-config` parts and the other commands are
+// run by a shell script afterwards.
+func (d *document) multipart(config *MultipartConfiguration) ([]byte, error) {
+	m := &cloudinit.Multipart{Gzip: config.Gzip}
+
+	if len(d.bootCommands) > 0 {
+		m.Parts = append(m.Parts, cloudinit.Part{
+			ContentType: cloudinit.ContentTypeBoothook,
+			Content:     []byte("#cloud-boothook\n#!/bin/sh\n" + strings.Join(d.bootCommands, "\n") + "\n"),
+		})
+	}
+
+	files, err := d.files.String()
+	if err != nil {
+		return nil, err
+	}
+	if config.MaxPartSize > 0 {
+		parts, err := cloudinit.SplitCloudConfig([]byte(files), config.MaxPartSize)
+		if err != nil {
+			return nil, err
+		}
+		m.Parts = append(m.Parts, parts...)
+	} else {
+		m.Parts = append(m.Parts, cloudinit.Part{ContentType: cloudinit.ContentTypeCloudConfig, Content: []byte(files)})
+	}
+
+	m.Parts = append(m.Parts, cloudinit.Part{
+		ContentType: cloudinit.ContentTypeShellScript,
+		Content:     []byte("#!/bin/sh\n" + strings.Join(d.commands, "\n") + "\n"),
+	})
+
+	return m.Encode()
+}
+
+// documentFromOperatingSystemConfig renders the given config. Units and drop-ins are written as
+// files below UnitsPath and enabled and started after systemd has been reloaded. The base profile
+// is only applied when provisioning a machine, files of the config take precedence over the files
+// of the profile.
+func documentFromOperatingSystemConfig(config *extensionsv1alpha1.OperatingSystemConfig, filesData map[string][]byte, baseProfile *operatingsystemconfig.BaseProfile) (*document, error) {
+	var (
+		doc       = &document{files: &CloudConfig{}}
+		provision = config.Spec.Purpose == extensionsv1alpha1.OperatingSystemConfigPurposeProvision
+	)
+
+	if err := doc.files.addFile(ReloadScriptPath, []byte(reloadScript), 0755); err != nil {
+		return nil, err
+	}
+
+	if provision {
+		paths := sets.NewString()
+		for _, file := range config.Spec.Files {
+			paths.Insert(file.Path)
+		}
+		for _, file := range baseProfile.Files {
+			if paths.Has(file.Path) {
+				continue
+			}
+			if err := doc.files.addFile(file.Path, []byte(file.Content), filePermissions(file.Permissions)); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, name := range baseProfile.MaskedUnits {
+			if err := systemd.ValidateUnitName(name); err != nil {
+				return nil, err
+			}
+			doc.bootCommands = append(doc.bootCommands, unitCommand("mask", name))
+		}
+		for _, name := range baseProfile.DisabledUnits {
+			if err := systemd.ValidateUnitName(name); err != nil {
+				return nil, err
+			}
+			doc.bootCommands = append(doc.bootCommands, unitCommand("disable", name), unitCommand("stop", name))
+		}
+		doc.commands = append(doc.commands, baseProfile.Commands...)
+	}
+
+	for _, file := range config.Spec.Files {
+		if err := doc.files.addFile(file.Path, filesData[file.Path], filePermissions(file.Permissions)); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, unit := range config.Spec.Units {
+		if err := systemd.ValidateUnitName(unit.Name); err != nil {
+			return nil, err
+		}
+		if unit.Command != nil && !unitCommands.Has(*unit.Command) {
+			return nil, fmt.Errorf("unit %q: unsupported command %q", unit.Name, *unit.Command)
+		}
+
+		if unit.Content != nil {
+			if err := doc.files.addFile(path.Join(UnitsPath, unit.Name), []byte(*unit.Content), 0644); err != nil {
+				return nil, err
+			}
+		}
+		for _, dropIn := range unit.DropIns {
+			if err := systemd.ValidateDropInName(dropIn.Name); err != nil {
+				return nil, fmt.Errorf("unit %q: %v", unit.Name, err)
+			}
+			if err := doc.files.addFile(path.Join(UnitsPath, unit.Name+".d", dropIn.Name), []byte(dropIn.Content), 0644); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// systemd is always reloaded, so `runcmd` is never empty and the reload script does not fall
+	// back to the commands of the user-data of the instance.
+	doc.commands = append(doc.commands, "systemctl daemon-reload")
+	for _, unit := range config.Spec.Units {
+		if unit.Enable != nil {
+			if *unit.Enable {
+				doc.commands = append(doc.commands, unitCommand("enable", unit.Name))
+			} else {
+				doc.commands = append(doc.commands, unitCommand("disable", unit.Name))
+			}
+		}
+		if unit.Command != nil {
+			doc.commands = append(doc.commands, unitCommand(*unit.Command, unit.Name))
+		}
+	}
+
+	return doc, nil
+}
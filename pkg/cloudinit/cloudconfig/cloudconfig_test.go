@@ -0,0 +1,196 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudconfig_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+
+	"github.com/gardener/gardener-extensions/pkg/cloudinit"
+	"github.com/gardener/gardener-extensions/pkg/cloudinit/cloudconfig"
+	"github.com/gardener/gardener-extensions/pkg/controller/operatingsystemconfig"
+	"github.com/gardener/gardener-extensions/pkg/simulator"
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+var _ = Describe("Renderer", func() {
+	var (
+		ctx    = context.TODO()
+		config *extensionsv1alpha1.OperatingSystemConfig
+		files  map[string][]byte
+	)
+
+	BeforeEach(func() {
+		config = &extensionsv1alpha1.OperatingSystemConfig{
+			Spec: extensionsv1alpha1.OperatingSystemConfigSpec{
+				Purpose: extensionsv1alpha1.OperatingSystemConfigPurposeProvision,
+				Units: []extensionsv1alpha1.Unit{
+					{
+						Name:    "kubelet.service",
+						Enable:  boolPtr(true),
+						Command: strPtr("start"),
+						Content: strPtr("[Service]\nExecStart=/opt/bin/kubelet\n"),
+						DropIns: []extensionsv1alpha1.DropIn{{Name: "10-env.conf", Content: "[Service]\nEnvironment=A=B\n"}},
+					},
+				},
+				Files: []extensionsv1alpha1.File{{Path: "/opt/bin/kubelet"}},
+			},
+		}
+		files = map[string][]byte{"/opt/bin/kubelet": []byte("#!/bin/bash\n")}
+	})
+
+	render := func(profile *operatingsystemconfig.BaseProfile) string {
+		result, err := cloudconfig.NewRenderer(cloudconfig.RendererOptions{BaseProfile: profile}).Render(ctx, config, files)
+		Expect(err).NotTo(HaveOccurred())
+		return string(result)
+	}
+
+	apply := func(userData []byte) *simulator.Manifest {
+		root, err := ioutil.TempDir("", "cloud-config")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(root)
+
+		sim := simulator.New(root)
+		Expect(sim.Apply(simulator.FormatCloudInit, userData)).To(Succeed())
+
+		manifest, err := sim.Manifest()
+		Expect(err).NotTo(HaveOccurred())
+		return manifest
+	}
+
+	It("should render a cloud-config applying the files and units", func() {
+		result := render(nil)
+		Expect(result).To(HavePrefix("#cloud-config\n"))
+
+		manifest := apply([]byte(result))
+		Expect(manifest.Files).To(HaveKeyWithValue("/opt/bin/kubelet", simulator.File{Content: "#!/bin/bash\n", Permissions: 0644}))
+		Expect(manifest.Files).To(HaveKey(cloudconfig.ReloadScriptPath))
+		Expect(manifest.Units).To(HaveKey("kubelet.service"))
+		Expect(manifest.Units["kubelet.service"].DropIns).To(Equal(map[string]string{"10-env.conf": "[Service]\nEnvironment=A=B\n"}))
+		Expect(manifest.VerifyUnitStates(&config.Spec)).To(Succeed())
+	})
+
+	It("should compress large files", func() {
+		files["/opt/bin/kubelet"] = make([]byte, cloudconfig.CompressionThreshold+1)
+		Expect(render(nil)).To(ContainSubstring("encoding: gzip+base64"))
+	})
+
+	It("should only apply the base profile when provisioning", func() {
+		profile := &operatingsystemconfig.BaseProfile{DisabledUnits: []string{"apt-daily.timer"}}
+		Expect(render(profile)).To(ContainSubstring("- systemctl disable 'apt-daily.timer'\n"))
+
+		config.Spec.Purpose = extensionsv1alpha1.OperatingSystemConfigPurposeReconcile
+		Expect(render(profile)).NotTo(ContainSubstring("apt-daily.timer"))
+	})
+
+	It("should reject invalid configs", func() {
+		for _, mutate := range []func(*extensionsv1alpha1.OperatingSystemConfig){
+			func(c *extensionsv1alpha1.OperatingSystemConfig) { c.Spec.Units[0].Command = strPtr("kill") },
+			func(c *extensionsv1alpha1.OperatingSystemConfig) { c.Spec.Units[0].Name = "kubelet" },
+			func(c *extensionsv1alpha1.OperatingSystemConfig) { c.Spec.Units[0].DropIns[0].Name = "10-env" },
+			func(c *extensionsv1alpha1.OperatingSystemConfig) { c.Spec.Files[0].Path = "relative" },
+		} {
+			invalid := config.DeepCopy()
+			mutate(invalid)
+			_, err := cloudconfig.NewRenderer(cloudconfig.RendererOptions{}).Render(ctx, invalid, files)
+			Expect(err).To(HaveOccurred())
+		}
+	})
+
+	Describe("multipart", func() {
+		var (
+			profile   = &operatingsystemconfig.BaseProfile{DisabledUnits: []string{"apt-daily.timer"}, Commands: []string{"echo provisioned"}}
+			multipart *cloudconfig.MultipartConfiguration
+		)
+
+		BeforeEach(func() {
+			multipart = &cloudconfig.MultipartConfiguration{}
+		})
+
+		renderMultipart := func() []byte {
+			result, err := cloudconfig.NewRenderer(cloudconfig.RendererOptions{BaseProfile: profile, Multipart: multipart}).Render(ctx, config, files)
+			Expect(err).NotTo(HaveOccurred())
+			return result
+		}
+
+		contentTypes := func(m *cloudinit.Multipart) []string {
+			var out []string
+			for _, part := range m.Parts {
+				out = append(out, part.ContentType)
+			}
+			return out
+		}
+
+		It("should render a boothook, a cloud-config and a script part", func() {
+			result := renderMultipart()
+
+			m, err := cloudinit.ParseMultipart(result)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(contentTypes(m)).To(Equal([]string{cloudinit.ContentTypeBoothook, cloudinit.ContentTypeCloudConfig, cloudinit.ContentTypeShellScript}))
+			Expect(string(m.Parts[0].Content)).To(Equal("#cloud-boothook\n#!/bin/sh\nsystemctl disable 'apt-daily.timer'\nsystemctl stop 'apt-daily.timer'\n"))
+			Expect(string(m.Parts[1].Content)).NotTo(ContainSubstring("\nruncmd:"))
+			Expect(string(m.Parts[2].Content)).To(Equal("#!/bin/sh\necho provisioned\nsystemctl daemon-reload\nsystemctl enable 'kubelet.service'\nsystemctl start 'kubelet.service'\n"))
+
+			manifest := apply(result)
+			Expect(manifest.Files).To(HaveKeyWithValue("/opt/bin/kubelet", simulator.File{Content: "#!/bin/bash\n", Permissions: 0644}))
+			Expect(manifest.Units["kubelet.service"].DropIns).To(Equal(map[string]string{"10-env.conf": "[Service]\nEnvironment=A=B\n"}))
+			Expect(manifest.VerifyUnitStates(&config.Spec)).To(Succeed())
+		})
+
+		It("should split the files across compressed cloud-config parts", func() {
+			multipart.MaxPartSize = 512
+			multipart.Gzip = true
+			result := renderMultipart()
+
+			m, err := cloudinit.ParseMultipart(result)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(m.Gzip).To(BeTrue())
+			Expect(len(m.Parts)).To(BeNumerically(">", 3))
+			for _, part := range m.Parts[1 : len(m.Parts)-1] {
+				Expect(part.ContentType).To(Equal(cloudinit.ContentTypeCloudConfig))
+				Expect(part.MergeType).To(Equal(cloudinit.DefaultMergeType))
+			}
+
+			manifest := apply(result)
+			Expect(manifest.Files).To(HaveKey(cloudconfig.ReloadScriptPath))
+			Expect(manifest.Files).To(HaveKey("/opt/bin/kubelet"))
+			Expect(manifest.VerifyUnitStates(&config.Spec)).To(Succeed())
+		})
+
+		It("should render reconcile configs as single cloud-config", func() {
+			config.Spec.Purpose = extensionsv1alpha1.OperatingSystemConfigPurposeReconcile
+			Expect(string(renderMultipart())).To(HavePrefix("#cloud-config\n"))
+		})
+
+		It("should omit the boothook without units to mask or disable", func() {
+			profile = &operatingsystemconfig.BaseProfile{}
+			m, err := cloudinit.ParseMultipart(renderMultipart())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(contentTypes(m)).To(Equal([]string{cloudinit.ContentTypeCloudConfig, cloudinit.ContentTypeShellScript}))
+		})
+	})
+})
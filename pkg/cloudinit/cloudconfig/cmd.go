@@ -0,0 +1,75 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudconfig
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/gardener/gardener-extensions/pkg/controller/operatingsystemconfig"
+
+	"github.com/go-logr/logr"
+	"github.com/spf13/cobra"
+)
+
+// NewActuatorFunc creates an actuator with the given logger and the given parsed Configuration.
+type NewActuatorFunc func(logr.Logger, *Configuration) operatingsystemconfig.Actuator
+
+// ActuatorFactory returns an operatingsystemconfig.ActuatorFactory that parses the Configuration
+// of the actuator and creates the actuator with the given function.
+func ActuatorFactory(newActuator NewActuatorFunc) operatingsystemconfig.ActuatorFactory {
+	return func(args *operatingsystemconfig.ActuatorArgs) (operatingsystemconfig.Actuator, error) {
+		config, err := ParseConfiguration(args.Config)
+		if err != nil {
+			return nil, err
+		}
+		return newActuator(args.Log, config), nil
+	}
+}
+
+// NewControllerCommand creates a new command for running the controller with the given name for
+// OperatingSystemConfigs of the given type. The actuators are created with the given function.
+func NewControllerCommand(ctx context.Context, name, typeName string, newActuator NewActuatorFunc) *cobra.Command {
+	opts := operatingsystemconfig.NewCommandOptions(name, typeName, ActuatorFactory(newActuator))
+	opts.Manager.LeaderElection = true
+	opts.Manager.LeaderElectionNamespace = os.Getenv("LEADER_ELECTION_NAMESPACE")
+
+	cmd := &cobra.Command{
+		Use: fmt.Sprintf("%s-controller-manager", name),
+
+		Run: func(cmd *cobra.Command, args []string) {
+			c, err := opts.Config()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				os.Exit(1)
+			}
+
+			if err := operatingsystemconfig.Run(ctx, c.Complete()); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	fs := cmd.Flags()
+	for _, f := range opts.Flags().FlagSets {
+		fs.AddFlagSet(f)
+	}
+
+	cmd.AddCommand(operatingsystemconfig.NewRenderCommand(ctx, opts.Controller))
+
+	return cmd
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudconfig
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/gardener/gardener-extensions/pkg/controller/operatingsystemconfig"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Configuration is the actuator specific section of the controller configuration file of the
+// operating systems bootstrapped with cloud-init.
+type Configuration struct {
+	// BaseProfile is the base profile applied to the machines at bootstrap. Fields that are not
+	// set keep their default values.
+	BaseProfile *operatingsystemconfig.BaseProfile `json:"baseProfile,omitempty"`
+	// ContainerRuntime is the container runtime of the machines. Fields that are not set keep
+	// their default values.
+	ContainerRuntime *operatingsystemconfig.ContainerRuntimeConfiguration `json:"containerRuntime,omitempty"`
+	// ReloadCommand is the command nodes use to apply the cloud-config, the quoted path of the
+	// config is appended as last argument. Defaults to ReloadScriptPath.
+	ReloadCommand string `json:"reloadCommand,omitempty"`
+	// Multipart renders the provisioning configs as multipart user-data if set.
+	Multipart *MultipartConfiguration `json:"multipart,omitempty"`
+}
+
+// ParseConfiguration decodes and validates the given Configuration. An empty input results in an
+// empty Configuration.
+func ParseConfiguration(data json.RawMessage) (*Configuration, error) {
+	config := &Configuration{}
+	if len(data) == 0 {
+		return config, nil
+	}
+
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, fmt.Errorf("could not decode actuator configuration: %v", err)
+	}
+	if config.BaseProfile != nil {
+		if err := config.BaseProfile.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if config.ContainerRuntime != nil {
+		if err := config.ContainerRuntime.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if config.Multipart != nil {
+		if err := config.Multipart.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
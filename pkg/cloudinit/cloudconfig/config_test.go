@@ -0,0 +1,51 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudconfig_test
+
+import (
+	"github.com/gardener/gardener-extensions/pkg/cloudinit/cloudconfig"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Configuration", func() {
+	Describe("#ParseConfiguration", func() {
+		It("should return an empty configuration for an empty input", func() {
+			config, err := cloudconfig.ParseConfiguration(nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config).To(Equal(&cloudconfig.Configuration{}))
+		})
+
+		It("should decode the configuration", func() {
+			config, err := cloudconfig.ParseConfiguration([]byte("reloadCommand: /opt/bin/reload\nmultipart:\n  maxPartSize: 1024\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config).To(Equal(&cloudconfig.Configuration{
+				ReloadCommand: "/opt/bin/reload",
+				Multipart:     &cloudconfig.MultipartConfiguration{MaxPartSize: 1024},
+			}))
+		})
+
+		It("should reject unknown fields", func() {
+			_, err := cloudconfig.ParseConfiguration([]byte("reload: /opt/bin/reload\n"))
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should reject invalid configurations", func() {
+			_, err := cloudconfig.ParseConfiguration([]byte("multipart:\n  maxPartSize: -1\n"))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,181 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudinit
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+)
+
+// FileCodecID is the id of a FileCodec for cloud-init scripts. IDs of the form `a+b` denote the
+// pipeline of the codecs `a` and `b`: data is encoded with `a` first and then with `b`, and
+// decoded in reverse order.
+type FileCodecID string
+
+const (
+	// B64FileCodecID is the base64 file codec id.
+	B64FileCodecID FileCodecID = "b64"
+	// GZIPFileCodecID is the gzip file codec id.
+	GZIPFileCodecID FileCodecID = "gzip"
+	// BZIP2FileCodecID is the bzip2 file codec id. The bzip2 FileCodec can only decode data.
+	BZIP2FileCodecID FileCodecID = "bzip2"
+	// GZIPB64FileCodecID is the gzip combined with base64 codec id.
+	GZIPB64FileCodecID FileCodecID = "gzip+b64"
+)
+
+// FileCodec is a codec to en- and decode data in cloud-init scripts with.
+type FileCodec interface {
+	Encode([]byte) ([]byte, error)
+	Decode([]byte) ([]byte, error)
+}
+
+var (
+	// B64FileCodec is the base64 FileCodec.
+	B64FileCodec FileCodec = b64FileCodec{}
+	// GZIPFileCodec is the gzip FileCodec.
+	GZIPFileCodec FileCodec = gzipFileCodec{}
+	// BZIP2FileCodec is the bzip2 FileCodec. It can only decode data.
+	BZIP2FileCodec FileCodec = NewDecodeOnlyFileCodec(BZIP2FileCodecID, decodeBZIP2)
+	// GZIPB64FileCodec is the FileCodec compressing with gzip and encoding the result with base64.
+	GZIPB64FileCodec FileCodec = fileCodecPipeline{GZIPFileCodec, B64FileCodec}
+)
+
+type b64FileCodec struct{}
+
+var encoding = base64.StdEncoding
+
+func (b64FileCodec) Encode(data []byte) ([]byte, error) {
+	dst := make([]byte, encoding.EncodedLen(len(data)))
+	encoding.Encode(dst, data)
+	return dst, nil
+}
+
+func (b64FileCodec) Decode(data []byte) ([]byte, error) {
+	dst := make([]byte, encoding.DecodedLen(len(data)))
+	n, err := encoding.Decode(dst, data)
+	if err != nil {
+		return nil, err
+	}
+	return dst[:n], nil
+}
+
+type gzipFileCodec struct{}
+
+func (gzipFileCodec) Encode(data []byte) ([]byte, error) {
+	var out bytes.Buffer
+	w := gzip.NewWriter(&out)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func (gzipFileCodec) Decode(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = r.Close() }()
+	return ioutil.ReadAll(r)
+}
+
+func decodeBZIP2(data []byte) ([]byte, error) {
+	return ioutil.ReadAll(bzip2.NewReader(bytes.NewReader(data)))
+}
+
+type decodeOnlyFileCodec struct {
+	id     FileCodecID
+	decode func([]byte) ([]byte, error)
+}
+
+// NewDecodeOnlyFileCodec creates a new FileCodec with the given decode function whose Encode
+// method always fails. It is meant for formats that are accepted, but never produced.
+func NewDecodeOnlyFileCodec(id FileCodecID, decode func([]byte) ([]byte, error)) FileCodec {
+	return decodeOnlyFileCodec{id, decode}
+}
+
+func (c decodeOnlyFileCodec) Encode([]byte) ([]byte, error) {
+	return nil, fmt.Errorf("file codec %q does not support encoding", c.id)
+}
+
+func (c decodeOnlyFileCodec) Decode(data []byte) ([]byte, error) {
+	return c.decode(data)
+}
+
+// fileCodecPipeline encodes data with all its codecs in order and decodes it in reverse order.
+type fileCodecPipeline []FileCodec
+
+func (p fileCodecPipeline) Encode(data []byte) ([]byte, error) {
+	for _, codec := range p {
+		var err error
+		if data, err = codec.Encode(data); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+func (p fileCodecPipeline) Decode(data []byte) ([]byte, error) {
+	for i := len(p) - 1; i >= 0; i-- {
+		var err error
+		if data, err = p[i].Decode(data); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// invalidFileCodec is returned for FileCodecIDs that cannot be resolved, so callers fail instead
+// of panicking.
+type invalidFileCodec struct {
+	err error
+}
+
+func (c invalidFileCodec) Encode([]byte) ([]byte, error) {
+	return nil, c.err
+}
+
+func (c invalidFileCodec) Decode([]byte) ([]byte, error) {
+	return nil, c.err
+}
+
+// Decode decodes the given data using the codec from resolving the given codecIDString.
+// It's a shorthand for parsing the FileCodecID and calling the `Decode` method on the obtained
+// FileCodec.
+func Decode(codecIDString string, data []byte) ([]byte, error) {
+	id, err := ParseFileCodecID(codecIDString)
+	if err != nil {
+		return nil, err
+	}
+
+	return FileCodecForID(id).Decode(data)
+}
+
+// Encode encodes the given data using the codec from resolving the given codecIDString.
+func Encode(codecIDString string, data []byte) ([]byte, error) {
+	id, err := ParseFileCodecID(codecIDString)
+	if err != nil {
+		return nil, err
+	}
+
+	return FileCodecForID(id).Encode(data)
+}
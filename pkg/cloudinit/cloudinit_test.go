@@ -0,0 +1,169 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudinit_test
+
+import (
+	"bytes"
+	"math/rand"
+	"testing/quick"
+
+	. "github.com/gardener/gardener-extensions/pkg/cloudinit"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// bzip2Foo is "foo\n" compressed with bzip2 and encoded with base64.
+const bzip2Foo = "QlpoOTFBWSZTWXb2s+UAAADBAAAQAQCgACGYGYQYXckU4UJB29rPlA=="
+
+var (
+	encodableIDs = []string{"b64", "base64", "gzip", "gz", "gzip+b64", "gz+base64", "b64+b64", "gzip+b64+gzip", "b64+gzip+b64"}
+	// registeredIDs are the ids and aliases of all built-in codecs, some invalid ids are added.
+	registeredIDs = []string{"b64", "base64", "gzip", "gz", "bzip2", "bz2", "", "rot13"}
+)
+
+// quickConfig returns a seeded configuration for property tests, so failures are reproducible.
+func quickConfig() *quick.Config {
+	return &quick.Config{MaxCount: 500, Rand: rand.New(rand.NewSource(1))}
+}
+
+var _ = Describe("Cloudinit", func() {
+	Describe("#Decode", func() {
+		It("should decode all valid codec ids", func() {
+			for _, id := range []FileCodecID{B64FileCodecID, GZIPFileCodecID, GZIPB64FileCodecID} {
+				encoded, err := FileCodecForID(id).Encode([]byte("foo\n"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(Decode(string(id), encoded)).To(Equal([]byte("foo\n")), string(id))
+			}
+		})
+
+		It("should not return trailing bytes for padded base64 data", func() {
+			Expect(Decode("b64", []byte("Zm9vCg=="))).To(Equal([]byte("foo\n")))
+		})
+
+		It("should decode pipelines in reverse order", func() {
+			Expect(Decode("gzip+b64", []byte("H4sIAAAAAAAAA0vLz+cCAKhlMn4EAAAA"))).To(Equal([]byte("foo\n")))
+			Expect(Decode("b64+base64", []byte("Wm05dkNnPT0="))).To(Equal([]byte("foo\n")))
+		})
+
+		It("should decode bzip2", func() {
+			Expect(Decode("bzip2+b64", []byte(bzip2Foo))).To(Equal([]byte("foo\n")))
+			Expect(Decode("bz2+base64", []byte(bzip2Foo))).To(Equal([]byte("foo\n")))
+		})
+
+		It("should fail for invalid codec ids", func() {
+			for _, id := range []string{"rot13", "", "+", "gzip+", "+b64", "gzip+rot13"} {
+				_, err := Decode(id, []byte("sbb"))
+				Expect(err).To(HaveOccurred(), id)
+			}
+		})
+
+		It("should not panic for random data and pipelines", func() {
+			Expect(quick.Check(func(data []byte, a, b uint8, encode bool) bool {
+				id := registeredIDs[int(a)%len(registeredIDs)] + "+" + registeredIDs[int(b)%len(registeredIDs)]
+				if encode {
+					// Base64 encoded garbage reaches the inner codec of the pipeline.
+					data, _ = Encode("b64", data)
+				}
+				_, _ = Decode(id, data)
+				return true
+			}, quickConfig())).To(Succeed())
+		})
+
+		It("should fail for invalid data", func() {
+			for _, id := range []string{"b64", "gzip", "bzip2", "gzip+b64"} {
+				_, err := Decode(id, []byte("not encoded!"))
+				Expect(err).To(HaveOccurred(), id)
+			}
+		})
+	})
+
+	Describe("#Encode", func() {
+		It("should round-trip all encodable codec ids", func() {
+			for _, id := range encodableIDs {
+				encoded, err := Encode(id, []byte("foo\n"))
+				Expect(err).NotTo(HaveOccurred(), id)
+				Expect(Decode(id, encoded)).To(Equal([]byte("foo\n")), id)
+			}
+		})
+
+		It("should round-trip random data with all encodable codecs and pipelines of two of them", func() {
+			encodable := encodableIDs[:4]
+			Expect(quick.Check(func(data []byte, a, b uint8) bool {
+				first, second := encodable[int(a)%len(encodable)], encodable[int(b)%len(encodable)]
+				for _, id := range []string{first, first + "+" + second} {
+					encoded, err := Encode(id, data)
+					if err != nil {
+						return false
+					}
+					if decoded, err := Decode(id, encoded); err != nil || !bytes.Equal(decoded, data) {
+						return false
+					}
+				}
+				return true
+			}, quickConfig())).To(Succeed())
+		})
+
+		It("should fail for decode-only codecs", func() {
+			_, err := Encode("bzip2+b64", []byte("foo\n"))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("#ParseFileCodecID", func() {
+		It("should resolve aliases", func() {
+			Expect(ParseFileCodecID("gz+base64")).To(Equal(GZIPB64FileCodecID))
+			Expect(ParseFileCodecID("bz2")).To(Equal(BZIP2FileCodecID))
+		})
+	})
+
+	Describe("#FileCodecForID", func() {
+		It("should return a failing codec for invalid ids", func() {
+			_, err := FileCodecForID("rot13").Decode([]byte("sbb"))
+			Expect(err).To(HaveOccurred())
+			_, err = FileCodecForID("rot13").Encode([]byte("foo"))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("#RegisterFileCodec", func() {
+		It("should make registered codecs usable in pipelines", func() {
+			RegisterFileCodec("reverse", reverseFileCodec{}, "rev")
+
+			encoded, err := Encode("rev+b64", []byte("foo\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(encoded)).To(Equal("Cm9vZg=="))
+			Expect(Decode("reverse+base64", encoded)).To(Equal([]byte("foo\n")))
+		})
+
+		It("should panic for pipeline ids", func() {
+			Expect(func() { RegisterFileCodec("a+b", reverseFileCodec{}) }).To(Panic())
+		})
+	})
+})
+
+type reverseFileCodec struct{}
+
+func (reverseFileCodec) Encode(data []byte) ([]byte, error) {
+	out := make([]byte, len(data))
+	for i, b := range data {
+		out[len(data)-1-i] = b
+	}
+	return out, nil
+}
+
+func (c reverseFileCodec) Decode(data []byte) ([]byte, error) {
+	return c.Encode(data)
+}
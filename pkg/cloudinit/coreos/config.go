@@ -0,0 +1,66 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package coreos
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/gardener/gardener-extensions/pkg/controller/operatingsystemconfig"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Configuration is the actuator specific section of the controller configuration file.
+type Configuration struct {
+	// Update is the update configuration of the machines. Fields that are not set keep their
+	// default values.
+	Update *UpdateConfiguration `json:"update,omitempty"`
+	// BaseProfile is the base profile applied to the machines. Fields that are not set keep their
+	// default values.
+	BaseProfile *operatingsystemconfig.BaseProfile `json:"baseProfile,omitempty"`
+	// ContainerRuntime is the container runtime of the machines. Fields that are not set keep
+	// their default values.
+	ContainerRuntime *operatingsystemconfig.ContainerRuntimeConfiguration `json:"containerRuntime,omitempty"`
+}
+
+// ParseConfiguration decodes and validates the given Configuration. An empty input results in an
+// empty Configuration.
+func ParseConfiguration(data json.RawMessage) (*Configuration, error) {
+	config := &Configuration{}
+	if len(data) == 0 {
+		return config, nil
+	}
+
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, fmt.Errorf("could not decode actuator configuration: %v", err)
+	}
+	if config.Update != nil {
+		if err := config.Update.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if config.BaseProfile != nil {
+		if err := config.BaseProfile.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if config.ContainerRuntime != nil {
+		if err := config.ContainerRuntime.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package coreos
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/gardener/gardener-extensions/pkg/cloudinit"
+)
+
+// CompressionThreshold is the size in bytes above which file contents are compressed in cloud configs.
+const CompressionThreshold = 4 * 1024
+
+// DecodeContent decodes file content with the encodings understood by Gardener and coreos-cloudinit,
+// i.e. no encoding and all codecs of the cloudinit package, e.g. `b64`/`base64`, `gz`/`gzip` and
+// their combinations like `gzip+base64`.
+func DecodeContent(encoding, content string) ([]byte, error) {
+	if encoding == "" {
+		return []byte(content), nil
+	}
+
+	data, err := cloudinit.Decode(encoding, []byte(content))
+	if err != nil {
+		return nil, fmt.Errorf("could not decode content with encoding %q: %v", encoding, err)
+	}
+	return data, nil
+}
+
+// EncodeContent encodes the given data in the canonical form for cloud configs and returns the
+// encoding and the encoded content. Contents larger than the CompressionThreshold are compressed
+// (`gzip+base64`), other contents are kept as they are if they are valid UTF-8 and base64 encoded
+// (`b64`) otherwise.
+func EncodeContent(data []byte) (string, string, error) {
+	switch {
+	case len(data) > CompressionThreshold:
+		encoded, err := cloudinit.GZIPB64FileCodec.Encode(data)
+		if err != nil {
+			return "", "", err
+		}
+		return "gzip+base64", string(encoded), nil
+	case utf8.Valid(data):
+		return "", string(data), nil
+	default:
+		encoded, err := cloudinit.B64FileCodec.Encode(data)
+		if err != nil {
+			return "", "", err
+		}
+		return "b64", string(encoded), nil
+	}
+}
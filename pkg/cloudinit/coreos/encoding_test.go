@@ -0,0 +1,68 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package coreos_test
+
+import (
+	"strings"
+
+	"github.com/gardener/gardener-extensions/pkg/cloudinit/coreos"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Encoding", func() {
+	Describe("#DecodeContent", func() {
+		It("should decode all encodings understood by Gardener and coreos-cloudinit", func() {
+			for encoding, content := range map[string]string{
+				"":            "foo\n",
+				"b64":         "Zm9vCg==",
+				"base64":      "Zm9vCg==",
+				"gzip+b64":    "H4sIAAAAAAAAA0vLz+cCAKhlMn4EAAAA",
+				"gzip+base64": "H4sIAAAAAAAAA0vLz+cCAKhlMn4EAAAA",
+				"gz+b64":      "H4sIAAAAAAAAA0vLz+cCAKhlMn4EAAAA",
+				"bzip2+b64":   "QlpoOTFBWSZTWXb2s+UAAADBAAAQAQCgACGYGYQYXckU4UJB29rPlA==",
+			} {
+				Expect(coreos.DecodeContent(encoding, content)).To(Equal([]byte("foo\n")), encoding)
+			}
+		})
+
+		It("should fail for unknown encodings", func() {
+			_, err := coreos.DecodeContent("rot13", "sbb")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("#EncodeContent", func() {
+		roundTrip := func(data []byte) string {
+			encoding, content, err := coreos.EncodeContent(data)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(coreos.DecodeContent(encoding, content)).To(Equal(data))
+			return encoding
+		}
+
+		It("should keep small text contents", func() {
+			Expect(roundTrip([]byte("vm.max_map_count = 135217728\n"))).To(BeEmpty())
+		})
+
+		It("should base64 encode binary contents", func() {
+			Expect(roundTrip([]byte{0xff, 0x00, 0xfe})).To(Equal("b64"))
+		})
+
+		It("should compress large contents", func() {
+			Expect(roundTrip([]byte(strings.Repeat("a", coreos.CompressionThreshold+1)))).To(Equal("gzip+base64"))
+		})
+	})
+})
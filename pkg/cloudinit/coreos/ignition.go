@@ -0,0 +1,205 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package coreos
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/gardener/gardener-extensions/pkg/systemd"
+)
+
+const (
+	// IgnitionVersion is the version of the Ignition specification rendered by the actuator.
+	IgnitionVersion = "2.2.0"
+	// IgnitionRootFilesystem is the name of the root filesystem in Ignition configs.
+	IgnitionRootFilesystem = "root"
+)
+
+// IgnitionConfig is a structure containing the relevant fields of an Ignition config (spec v2.x).
+// It can be marshalled to JSON.
+type IgnitionConfig struct {
+	// Ignition contains metadata about the config itself.
+	Ignition Ignition `json:"ignition"`
+	// Storage describes the files that are written to the disk.
+	Storage IgnitionStorage `json:"storage,omitempty"`
+	// Systemd describes the systemd units.
+	Systemd IgnitionSystemd `json:"systemd,omitempty"`
+}
+
+// Ignition contains metadata about the Ignition config.
+type Ignition struct {
+	// Version is the version of the Ignition specification.
+	Version string `json:"version"`
+}
+
+// IgnitionStorage describes the files that are written to the disk.
+type IgnitionStorage struct {
+	// Files is a list of files.
+	Files []IgnitionFile `json:"files,omitempty"`
+}
+
+// IgnitionFile is a file that gets written to the disk.
+type IgnitionFile struct {
+	// Filesystem is the name of the filesystem the file is written to.
+	Filesystem string `json:"filesystem"`
+	// Path is the absolute path of the file.
+	Path string `json:"path"`
+	// Contents describes the contents of the file.
+	Contents IgnitionFileContents `json:"contents"`
+	// Mode is the file's permission mode as decimal number.
+	Mode *int `json:"mode,omitempty"`
+}
+
+// IgnitionFileContents describes the contents of a file.
+type IgnitionFileContents struct {
+	// Source is the URL of the contents, e.g. a data URL.
+	Source string `json:"source"`
+}
+
+// IgnitionSystemd describes the systemd units.
+type IgnitionSystemd struct {
+	// Units is a list of units.
+	Units []IgnitionUnit `json:"units,omitempty"`
+}
+
+// IgnitionUnit is a systemd unit.
+type IgnitionUnit struct {
+	// Name is the name of the unit.
+	Name string `json:"name"`
+	// Enabled defines whether the unit is enabled.
+	Enabled *bool `json:"enabled,omitempty"`
+	// Mask defines whether the unit is masked.
+	Mask bool `json:"mask,omitempty"`
+	// Contents is the content of the unit.
+	Contents string `json:"contents,omitempty"`
+	// Dropins is a list of drop-ins of the unit.
+	Dropins []IgnitionDropin `json:"dropins,omitempty"`
+}
+
+// IgnitionDropin is a drop-in of a systemd unit.
+type IgnitionDropin struct {
+	// Name is the name of the drop-in.
+	Name string `json:"name"`
+	// Contents is the content of the drop-in.
+	Contents string `json:"contents,omitempty"`
+}
+
+// String returns the JSON representation of the IgnitionConfig structure.
+func (c IgnitionConfig) String() (string, error) {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
+
+// ParseIgnition strictly decodes and validates the given Ignition config.
+func ParseIgnition(data []byte) (*IgnitionConfig, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	config := &IgnitionConfig{}
+	if err := decoder.Decode(config); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Validate validates the IgnitionConfig against the constraints of the Ignition specification v2.x.
+func (c *IgnitionConfig) Validate() error {
+	var problems []string
+	if !strings.HasPrefix(c.Ignition.Version, "2.") {
+		problems = append(problems, fmt.Sprintf("unsupported ignition version %q", c.Ignition.Version))
+	}
+
+	paths := map[string]bool{}
+	for _, file := range c.Storage.Files {
+		switch {
+		case file.Filesystem == "":
+			problems = append(problems, fmt.Sprintf("file %q has no filesystem", file.Path))
+		case !path.IsAbs(file.Path) || path.Clean(file.Path) != file.Path:
+			problems = append(problems, fmt.Sprintf("file path %q is not absolute and clean", file.Path))
+		case paths[file.Path]:
+			problems = append(problems, fmt.Sprintf("file %q is specified multiple times", file.Path))
+		}
+		paths[file.Path] = true
+
+		if file.Mode != nil && (*file.Mode < 0 || *file.Mode > 07777) {
+			problems = append(problems, fmt.Sprintf("file %q has invalid mode %d", file.Path, *file.Mode))
+		}
+		if _, err := DecodeDataURL(file.Contents.Source); err != nil {
+			problems = append(problems, fmt.Sprintf("file %q has invalid source: %v", file.Path, err))
+		}
+	}
+
+	units := map[string]bool{}
+	for _, unit := range c.Systemd.Units {
+		if err := systemd.ValidateUnitName(unit.Name); err != nil {
+			problems = append(problems, err.Error())
+		}
+		if units[unit.Name] {
+			problems = append(problems, fmt.Sprintf("unit %q is specified multiple times", unit.Name))
+		}
+		units[unit.Name] = true
+
+		for _, dropin := range unit.Dropins {
+			if err := systemd.ValidateDropInName(dropin.Name); err != nil {
+				problems = append(problems, fmt.Sprintf("unit %q: %v", unit.Name, err))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid ignition config:\n%s", strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// DataURL returns a base64 encoded data URL (RFC 2397) containing the given data.
+func DataURL(data []byte) string {
+	return "data:;base64," + base64.StdEncoding.EncodeToString(data)
+}
+
+// DecodeDataURL decodes the given data URL (RFC 2397).
+func DecodeDataURL(dataURL string) ([]byte, error) {
+	if !strings.HasPrefix(dataURL, "data:") {
+		return nil, fmt.Errorf("unsupported source %q, only data URLs are supported", dataURL)
+	}
+
+	i := strings.Index(dataURL, ",")
+	if i < 0 {
+		return nil, fmt.Errorf("data URL without data")
+	}
+	mediaType, data := dataURL[len("data:"):i], dataURL[i+1:]
+
+	if strings.HasSuffix(mediaType, ";base64") {
+		return base64.StdEncoding.DecodeString(data)
+	}
+
+	decoded, err := url.PathUnescape(data)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(decoded), nil
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package coreos_test
+
+import (
+	"github.com/gardener/gardener-extensions/pkg/cloudinit/coreos"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Ignition", func() {
+	Describe("#ParseIgnition", func() {
+		It("should accept valid configs", func() {
+			config, err := coreos.ParseIgnition([]byte(`{
+  "ignition": {"version": "2.2.0"},
+  "storage": {"files": [{"filesystem": "root", "path": "/etc/foo", "contents": {"source": "data:,foo"}, "mode": 420}]},
+  "systemd": {"units": [{"name": "foo.service", "enabled": true, "dropins": [{"name": "10-foo.conf"}]}]}
+}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config.Storage.Files).To(HaveLen(1))
+			Expect(coreos.DecodeDataURL(config.Storage.Files[0].Contents.Source)).To(Equal([]byte("foo")))
+		})
+
+		It("should reject configs violating the specification", func() {
+			for _, data := range []string{
+				`{"ignition": {"version": "3.0.0"}}`,
+				`{"ignition": {"version": "2.2.0"}, "unknown": {}}`,
+				`{"ignition": {"version": "2.2.0"}, "storage": {"files": [{"filesystem": "root", "path": "relative", "contents": {"source": "data:,"}}]}}`,
+				`{"ignition": {"version": "2.2.0"}, "storage": {"files": [{"path": "/foo", "contents": {"source": "data:,"}}]}}`,
+				`{"ignition": {"version": "2.2.0"}, "storage": {"files": [{"filesystem": "root", "path": "/foo", "contents": {"source": "https://example.com"}}]}}`,
+				`{"ignition": {"version": "2.2.0"}, "storage": {"files": [{"filesystem": "root", "path": "/foo", "contents": {"source": "data:,"}, "mode": 65535}]}}`,
+				`{"ignition": {"version": "2.2.0"}, "systemd": {"units": [{"name": "foo"}]}}`,
+				`{"ignition": {"version": "2.2.0"}, "systemd": {"units": [{"name": "foo bar.service"}]}}`,
+				`{"ignition": {"version": "2.2.0"}, "systemd": {"units": [{"name": "foo.service"}, {"name": "foo.service"}]}}`,
+				`{"ignition": {"version": "2.2.0"}, "systemd": {"units": [{"name": "foo.service", "dropins": [{"name": "foo"}]}]}}`,
+			} {
+				_, err := coreos.ParseIgnition([]byte(data))
+				Expect(err).To(HaveOccurred(), data)
+			}
+		})
+	})
+
+	Describe("#DataURL", func() {
+		It("should round trip", func() {
+			Expect(coreos.DecodeDataURL(coreos.DataURL([]byte("foo\nbar")))).To(Equal([]byte("foo\nbar")))
+		})
+	})
+})
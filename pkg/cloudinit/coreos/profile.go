@@ -0,0 +1,122 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package coreos
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/gardener/gardener-extensions/pkg/controller/operatingsystemconfig"
+	"github.com/gardener/gardener-extensions/pkg/shell"
+
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// BaseProfileUnitName is the name of the unit that runs the bootstrap script of the base profile.
+	BaseProfileUnitName = "base-profile.service"
+	// BaseProfileScriptPath is the path of the bootstrap script of the base profile.
+	BaseProfileScriptPath = "/opt/bin/base-profile"
+)
+
+// DefaultBaseProfile returns the default base profile of CoreOS machines. It is empty, as the
+// update related units are masked according to the update configuration.
+func DefaultBaseProfile() *operatingsystemconfig.BaseProfile {
+	return &operatingsystemconfig.BaseProfile{}
+}
+
+// withBaseProfile returns the update configuration masking the units of the given base profile,
+// too. If the given config is a provisioning config, the returned copy of it additionally
+// contains the files of the base profile and a unit running the disabling of units and the
+// commands of the base profile at boot. Files of the config take precedence.
+func withBaseProfile(profile *operatingsystemconfig.BaseProfile, update *UpdateConfiguration, config *extensionsv1alpha1.OperatingSystemConfig) (*UpdateConfiguration, *extensionsv1alpha1.OperatingSystemConfig) {
+	if len(profile.MaskedUnits) > 0 {
+		masked := sets.NewString(update.MaskedUnits...)
+		maskedUnits := append([]string{}, update.MaskedUnits...)
+		for _, name := range profile.MaskedUnits {
+			if !masked.Has(name) {
+				masked.Insert(name)
+				maskedUnits = append(maskedUnits, name)
+			}
+		}
+		update = update.Merge(&UpdateConfiguration{MaskedUnits: maskedUnits})
+	}
+
+	if config.Spec.Purpose != extensionsv1alpha1.OperatingSystemConfigPurposeProvision || !profile.HasBootstrapActions() {
+		return update, config
+	}
+
+	config = config.DeepCopy()
+	paths := sets.NewString()
+	for _, file := range config.Spec.Files {
+		paths.Insert(file.Path)
+	}
+
+	var files []extensionsv1alpha1.File
+	for _, file := range profile.Files {
+		if paths.Has(file.Path) {
+			continue
+		}
+		files = append(files, extensionsv1alpha1.File{
+			Path:        file.Path,
+			Permissions: file.Permissions,
+			Content:     extensionsv1alpha1.FileContent{Inline: &extensionsv1alpha1.FileContentInline{Data: file.Content}},
+		})
+	}
+
+	if len(profile.DisabledUnits) > 0 || len(profile.Commands) > 0 {
+		permissions := int32(0755)
+		files = append(files, extensionsv1alpha1.File{
+			Path:        BaseProfileScriptPath,
+			Permissions: &permissions,
+			Content:     extensionsv1alpha1.FileContent{Inline: &extensionsv1alpha1.FileContentInline{Data: baseProfileScript(profile)}},
+		})
+
+		var (
+			enable  = true
+			command = "start"
+			content = fmt.Sprintf(`[Unit]
+Description=Applies the base profile of the operating system
+Before=docker.service kubelet.service
+[Install]
+WantedBy=multi-user.target
+[Service]
+Type=oneshot
+RemainAfterExit=yes
+ExecStart=%s
+`, BaseProfileScriptPath)
+		)
+		config.Spec.Units = append([]extensionsv1alpha1.Unit{{Name: BaseProfileUnitName, Enable: &enable, Command: &command, Content: &content}}, config.Spec.Units...)
+	}
+
+	config.Spec.Files = append(files, config.Spec.Files...)
+	return update, config
+}
+
+// baseProfileScript returns a script disabling and stopping the disabled units and running the
+// commands of the given base profile. It runs at every boot, so the commands have to be idempotent.
+func baseProfileScript(profile *operatingsystemconfig.BaseProfile) string {
+	var script bytes.Buffer
+	script.WriteString("#!/bin/bash\n")
+	for _, name := range profile.DisabledUnits {
+		quoted := shell.Quote(name)
+		fmt.Fprintf(&script, "systemctl disable %s\nsystemctl stop %s\n", quoted, quoted)
+	}
+	for _, command := range profile.Commands {
+		script.WriteString(command + "\n")
+	}
+	return script.String()
+}
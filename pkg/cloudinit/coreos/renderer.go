@@ -0,0 +1,292 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package coreos renders OperatingSystemConfigs as CoreOS `#cloud-config` documents or Ignition
+// configs, as understood by CoreOS and Flatcar Container Linux.
+package coreos
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gardener/gardener-extensions/pkg/controller/operatingsystemconfig"
+
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	// DefaultReloadCommand is the default command prefix used to reload the cloud config on a node.
+	DefaultReloadCommand = "/usr/bin/coreos-cloudinit --from-file="
+	// ApplierReloadCommand is the command prefix used to reload the cloud config on a node with the
+	// os-config-applier.
+	ApplierReloadCommand = "/opt/bin/os-config-applier --from-file="
+	// DefaultUpdateConfPath is the default path of the update configuration written by Ignition configs.
+	DefaultUpdateConfPath = "/etc/coreos/update.conf"
+)
+
+// DefaultMaskedUnits are the update related units masked by default.
+var DefaultMaskedUnits = []string{"update-engine.service", "locksmithd.service"}
+
+// Format is the format of the rendered result.
+type Format string
+
+const (
+	// FormatCloudConfig renders a CoreOS `#cloud-config` document.
+	FormatCloudConfig Format = "cloud-config"
+	// FormatIgnition renders an Ignition (spec v2.x) JSON config.
+	FormatIgnition Format = "ignition"
+)
+
+// RendererOptions are options for the creation of a CoreOS renderer.
+type RendererOptions struct {
+	// Format is the format of the result. Defaults to FormatCloudConfig.
+	Format Format
+	// IgnitionTypes are the types of OperatingSystemConfigs which are always rendered as Ignition
+	// config regardless of the Format.
+	IgnitionTypes []string
+	// Update is the update configuration of the machines, it can be overridden per config with
+	// the UpdateAnnotation. Defaults to DefaultUpdateConfiguration.
+	Update *UpdateConfiguration
+	// UpdateConfPath is the path the update configuration is written to by Ignition configs.
+	// Defaults to DefaultUpdateConfPath.
+	UpdateConfPath string
+	// BaseProfile is the base profile applied to the machines. Defaults to DefaultBaseProfile.
+	BaseProfile *operatingsystemconfig.BaseProfile
+}
+
+// Renderer renders OperatingSystemConfigs as CoreOS cloud configs or Ignition configs.
+type Renderer struct {
+	format         Format
+	ignitionTypes  []string
+	update         *UpdateConfiguration
+	updateConfPath string
+	baseProfile    *operatingsystemconfig.BaseProfile
+}
+
+var _ operatingsystemconfig.Renderer = &Renderer{}
+
+// NewRenderer creates a new Renderer with the given options.
+func NewRenderer(opts RendererOptions) *Renderer {
+	r := &Renderer{
+		format:         opts.Format,
+		ignitionTypes:  opts.IgnitionTypes,
+		update:         opts.Update,
+		updateConfPath: opts.UpdateConfPath,
+		baseProfile:    opts.BaseProfile,
+	}
+	if r.format == "" {
+		r.format = FormatCloudConfig
+	}
+	if r.update == nil {
+		r.update = DefaultUpdateConfiguration()
+	}
+	if r.updateConfPath == "" {
+		r.updateConfPath = DefaultUpdateConfPath
+	}
+	if r.baseProfile == nil {
+		r.baseProfile = DefaultBaseProfile()
+	}
+	return r
+}
+
+func (r *Renderer) formatFor(config *extensionsv1alpha1.OperatingSystemConfig) Format {
+	for _, t := range r.ignitionTypes {
+		if strings.EqualFold(config.Spec.Type, t) {
+			return FormatIgnition
+		}
+	}
+	return r.format
+}
+
+// Render renders the given config in the configured format.
+func (r *Renderer) Render(_ context.Context, config *extensionsv1alpha1.OperatingSystemConfig, files map[string][]byte) ([]byte, error) {
+	update, err := updateConfigurationFor(r.update, config)
+	if err != nil {
+		return nil, err
+	}
+	update, profiled := withBaseProfile(r.baseProfile, update, config)
+	files = withProfileFilesData(profiled, files)
+
+	var result string
+	switch format := r.formatFor(config); format {
+	case FormatCloudConfig:
+		result, err = cloudConfigFromOperatingSystemConfig(profiled, update, files)
+	case FormatIgnition:
+		result, err = r.ignitionFromOperatingSystemConfig(profiled, update, files)
+	default:
+		err = fmt.Errorf("unknown format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []byte(result), nil
+}
+
+// withProfileFilesData returns the given decoded content of the files completed by the content of
+// the files the base profile added to the given config. These files have plain inline content.
+func withProfileFilesData(profiled *extensionsv1alpha1.OperatingSystemConfig, files map[string][]byte) map[string][]byte {
+	out := make(map[string][]byte, len(profiled.Spec.Files))
+	for _, file := range profiled.Spec.Files {
+		data, ok := files[file.Path]
+		if !ok && file.Content.Inline != nil {
+			data = []byte(file.Content.Inline.Data)
+		}
+		out[file.Path] = data
+	}
+	return out
+}
+
+func filePermissions(file extensionsv1alpha1.File) int32 {
+	if p := file.Permissions; p != nil {
+		return *p
+	}
+	return extensionsv1alpha1.OperatingSystemConfigDefaultFilePermission
+}
+
+func (r *Renderer) ignitionFromOperatingSystemConfig(config *extensionsv1alpha1.OperatingSystemConfig, update *UpdateConfiguration, filesData map[string][]byte) (string, error) {
+	ignition := &IgnitionConfig{
+		Ignition: Ignition{Version: IgnitionVersion},
+	}
+
+	updateConfig := update.config()
+	for _, unit := range updateConfig.Units {
+		ignition.Systemd.Units = append(ignition.Systemd.Units, IgnitionUnit{Name: unit.Name, Mask: unit.Mask})
+	}
+
+	for _, unit := range config.Spec.Units {
+		enabled, err := ignitionUnitEnabled(unit)
+		if err != nil {
+			return "", err
+		}
+
+		u := IgnitionUnit{Name: unit.Name, Enabled: enabled}
+		if unit.Content != nil {
+			u.Contents = *unit.Content
+		}
+		for _, dropIn := range unit.DropIns {
+			u.Dropins = append(u.Dropins, IgnitionDropin{Name: dropIn.Name, Contents: dropIn.Content})
+		}
+
+		ignition.Systemd.Units = append(ignition.Systemd.Units, u)
+	}
+
+	var files []IgnitionFile
+	if updateConf := updateConfig.UpdateConf(); len(updateConf) > 0 {
+		files = append(files, ignitionFile(r.updateConfPath, updateConf, 0644))
+	}
+	for _, file := range config.Spec.Files {
+		files = append(files, ignitionFile(file.Path, filesData[file.Path], int(filePermissions(file))))
+	}
+	ignition.Storage.Files = files
+
+	if err := ignition.Validate(); err != nil {
+		return "", err
+	}
+
+	return ignition.String()
+}
+
+// ignitionUnitEnabled returns whether the given unit has to be enabled in Ignition configs. Ignition
+// cannot execute unit commands, it only enables units which systemd starts during the first boot.
+// Hence, commands starting a unit require it to be enabled and `stop` requires it to be disabled.
+// Other commands only affect running units and cannot be expressed.
+func ignitionUnitEnabled(unit extensionsv1alpha1.Unit) (*bool, error) {
+	if unit.Command == nil {
+		return unit.Enable, nil
+	}
+
+	var enabled bool
+	switch command := *unit.Command; command {
+	case "start", "restart", "reload-or-restart":
+		enabled = true
+	case "stop":
+		enabled = false
+	default:
+		return nil, fmt.Errorf("command %q of unit %q is not supported in ignition configs", command, unit.Name)
+	}
+
+	if unit.Enable != nil && *unit.Enable != enabled {
+		return nil, fmt.Errorf("command %q of unit %q contradicts enable=%t in ignition configs", *unit.Command, unit.Name, *unit.Enable)
+	}
+	return &enabled, nil
+}
+
+func ignitionFile(path string, data []byte, mode int) IgnitionFile {
+	return IgnitionFile{
+		Filesystem: IgnitionRootFilesystem,
+		Path:       path,
+		Contents:   IgnitionFileContents{Source: DataURL(data)},
+		Mode:       &mode,
+	}
+}
+
+func cloudConfigFromOperatingSystemConfig(config *extensionsv1alpha1.OperatingSystemConfig, update *UpdateConfiguration, filesData map[string][]byte) (string, error) {
+	cloudConfig := &CloudConfig{
+		CoreOS: update.config(),
+	}
+
+	for _, unit := range config.Spec.Units {
+		u := Unit{Name: unit.Name}
+
+		if unit.Command != nil {
+			u.Command = *unit.Command
+		}
+		if unit.Enable != nil {
+			u.Enable = *unit.Enable
+		}
+		if unit.Content != nil {
+			u.Content = *unit.Content
+		}
+
+		for _, dropIn := range unit.DropIns {
+			u.DropIns = append(u.DropIns, UnitDropIn{
+				Name:    dropIn.Name,
+				Content: dropIn.Content,
+			})
+		}
+
+		cloudConfig.CoreOS.Units = append(cloudConfig.CoreOS.Units, u)
+	}
+
+	for _, file := range config.Spec.Files {
+		f := File{
+			Path: file.Path,
+		}
+
+		f.RawFilePermissions = strconv.FormatInt(int64(filePermissions(file)), 8)
+
+		var err error
+		f.Encoding, f.Content, err = EncodeContent(filesData[file.Path])
+		if err != nil {
+			return "", err
+		}
+
+		cloudConfig.WriteFiles = append(cloudConfig.WriteFiles, f)
+	}
+
+	return cloudConfig.String()
+}
+
+// String returns the string representation of the CloudConfig structure.
+func (c CloudConfig) String() (string, error) {
+	bytes, err := yaml.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("#cloud-config\n\n%s", string(bytes)), nil
+}
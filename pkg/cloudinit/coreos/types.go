@@ -28,6 +28,8 @@ type CloudConfig struct {
 type Config struct {
 	// Update contains configuration for the Container Linux update procedure.
 	Update Update `yaml:"update,omitempty"`
+	// Locksmith contains configuration for the reboot manager.
+	Locksmith Locksmith `yaml:"locksmith,omitempty"`
 	// Units is a list of units that are translated to systemd later.
 	Units []Unit `yaml:"units,omitempty"`
 }
@@ -42,6 +44,14 @@ type Update struct {
 	Server string `yaml:"server,omitempty"`
 }
 
+// Locksmith contains configuration for locksmithd, the reboot manager of Container Linux.
+type Locksmith struct {
+	// WindowStart is the start of the reboot window, e.g. `Thu 04:00`.
+	WindowStart string `yaml:"window_start,omitempty"`
+	// WindowLength is the length of the reboot window, e.g. `1h`.
+	WindowLength string `yaml:"window_length,omitempty"`
+}
+
 // Unit gets translated to a systemd unit.
 type Unit struct {
 	// Name is the name of the unit.
@@ -0,0 +1,201 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package coreos
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gardener/gardener-extensions/pkg/systemd"
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+
+	"sigs.k8s.io/yaml"
+)
+
+// UpdateAnnotation is the annotation of OperatingSystemConfigs containing an UpdateConfiguration
+// (as JSON or YAML) that overrides the configured update procedure for the machines of the config.
+const UpdateAnnotation = "coreos.os.extensions.gardener.cloud/update"
+
+// Reboot strategies understood by locksmithd.
+const (
+	// RebootStrategyOff disables reboots after updates.
+	RebootStrategyOff = "off"
+	// RebootStrategyReboot reboots immediately after an update has been applied.
+	RebootStrategyReboot = "reboot"
+	// RebootStrategyEtcdLock reboots after acquiring a reboot lock in etcd.
+	RebootStrategyEtcdLock = "etcd-lock"
+	// RebootStrategyBestEffort uses etcd-lock if etcd is running, otherwise reboot.
+	RebootStrategyBestEffort = "best-effort"
+)
+
+// UpdateConfiguration configures the update procedure of Container Linux machines.
+type UpdateConfiguration struct {
+	// RebootStrategy is the strategy locksmithd reboots the machine with after an update.
+	RebootStrategy string `json:"rebootStrategy,omitempty"`
+	// Group is the update group (channel) of the machine, e.g. `stable`.
+	Group string `json:"group,omitempty"`
+	// Server is the URL of the update server.
+	Server string `json:"server,omitempty"`
+	// RebootWindow is the time window reboots are restricted to.
+	RebootWindow *RebootWindow `json:"rebootWindow,omitempty"`
+	// MaskedUnits are the update related units that are masked. An empty list keeps all units,
+	// if not set, the units of the configuration that is overridden are masked.
+	MaskedUnits []string `json:"maskedUnits,omitempty"`
+}
+
+// RebootWindow is the time window locksmithd reboots machines in.
+type RebootWindow struct {
+	// Start is the start of the window, e.g. `04:00` or `Thu 04:00`.
+	Start string `json:"start"`
+	// Length is the duration of the window, e.g. `1h30m`.
+	Length string `json:"length"`
+}
+
+// DefaultUpdateConfiguration returns the default UpdateConfiguration: updates and reboots are
+// disabled, as machines are replaced instead.
+func DefaultUpdateConfiguration() *UpdateConfiguration {
+	return &UpdateConfiguration{
+		RebootStrategy: RebootStrategyOff,
+		MaskedUnits:    append([]string{}, DefaultMaskedUnits...),
+	}
+}
+
+// ParseUpdateConfiguration decodes and validates the given UpdateConfiguration (JSON or YAML).
+func ParseUpdateConfiguration(data []byte) (*UpdateConfiguration, error) {
+	config := &UpdateConfiguration{}
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, fmt.Errorf("could not decode update configuration: %v", err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Merge returns a copy of the UpdateConfiguration with all fields set in the given override replaced.
+func (u *UpdateConfiguration) Merge(override *UpdateConfiguration) *UpdateConfiguration {
+	out := *u
+	if override == nil {
+		return &out
+	}
+
+	if override.RebootStrategy != "" {
+		out.RebootStrategy = override.RebootStrategy
+	}
+	if override.Group != "" {
+		out.Group = override.Group
+	}
+	if override.Server != "" {
+		out.Server = override.Server
+	}
+	if override.RebootWindow != nil {
+		out.RebootWindow = override.RebootWindow
+	}
+	if override.MaskedUnits != nil {
+		out.MaskedUnits = override.MaskedUnits
+	}
+	return &out
+}
+
+var rebootWindowStartRegexp = regexp.MustCompile(`^((Mon|Tue|Wed|Thu|Fri|Sat|Sun) )?([01]?[0-9]|2[0-3]):[0-5][0-9]$`)
+
+// Validate validates the UpdateConfiguration.
+func (u *UpdateConfiguration) Validate() error {
+	var problems []string
+
+	switch u.RebootStrategy {
+	case "", RebootStrategyOff, RebootStrategyReboot, RebootStrategyEtcdLock, RebootStrategyBestEffort:
+	default:
+		problems = append(problems, fmt.Sprintf("unknown reboot strategy %q", u.RebootStrategy))
+	}
+
+	if w := u.RebootWindow; w != nil {
+		if !rebootWindowStartRegexp.MatchString(w.Start) {
+			problems = append(problems, fmt.Sprintf("invalid reboot window start %q, expected `[Day ]HH:MM`", w.Start))
+		}
+		if d, err := time.ParseDuration(w.Length); err != nil || d <= 0 {
+			problems = append(problems, fmt.Sprintf("invalid reboot window length %q", w.Length))
+		}
+	}
+
+	for _, name := range u.MaskedUnits {
+		if err := systemd.ValidateUnitName(name); err != nil {
+			problems = append(problems, fmt.Sprintf("invalid masked unit: %v", err))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid update configuration:\n%s", strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// config returns the update related part of the cloud config.
+func (u *UpdateConfiguration) config() Config {
+	config := Config{
+		Update: Update{
+			RebootStrategy: u.RebootStrategy,
+			Group:          u.Group,
+			Server:         u.Server,
+		},
+	}
+	if w := u.RebootWindow; w != nil {
+		config.Locksmith = Locksmith{WindowStart: w.Start, WindowLength: w.Length}
+	}
+	for _, name := range u.MaskedUnits {
+		config.Units = append(config.Units, Unit{Name: name, Mask: true})
+	}
+	return config
+}
+
+// updateConfigurationFor returns the given UpdateConfiguration merged with the override of the
+// annotation of the given OperatingSystemConfig, if any.
+func updateConfigurationFor(update *UpdateConfiguration, config *extensionsv1alpha1.OperatingSystemConfig) (*UpdateConfiguration, error) {
+	data, ok := config.Annotations[UpdateAnnotation]
+	if !ok {
+		return update, nil
+	}
+
+	override, err := ParseUpdateConfiguration([]byte(data))
+	if err != nil {
+		return nil, fmt.Errorf("annotation %q: %v", UpdateAnnotation, err)
+	}
+	return update.Merge(override), nil
+}
+
+// UpdateConf returns the update and reboot configuration in the format of the `update.conf` file
+// read by update-engine and locksmithd.
+func (c Config) UpdateConf() []byte {
+	var buf bytes.Buffer
+	if c.Update.Group != "" {
+		fmt.Fprintf(&buf, "GROUP=%s\n", c.Update.Group)
+	}
+	if c.Update.Server != "" {
+		fmt.Fprintf(&buf, "SERVER=%s\n", c.Update.Server)
+	}
+	if c.Update.RebootStrategy != "" {
+		fmt.Fprintf(&buf, "REBOOT_STRATEGY=%s\n", c.Update.RebootStrategy)
+	}
+	if c.Locksmith.WindowStart != "" {
+		fmt.Fprintf(&buf, "LOCKSMITHD_REBOOT_WINDOW_START=%s\n", c.Locksmith.WindowStart)
+	}
+	if c.Locksmith.WindowLength != "" {
+		fmt.Fprintf(&buf, "LOCKSMITHD_REBOOT_WINDOW_LENGTH=%s\n", c.Locksmith.WindowLength)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package coreos_test
+
+import (
+	"context"
+
+	"github.com/gardener/gardener-extensions/pkg/cloudinit/coreos"
+	"github.com/gardener/gardener-extensions/pkg/controller/operatingsystemconfig"
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+var _ = Describe("Update", func() {
+	Describe("#ParseUpdateConfiguration", func() {
+		It("should accept valid configurations", func() {
+			update, err := coreos.ParseUpdateConfiguration([]byte(`
+rebootStrategy: etcd-lock
+group: beta
+rebootWindow:
+  start: Thu 04:00
+  length: 1h30m
+maskedUnits: []
+`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(update).To(Equal(&coreos.UpdateConfiguration{
+				RebootStrategy: coreos.RebootStrategyEtcdLock,
+				Group:          "beta",
+				RebootWindow:   &coreos.RebootWindow{Start: "Thu 04:00", Length: "1h30m"},
+				MaskedUnits:    []string{},
+			}))
+		})
+
+		It("should reject invalid configurations", func() {
+			for _, data := range []string{
+				`{"rebootStrategy": "sometimes"}`,
+				`{"rebootWindow": {"start": "Thursday 4am", "length": "1h"}}`,
+				`{"rebootWindow": {"start": "04:00", "length": "forever"}}`,
+				`{"maskedUnits": ["locksmithd"]}`,
+				`{"unknown": true}`,
+			} {
+				_, err := coreos.ParseUpdateConfiguration([]byte(data))
+				Expect(err).To(HaveOccurred(), data)
+			}
+		})
+	})
+
+	Describe("#Merge", func() {
+		It("should only override the set fields", func() {
+			merged := coreos.DefaultUpdateConfiguration().Merge(&coreos.UpdateConfiguration{
+				RebootStrategy: coreos.RebootStrategyReboot,
+				MaskedUnits:    []string{},
+			})
+
+			Expect(merged).To(Equal(&coreos.UpdateConfiguration{
+				RebootStrategy: coreos.RebootStrategyReboot,
+				MaskedUnits:    []string{},
+			}))
+			Expect(coreos.DefaultUpdateConfiguration().Merge(nil)).To(Equal(coreos.DefaultUpdateConfiguration()))
+		})
+	})
+
+	Describe("rendering", func() {
+		var (
+			ctx = context.TODO()
+			osc *extensionsv1alpha1.OperatingSystemConfig
+		)
+
+		BeforeEach(func() {
+			osc = &extensionsv1alpha1.OperatingSystemConfig{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "shoot--foo--bar", Name: "pool"},
+				Spec: extensionsv1alpha1.OperatingSystemConfigSpec{
+					DefaultSpec: extensionsv1alpha1.DefaultSpec{Type: "coreos"},
+				},
+			}
+		})
+
+		render := func(opts coreos.RendererOptions) (string, error) {
+			config := operatingsystemconfig.WithContainerRuntime(operatingsystemconfig.DefaultContainerRuntimeConfiguration(), osc)
+			data, err := coreos.NewRenderer(opts).Render(ctx, config, nil)
+			return string(data), err
+		}
+
+		It("should render the configured update procedure", func() {
+			data, err := render(coreos.RendererOptions{Update: &coreos.UpdateConfiguration{
+				RebootStrategy: coreos.RebootStrategyReboot,
+				Server:         "https://updates.example.com/v1/update/",
+				RebootWindow:   &coreos.RebootWindow{Start: "Sun 03:00", Length: "2h"},
+				MaskedUnits:    []string{"update-engine.service"},
+			}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(data).To(Equal(`#cloud-config
+
+coreos:
+  update:
+    reboot_strategy: reboot
+    server: https://updates.example.com/v1/update/
+  locksmith:
+    window_start: Sun 03:00
+    window_length: 2h
+  units:
+  - name: update-engine.service
+    mask: true
+  - name: docker.service
+    enable: true
+    command: start
+`))
+		})
+
+		It("should apply the override of the annotation", func() {
+			osc.Annotations = map[string]string{coreos.UpdateAnnotation: `{"rebootStrategy": "best-effort", "group": "stable", "maskedUnits": []}`}
+
+			data, err := render(coreos.RendererOptions{Format: coreos.FormatIgnition})
+			Expect(err).NotTo(HaveOccurred())
+
+			ignition, err := coreos.ParseIgnition([]byte(data))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ignition.Systemd.Units).To(ConsistOf(coreos.IgnitionUnit{Name: "docker.service", Enabled: boolPtr(true)}))
+			Expect(ignition.Storage.Files).To(HaveLen(1))
+			Expect(ignition.Storage.Files[0].Path).To(Equal(coreos.DefaultUpdateConfPath))
+			Expect(coreos.DecodeDataURL(ignition.Storage.Files[0].Contents.Source)).To(Equal([]byte("GROUP=stable\nREBOOT_STRATEGY=best-effort\n")))
+		})
+
+		It("should fail for invalid overrides", func() {
+			osc.Annotations = map[string]string{coreos.UpdateAnnotation: `{"rebootStrategy": "sometimes"}`}
+
+			_, err := render(coreos.RendererOptions{})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
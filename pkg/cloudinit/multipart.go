@@ -0,0 +1,404 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudinit
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// ContentTypeMultipart is the content type of multipart user-data.
+	ContentTypeMultipart = "multipart/mixed"
+	// ContentTypeCloudConfig is the content type of `#cloud-config` parts.
+	ContentTypeCloudConfig = "text/cloud-config"
+	// ContentTypeShellScript is the content type of shell script parts.
+	ContentTypeShellScript = "text/x-shellscript"
+	// ContentTypeBoothook is the content type of `#cloud-boothook` parts.
+	ContentTypeBoothook = "text/cloud-boothook"
+	// ContentTypeGzip is the content type of gzip compressed parts. cloud-init decompresses them and
+	// derives their content type from the start of their content.
+	ContentTypeGzip = "application/x-gzip"
+
+	// DefaultMergeType is the merge type of cloud-config parts split by SplitCloudConfig. It makes
+	// cloud-init append the lists of later parts instead of replacing them.
+	DefaultMergeType = "list(append)+dict(no_replace,recurse_list)+str()"
+)
+
+// contentTypePrefixes are the prefixes cloud-init derives the content type of a part from.
+var contentTypePrefixes = []struct {
+	prefix      string
+	contentType string
+}{
+	{"#cloud-config", ContentTypeCloudConfig},
+	{"#cloud-boothook", ContentTypeBoothook},
+	{"#!", ContentTypeShellScript},
+}
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// Part is a part of multipart user-data.
+type Part struct {
+	// ContentType is the content type of the part, e.g. ContentTypeCloudConfig.
+	ContentType string
+	// Filename is the file name of the part. Defaults to `part-NNN` with the 1-based index.
+	Filename string
+	// MergeType is the optional merge type of cloud-config parts, see DefaultMergeType.
+	MergeType string
+	// Content is the uncompressed content of the part.
+	Content []byte
+	// Gzip compresses the part. Its content must start with the prefix of its content type, e.g.
+	// `#cloud-config` or `#!`, as cloud-init derives the type from it after decompressing.
+	Gzip bool
+}
+
+// Multipart is `multipart/mixed` user-data as understood by cloud-init.
+type Multipart struct {
+	// Parts are the parts of the user-data in the order cloud-init processes them.
+	Parts []Part
+	// Gzip compresses the whole document.
+	Gzip bool
+}
+
+// ContentTypeOf returns the content type cloud-init derives from the start of the given content,
+// or an empty string if it is not known.
+func ContentTypeOf(content []byte) string {
+	for _, p := range contentTypePrefixes {
+		if bytes.HasPrefix(content, []byte(p.prefix)) {
+			return p.contentType
+		}
+	}
+	return ""
+}
+
+// Encode encodes the multipart user-data. The boundary is derived from the content of the parts,
+// so the same parts always result in the same document.
+func (m *Multipart) Encode() ([]byte, error) {
+	if len(m.Parts) == 0 {
+		return nil, fmt.Errorf("multipart user-data must have at least one part")
+	}
+
+	boundary, err := boundaryFor(m.Parts)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "Content-Type: %s\r\nMIME-Version: 1.0\r\n\r\n", mime.FormatMediaType(ContentTypeMultipart, map[string]string{"boundary": boundary}))
+
+	w := multipart.NewWriter(&out)
+	if err := w.SetBoundary(boundary); err != nil {
+		return nil, err
+	}
+	for i, part := range m.Parts {
+		if err := writePart(w, i, part); err != nil {
+			return nil, fmt.Errorf("could not write part %d: %v", i, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	if m.Gzip {
+		return GZIPFileCodec.Encode(out.Bytes())
+	}
+	return out.Bytes(), nil
+}
+
+func boundaryFor(parts []Part) (string, error) {
+	h := sha256.New()
+	for _, part := range parts {
+		fmt.Fprintf(h, "%s\x00%s\x00%s\x00%t\x00%d\x00", part.ContentType, part.Filename, part.MergeType, part.Gzip, len(part.Content))
+		h.Write(part.Content)
+	}
+	boundary := fmt.Sprintf("==============%x==", h.Sum(nil)[:16])
+
+	for i, part := range parts {
+		if bytes.Contains(part.Content, []byte(boundary)) {
+			return "", fmt.Errorf("content of part %d contains the boundary %q", i, boundary)
+		}
+	}
+	return boundary, nil
+}
+
+func writePart(w *multipart.Writer, i int, part Part) error {
+	if part.ContentType == "" {
+		return fmt.Errorf("content type must not be empty")
+	}
+	if strings.HasPrefix(part.ContentType, "multipart/") {
+		return fmt.Errorf("nested multipart content is not supported")
+	}
+
+	filename := part.Filename
+	if filename == "" {
+		filename = fmt.Sprintf("part-%03d", i+1)
+	}
+
+	var (
+		contentType = part.ContentType
+		content     = part.Content
+		err         error
+	)
+	if part.Gzip {
+		if actual := ContentTypeOf(content); actual != part.ContentType {
+			return fmt.Errorf("content of gzip compressed %s part must start with its prefix", part.ContentType)
+		}
+		contentType = ContentTypeGzip
+		if content, err = GZIPFileCodec.Encode(content); err != nil {
+			return err
+		}
+	}
+
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", mime.FormatMediaType(contentType, nil))
+	header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	if part.MergeType != "" {
+		header.Set("Merge-Type", part.MergeType)
+	}
+
+	if isPlainText(content) {
+		header.Set("Content-Transfer-Encoding", "7bit")
+	} else {
+		header.Set("Content-Transfer-Encoding", "base64")
+		content = wrapBase64(content)
+	}
+
+	pw, err := w.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	_, err = pw.Write(content)
+	return err
+}
+
+// isPlainText returns whether the given content can be written without a transfer encoding, i.e.
+// whether it is ASCII without NUL bytes or carriage returns and its lines are short enough.
+func isPlainText(content []byte) bool {
+	if !utf8.Valid(content) {
+		return false
+	}
+	for _, line := range bytes.Split(content, []byte("\n")) {
+		if len(line) > 998 {
+			return false
+		}
+		for _, c := range line {
+			if c == 0 || c == '\r' || c > 127 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func wrapBase64(content []byte) []byte {
+	encoded := base64.StdEncoding.EncodeToString(content)
+
+	var out bytes.Buffer
+	for len(encoded) > 76 {
+		out.WriteString(encoded[:76])
+		out.WriteString("\r\n")
+		encoded = encoded[76:]
+	}
+	out.WriteString(encoded)
+	return out.Bytes()
+}
+
+// ParseMultipart parses the given user-data. Gzip compressed documents and parts are
+// decompressed. User-data that is a single document, e.g. a shell script, results in a single
+// part whose content type is derived from its content.
+func ParseMultipart(data []byte) (*Multipart, error) {
+	m := &Multipart{}
+	if bytes.HasPrefix(data, gzipMagic) {
+		decompressed, err := GZIPFileCodec.Decode(data)
+		if err != nil {
+			return nil, fmt.Errorf("could not decompress user-data: %v", err)
+		}
+		data, m.Gzip = decompressed, true
+	}
+
+	if contentType := ContentTypeOf(data); contentType != "" {
+		m.Parts = []Part{{ContentType: contentType, Content: data}}
+		return m, nil
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("could not read user-data: %v", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse content type of user-data: %v", err)
+	}
+	if mediaType != ContentTypeMultipart {
+		return nil, fmt.Errorf("unsupported content type %q of user-data", mediaType)
+	}
+
+	r := multipart.NewReader(msg.Body, params["boundary"])
+	for i := 0; ; i++ {
+		p, err := r.NextPart()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("could not read part %d: %v", i, err)
+		}
+
+		part, err := readPart(p)
+		if err != nil {
+			return nil, fmt.Errorf("could not read part %d: %v", i, err)
+		}
+		m.Parts = append(m.Parts, *part)
+	}
+	if len(m.Parts) == 0 {
+		return nil, fmt.Errorf("multipart user-data has no parts")
+	}
+	return m, nil
+}
+
+func readPart(p *multipart.Part) (*Part, error) {
+	content, err := ioutil.ReadAll(p)
+	if err != nil {
+		return nil, err
+	}
+
+	switch encoding := strings.ToLower(p.Header.Get("Content-Transfer-Encoding")); encoding {
+	case "", "7bit", "8bit", "binary":
+	case "base64":
+		if content, err = base64.StdEncoding.DecodeString(string(bytes.Join(bytes.Fields(content), nil))); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported transfer encoding %q", encoding)
+	}
+
+	part := &Part{
+		ContentType: ContentTypeShellScript,
+		Filename:    p.FileName(),
+		MergeType:   p.Header.Get("Merge-Type"),
+	}
+	if contentType := p.Header.Get("Content-Type"); contentType != "" {
+		if part.ContentType, _, err = mime.ParseMediaType(contentType); err != nil {
+			return nil, err
+		}
+	}
+	if strings.HasPrefix(part.ContentType, "multipart/") {
+		return nil, fmt.Errorf("nested multipart content is not supported")
+	}
+
+	if part.ContentType == ContentTypeGzip || part.ContentType == "application/gzip" {
+		if content, err = GZIPFileCodec.Decode(content); err != nil {
+			return nil, err
+		}
+		if part.ContentType = ContentTypeOf(content); part.ContentType == "" {
+			return nil, fmt.Errorf("could not determine the content type of the decompressed content")
+		}
+		part.Gzip = true
+	}
+	part.Content = content
+	return part, nil
+}
+
+// SplitCloudConfig splits the given `#cloud-config` document into parts of at most maxSize bytes
+// that cloud-init merges with DefaultMergeType. The items of top-level lists, e.g. `write_files`,
+// are distributed across the parts, all other keys are kept in the first part. Items that exceed
+// maxSize on their own are put into a part of their own.
+func SplitCloudConfig(config []byte, maxSize int) ([]Part, error) {
+	if ContentTypeOf(config) != ContentTypeCloudConfig {
+		return nil, fmt.Errorf("cloud-config must start with %q", "#cloud-config")
+	}
+
+	jsonData, err := yaml.YAMLToJSON(config)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse cloud-config: %v", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(jsonData))
+	decoder.UseNumber()
+	var values map[string]interface{}
+	if err := decoder.Decode(&values); err != nil {
+		return nil, fmt.Errorf("could not parse cloud-config: %v", err)
+	}
+
+	var (
+		keys    []string
+		current = map[string]interface{}{}
+		configs []map[string]interface{}
+	)
+	for key, value := range values {
+		if _, ok := value.([]interface{}); ok {
+			keys = append(keys, key)
+		} else {
+			current[key] = value
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		for _, item := range values[key].([]interface{}) {
+			items, _ := current[key].([]interface{})
+			current[key] = append(items, item)
+
+			content, err := marshalCloudConfig(current)
+			if err != nil {
+				return nil, err
+			}
+			if len(content) > maxSize && (len(current) > 1 || len(items) > 0) {
+				if len(items) > 0 {
+					current[key] = items
+				} else {
+					delete(current, key)
+				}
+				configs = append(configs, current)
+				current = map[string]interface{}{key: []interface{}{item}}
+			}
+		}
+	}
+	configs = append(configs, current)
+
+	parts := make([]Part, 0, len(configs))
+	for _, c := range configs {
+		content, err := marshalCloudConfig(c)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, Part{ContentType: ContentTypeCloudConfig, MergeType: DefaultMergeType, Content: content})
+	}
+	return parts, nil
+}
+
+func marshalCloudConfig(values map[string]interface{}) ([]byte, error) {
+	jsonData, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	data, err := yaml.JSONToYAML(jsonData)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte("#cloud-config\n"), data...), nil
+}
@@ -0,0 +1,193 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudinit_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	. "github.com/gardener/gardener-extensions/pkg/cloudinit"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Multipart", func() {
+	var parts []Part
+
+	BeforeEach(func() {
+		parts = []Part{
+			{ContentType: ContentTypeCloudConfig, MergeType: DefaultMergeType, Content: []byte("#cloud-config\nruncmd:\n- [echo, foo]\n")},
+			{ContentType: ContentTypeShellScript, Filename: "bootstrap.sh", Content: []byte("#!/bin/bash\necho 'bär'\n")},
+			{ContentType: ContentTypeBoothook, Content: []byte("#cloud-boothook\necho foo > /run/foo\n"), Gzip: true},
+		}
+	})
+
+	Describe("#Encode", func() {
+		It("should write the parts with their content types", func() {
+			data, err := (&Multipart{Parts: parts}).Encode()
+			Expect(err).NotTo(HaveOccurred())
+
+			document := string(data)
+			Expect(document).To(HavePrefix("Content-Type: multipart/mixed; boundary="))
+			Expect(document).To(ContainSubstring("MIME-Version: 1.0\r\n"))
+			Expect(document).To(ContainSubstring("Content-Type: text/cloud-config\r\n"))
+			Expect(document).To(ContainSubstring("Content-Disposition: attachment; filename=part-001\r\n"))
+			Expect(document).To(ContainSubstring("Merge-Type: " + DefaultMergeType + "\r\n"))
+			Expect(document).To(ContainSubstring("#cloud-config\nruncmd:\n- [echo, foo]\n"))
+			Expect(document).To(ContainSubstring("Content-Type: text/x-shellscript\r\n"))
+			Expect(document).To(ContainSubstring("Content-Disposition: attachment; filename=bootstrap.sh\r\n"))
+			Expect(document).To(ContainSubstring("Content-Type: application/x-gzip\r\n"))
+			Expect(strings.Count(document, "Content-Transfer-Encoding: base64\r\n")).To(Equal(2))
+		})
+
+		It("should be deterministic", func() {
+			first, err := (&Multipart{Parts: parts}).Encode()
+			Expect(err).NotTo(HaveOccurred())
+			second, err := (&Multipart{Parts: parts}).Encode()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(first).To(Equal(second))
+
+			parts[0].Content = []byte("#cloud-config\n")
+			third, err := (&Multipart{Parts: parts}).Encode()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(third).NotTo(Equal(first))
+		})
+
+		It("should compress the whole document", func() {
+			data, err := (&Multipart{Parts: parts, Gzip: true}).Encode()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(data).To(HavePrefix(string([]byte{0x1f, 0x8b})))
+		})
+
+		It("should fail for invalid parts", func() {
+			for _, invalid := range []Part{
+				{Content: []byte("#!/bin/bash\n")},
+				{ContentType: ContentTypeMultipart, Content: []byte("foo")},
+				{ContentType: ContentTypeCloudConfig, Content: []byte("#!/bin/bash\n"), Gzip: true},
+			} {
+				_, err := (&Multipart{Parts: []Part{invalid}}).Encode()
+				Expect(err).To(HaveOccurred(), fmt.Sprintf("%+v", invalid))
+			}
+
+			_, err := (&Multipart{}).Encode()
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("#ParseMultipart", func() {
+		It("should parse encoded user-data", func() {
+			for _, compress := range []bool{false, true} {
+				data, err := (&Multipart{Parts: parts, Gzip: compress}).Encode()
+				Expect(err).NotTo(HaveOccurred())
+
+				m, err := ParseMultipart(data)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(m.Gzip).To(Equal(compress))
+
+				parts[0].Filename = "part-001"
+				parts[2].Filename = "part-003"
+				Expect(m.Parts).To(Equal(parts))
+			}
+		})
+
+		It("should parse user-data that is a single document", func() {
+			m, err := ParseMultipart([]byte("#!/bin/bash\necho foo\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(m.Parts).To(Equal([]Part{{ContentType: ContentTypeShellScript, Content: []byte("#!/bin/bash\necho foo\n")}}))
+		})
+
+		It("should parse user-data written by other tools", func() {
+			m, err := ParseMultipart([]byte(`Content-Type: multipart/mixed; boundary="===============1234=="
+MIME-Version: 1.0
+
+--===============1234==
+Content-Type: text/x-shellscript; charset="us-ascii"
+MIME-Version: 1.0
+Content-Transfer-Encoding: base64
+Content-Disposition: attachment; filename="script.sh"
+
+IyEvYmluL2Jhc2gK
+ZWNobyBmb28K
+
+--===============1234==--
+`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(m.Parts).To(Equal([]Part{{ContentType: ContentTypeShellScript, Filename: "script.sh", Content: []byte("#!/bin/bash\necho foo\n")}}))
+		})
+
+		It("should fail for unsupported user-data", func() {
+			for _, data := range []string{
+				"foo",
+				"Content-Type: text/plain\n\nfoo\n",
+				"Content-Type: multipart/mixed; boundary=x\n\n--x--\n",
+			} {
+				_, err := ParseMultipart([]byte(data))
+				Expect(err).To(HaveOccurred(), data)
+			}
+		})
+	})
+
+	Describe("#SplitCloudConfig", func() {
+		var config []byte
+
+		BeforeEach(func() {
+			var buf bytes.Buffer
+			buf.WriteString("#cloud-config\nhostname: foo\nwrite_files:\n")
+			for i := 0; i < 10; i++ {
+				fmt.Fprintf(&buf, "- path: /etc/file-%d\n  permissions: '0644'\n  content: %s\n", i, strings.Repeat("x", 100))
+			}
+			buf.WriteString("runcmd:\n- [systemctl, daemon-reload]\n")
+			config = buf.Bytes()
+		})
+
+		It("should keep small configs in a single part", func() {
+			parts, err := SplitCloudConfig(config, 64*1024)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(parts).To(HaveLen(1))
+			Expect(parts[0].ContentType).To(Equal(ContentTypeCloudConfig))
+			Expect(parts[0].MergeType).To(Equal(DefaultMergeType))
+			Expect(string(parts[0].Content)).To(HavePrefix("#cloud-config\nhostname: foo\nruncmd:\n"))
+		})
+
+		It("should distribute the list items across parts of limited size", func() {
+			parts, err := SplitCloudConfig(config, 500)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(len(parts)).To(BeNumerically(">", 2))
+
+			files := 0
+			for i, part := range parts {
+				Expect(len(part.Content)).To(BeNumerically("<=", 500), string(part.Content))
+				Expect(string(part.Content)).To(HavePrefix("#cloud-config\n"))
+				Expect(strings.Contains(string(part.Content), "hostname: foo")).To(Equal(i == 0))
+				files += strings.Count(string(part.Content), "- content: ")
+			}
+			Expect(files).To(Equal(10))
+		})
+
+		It("should put items exceeding the size into a part of their own", func() {
+			parts, err := SplitCloudConfig(config, 10)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(parts).To(HaveLen(12))
+			Expect(string(parts[0].Content)).To(Equal("#cloud-config\nhostname: foo\n"))
+		})
+
+		It("should fail for documents that are no cloud-config", func() {
+			_, err := SplitCloudConfig([]byte("#!/bin/bash\n"), 1024)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,98 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudinit
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const pipelineSeparator = "+"
+
+var registry = struct {
+	sync.RWMutex
+	codecs  map[FileCodecID]FileCodec
+	aliases map[string]FileCodecID
+}{
+	codecs: map[FileCodecID]FileCodec{
+		B64FileCodecID:   B64FileCodec,
+		GZIPFileCodecID:  GZIPFileCodec,
+		BZIP2FileCodecID: BZIP2FileCodec,
+	},
+	aliases: map[string]FileCodecID{
+		"base64": B64FileCodecID,
+		"gz":     GZIPFileCodecID,
+		"bz2":    BZIP2FileCodecID,
+	},
+}
+
+// RegisterFileCodec registers the given FileCodec under the given id and aliases, replacing any
+// codec registered before. It can be used to add further formats, e.g. a zstd decoder. The id must
+// not contain the pipeline separator `+`.
+func RegisterFileCodec(id FileCodecID, codec FileCodec, aliases ...string) {
+	if id == "" || strings.Contains(string(id), pipelineSeparator) || codec == nil {
+		panic(fmt.Sprintf("cannot register file codec %q", id))
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+	registry.codecs[id] = codec
+	for _, alias := range aliases {
+		registry.aliases[alias] = id
+	}
+}
+
+// ParseFileCodecID tries to parse a string into a FileCodecID. Each element of a pipeline must be
+// the id or an alias of a registered FileCodec. The returned id is canonical, i.e. aliases are
+// replaced by the ids they stand for, e.g. `gz+base64` is parsed into `gzip+b64`.
+func ParseFileCodecID(s string) (FileCodecID, error) {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	var ids []string
+	for _, element := range strings.Split(s, pipelineSeparator) {
+		id, ok := registry.aliases[element]
+		if !ok {
+			id = FileCodecID(element)
+		}
+		if _, ok := registry.codecs[id]; !ok {
+			return FileCodecID(s), fmt.Errorf("invalid file codec id %q", s)
+		}
+		ids = append(ids, string(id))
+	}
+	return FileCodecID(strings.Join(ids, pipelineSeparator)), nil
+}
+
+// FileCodecForID retrieves the FileCodec for the given FileCodecID. If the id cannot be parsed,
+// the methods of the returned FileCodec fail.
+func FileCodecForID(id FileCodecID) FileCodec {
+	canonical, err := ParseFileCodecID(string(id))
+	if err != nil {
+		return invalidFileCodec{err}
+	}
+
+	registry.RLock()
+	defer registry.RUnlock()
+
+	var pipeline fileCodecPipeline
+	for _, element := range strings.Split(string(canonical), pipelineSeparator) {
+		pipeline = append(pipeline, registry.codecs[FileCodecID(element)])
+	}
+	if len(pipeline) == 1 {
+		return pipeline[0]
+	}
+	return pipeline
+}
@@ -0,0 +1,260 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package memoryclient contains an in-memory client.Client for places without access to an API
+// server, like tests and the render command of the controllers.
+package memoryclient
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+type objectKey struct {
+	gvk       schema.GroupVersionKind
+	namespace string
+	name      string
+}
+
+// Client is an in-memory client.Client that can be used where there is no access to a real API
+// server. It stores deep copies of all objects it is given and does not perform any
+// defaulting, validation or conflict detection.
+type Client struct {
+	scheme *runtime.Scheme
+
+	lock            sync.RWMutex
+	objects         map[objectKey]runtime.Object
+	resourceVersion int
+}
+
+var _ client.Client = &Client{}
+
+// New creates a new in-memory Client for the given scheme, pre-populated with the given objects.
+func New(scheme *runtime.Scheme, objs ...runtime.Object) (*Client, error) {
+	c := &Client{
+		scheme:  scheme,
+		objects: make(map[objectKey]runtime.Object),
+	}
+
+	for _, obj := range objs {
+		if err := c.Create(context.TODO(), obj); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (c *Client) keyFor(obj runtime.Object) (objectKey, error) {
+	gvk, err := apiutil.GVKForObject(obj, c.scheme)
+	if err != nil {
+		return objectKey{}, err
+	}
+
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return objectKey{}, err
+	}
+
+	return objectKey{gvk: gvk, namespace: accessor.GetNamespace(), name: accessor.GetName()}, nil
+}
+
+func notFound(key objectKey) error {
+	return apierrors.NewNotFound(schema.GroupResource{Group: key.gvk.Group, Resource: strings.ToLower(key.gvk.Kind)}, key.name)
+}
+
+func copyInto(src, dst runtime.Object) {
+	reflect.ValueOf(dst).Elem().Set(reflect.ValueOf(src.DeepCopyObject()).Elem())
+}
+
+// Get implements client.Client.
+func (c *Client) Get(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+	k, err := c.keyFor(obj)
+	if err != nil {
+		return err
+	}
+	k.namespace, k.name = key.Namespace, key.Name
+
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	stored, ok := c.objects[k]
+	if !ok {
+		return notFound(k)
+	}
+
+	copyInto(stored, obj)
+	return nil
+}
+
+// List implements client.Client.
+func (c *Client) List(_ context.Context, opts *client.ListOptions, list runtime.Object) error {
+	gvk, err := apiutil.GVKForObject(list, c.scheme)
+	if err != nil {
+		return err
+	}
+	if !strings.HasSuffix(gvk.Kind, "List") {
+		return fmt.Errorf("non-list type %T (kind %q) passed as output", list, gvk)
+	}
+	gvk.Kind = strings.TrimSuffix(gvk.Kind, "List")
+
+	var (
+		namespace string
+		selector  = labels.Everything()
+	)
+	if opts != nil {
+		namespace = opts.Namespace
+		if opts.LabelSelector != nil {
+			selector = opts.LabelSelector
+		}
+	}
+
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	var items []runtime.Object
+	for key, obj := range c.objects {
+		if key.gvk != gvk || (namespace != "" && key.namespace != namespace) {
+			continue
+		}
+
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return err
+		}
+		if !selector.Matches(labels.Set(accessor.GetLabels())) {
+			continue
+		}
+
+		items = append(items, obj.DeepCopyObject())
+	}
+
+	return meta.SetList(list, items)
+}
+
+// Create implements client.Client.
+func (c *Client) Create(_ context.Context, obj runtime.Object) error {
+	key, err := c.keyFor(obj)
+	if err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, ok := c.objects[key]; ok {
+		return apierrors.NewAlreadyExists(schema.GroupResource{Group: key.gvk.Group, Resource: strings.ToLower(key.gvk.Kind)}, key.name)
+	}
+
+	return c.store(key, obj)
+}
+
+// Update implements client.Client.
+func (c *Client) Update(_ context.Context, obj runtime.Object) error {
+	key, err := c.keyFor(obj)
+	if err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, ok := c.objects[key]; !ok {
+		return notFound(key)
+	}
+
+	return c.store(key, obj)
+}
+
+// Delete implements client.Client.
+func (c *Client) Delete(_ context.Context, obj runtime.Object, _ ...client.DeleteOptionFunc) error {
+	key, err := c.keyFor(obj)
+	if err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, ok := c.objects[key]; !ok {
+		return notFound(key)
+	}
+
+	delete(c.objects, key)
+	return nil
+}
+
+// Status implements client.Client. Status updates only change the `Status` field of objects
+// that have one, like the status subresource of an API server does.
+func (c *Client) Status() client.StatusWriter {
+	return &statusWriter{c}
+}
+
+type statusWriter struct {
+	client *Client
+}
+
+// Update implements client.StatusWriter.
+func (w *statusWriter) Update(_ context.Context, obj runtime.Object) error {
+	c := w.client
+	key, err := c.keyFor(obj)
+	if err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	stored, ok := c.objects[key]
+	if !ok {
+		return notFound(key)
+	}
+
+	status := reflect.ValueOf(obj).Elem().FieldByName("Status")
+	if !status.IsValid() {
+		return c.store(key, obj)
+	}
+
+	updated := stored.DeepCopyObject()
+	reflect.ValueOf(updated).Elem().FieldByName("Status").Set(status)
+	if err := c.store(key, updated); err != nil {
+		return err
+	}
+	copyInto(c.objects[key], obj)
+	return nil
+}
+
+func (c *Client) store(key objectKey, obj runtime.Object) error {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return err
+	}
+
+	c.resourceVersion++
+	accessor.SetResourceVersion(strconv.Itoa(c.resourceVersion))
+
+	c.objects[key] = obj.DeepCopyObject()
+	return nil
+}
@@ -31,3 +31,21 @@ type Actuator interface {
 	// Exists checks whether the given config currently exists.
 	Exists(ctx context.Context, config *extensionsv1alpha1.OperatingSystemConfig) (bool, error)
 }
+
+// MigrationActuator is an optional extension of an Actuator that supports moving an
+// OperatingSystemConfig to another seed.
+type MigrationActuator interface {
+	// Migrate exports the state of the actuator into the status of the given config without
+	// deleting any generated artifacts.
+	Migrate(ctx context.Context, config *extensionsv1alpha1.OperatingSystemConfig) error
+	// Restore rehydrates the actuator from the state of the given config and reconciles it.
+	Restore(ctx context.Context, config *extensionsv1alpha1.OperatingSystemConfig) error
+}
+
+// InputWatcher is an optional extension of an Actuator whose results depend on inputs besides
+// the OperatingSystemConfigs and the secrets they reference, e.g. templates mounted from a ConfigMap.
+type InputWatcher interface {
+	// WatchInputs watches the inputs until the given stop channel is closed. It calls the given
+	// function whenever they changed, so that all OperatingSystemConfigs are reconciled again.
+	WatchInputs(stop <-chan struct{}, changed func())
+}
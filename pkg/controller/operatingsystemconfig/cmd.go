@@ -16,6 +16,7 @@ package operatingsystemconfig
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"time"
@@ -31,8 +32,10 @@ import (
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
@@ -90,6 +93,8 @@ func (m *ManagerOptions) AddFlags(fs *pflag.FlagSet) {
 // ActuatorArgs are arguments given to the instantiation of an Actuator.
 type ActuatorArgs struct {
 	Log logr.Logger
+	// Config is the actuator specific section of the configuration file, if any.
+	Config json.RawMessage
 }
 
 // ControllerOptions are options used for the creation of a Controller.
@@ -100,11 +105,56 @@ type ControllerOptions struct {
 	Predicates              []predicate.Predicate
 	ActuatorFactory         ActuatorFactory
 	MaxConcurrentReconciles int
+	// AdditionalTypes are further types of OperatingSystemConfigs handled by the controller.
+	AdditionalTypes []string
+	// Mutators are applied to the OperatingSystemConfigs before the mutators of the configuration file.
+	Mutators []NamedMutator
+	// MutatorFactories are the factories of the mutator types that can be used in the
+	// configuration file. Defaults to DefaultMutatorFactories.
+	MutatorFactories map[string]MutatorFactory
+	// ConfigFile is the path of the ControllerConfiguration file.
+	ConfigFile string
+	// Annotations are the keys of annotations whose changes trigger a reconciliation.
+	Annotations []string
+	// DuplicateFilePolicy defines how files with the same path are handled if the configuration
+	// file does not define it.
+	DuplicateFilePolicy DuplicateFilePolicy
 }
 
 // AddFlags adds all ControllerOptions relevant flags to the given FlagSet.
 func (c *ControllerOptions) AddFlags(fs *pflag.FlagSet) {
 	fs.IntVar(&c.MaxConcurrentReconciles, "max-concurrent-reconciles", c.MaxConcurrentReconciles, "The maximum number of concurrent reconciliations.")
+	fs.StringVar(&c.ConfigFile, "config-file", c.ConfigFile, "Path of the controller configuration file, e.g. configuring mutators and the actuator.")
+}
+
+func (c *ControllerOptions) configuration() (*ControllerConfiguration, error) {
+	if c.ConfigFile == "" {
+		return &ControllerConfiguration{}, nil
+	}
+	return LoadControllerConfiguration(c.ConfigFile)
+}
+
+func (c *ControllerOptions) mutators(config *ControllerConfiguration) (MutatorChain, error) {
+	mutators := append(MutatorChain{}, c.Mutators...)
+
+	factories := c.MutatorFactories
+	if factories == nil {
+		factories = DefaultMutatorFactories()
+	}
+
+	configured, err := NewMutatorChain(config.Mutators, factories)
+	if err != nil {
+		return nil, err
+	}
+	return append(mutators, configured...), nil
+}
+
+func (c *ControllerOptions) duplicateFilePolicy(config *ControllerConfiguration) (DuplicateFilePolicy, error) {
+	policy := c.DuplicateFilePolicy
+	if config.DuplicateFilePolicy != "" {
+		policy = config.DuplicateFilePolicy
+	}
+	return policy, policy.Validate()
 }
 
 // Config produces a ControllerConfig used for instantiating a Controller.
@@ -115,23 +165,44 @@ func (c *ControllerOptions) Config() (*ControllerConfig, error) {
 	}
 	log = log.WithName(c.Name)
 
-	actuator, err := c.ActuatorFactory(&ActuatorArgs{Log: log.WithName("actuator")})
+	config, err := c.configuration()
+	if err != nil {
+		return nil, err
+	}
+
+	actuator, err := c.ActuatorFactory(&ActuatorArgs{Log: log.WithName("actuator"), Config: config.Actuator})
+	if err != nil {
+		return nil, err
+	}
+
+	mutators, err := c.mutators(config)
+	if err != nil {
+		return nil, err
+	}
+
+	filePolicy, err := c.duplicateFilePolicy(config)
 	if err != nil {
 		return nil, err
 	}
 
 	predicates := c.Predicates
 	if predicates == nil {
-		predicates = []predicate.Predicate{GenerationChangedPredicate()}
+		predicates = []predicate.Predicate{Or(GenerationChangedPredicate(), OperationAnnotationPredicate(), AnnotationsChangedPredicate(c.Annotations...))}
 	}
-	predicates = append(predicates, TypePredicate(c.Type))
+	predicates = append(predicates, TypePredicate(append([]string{c.Type}, c.AdditionalTypes...)...))
+
+	watcher, _ := actuator.(InputWatcher)
 
 	return &ControllerConfig{
-		Name: c.Name,
-		Log:  log.WithName("controller"),
+		Name:         c.Name,
+		Log:          log.WithName("controller"),
+		InputWatcher: watcher,
 		Options: controller.Options{
 			MaxConcurrentReconciles: c.MaxConcurrentReconciles,
-			Reconciler:              NewReconciler(log.WithName("reconciler"), actuator),
+			Reconciler: NewReconcilerWithOptions(log.WithName("reconciler"), actuator, ReconcilerOptions{
+				Mutators:            mutators,
+				DuplicateFilePolicy: filePolicy,
+			}),
 		},
 		Predicates: predicates,
 	}, nil
@@ -160,6 +231,8 @@ func (c *CommandOptions) Flags() cmd.NamedFlagSet {
 // MapperOptions are options used for creating a secretToOSCMapper.
 type MapperOptions struct {
 	Type string
+	// AdditionalTypes are further types of OperatingSystemConfigs handled by the controller.
+	AdditionalTypes []string
 }
 
 // NewMapperOptions creates new MapperOptions with the given name.
@@ -172,7 +245,7 @@ func NewMapperOptions(typeName string) *MapperOptions {
 // Config returns the MapperConfig for the MapperOptions.
 func (m *MapperOptions) Config() (*MapperConfig, error) {
 	return &MapperConfig{
-		Type: m.Type,
+		Types: append([]string{m.Type}, m.AdditionalTypes...),
 	}, nil
 }
 
@@ -188,13 +261,14 @@ func NewManagerOptions(name string) *ManagerOptions {
 type ActuatorFactory func(*ActuatorArgs) (Actuator, error)
 
 // NewControllerOptions creates new ControllerOptions with the given name, type name and
-// actuator factory.
+// actuator factory. Changes of the ContainerRuntimeAnnotation trigger a reconciliation.
 func NewControllerOptions(name, typeName string, actuatorFactory ActuatorFactory) *ControllerOptions {
 	return &ControllerOptions{
 		Name:                    name,
 		Type:                    typeName,
 		ActuatorFactory:         actuatorFactory,
 		MaxConcurrentReconciles: DefaultMaxConcurrentReconciles,
+		Annotations:             []string{ContainerRuntimeAnnotation},
 	}
 }
 
@@ -248,11 +322,13 @@ type ControllerConfig struct {
 	Log        logr.Logger
 	Predicates []predicate.Predicate
 	Options    controller.Options
+	// InputWatcher is the actuator if it implements InputWatcher, nil otherwise.
+	InputWatcher InputWatcher
 }
 
 // MapperConfig is the configuration for creating the secretToOSCMapper.
 type MapperConfig struct {
-	Type string
+	Types []string
 }
 
 // CommandConfig is the configuration for creating a operating system config command.
@@ -278,6 +354,11 @@ type CompletedConfig struct {
 	*completedConfig
 }
 
+// recorderInjector is implemented by reconcilers that report events.
+type recorderInjector interface {
+	InjectRecorder(record.EventRecorder) error
+}
+
 // Run runs the operating system config command with the given completed configuration.
 func Run(ctx context.Context, config *CompletedConfig) error {
 	log := config.Controller.Log.WithName("entrypoint")
@@ -295,15 +376,44 @@ func Run(ctx context.Context, config *CompletedConfig) error {
 		return err
 	}
 
+	if r, ok := config.Controller.Options.Reconciler.(recorderInjector); ok {
+		if err := r.InjectRecorder(mgr.GetRecorder(config.Controller.Name)); err != nil {
+			log.Error(err, "Could not inject event recorder")
+			return err
+		}
+	}
+
 	if err := ctrl.Watch(&source.Kind{Type: &extensionsv1alpha1.OperatingSystemConfig{}}, &handler.EnqueueRequestForObject{}, config.Controller.Predicates...); err != nil {
 		log.Error(err, "Could not watch operating system configs")
 		return err
 	}
 
-	if err := ctrl.Watch(&source.Kind{Type: &corev1.Secret{}}, &handler.EnqueueRequestsFromMapFunc{ToRequests: SecretToOSCMapper(mgr.GetClient(), config.Mapper.Type)}); err != nil {
+	if err := ctrl.Watch(&source.Kind{Type: &corev1.Secret{}}, &handler.EnqueueRequestsFromMapFunc{ToRequests: SecretToOSCMapper(mgr.GetClient(), config.Mapper.Types...)}); err != nil {
 		log.Error(err, "Could not watch secrets")
 		return err
 	}
 
+	if watcher := config.Controller.InputWatcher; watcher != nil {
+		// Changes are coalesced, a pending event already reconciles all configs.
+		events := make(chan event.GenericEvent, 1)
+		if err := mgr.Add(manager.RunnableFunc(func(stop <-chan struct{}) error {
+			watcher.WatchInputs(stop, func() {
+				select {
+				case events <- event.GenericEvent{}:
+				default:
+				}
+			})
+			return nil
+		})); err != nil {
+			log.Error(err, "Could not add watcher of actuator inputs")
+			return err
+		}
+
+		if err := ctrl.Watch(&source.Channel{Source: events}, &handler.EnqueueRequestsFromMapFunc{ToRequests: TypeToOSCMapper(mgr.GetClient(), config.Mapper.Types...)}); err != nil {
+			log.Error(err, "Could not watch actuator inputs")
+			return err
+		}
+	}
+
 	return mgr.Start(ctx.Done())
 }
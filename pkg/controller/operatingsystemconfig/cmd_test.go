@@ -0,0 +1,44 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operatingsystemconfig_test
+
+import (
+	"github.com/gardener/gardener-extensions/pkg/controller/operatingsystemconfig"
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+var _ = Describe("Command", func() {
+	Describe("#NewControllerOptions", func() {
+		It("should reconcile on changes of the container runtime annotation", func() {
+			opts := operatingsystemconfig.NewControllerOptions("test", "test", func(*operatingsystemconfig.ActuatorArgs) (operatingsystemconfig.Actuator, error) {
+				return nil, nil
+			})
+			Expect(opts.Annotations).To(ConsistOf(operatingsystemconfig.ContainerRuntimeAnnotation))
+
+			config, err := opts.Config()
+			Expect(err).NotTo(HaveOccurred())
+
+			oldObj := &extensionsv1alpha1.OperatingSystemConfig{ObjectMeta: metav1.ObjectMeta{Generation: 1}}
+			newObj := oldObj.DeepCopy()
+			newObj.Annotations = map[string]string{operatingsystemconfig.ContainerRuntimeAnnotation: "containerd"}
+			Expect(config.Predicates[0].Update(event.UpdateEvent{MetaOld: oldObj, ObjectOld: oldObj, MetaNew: newObj, ObjectNew: newObj})).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,307 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package conformance contains a ginkgo test suite that every operatingsystemconfig.Actuator
+// is expected to pass.
+//
+// Extensions run it from one of their test files:
+//
+//	var _ = conformance.DescribeActuator(coreos.Type, func() operatingsystemconfig.Actuator {
+//		return coreos.NewActuator(log.Log, coreos.Options{})
+//	}, nil)
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/gardener/gardener-extensions/pkg/controller"
+	"github.com/gardener/gardener-extensions/pkg/controller/memoryclient"
+	"github.com/gardener/gardener-extensions/pkg/controller/operatingsystemconfig"
+	"github.com/gardener/gardener-extensions/pkg/simulator"
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/inject"
+)
+
+// ActuatorFactory creates the actuator under test.
+type ActuatorFactory func() operatingsystemconfig.Actuator
+
+// Options configure the conformance suite.
+type Options struct {
+	// Fixtures are the OperatingSystemConfigs the actuator is tested with. Defaults to DefaultFixtures.
+	Fixtures []Fixture
+	// Scheme is the scheme injected into the actuator. Defaults to operatingsystemconfig.ExtensionsScheme.
+	Scheme *runtime.Scheme
+	// SkipCommand disables the check for `.status.command` if a reload config file path is given.
+	SkipCommand bool
+	// SkipUnits disables the check for `.status.units`.
+	SkipUnits bool
+	// Format is the format of the rendered result. If set, the result is applied to a simulated
+	// machine and compared with the OperatingSystemConfig.
+	Format simulator.Format
+	// SkipUnitStates disables the check whether the units have been enabled and started as
+	// requested on the simulated machine.
+	SkipUnitStates bool
+	// HTTPGet answers the HTTP requests of the rendered result on the simulated machine, e.g. to
+	// a metadata service. If nil, all requests fail.
+	HTTPGet func(url string) ([]byte, error)
+}
+
+func (o *Options) complete(typeName string) *Options {
+	out := &Options{}
+	if o != nil {
+		*out = *o
+	}
+	if out.Fixtures == nil {
+		out.Fixtures = DefaultFixtures(typeName)
+	}
+	if out.Scheme == nil {
+		out.Scheme = operatingsystemconfig.ExtensionsScheme
+	}
+	return out
+}
+
+// DescribeActuator registers the conformance specs for the actuators created by the given factory.
+// It has to be called during ginkgo's tree construction phase, e.g. in a `var _ =` declaration.
+func DescribeActuator(typeName string, factory ActuatorFactory, opts *Options) bool {
+	opts = opts.complete(typeName)
+
+	return ginkgo.Describe(fmt.Sprintf("Actuator conformance for type %q", typeName), func() {
+		for _, fixture := range opts.Fixtures {
+			describeFixture(factory, opts, fixture)
+		}
+	})
+}
+
+func describeFixture(factory ActuatorFactory, opts *Options, fixture Fixture) {
+	ginkgo.Context(fixture.Name, func() {
+		var (
+			ctx      context.Context
+			c        *memoryclient.Client
+			actuator operatingsystemconfig.Actuator
+			osc      *extensionsv1alpha1.OperatingSystemConfig
+		)
+
+		ginkgo.BeforeEach(func() {
+			ctx = context.TODO()
+			osc = fixture.Config.DeepCopy()
+
+			objs := []runtime.Object{osc.DeepCopy()}
+			for _, obj := range fixture.Objects {
+				objs = append(objs, obj.DeepCopyObject())
+			}
+
+			var err error
+			c, err = memoryclient.New(opts.Scheme, objs...)
+			gomega.Expect(err).NotTo(gomega.HaveOccurred())
+
+			actuator = factory()
+			_, err = inject.SchemeInto(opts.Scheme, actuator)
+			gomega.Expect(err).NotTo(gomega.HaveOccurred())
+			_, err = inject.ClientInto(c, actuator)
+			gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		})
+
+		resultData := func() []byte {
+			gomega.Expect(osc.Status.CloudConfig).NotTo(gomega.BeNil())
+
+			secret := &corev1.Secret{}
+			ref := osc.Status.CloudConfig.SecretRef
+			gomega.Expect(c.Get(ctx, client.ObjectKey{Namespace: ref.Namespace, Name: ref.Name}, secret)).To(gomega.Succeed())
+			gomega.Expect(secret.Data).To(gomega.HaveKey(extensionsv1alpha1.OperatingSystemConfigSecretDataKey))
+
+			return secret.Data[extensionsv1alpha1.OperatingSystemConfigSecretDataKey]
+		}
+
+		ginkgo.It("should have units without lint problems", func() {
+			gomega.Expect(operatingsystemconfig.LintUnits(osc.Spec.Units)).To(gomega.BeEmpty())
+		})
+
+		ginkgo.It("should not exist before creation", func() {
+			gomega.Expect(actuator.Exists(ctx, osc)).To(gomega.BeFalse())
+		})
+
+		ginkgo.It("should set the required status fields", func() {
+			gomega.Expect(actuator.Create(ctx, osc)).To(gomega.Succeed())
+
+			gomega.Expect(osc.Status.CloudConfig).NotTo(gomega.BeNil())
+			gomega.Expect(osc.Status.CloudConfig.SecretRef.Name).NotTo(gomega.BeEmpty())
+			gomega.Expect(osc.Status.CloudConfig.SecretRef.Namespace).To(gomega.Equal(osc.Namespace))
+			gomega.Expect(osc.Status.ObservedGeneration).To(gomega.Equal(osc.Generation))
+			gomega.Expect(osc.Status.LastError).To(gomega.BeNil())
+			gomega.Expect(osc.Status.LastOperation).NotTo(gomega.BeNil())
+			gomega.Expect(osc.Status.LastOperation.Type).To(gomega.Equal(extensionsv1alpha1.LastOperationTypeReconcile))
+			gomega.Expect(osc.Status.LastOperation.State).To(gomega.Equal(extensionsv1alpha1.LastOperationStateSucceeded))
+
+			if path := osc.Spec.ReloadConfigFilePath; path != nil && !opts.SkipCommand {
+				gomega.Expect(osc.Status.Command).To(gomega.ContainSubstring(*path))
+			}
+
+			if !opts.SkipUnits {
+				for _, unit := range osc.Spec.Units {
+					gomega.Expect(osc.Status.Units).To(gomega.ContainElement(unit.Name))
+				}
+			}
+
+			gomega.Expect(actuator.Exists(ctx, osc)).To(gomega.BeTrue())
+		})
+
+		ginkgo.It("should persist the status", func() {
+			gomega.Expect(actuator.Create(ctx, osc)).To(gomega.Succeed())
+
+			stored := &extensionsv1alpha1.OperatingSystemConfig{}
+			gomega.Expect(c.Get(ctx, client.ObjectKey{Namespace: osc.Namespace, Name: osc.Name}, stored)).To(gomega.Succeed())
+			gomega.Expect(stored.Status.CloudConfig).To(gomega.Equal(osc.Status.CloudConfig))
+			gomega.Expect(stored.Status.Command).To(gomega.Equal(osc.Status.Command))
+			gomega.Expect(stored.Status.Units).To(gomega.Equal(osc.Status.Units))
+		})
+
+		ginkgo.It("should write a result secret containing all files and units", func() {
+			gomega.Expect(actuator.Create(ctx, osc)).To(gomega.Succeed())
+
+			data := string(resultData())
+			gomega.Expect(data).NotTo(gomega.BeEmpty())
+
+			for _, file := range osc.Spec.Files {
+				gomega.Expect(data).To(gomega.ContainSubstring(file.Path), "file %q missing in output", file.Path)
+			}
+			for _, unit := range osc.Spec.Units {
+				gomega.Expect(data).To(gomega.ContainSubstring(unit.Name), "unit %q missing in output", unit.Name)
+				for _, dropIn := range unit.DropIns {
+					gomega.Expect(data).To(gomega.ContainSubstring(dropIn.Name), "drop-in %q of unit %q missing in output", dropIn.Name, unit.Name)
+				}
+			}
+		})
+
+		if opts.Format != "" {
+			ginkgo.It("should render a result that yields the specified files and units", func() {
+				gomega.Expect(actuator.Create(ctx, osc)).To(gomega.Succeed())
+
+				root, err := ioutil.TempDir("", "conformance")
+				gomega.Expect(err).NotTo(gomega.HaveOccurred())
+				defer func() { _ = os.RemoveAll(root) }()
+
+				sim := simulator.New(root)
+				sim.HTTPGet = opts.HTTPGet
+				gomega.Expect(sim.Apply(opts.Format, resultData())).To(gomega.Succeed())
+
+				manifest, err := sim.Manifest()
+				gomega.Expect(err).NotTo(gomega.HaveOccurred())
+				gomega.Expect(manifest.Verify(&osc.Spec, func(content *extensionsv1alpha1.FileContent) ([]byte, error) {
+					if ref := content.SecretRef; ref != nil {
+						secret := &corev1.Secret{}
+						if err := c.Get(ctx, client.ObjectKey{Namespace: osc.Namespace, Name: ref.Name}, secret); err != nil {
+							return nil, err
+						}
+						return secret.Data[ref.DataKey], nil
+					}
+					return simulator.InlineContentResolver(content)
+				})).To(gomega.Succeed())
+
+				if !opts.SkipUnitStates {
+					gomega.Expect(manifest.VerifyUnitStates(&osc.Spec)).To(gomega.Succeed())
+				}
+			})
+		}
+
+		ginkgo.It("should produce the same result when updated", func() {
+			gomega.Expect(actuator.Create(ctx, osc)).To(gomega.Succeed())
+			created := resultData()
+
+			gomega.Expect(actuator.Update(ctx, osc)).To(gomega.Succeed())
+			gomega.Expect(resultData()).To(gomega.Equal(created))
+			gomega.Expect(osc.Status.LastOperation.State).To(gomega.Equal(extensionsv1alpha1.LastOperationStateSucceeded))
+		})
+
+		if !opts.SkipUnits {
+			ginkgo.It("should only report units whose effective configuration changed", func() {
+				gomega.Expect(actuator.Create(ctx, osc)).To(gomega.Succeed())
+				created := osc.Status.Units
+
+				gomega.Expect(actuator.Update(ctx, osc)).To(gomega.Succeed())
+				gomega.Expect(osc.Status.Units).To(gomega.Equal(created), "units must be kept while the result is unchanged")
+
+				if len(osc.Spec.Units) == 0 {
+					return
+				}
+				changed := &osc.Spec.Units[len(osc.Spec.Units)-1]
+				changed.DropIns = append(changed.DropIns, extensionsv1alpha1.DropIn{Name: "99-conformance.conf", Content: "[Unit]\nDescription=changed\n"})
+				gomega.Expect(actuator.Update(ctx, osc)).To(gomega.Succeed())
+				gomega.Expect(osc.Status.Units).To(gomega.Equal([]string{changed.Name}))
+			})
+		}
+
+		if _, ok := factory().(operatingsystemconfig.MigrationActuator); ok {
+			ginkgo.It("should migrate and restore", func() {
+				gomega.Expect(actuator.Create(ctx, osc)).To(gomega.Succeed())
+				created := resultData()
+				secretRef := osc.Status.CloudConfig.SecretRef
+
+				gomega.Expect(actuator.(operatingsystemconfig.MigrationActuator).Migrate(ctx, osc)).To(gomega.Succeed())
+				gomega.Expect(osc.Status.LastOperation).NotTo(gomega.BeNil())
+				gomega.Expect(osc.Status.LastOperation.Type).To(gomega.Equal(controller.LastOperationTypeMigrate))
+				gomega.Expect(osc.Status.LastOperation.State).To(gomega.Equal(extensionsv1alpha1.LastOperationStateSucceeded))
+				gomega.Expect(resultData()).To(gomega.Equal(created), "migration must not delete generated artifacts")
+
+				// Only the state is handed over to the target seed.
+				restored := fixture.Config.DeepCopy()
+				restored.Status.State = osc.Status.State
+				restored.Annotations = map[string]string{controller.OperationAnnotation: controller.OperationRestore}
+				objs := []runtime.Object{restored.DeepCopy()}
+				for _, obj := range fixture.Objects {
+					objs = append(objs, obj.DeepCopyObject())
+				}
+
+				var err error
+				c, err = memoryclient.New(opts.Scheme, objs...)
+				gomega.Expect(err).NotTo(gomega.HaveOccurred())
+				target := factory()
+				_, err = inject.SchemeInto(opts.Scheme, target)
+				gomega.Expect(err).NotTo(gomega.HaveOccurred())
+				_, err = inject.ClientInto(c, target)
+				gomega.Expect(err).NotTo(gomega.HaveOccurred())
+
+				osc = restored
+				gomega.Expect(target.(operatingsystemconfig.MigrationActuator).Restore(ctx, osc)).To(gomega.Succeed())
+				gomega.Expect(osc.Status.LastOperation).NotTo(gomega.BeNil())
+				gomega.Expect(osc.Status.LastOperation.Type).To(gomega.Equal(controller.LastOperationTypeRestore))
+				gomega.Expect(osc.Status.LastOperation.State).To(gomega.Equal(extensionsv1alpha1.LastOperationStateSucceeded))
+				gomega.Expect(osc.Status.CloudConfig.SecretRef).To(gomega.Equal(secretRef))
+				gomega.Expect(resultData()).To(gomega.Equal(created))
+				gomega.Expect(target.Exists(ctx, osc)).To(gomega.BeTrue())
+				if !opts.SkipUnits {
+					gomega.Expect(osc.Status.Units).To(gomega.BeEmpty(), "restoration must not report unchanged units")
+				}
+			})
+		}
+
+		ginkgo.It("should delete successfully", func() {
+			gomega.Expect(actuator.Create(ctx, osc)).To(gomega.Succeed())
+			gomega.Expect(actuator.Delete(ctx, osc)).To(gomega.Succeed())
+
+			gomega.Expect(osc.Status.LastOperation).NotTo(gomega.BeNil())
+			gomega.Expect(osc.Status.LastOperation.Type).To(gomega.Equal(extensionsv1alpha1.LastOperationTypeDelete))
+			gomega.Expect(osc.Status.LastOperation.State).To(gomega.Equal(extensionsv1alpha1.LastOperationStateSucceeded))
+		})
+	})
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conformance
+
+import (
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Namespace is the namespace all fixtures live in.
+const Namespace = "shoot--conformance--test"
+
+// Fixture is a single OperatingSystemConfig the conformance suite is run with, together with
+// all objects it references.
+type Fixture struct {
+	// Name describes the fixture.
+	Name string
+	// Config is the OperatingSystemConfig given to the actuator.
+	Config *extensionsv1alpha1.OperatingSystemConfig
+	// Objects are additional objects (e.g. referenced secrets) that exist before the actuator runs.
+	Objects []runtime.Object
+}
+
+func newOSC(name, typeName string, purpose extensionsv1alpha1.OperatingSystemConfigPurpose) *extensionsv1alpha1.OperatingSystemConfig {
+	return &extensionsv1alpha1.OperatingSystemConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       name,
+			Namespace:  Namespace,
+			Generation: 1,
+		},
+		Spec: extensionsv1alpha1.OperatingSystemConfigSpec{
+			DefaultSpec: extensionsv1alpha1.DefaultSpec{Type: typeName},
+			Purpose:     purpose,
+		},
+	}
+}
+
+func strPtr(s string) *string { return &s }
+
+func boolPtr(b bool) *bool { return &b }
+
+func int32Ptr(i int32) *int32 { return &i }
+
+// DefaultFixtures returns the set of fixtures every actuator for the given type has to handle.
+func DefaultFixtures(typeName string) []Fixture {
+	var (
+		empty = newOSC("empty", typeName, extensionsv1alpha1.OperatingSystemConfigPurposeProvision)
+
+		unitWithContent = newOSC("unit-with-content", typeName, extensionsv1alpha1.OperatingSystemConfigPurposeProvision)
+
+		unitWithoutContent = newOSC("unit-without-content", typeName, extensionsv1alpha1.OperatingSystemConfigPurposeProvision)
+
+		files = newOSC("files", typeName, extensionsv1alpha1.OperatingSystemConfigPurposeProvision)
+
+		secretRef = newOSC("secret-ref", typeName, extensionsv1alpha1.OperatingSystemConfigPurposeProvision)
+		secret    = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "kubelet-ca", Namespace: Namespace},
+			Data:       map[string][]byte{"ca.crt": []byte("certificate")},
+		}
+
+		reload = newOSC("reload", typeName, extensionsv1alpha1.OperatingSystemConfigPurposeReconcile)
+	)
+
+	unitWithContent.Spec.Units = []extensionsv1alpha1.Unit{
+		{
+			Name:    "docker-monitor.service",
+			Command: strPtr("start"),
+			Enable:  boolPtr(true),
+			Content: strPtr("[Unit]\nDescription=Docker monitor\n[Install]\nWantedBy=multi-user.target\n[Service]\nExecStart=/opt/bin/health-monitor docker\n"),
+		},
+	}
+
+	unitWithoutContent.Spec.Units = []extensionsv1alpha1.Unit{
+		{
+			Name: "docker.service",
+			DropIns: []extensionsv1alpha1.DropIn{
+				{
+					Name:    "10-docker-opts.conf",
+					Content: "[Service]\nEnvironment=\"DOCKER_OPTS=--log-opt max-size=60m\"\n",
+				},
+			},
+		},
+	}
+
+	files.Spec.Files = []extensionsv1alpha1.File{
+		{
+			Path:        "/etc/sysctl.d/99-k8s-general.conf",
+			Permissions: int32Ptr(0644),
+			Content: extensionsv1alpha1.FileContent{
+				Inline: &extensionsv1alpha1.FileContentInline{Data: "vm.max_map_count = 135217728\n"},
+			},
+		},
+		{
+			Path: "/var/lib/kubelet/default-permissions",
+			Content: extensionsv1alpha1.FileContent{
+				Inline: &extensionsv1alpha1.FileContentInline{Data: "data"},
+			},
+		},
+		{
+			Path:        "/opt/bin/health-monitor",
+			Permissions: int32Ptr(0755),
+			Content: extensionsv1alpha1.FileContent{
+				Inline: &extensionsv1alpha1.FileContentInline{Encoding: "b64", Data: "IyEvYmluL2Jhc2gK"},
+			},
+		},
+	}
+
+	secretRef.Spec.Files = []extensionsv1alpha1.File{
+		{
+			Path:        "/var/lib/kubelet/ca.crt",
+			Permissions: int32Ptr(0600),
+			Content: extensionsv1alpha1.FileContent{
+				SecretRef: &extensionsv1alpha1.FileContentSecretRef{Name: secret.Name, DataKey: "ca.crt"},
+			},
+		},
+	}
+
+	reload.Spec.ReloadConfigFilePath = strPtr("/var/lib/cloud-config-downloader/downloads/cloud_config")
+	reload.Spec.Units = append(unitWithContent.Spec.Units, unitWithoutContent.Spec.Units...)
+	reload.Spec.Files = files.Spec.Files
+
+	return []Fixture{
+		{Name: "without units and files", Config: empty},
+		{Name: "with a unit with content", Config: unitWithContent},
+		{Name: "with a unit consisting of drop-ins only", Config: unitWithoutContent},
+		{Name: "with inline files and permissions", Config: files},
+		{Name: "with a file referencing a secret", Config: secretRef, Objects: []runtime.Object{secret}},
+		{Name: "with a reload config file path", Config: reload},
+	}
+}
@@ -16,16 +16,21 @@ package operatingsystemconfig
 
 import (
 	"context"
+	"fmt"
+	"strings"
 
 	"github.com/gardener/gardener-extensions/pkg/controller"
+	"github.com/gardener/gardener-extensions/pkg/systemd"
 
 	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
 
 	"github.com/go-logr/logr"
 	"sigs.k8s.io/controller-runtime/pkg/runtime/inject"
 
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
@@ -38,19 +43,46 @@ const (
 // operatingSystemConfigReconciler reconciles OperatingSystemConfig resources of Gardener's
 // `extensions.gardener.cloud` API group.
 type operatingSystemConfigReconciler struct {
-	logger   logr.Logger
-	actuator Actuator
+	logger     logr.Logger
+	actuator   Actuator
+	mutators   MutatorChain
+	filePolicy DuplicateFilePolicy
 
-	ctx    context.Context
-	client client.Client
+	ctx      context.Context
+	client   client.Client
+	recorder record.EventRecorder
 }
 
 var _ reconcile.Reconciler = &operatingSystemConfigReconciler{}
 
+// ReconcilerOptions are options for the creation of a reconciler.
+type ReconcilerOptions struct {
+	// Mutators are applied to the OperatingSystemConfigs before they are passed to the actuator
+	// for creation, update or restoration.
+	Mutators []NamedMutator
+	// DuplicateFilePolicy defines how files with the same path are handled when normalizing
+	// the OperatingSystemConfigs. Defaults to DuplicateFilePolicyLastWins.
+	DuplicateFilePolicy DuplicateFilePolicy
+}
+
 // NewReconciler creates a new reconcile.Reconciler that reconciles
 // OperatingSystemConfig resources of Gardener's `extensions.gardener.cloud` API group.
-func NewReconciler(logger logr.Logger, actuator Actuator) reconcile.Reconciler {
-	return &operatingSystemConfigReconciler{logger: logger, actuator: actuator}
+// The given mutators are applied to the OperatingSystemConfigs before they are passed to the
+// actuator for creation, update or restoration.
+func NewReconciler(logger logr.Logger, actuator Actuator, mutators ...NamedMutator) reconcile.Reconciler {
+	return NewReconcilerWithOptions(logger, actuator, ReconcilerOptions{Mutators: mutators})
+}
+
+// NewReconcilerWithOptions creates a new reconcile.Reconciler that reconciles
+// OperatingSystemConfig resources of Gardener's `extensions.gardener.cloud` API group with the
+// given options.
+func NewReconcilerWithOptions(logger logr.Logger, actuator Actuator, opts ReconcilerOptions) reconcile.Reconciler {
+	return &operatingSystemConfigReconciler{
+		logger:     logger,
+		actuator:   actuator,
+		mutators:   opts.Mutators,
+		filePolicy: opts.DuplicateFilePolicy,
+	}
 }
 
 // InjectFunc enables dependency injection into the actuator.
@@ -64,6 +96,12 @@ func (r *operatingSystemConfigReconciler) InjectClient(client client.Client) err
 	return nil
 }
 
+// InjectRecorder injects the event recorder used to report problems of the units into the reconciler.
+func (r *operatingSystemConfigReconciler) InjectRecorder(recorder record.EventRecorder) error {
+	r.recorder = recorder
+	return nil
+}
+
 // InjectStopChannel is an implementation for getting the respective stop channel managed by the controller-runtime.
 func (r *operatingSystemConfigReconciler) InjectStopChannel(stopCh <-chan struct{}) error {
 	r.ctx = controller.ContextFromStopChannel(stopCh)
@@ -82,20 +120,36 @@ func (r *operatingSystemConfigReconciler) Reconcile(request reconcile.Request) (
 		return reconcile.Result{}, err
 	}
 
+	switch osc.Annotations[controller.OperationAnnotation] {
+	case controller.OperationMigrate:
+		return r.migrate(r.ctx, osc)
+	case controller.OperationRestore:
+		return r.restore(r.ctx, osc)
+	}
+
 	if osc.DeletionTimestamp != nil {
 		return r.delete(r.ctx, osc)
 	}
+	if controller.IsMigrated(osc.Status.LastOperation) {
+		r.logger.Info("Reconciling operating system config causes a no-op as it has been migrated.", "osc", osc.Name)
+		return reconcile.Result{}, nil
+	}
 	return r.reconcile(r.ctx, osc)
 }
 
-func (r *operatingSystemConfigReconciler) reconcile(ctx context.Context, osc *extensionsv1alpha1.OperatingSystemConfig) (reconcile.Result, error) {
-	// Add finalizer to resource if not yet done.
+func (r *operatingSystemConfigReconciler) addFinalizer(ctx context.Context, osc *extensionsv1alpha1.OperatingSystemConfig) error {
 	if finalizers := sets.NewString(osc.Finalizers...); !finalizers.Has(FinalizerName) {
 		finalizers.Insert(FinalizerName)
 		osc.Finalizers = finalizers.UnsortedList()
-		if err := r.client.Update(ctx, osc); err != nil {
-			return reconcile.Result{}, err
-		}
+		return r.client.Update(ctx, osc)
+	}
+	return nil
+}
+
+func (r *operatingSystemConfigReconciler) reconcile(ctx context.Context, osc *extensionsv1alpha1.OperatingSystemConfig) (reconcile.Result, error) {
+	// Add finalizer to resource if not yet done.
+	if err := r.addFinalizer(ctx, osc); err != nil {
+		return reconcile.Result{}, err
 	}
 
 	exist, err := r.actuator.Exists(ctx, osc)
@@ -105,20 +159,128 @@ func (r *operatingSystemConfigReconciler) reconcile(ctx context.Context, osc *ex
 
 	if exist {
 		r.logger.Info("Reconciling operating system config triggers idempotent update.", "osc", osc.Name)
-		if err := r.actuator.Update(ctx, osc); err != nil {
+		if err := r.mutateAndRun(ctx, osc, r.actuator.Update); err != nil {
 			return controller.ReconcileErr(err)
 		}
 		return reconcile.Result{}, nil
 	}
 
 	r.logger.Info("Reconciling operating system config triggers idempotent create.", "osc", osc.Name)
-	if err := r.actuator.Create(ctx, osc); err != nil {
+	if err := r.mutateAndRun(ctx, osc, r.actuator.Create); err != nil {
 		r.logger.Error(err, "Unable to create operating system config", "osc", osc.Name)
 		return controller.ReconcileErr(err)
 	}
 	return reconcile.Result{}, nil
 }
 
+// mutateAndRun applies the mutators to a deep copy of the given config, normalizes it, lints its
+// units and runs the given operation of the actuator with it. Afterwards, the status of the copy is transferred
+// back to the given config and the applied mutators are recorded.
+func (r *operatingSystemConfigReconciler) mutateAndRun(ctx context.Context, osc *extensionsv1alpha1.OperatingSystemConfig, run func(context.Context, *extensionsv1alpha1.OperatingSystemConfig) error) error {
+	mutated, applied, err := r.mutators.Apply(ctx, osc)
+	if err != nil {
+		r.reportError(ctx, osc, fmt.Sprintf("Could not mutate operating system config: %v", err))
+		return err
+	}
+
+	merges, err := Normalize(&mutated.Spec, r.filePolicy)
+	if err != nil {
+		r.reportError(ctx, osc, fmt.Sprintf("Could not normalize operating system config: %v", err))
+		return err
+	}
+	if r.recorder != nil {
+		for _, merge := range merges {
+			r.recorder.Event(osc, corev1.EventTypeNormal, EventReasonMerged, merge)
+		}
+	}
+
+	if err := r.lintUnits(ctx, osc, mutated); err != nil {
+		return err
+	}
+
+	err = run(ctx, mutated)
+	osc.ResourceVersion = mutated.ResourceVersion
+	osc.Status = mutated.Status
+	if err != nil {
+		return err
+	}
+
+	return r.recordMutators(ctx, osc, applied)
+}
+
+// lintUnits lints the units of the given mutated config and reports the found problems as
+// warning events of the given config. If errors are found, they are recorded as last error.
+func (r *operatingSystemConfigReconciler) lintUnits(ctx context.Context, osc, mutated *extensionsv1alpha1.OperatingSystemConfig) error {
+	problems := LintUnits(mutated.Spec.Units)
+	if r.recorder != nil {
+		for _, problem := range problems {
+			r.recorder.Event(osc, corev1.EventTypeWarning, EventReasonUnitLint, problem.String())
+		}
+	}
+	if !systemd.HasErrors(problems) {
+		return nil
+	}
+
+	var messages []string
+	for _, problem := range problems {
+		if problem.Severity == systemd.SeverityError {
+			messages = append(messages, problem.String())
+		}
+	}
+	err := fmt.Errorf("invalid units:\n%s", strings.Join(messages, "\n"))
+	r.reportError(ctx, osc, err.Error())
+	return err
+}
+
+// reportError records the given error message as last error of the given config.
+func (r *operatingSystemConfigReconciler) reportError(ctx context.Context, osc *extensionsv1alpha1.OperatingSystemConfig, message string) {
+	osc.Status.ObservedGeneration = osc.Generation
+	osc.Status.LastOperation, osc.Status.LastError = controller.ReconcileError(extensionsv1alpha1.LastOperationTypeReconcile, message, 0)
+	if err := r.client.Status().Update(ctx, osc); err != nil {
+		r.logger.Error(err, "Could not update operating system config status after error", "osc", osc.Name)
+	}
+}
+
+// recordMutators records the names of the applied mutators in the status of the given config and
+// in the annotations of its result secret.
+func (r *operatingSystemConfigReconciler) recordMutators(ctx context.Context, osc *extensionsv1alpha1.OperatingSystemConfig, applied []string) error {
+	if len(applied) > 0 && osc.Status.LastOperation != nil {
+		suffix := fmt.Sprintf(" (applied mutators: %s)", strings.Join(applied, ", "))
+		if !strings.HasSuffix(osc.Status.LastOperation.Description, suffix) {
+			osc.Status.LastOperation.Description += suffix
+			if err := r.client.Status().Update(ctx, osc); err != nil {
+				return err
+			}
+		}
+	}
+
+	if osc.Status.CloudConfig == nil {
+		return nil
+	}
+
+	secret := &corev1.Secret{}
+	ref := osc.Status.CloudConfig.SecretRef
+	if err := r.client.Get(ctx, client.ObjectKey{Namespace: ref.Namespace, Name: ref.Name}, secret); err != nil {
+		return err
+	}
+
+	value, ok := secret.Annotations[MutatorsAnnotation]
+	switch {
+	case len(applied) == 0 && !ok:
+		return nil
+	case len(applied) == 0:
+		delete(secret.Annotations, MutatorsAnnotation)
+	case value == strings.Join(applied, ","):
+		return nil
+	default:
+		if secret.Annotations == nil {
+			secret.Annotations = make(map[string]string)
+		}
+		secret.Annotations[MutatorsAnnotation] = strings.Join(applied, ",")
+	}
+	return r.client.Update(ctx, secret)
+}
+
 func (r *operatingSystemConfigReconciler) delete(ctx context.Context, osc *extensionsv1alpha1.OperatingSystemConfig) (reconcile.Result, error) {
 	finalizers := sets.NewString(osc.Finalizers...)
 	if !finalizers.Has(FinalizerName) {
@@ -140,3 +302,61 @@ func (r *operatingSystemConfigReconciler) delete(ctx context.Context, osc *exten
 	}
 	return reconcile.Result{}, nil
 }
+
+func (r *operatingSystemConfigReconciler) migrate(ctx context.Context, osc *extensionsv1alpha1.OperatingSystemConfig) (reconcile.Result, error) {
+	if actuator, ok := r.actuator.(MigrationActuator); ok {
+		r.logger.Info("Migrating operating system config.", "osc", osc.Name)
+		if err := actuator.Migrate(ctx, osc); err != nil {
+			r.logger.Error(err, "Error migrating operating system config", "osc", osc.Name)
+			return controller.ReconcileErr(err)
+		}
+	}
+
+	// Record the migration independent of the actuator so that later reconciliations are no-ops.
+	osc.Status.ObservedGeneration = osc.Generation
+	osc.Status.LastOperation, osc.Status.LastError = controller.ReconcileSucceeded(controller.LastOperationTypeMigrate, "Successfully migrated operating system config")
+	if err := r.client.Status().Update(ctx, osc); err != nil {
+		r.logger.Error(err, "Could not update operating system config status for migration", "osc", osc.Name)
+		return reconcile.Result{}, err
+	}
+
+	// Release the finalizer so that the config can be deleted without deleting the generated artifacts.
+	r.logger.Info("Operating system config migration successful, removing finalizer and operation annotation.", "osc", osc.Name)
+	finalizers := sets.NewString(osc.Finalizers...)
+	finalizers.Delete(FinalizerName)
+	osc.Finalizers = finalizers.UnsortedList()
+	delete(osc.Annotations, controller.OperationAnnotation)
+	if err := r.client.Update(ctx, osc); err != nil {
+		r.logger.Error(err, "Error releasing migrated operating system config", "osc", osc.Name)
+		return reconcile.Result{}, err
+	}
+	return reconcile.Result{}, nil
+}
+
+func (r *operatingSystemConfigReconciler) restore(ctx context.Context, osc *extensionsv1alpha1.OperatingSystemConfig) (reconcile.Result, error) {
+	if err := r.addFinalizer(ctx, osc); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	if actuator, ok := r.actuator.(MigrationActuator); ok {
+		r.logger.Info("Restoring operating system config.", "osc", osc.Name)
+		if err := r.mutateAndRun(ctx, osc, actuator.Restore); err != nil {
+			r.logger.Error(err, "Error restoring operating system config", "osc", osc.Name)
+			return controller.ReconcileErr(err)
+		}
+	} else {
+		r.logger.Info("Restoring operating system config triggers idempotent create.", "osc", osc.Name)
+		if err := r.mutateAndRun(ctx, osc, r.actuator.Create); err != nil {
+			r.logger.Error(err, "Unable to restore operating system config", "osc", osc.Name)
+			return controller.ReconcileErr(err)
+		}
+	}
+
+	r.logger.Info("Operating system config restoration successful, removing operation annotation.", "osc", osc.Name)
+	delete(osc.Annotations, controller.OperationAnnotation)
+	if err := r.client.Update(ctx, osc); err != nil {
+		r.logger.Error(err, "Error removing operation annotation from operating system config", "osc", osc.Name)
+		return reconcile.Result{}, err
+	}
+	return reconcile.Result{}, nil
+}
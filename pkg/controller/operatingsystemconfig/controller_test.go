@@ -0,0 +1,355 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operatingsystemconfig_test
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gardener/gardener-extensions/pkg/controller"
+	"github.com/gardener/gardener-extensions/pkg/controller/memoryclient"
+	"github.com/gardener/gardener-extensions/pkg/controller/operatingsystemconfig"
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/inject"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+// recordingActuator records the operations it has been called with.
+type recordingActuator struct {
+	operations []string
+}
+
+func (a *recordingActuator) Create(_ context.Context, _ *extensionsv1alpha1.OperatingSystemConfig) error {
+	a.operations = append(a.operations, "create")
+	return nil
+}
+
+func (a *recordingActuator) Delete(_ context.Context, _ *extensionsv1alpha1.OperatingSystemConfig) error {
+	a.operations = append(a.operations, "delete")
+	return nil
+}
+
+func (a *recordingActuator) Update(_ context.Context, _ *extensionsv1alpha1.OperatingSystemConfig) error {
+	a.operations = append(a.operations, "update")
+	return nil
+}
+
+func (a *recordingActuator) Exists(_ context.Context, _ *extensionsv1alpha1.OperatingSystemConfig) (bool, error) {
+	return false, nil
+}
+
+// recordingMigrationActuator additionally records migrations and restorations.
+type recordingMigrationActuator struct {
+	recordingActuator
+}
+
+func (a *recordingMigrationActuator) Migrate(_ context.Context, _ *extensionsv1alpha1.OperatingSystemConfig) error {
+	a.operations = append(a.operations, "migrate")
+	return nil
+}
+
+func (a *recordingMigrationActuator) Restore(_ context.Context, _ *extensionsv1alpha1.OperatingSystemConfig) error {
+	a.operations = append(a.operations, "restore")
+	return nil
+}
+
+// secretActuator writes a result secret containing the names of all files and reports success.
+type secretActuator struct {
+	recordingActuator
+	client client.Client
+}
+
+func (a *secretActuator) InjectClient(c client.Client) error {
+	a.client = c
+	return nil
+}
+
+func (a *secretActuator) Create(ctx context.Context, config *extensionsv1alpha1.OperatingSystemConfig) error {
+	var paths []string
+	for _, file := range config.Spec.Files {
+		paths = append(paths, file.Path)
+	}
+
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: config.Namespace, Name: "osc-result"}}
+	if err := controller.CreateOrUpdate(ctx, a.client, secret, func() error {
+		secret.Data = map[string][]byte{extensionsv1alpha1.OperatingSystemConfigSecretDataKey: []byte(strings.Join(paths, ","))}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	config.Status.CloudConfig = &extensionsv1alpha1.CloudConfig{SecretRef: corev1.SecretReference{Namespace: secret.Namespace, Name: secret.Name}}
+	config.Status.LastOperation, config.Status.LastError = controller.ReconcileSucceeded(extensionsv1alpha1.LastOperationTypeReconcile, "Successfully generated cloud config")
+	return a.client.Status().Update(ctx, config)
+}
+
+var _ = Describe("Reconciler", func() {
+	var (
+		ctx      = context.TODO()
+		request  = reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "shoot--foo--bar", Name: "osc"}}
+		c        *memoryclient.Client
+		mutators []operatingsystemconfig.NamedMutator
+	)
+
+	BeforeEach(func() {
+		mutators = nil
+	})
+
+	newReconciler := func(actuator operatingsystemconfig.Actuator, osc *extensionsv1alpha1.OperatingSystemConfig) reconcile.Reconciler {
+		var err error
+		c, err = memoryclient.New(operatingsystemconfig.ExtensionsScheme, osc)
+		Expect(err).NotTo(HaveOccurred())
+
+		r := operatingsystemconfig.NewReconciler(log.Log, actuator, mutators...)
+		_, err = inject.ClientInto(c, r)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = inject.InjectorInto(func(i interface{}) error {
+			_, err := inject.ClientInto(c, i)
+			return err
+		}, r)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = inject.StopChannelInto(make(chan struct{}), r)
+		Expect(err).NotTo(HaveOccurred())
+		return r
+	}
+
+	newConfig := func(operation string, finalizers ...string) *extensionsv1alpha1.OperatingSystemConfig {
+		osc := &extensionsv1alpha1.OperatingSystemConfig{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:  request.Namespace,
+				Name:       request.Name,
+				Finalizers: finalizers,
+			},
+		}
+		if operation != "" {
+			osc.Annotations = map[string]string{controller.OperationAnnotation: operation}
+		}
+		return osc
+	}
+
+	stored := func() *extensionsv1alpha1.OperatingSystemConfig {
+		osc := &extensionsv1alpha1.OperatingSystemConfig{}
+		Expect(c.Get(ctx, request.NamespacedName, osc)).To(Succeed())
+		return osc
+	}
+
+	It("should migrate and release the finalizer", func() {
+		actuator := &recordingMigrationActuator{}
+		r := newReconciler(actuator, newConfig(controller.OperationMigrate, operatingsystemconfig.FinalizerName))
+
+		_, err := r.Reconcile(request)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(actuator.operations).To(Equal([]string{"migrate"}))
+		Expect(stored().Finalizers).To(BeEmpty())
+		Expect(stored().Annotations).NotTo(HaveKey(controller.OperationAnnotation))
+	})
+
+	It("should release the finalizer of actuators without migration support", func() {
+		actuator := &recordingActuator{}
+		r := newReconciler(actuator, newConfig(controller.OperationMigrate, operatingsystemconfig.FinalizerName))
+
+		_, err := r.Reconcile(request)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(actuator.operations).To(BeEmpty())
+		Expect(stored().Finalizers).To(BeEmpty())
+		Expect(controller.IsMigrated(stored().Status.LastOperation)).To(BeTrue())
+	})
+
+	It("should not reconcile configs migrated by actuators without migration support", func() {
+		actuator := &recordingActuator{}
+		r := newReconciler(actuator, newConfig(controller.OperationMigrate, operatingsystemconfig.FinalizerName))
+
+		_, err := r.Reconcile(request)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = r.Reconcile(request)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(actuator.operations).To(BeEmpty())
+		Expect(stored().Finalizers).To(BeEmpty())
+	})
+
+	It("should not reconcile migrated configs", func() {
+		actuator := &recordingMigrationActuator{}
+		osc := newConfig("")
+		osc.Status.LastOperation, _ = controller.ReconcileSucceeded(controller.LastOperationTypeMigrate, "migrated")
+		r := newReconciler(actuator, osc)
+
+		_, err := r.Reconcile(request)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(actuator.operations).To(BeEmpty())
+		Expect(stored().Finalizers).To(BeEmpty())
+	})
+
+	It("should restore and add the finalizer", func() {
+		actuator := &recordingMigrationActuator{}
+		r := newReconciler(actuator, newConfig(controller.OperationRestore))
+
+		_, err := r.Reconcile(request)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(actuator.operations).To(Equal([]string{"restore"}))
+		Expect(stored().Finalizers).To(ConsistOf(operatingsystemconfig.FinalizerName))
+		Expect(stored().Annotations).NotTo(HaveKey(controller.OperationAnnotation))
+	})
+
+	It("should create configs of actuators without migration support on restore", func() {
+		actuator := &recordingActuator{}
+		r := newReconciler(actuator, newConfig(controller.OperationRestore))
+
+		_, err := r.Reconcile(request)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(actuator.operations).To(Equal([]string{"create"}))
+		Expect(stored().Finalizers).To(ConsistOf(operatingsystemconfig.FinalizerName))
+	})
+
+	It("should render mutated configs and record the applied mutators", func() {
+		mutators = []operatingsystemconfig.NamedMutator{
+			{
+				Name: "ca-bundle",
+				Mutator: operatingsystemconfig.MutatorFunc(func(_ context.Context, spec *extensionsv1alpha1.OperatingSystemConfigSpec) error {
+					spec.Files = append(spec.Files, extensionsv1alpha1.File{Path: "/etc/ssl/ca.pem"})
+					return nil
+				}),
+			},
+		}
+		r := newReconciler(&secretActuator{}, newConfig(""))
+
+		_, err := r.Reconcile(request)
+		Expect(err).NotTo(HaveOccurred())
+
+		osc := stored()
+		Expect(osc.Spec.Files).To(BeEmpty())
+		Expect(osc.Status.LastOperation.Description).To(ContainSubstring("applied mutators: ca-bundle"))
+
+		secret := &corev1.Secret{}
+		Expect(c.Get(ctx, client.ObjectKey{Namespace: request.Namespace, Name: "osc-result"}, secret)).To(Succeed())
+		Expect(string(secret.Data[extensionsv1alpha1.OperatingSystemConfigSecretDataKey])).To(Equal("/etc/ssl/ca.pem"))
+		Expect(secret.Annotations).To(HaveKeyWithValue(operatingsystemconfig.MutatorsAnnotation, "ca-bundle"))
+
+		_, err = r.Reconcile(request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(strings.Count(stored().Status.LastOperation.Description, "applied mutators")).To(Equal(1))
+	})
+
+	Describe("unit linting", func() {
+		var recorder *record.FakeRecorder
+
+		BeforeEach(func() {
+			recorder = record.NewFakeRecorder(10)
+		})
+
+		newLintingReconciler := func(actuator operatingsystemconfig.Actuator, osc *extensionsv1alpha1.OperatingSystemConfig) reconcile.Reconciler {
+			r := newReconciler(actuator, osc)
+			Expect(r.(interface {
+				InjectRecorder(record.EventRecorder) error
+			}).InjectRecorder(recorder)).To(Succeed())
+			return r
+		}
+
+		events := func() []string {
+			var out []string
+			for len(recorder.Events) > 0 {
+				out = append(out, <-recorder.Events)
+			}
+			return out
+		}
+
+		It("should report warnings as events and render the config", func() {
+			osc := newConfig("")
+			osc.Spec.Units = []extensionsv1alpha1.Unit{{Name: "foo.service", Content: strPtr("[Unit]\nDescripton=foo\n")}}
+			actuator := &recordingActuator{}
+			r := newLintingReconciler(actuator, osc)
+
+			_, err := r.Reconcile(request)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(actuator.operations).To(Equal([]string{"create"}))
+			Expect(events()).To(Equal([]string{
+				`Warning UnitLint Warning: foo.service:2: unknown key "Descripton" in section [Unit]`,
+			}))
+		})
+
+		It("should report merges as events and render the normalized config", func() {
+			osc := newConfig("")
+			osc.Spec.Files = []extensionsv1alpha1.File{{Path: "/etc/foo"}, {Path: "/etc/foo"}}
+			actuator := &secretActuator{}
+			r := newLintingReconciler(actuator, osc)
+
+			_, err := r.Reconcile(request)
+			Expect(err).NotTo(HaveOccurred())
+
+			secret := &corev1.Secret{}
+			Expect(c.Get(ctx, client.ObjectKey{Namespace: request.Namespace, Name: "osc-result"}, secret)).To(Succeed())
+			Expect(string(secret.Data[extensionsv1alpha1.OperatingSystemConfigSecretDataKey])).To(Equal("/etc/foo"))
+			Expect(events()).To(Equal([]string{
+				`Normal Merged merged duplicate file "/etc/foo", the last file wins`,
+			}))
+		})
+
+		It("should not render configs with duplicate files with the reject policy", func() {
+			osc := newConfig("")
+			osc.Spec.Files = []extensionsv1alpha1.File{{Path: "/etc/foo"}, {Path: "/etc/foo"}}
+			actuator := &recordingActuator{}
+			r := operatingsystemconfig.NewReconcilerWithOptions(log.Log, actuator, operatingsystemconfig.ReconcilerOptions{DuplicateFilePolicy: operatingsystemconfig.DuplicateFilePolicyReject})
+			var err error
+			c, err = memoryclient.New(operatingsystemconfig.ExtensionsScheme, osc)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = inject.ClientInto(c, r)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = inject.StopChannelInto(make(chan struct{}), r)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = r.Reconcile(request)
+			Expect(err).To(HaveOccurred())
+
+			Expect(actuator.operations).To(BeEmpty())
+			Expect(stored().Status.LastError).NotTo(BeNil())
+			Expect(stored().Status.LastError.Description).To(ContainSubstring("duplicate paths"))
+		})
+
+		It("should not render configs with invalid units and record the error", func() {
+			osc := newConfig("")
+			osc.Spec.Units = []extensionsv1alpha1.Unit{
+				{Name: "a.service", Content: strPtr("[Unit]\nAfter=b.service\n")},
+				{Name: "b.service", Content: strPtr("[Unit]\nAfter=a.service\n")},
+			}
+			actuator := &recordingActuator{}
+			r := newLintingReconciler(actuator, osc)
+
+			_, err := r.Reconcile(request)
+			Expect(err).To(HaveOccurred())
+
+			Expect(actuator.operations).To(BeEmpty())
+			Expect(events()).To(Equal([]string{
+				"Warning UnitLint Error: a.service: ordering cycle: a.service -> b.service -> a.service",
+			}))
+			Expect(stored().Status.LastError).NotTo(BeNil())
+			Expect(stored().Status.LastError.Description).To(ContainSubstring("ordering cycle"))
+		})
+	})
+})
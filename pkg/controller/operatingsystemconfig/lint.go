@@ -0,0 +1,44 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operatingsystemconfig
+
+import (
+	"github.com/gardener/gardener-extensions/pkg/systemd"
+
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+)
+
+const (
+	// EventReasonUnitLint is the reason of events about problems found in the units of an
+	// OperatingSystemConfig.
+	EventReasonUnitLint = "UnitLint"
+)
+
+// LintUnits lints the content and drop-ins of the given units of an OperatingSystemConfig.
+func LintUnits(units []extensionsv1alpha1.Unit) []systemd.Problem {
+	lintUnits := make([]systemd.Unit, 0, len(units))
+	for _, unit := range units {
+		lintUnit := systemd.Unit{
+			Name:    unit.Name,
+			Enable:  unit.Enable != nil && *unit.Enable,
+			Content: unit.Content,
+		}
+		for _, dropIn := range unit.DropIns {
+			lintUnit.DropIns = append(lintUnit.DropIns, systemd.DropIn{Name: dropIn.Name, Content: dropIn.Content})
+		}
+		lintUnits = append(lintUnits, lintUnit)
+	}
+	return systemd.Lint(lintUnits)
+}
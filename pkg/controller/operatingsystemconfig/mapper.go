@@ -21,6 +21,7 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
 
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
@@ -28,8 +29,8 @@ import (
 )
 
 type secretToOSCMapper struct {
-	client   client.Client
-	typeName string
+	client    client.Client
+	typeNames sets.String
 }
 
 func (m *secretToOSCMapper) Map(obj handler.MapObject) []reconcile.Request {
@@ -52,7 +53,7 @@ func (m *secretToOSCMapper) Map(obj handler.MapObject) []reconcile.Request {
 	var requests []reconcile.Request
 
 	for _, osc := range oscList.Items {
-		if osc.Spec.Type != m.typeName {
+		if !m.typeNames.Has(osc.Spec.Type) {
 			continue
 		}
 
@@ -71,11 +72,45 @@ func (m *secretToOSCMapper) Map(obj handler.MapObject) []reconcile.Request {
 	return requests
 }
 
-// SecretToOSCMapper returns a mapper that returns requests for OperatingSystemConfigs whose
-// referenced secrets have been modified.
-func SecretToOSCMapper(client client.Client, typeName string) handler.Mapper {
+// SecretToOSCMapper returns a mapper that returns requests for OperatingSystemConfigs of the
+// given types whose referenced secrets have been modified.
+func SecretToOSCMapper(client client.Client, typeNames ...string) handler.Mapper {
 	return &secretToOSCMapper{
-		client:   client,
-		typeName: typeName,
+		client:    client,
+		typeNames: sets.NewString(typeNames...),
+	}
+}
+
+type typeToOSCMapper struct {
+	client    client.Client
+	typeNames sets.String
+}
+
+func (m *typeToOSCMapper) Map(handler.MapObject) []reconcile.Request {
+	oscList := &extensions1alpha1.OperatingSystemConfigList{}
+	if err := m.client.List(context.TODO(), &client.ListOptions{}, oscList); err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, osc := range oscList.Items {
+		if m.typeNames.Has(osc.Spec.Type) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Namespace: osc.Namespace,
+					Name:      osc.Name,
+				},
+			})
+		}
+	}
+	return requests
+}
+
+// TypeToOSCMapper returns a mapper that returns requests for all OperatingSystemConfigs of the
+// given types, whatever object is mapped.
+func TypeToOSCMapper(client client.Client, typeNames ...string) handler.Mapper {
+	return &typeToOSCMapper{
+		client:    client,
+		typeNames: sets.NewString(typeNames...),
 	}
 }
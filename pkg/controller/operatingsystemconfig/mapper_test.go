@@ -0,0 +1,50 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operatingsystemconfig_test
+
+import (
+	"github.com/gardener/gardener-extensions/pkg/controller/memoryclient"
+	"github.com/gardener/gardener-extensions/pkg/controller/operatingsystemconfig"
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("Mapper", func() {
+	Describe("#TypeToOSCMapper", func() {
+		It("should return requests for all configs of the given types", func() {
+			newOSC := func(namespace, name, typeName string) *extensionsv1alpha1.OperatingSystemConfig {
+				return &extensionsv1alpha1.OperatingSystemConfig{
+					ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+					Spec:       extensionsv1alpha1.OperatingSystemConfigSpec{DefaultSpec: extensionsv1alpha1.DefaultSpec{Type: typeName}},
+				}
+			}
+			c, err := memoryclient.New(operatingsystemconfig.ExtensionsScheme,
+				newOSC("foo", "a", "coreos"), newOSC("bar", "b", "coreos"), newOSC("foo", "c", "ubuntu"))
+			Expect(err).NotTo(HaveOccurred())
+
+			requests := operatingsystemconfig.TypeToOSCMapper(c, "coreos").Map(handler.MapObject{})
+			Expect(requests).To(ConsistOf(
+				reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "foo", Name: "a"}},
+				reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "bar", Name: "b"}},
+			))
+		})
+	})
+})
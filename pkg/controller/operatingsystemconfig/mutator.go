@@ -0,0 +1,299 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operatingsystemconfig
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// MutatorsAnnotation is the annotation of result secrets that lists the names of the mutators
+	// that have been applied to the rendered OperatingSystemConfig.
+	MutatorsAnnotation = "operatingsystemconfig.extensions.gardener.cloud/mutators"
+
+	// StaticMutatorType is the type of the built-in mutator that adds static files and units.
+	StaticMutatorType = "static"
+)
+
+// Mutator modifies the spec of OperatingSystemConfigs before they are rendered, e.g. to add
+// files or units to every node.
+type Mutator interface {
+	// Mutate modifies the given spec. It is a deep copy that is only passed to the actuator.
+	Mutate(ctx context.Context, spec *extensionsv1alpha1.OperatingSystemConfigSpec) error
+}
+
+// MutatorFunc is a function that implements Mutator.
+type MutatorFunc func(ctx context.Context, spec *extensionsv1alpha1.OperatingSystemConfigSpec) error
+
+// Mutate implements Mutator.
+func (f MutatorFunc) Mutate(ctx context.Context, spec *extensionsv1alpha1.OperatingSystemConfigSpec) error {
+	return f(ctx, spec)
+}
+
+// MutatorSelector selects the OperatingSystemConfigs a mutator is applied to. Empty fields match
+// all OperatingSystemConfigs.
+type MutatorSelector struct {
+	// Namespaces are the namespaces of the selected OperatingSystemConfigs.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Labels selects OperatingSystemConfigs by their labels.
+	Labels *metav1.LabelSelector `json:"labels,omitempty"`
+	// Purposes are the purposes of the selected OperatingSystemConfigs.
+	Purposes []extensionsv1alpha1.OperatingSystemConfigPurpose `json:"purposes,omitempty"`
+}
+
+// Matches returns whether the given OperatingSystemConfig is selected.
+func (s *MutatorSelector) Matches(config *extensionsv1alpha1.OperatingSystemConfig) (bool, error) {
+	if s == nil {
+		return true, nil
+	}
+
+	if len(s.Namespaces) > 0 && !sets.NewString(s.Namespaces...).Has(config.Namespace) {
+		return false, nil
+	}
+
+	if len(s.Purposes) > 0 {
+		var found bool
+		for _, purpose := range s.Purposes {
+			if purpose == config.Spec.Purpose {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false, nil
+		}
+	}
+
+	if s.Labels != nil {
+		selector, err := metav1.LabelSelectorAsSelector(s.Labels)
+		if err != nil {
+			return false, err
+		}
+		if !selector.Matches(labels.Set(config.Labels)) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// NamedMutator is a Mutator with a name that is applied to the selected OperatingSystemConfigs.
+type NamedMutator struct {
+	// Name is the name of the mutator. It is recorded for every OperatingSystemConfig it is applied to.
+	Name string
+	// Selector selects the OperatingSystemConfigs the mutator is applied to. If nil, it is applied
+	// to all OperatingSystemConfigs.
+	Selector *MutatorSelector
+	// Mutator is the actual mutator.
+	Mutator Mutator
+}
+
+// MutatorChain is an ordered list of mutators.
+type MutatorChain []NamedMutator
+
+// Apply applies all mutators selecting the given config to a deep copy of it. It returns the
+// mutated copy and the names of the applied mutators.
+func (c MutatorChain) Apply(ctx context.Context, config *extensionsv1alpha1.OperatingSystemConfig) (*extensionsv1alpha1.OperatingSystemConfig, []string, error) {
+	mutated := config.DeepCopy()
+
+	var applied []string
+	for _, mutator := range c {
+		ok, err := mutator.Selector.Matches(config)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid selector of mutator %q: %v", mutator.Name, err)
+		}
+		if !ok {
+			continue
+		}
+
+		if err := mutator.Mutator.Mutate(ctx, &mutated.Spec); err != nil {
+			return nil, nil, fmt.Errorf("mutator %q failed: %v", mutator.Name, err)
+		}
+		applied = append(applied, mutator.Name)
+	}
+
+	return mutated, applied, nil
+}
+
+// MutatorFactory creates a Mutator from its raw configuration.
+type MutatorFactory func(config json.RawMessage) (Mutator, error)
+
+// DefaultMutatorFactories returns the factories of the built-in mutator types.
+func DefaultMutatorFactories() map[string]MutatorFactory {
+	return map[string]MutatorFactory{
+		StaticMutatorType: NewStaticMutatorFromConfig,
+	}
+}
+
+// MutatorConfiguration is the configuration of a single mutator.
+type MutatorConfiguration struct {
+	// Name is the name of the mutator.
+	Name string `json:"name"`
+	// Type is the type of the mutator, e.g. `static`.
+	Type string `json:"type"`
+	// Selector selects the OperatingSystemConfigs the mutator is applied to.
+	Selector *MutatorSelector `json:"selector,omitempty"`
+	// Config is the type specific configuration of the mutator.
+	Config json.RawMessage `json:"config,omitempty"`
+}
+
+// ControllerConfiguration is the content of the configuration file of an operating system
+// config controller.
+type ControllerConfiguration struct {
+	// Mutators are the mutators applied to OperatingSystemConfigs before they are rendered, in order.
+	Mutators []MutatorConfiguration `json:"mutators,omitempty"`
+	// Actuator is the actuator specific configuration, it is passed to the ActuatorFactory.
+	Actuator json.RawMessage `json:"actuator,omitempty"`
+	// DuplicateFilePolicy defines how files with the same path are handled, either `LastWins`
+	// (default) or `Reject`.
+	DuplicateFilePolicy DuplicateFilePolicy `json:"duplicateFilePolicy,omitempty"`
+}
+
+// LoadControllerConfiguration reads the ControllerConfiguration from the given YAML or JSON file.
+// Unknown fields are rejected.
+func LoadControllerConfiguration(path string) (*ControllerConfiguration, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &ControllerConfiguration{}
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, fmt.Errorf("could not decode controller configuration %q: %v", path, err)
+	}
+	return config, nil
+}
+
+// NewMutatorChain creates the MutatorChain described by the given configurations using the
+// given factories.
+func NewMutatorChain(configs []MutatorConfiguration, factories map[string]MutatorFactory) (MutatorChain, error) {
+	var (
+		chain MutatorChain
+		names = sets.NewString()
+	)
+
+	for _, config := range configs {
+		if config.Name == "" {
+			return nil, fmt.Errorf("mutator without name")
+		}
+		if strings.Contains(config.Name, ",") {
+			return nil, fmt.Errorf("name of mutator %q must not contain commas", config.Name)
+		}
+		if names.Has(config.Name) {
+			return nil, fmt.Errorf("duplicate mutator %q", config.Name)
+		}
+		names.Insert(config.Name)
+
+		factory, ok := factories[config.Type]
+		if !ok {
+			return nil, fmt.Errorf("mutator %q has unknown type %q", config.Name, config.Type)
+		}
+
+		mutator, err := factory(config.Config)
+		if err != nil {
+			return nil, fmt.Errorf("could not create mutator %q: %v", config.Name, err)
+		}
+
+		chain = append(chain, NamedMutator{Name: config.Name, Selector: config.Selector, Mutator: mutator})
+	}
+
+	return chain, nil
+}
+
+// StaticMutator adds static files and units to OperatingSystemConfigs. Files replace existing
+// files with the same path. Units with the same name as an existing unit override its content,
+// command and enablement if set and add their drop-ins.
+type StaticMutator struct {
+	// Files are the files to add.
+	Files []extensionsv1alpha1.File `json:"files,omitempty"`
+	// Units are the units to add.
+	Units []extensionsv1alpha1.Unit `json:"units,omitempty"`
+}
+
+// NewStaticMutatorFromConfig creates a StaticMutator from its JSON configuration.
+func NewStaticMutatorFromConfig(config json.RawMessage) (Mutator, error) {
+	m := &StaticMutator{}
+	if len(config) > 0 {
+		if err := yaml.UnmarshalStrict(config, m); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, file := range m.Files {
+		if file.Content.SecretRef != nil {
+			return nil, fmt.Errorf("file %q must have inline content", file.Path)
+		}
+	}
+	return m, nil
+}
+
+// Mutate implements Mutator.
+func (m *StaticMutator) Mutate(_ context.Context, spec *extensionsv1alpha1.OperatingSystemConfigSpec) error {
+	for _, file := range m.Files {
+		file = *file.DeepCopy()
+
+		replaced := false
+		for i := range spec.Files {
+			if spec.Files[i].Path == file.Path {
+				spec.Files[i] = file
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			spec.Files = append(spec.Files, file)
+		}
+	}
+
+	for _, unit := range m.Units {
+		unit = *unit.DeepCopy()
+
+		var existing *extensionsv1alpha1.Unit
+		for i := range spec.Units {
+			if spec.Units[i].Name == unit.Name {
+				existing = &spec.Units[i]
+				break
+			}
+		}
+		if existing == nil {
+			spec.Units = append(spec.Units, unit)
+			continue
+		}
+
+		if unit.Content != nil {
+			existing.Content = unit.Content
+		}
+		if unit.Command != nil {
+			existing.Command = unit.Command
+		}
+		if unit.Enable != nil {
+			existing.Enable = unit.Enable
+		}
+		existing.DropIns = append(existing.DropIns, unit.DropIns...)
+	}
+
+	return nil
+}
@@ -0,0 +1,201 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operatingsystemconfig_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+
+	"github.com/gardener/gardener-extensions/pkg/controller/operatingsystemconfig"
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Mutators", func() {
+	var (
+		ctx = context.TODO()
+		osc *extensionsv1alpha1.OperatingSystemConfig
+	)
+
+	BeforeEach(func() {
+		osc = &extensionsv1alpha1.OperatingSystemConfig{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "shoot--foo--bar",
+				Name:      "osc",
+				Labels:    map[string]string{"worker.gardener.cloud/pool": "cpu"},
+			},
+			Spec: extensionsv1alpha1.OperatingSystemConfigSpec{
+				Purpose: extensionsv1alpha1.OperatingSystemConfigPurposeReconcile,
+				Units: []extensionsv1alpha1.Unit{
+					{Name: "kubelet.service", Content: strPtr("[Unit]")},
+				},
+				Files: []extensionsv1alpha1.File{
+					{Path: "/etc/foo", Content: extensionsv1alpha1.FileContent{Inline: &extensionsv1alpha1.FileContentInline{Data: "foo"}}},
+				},
+			},
+		}
+	})
+
+	Describe("MutatorSelector", func() {
+		It("should match everything if empty", func() {
+			var selector *operatingsystemconfig.MutatorSelector
+			Expect(selector.Matches(osc)).To(BeTrue())
+			Expect((&operatingsystemconfig.MutatorSelector{}).Matches(osc)).To(BeTrue())
+		})
+
+		It("should select by namespace, purpose and labels", func() {
+			selector := &operatingsystemconfig.MutatorSelector{
+				Namespaces: []string{"shoot--foo--bar"},
+				Purposes:   []extensionsv1alpha1.OperatingSystemConfigPurpose{extensionsv1alpha1.OperatingSystemConfigPurposeReconcile},
+				Labels:     &metav1.LabelSelector{MatchLabels: map[string]string{"worker.gardener.cloud/pool": "cpu"}},
+			}
+			Expect(selector.Matches(osc)).To(BeTrue())
+
+			selector.Purposes = []extensionsv1alpha1.OperatingSystemConfigPurpose{extensionsv1alpha1.OperatingSystemConfigPurposeProvision}
+			Expect(selector.Matches(osc)).To(BeFalse())
+
+			selector.Purposes = nil
+			selector.Namespaces = []string{"garden"}
+			Expect(selector.Matches(osc)).To(BeFalse())
+
+			selector.Namespaces = nil
+			selector.Labels.MatchLabels["worker.gardener.cloud/pool"] = "gpu"
+			Expect(selector.Matches(osc)).To(BeFalse())
+		})
+	})
+
+	Describe("MutatorChain", func() {
+		It("should apply the selected mutators to a deep copy", func() {
+			chain := operatingsystemconfig.MutatorChain{
+				{
+					Name: "add-file",
+					Mutator: operatingsystemconfig.MutatorFunc(func(_ context.Context, spec *extensionsv1alpha1.OperatingSystemConfigSpec) error {
+						spec.Files = append(spec.Files, extensionsv1alpha1.File{Path: "/etc/bar"})
+						return nil
+					}),
+				},
+				{
+					Name:     "other-namespace",
+					Selector: &operatingsystemconfig.MutatorSelector{Namespaces: []string{"garden"}},
+					Mutator: operatingsystemconfig.MutatorFunc(func(_ context.Context, _ *extensionsv1alpha1.OperatingSystemConfigSpec) error {
+						Fail("mutator must not be applied")
+						return nil
+					}),
+				},
+			}
+
+			mutated, applied, err := chain.Apply(ctx, osc)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(applied).To(Equal([]string{"add-file"}))
+			Expect(mutated.Spec.Files).To(HaveLen(2))
+			Expect(osc.Spec.Files).To(HaveLen(1))
+		})
+	})
+
+	Describe("#NewMutatorChain", func() {
+		It("should create static mutators from the configuration file", func() {
+			f, err := ioutil.TempFile("", "config")
+			Expect(err).NotTo(HaveOccurred())
+			defer func() { _ = os.Remove(f.Name()) }()
+
+			_, err = f.WriteString(`mutators:
+- name: corporate-ca
+  type: static
+  selector:
+    purposes:
+    - reconcile
+  config:
+    files:
+    - path: /etc/ssl/certs/corporate-ca.pem
+      content:
+        inline:
+          encoding: ""
+          data: ca
+    units:
+    - name: kubelet.service
+      dropIns:
+      - name: 10-ca.conf
+        content: "[Service]"
+    - name: auditd.service
+      command: start
+      enable: true
+      content: "[Unit]"
+`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(f.Close()).To(Succeed())
+
+			config, err := operatingsystemconfig.LoadControllerConfiguration(f.Name())
+			Expect(err).NotTo(HaveOccurred())
+			chain, err := operatingsystemconfig.NewMutatorChain(config.Mutators, operatingsystemconfig.DefaultMutatorFactories())
+			Expect(err).NotTo(HaveOccurred())
+
+			mutated, applied, err := chain.Apply(ctx, osc)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(applied).To(Equal([]string{"corporate-ca"}))
+
+			Expect(mutated.Spec.Files).To(HaveLen(2))
+			Expect(mutated.Spec.Files[1].Path).To(Equal("/etc/ssl/certs/corporate-ca.pem"))
+			Expect(mutated.Spec.Units).To(HaveLen(2))
+			Expect(*mutated.Spec.Units[0].Content).To(Equal("[Unit]"))
+			Expect(mutated.Spec.Units[0].DropIns).To(Equal([]extensionsv1alpha1.DropIn{{Name: "10-ca.conf", Content: "[Service]"}}))
+			Expect(mutated.Spec.Units[1].Name).To(Equal("auditd.service"))
+		})
+
+		It("should reject unknown fields in the configuration file", func() {
+			for _, data := range []string{
+				"mutator:\n- name: corporate-ca\n  type: static\n",
+				"mutators:\n- name: corporate-ca\n  typ: static\n",
+			} {
+				f, err := ioutil.TempFile("", "config")
+				Expect(err).NotTo(HaveOccurred())
+				defer func() { _ = os.Remove(f.Name()) }()
+				_, err = f.WriteString(data)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(f.Close()).To(Succeed())
+
+				_, err = operatingsystemconfig.LoadControllerConfiguration(f.Name())
+				Expect(err).To(HaveOccurred(), data)
+			}
+		})
+
+		It("should reject invalid configurations", func() {
+			factories := operatingsystemconfig.DefaultMutatorFactories()
+			_, err := operatingsystemconfig.NewMutatorChain([]operatingsystemconfig.MutatorConfiguration{{Name: "foo", Type: "static", Config: []byte(`{"file": []}`)}}, factories)
+			Expect(err).To(HaveOccurred())
+			_, err = operatingsystemconfig.NewMutatorChain([]operatingsystemconfig.MutatorConfiguration{{Name: "foo", Type: "unknown"}}, factories)
+			Expect(err).To(HaveOccurred())
+			_, err = operatingsystemconfig.NewMutatorChain([]operatingsystemconfig.MutatorConfiguration{{Name: "foo", Type: "static"}, {Name: "foo", Type: "static"}}, factories)
+			Expect(err).To(HaveOccurred())
+			_, err = operatingsystemconfig.NewMutatorChain([]operatingsystemconfig.MutatorConfiguration{{Type: "static"}}, factories)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
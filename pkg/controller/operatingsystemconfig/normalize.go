@@ -0,0 +1,136 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operatingsystemconfig
+
+import (
+	"fmt"
+	"strings"
+
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+)
+
+// DuplicateFilePolicy defines how files of an OperatingSystemConfig with the same path are handled.
+type DuplicateFilePolicy string
+
+const (
+	// DuplicateFilePolicyLastWins merges files with the same path, the last file wins.
+	DuplicateFilePolicyLastWins DuplicateFilePolicy = "LastWins"
+	// DuplicateFilePolicyReject rejects OperatingSystemConfigs containing files with the same path.
+	DuplicateFilePolicyReject DuplicateFilePolicy = "Reject"
+
+	// EventReasonMerged is the reason of events about merged units, drop-ins and files of an
+	// OperatingSystemConfig.
+	EventReasonMerged = "Merged"
+)
+
+// Validate validates the DuplicateFilePolicy. The empty policy is valid and means DuplicateFilePolicyLastWins.
+func (p DuplicateFilePolicy) Validate() error {
+	switch p {
+	case "", DuplicateFilePolicyLastWins, DuplicateFilePolicyReject:
+		return nil
+	default:
+		return fmt.Errorf("unknown duplicate file policy %q, must be one of %q, %q", p, DuplicateFilePolicyLastWins, DuplicateFilePolicyReject)
+	}
+}
+
+// Normalize merges the units, drop-ins and files of the given spec that have the same name or
+// path, as declared by the patch merge keys of the API. Units are merged by name: the content,
+// command and enablement of later units win, drop-ins are merged by name and the content of
+// later drop-ins wins. Files with the same path are handled according to the given policy.
+// Merged entries keep the position of their first occurrence. Normalize returns a description
+// of each merge.
+func Normalize(spec *extensionsv1alpha1.OperatingSystemConfigSpec, policy DuplicateFilePolicy) ([]string, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+
+	var merges []string
+
+	var (
+		units       []extensionsv1alpha1.Unit
+		unitIndices = map[string]int{}
+	)
+	for _, unit := range spec.Units {
+		unit.DropIns, merges = normalizeDropIns(unit.Name, unit.DropIns, nil, merges)
+
+		i, ok := unitIndices[unit.Name]
+		if !ok {
+			unitIndices[unit.Name] = len(units)
+			units = append(units, unit)
+			continue
+		}
+
+		merges = append(merges, fmt.Sprintf("merged duplicate unit %q", unit.Name))
+		merged := &units[i]
+		if unit.Content != nil {
+			merged.Content = unit.Content
+		}
+		if unit.Command != nil {
+			merged.Command = unit.Command
+		}
+		if unit.Enable != nil {
+			merged.Enable = unit.Enable
+		}
+		merged.DropIns, merges = normalizeDropIns(unit.Name, merged.DropIns, unit.DropIns, merges)
+	}
+	spec.Units = units
+
+	var (
+		files       []extensionsv1alpha1.File
+		fileIndices = map[string]int{}
+		duplicates  []string
+	)
+	for _, file := range spec.Files {
+		i, ok := fileIndices[file.Path]
+		if !ok {
+			fileIndices[file.Path] = len(files)
+			files = append(files, file)
+			continue
+		}
+
+		if policy == DuplicateFilePolicyReject {
+			duplicates = append(duplicates, file.Path)
+			continue
+		}
+		merges = append(merges, fmt.Sprintf("merged duplicate file %q, the last file wins", file.Path))
+		files[i] = file
+	}
+	if len(duplicates) > 0 {
+		return nil, fmt.Errorf("files with duplicate paths are not allowed: %s", strings.Join(duplicates, ", "))
+	}
+	spec.Files = files
+
+	return merges, nil
+}
+
+// normalizeDropIns merges the given additional drop-ins into the given drop-ins of a unit by name.
+func normalizeDropIns(unitName string, dropIns, additional []extensionsv1alpha1.DropIn, merges []string) ([]extensionsv1alpha1.DropIn, []string) {
+	var (
+		result  []extensionsv1alpha1.DropIn
+		indices = map[string]int{}
+	)
+	for _, dropIn := range append(append([]extensionsv1alpha1.DropIn{}, dropIns...), additional...) {
+		i, ok := indices[dropIn.Name]
+		if !ok {
+			indices[dropIn.Name] = len(result)
+			result = append(result, dropIn)
+			continue
+		}
+
+		merges = append(merges, fmt.Sprintf("merged duplicate drop-in %q of unit %q, the last drop-in wins", dropIn.Name, unitName))
+		result[i] = dropIn
+	}
+	return result, merges
+}
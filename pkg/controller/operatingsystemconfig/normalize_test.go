@@ -0,0 +1,107 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operatingsystemconfig_test
+
+import (
+	"github.com/gardener/gardener-extensions/pkg/controller/operatingsystemconfig"
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Normalize", func() {
+	var spec *extensionsv1alpha1.OperatingSystemConfigSpec
+
+	inline := func(data string) extensionsv1alpha1.FileContent {
+		return extensionsv1alpha1.FileContent{Inline: &extensionsv1alpha1.FileContentInline{Data: data}}
+	}
+
+	BeforeEach(func() {
+		spec = &extensionsv1alpha1.OperatingSystemConfigSpec{
+			Units: []extensionsv1alpha1.Unit{
+				{
+					Name:    "docker.service",
+					DropIns: []extensionsv1alpha1.DropIn{{Name: "10-opts.conf", Content: "a"}, {Name: "20-log.conf", Content: "b"}},
+				},
+				{Name: "kubelet.service", Enable: boolPtr(true), Command: strPtr("start"), Content: strPtr("kubelet")},
+				{
+					Name:    "docker.service",
+					Enable:  boolPtr(true),
+					DropIns: []extensionsv1alpha1.DropIn{{Name: "10-opts.conf", Content: "c"}, {Name: "30-mirror.conf", Content: "d"}},
+				},
+			},
+			Files: []extensionsv1alpha1.File{
+				{Path: "/etc/foo", Content: inline("first")},
+				{Path: "/etc/bar", Content: inline("bar")},
+				{Path: "/etc/foo", Permissions: int32Ptr(0600), Content: inline("second")},
+			},
+		}
+	})
+
+	It("should merge units and drop-ins by name and files by path", func() {
+		merges, err := operatingsystemconfig.Normalize(spec, operatingsystemconfig.DuplicateFilePolicyLastWins)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(spec.Units).To(Equal([]extensionsv1alpha1.Unit{
+			{
+				Name:   "docker.service",
+				Enable: boolPtr(true),
+				DropIns: []extensionsv1alpha1.DropIn{
+					{Name: "10-opts.conf", Content: "c"},
+					{Name: "20-log.conf", Content: "b"},
+					{Name: "30-mirror.conf", Content: "d"},
+				},
+			},
+			{Name: "kubelet.service", Enable: boolPtr(true), Command: strPtr("start"), Content: strPtr("kubelet")},
+		}))
+		Expect(spec.Files).To(Equal([]extensionsv1alpha1.File{
+			{Path: "/etc/foo", Permissions: int32Ptr(0600), Content: inline("second")},
+			{Path: "/etc/bar", Content: inline("bar")},
+		}))
+		Expect(merges).To(Equal([]string{
+			`merged duplicate unit "docker.service"`,
+			`merged duplicate drop-in "10-opts.conf" of unit "docker.service", the last drop-in wins`,
+			`merged duplicate file "/etc/foo", the last file wins`,
+		}))
+	})
+
+	It("should merge files by default", func() {
+		_, err := operatingsystemconfig.Normalize(spec, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(spec.Files).To(HaveLen(2))
+	})
+
+	It("should reject duplicate files with the reject policy", func() {
+		_, err := operatingsystemconfig.Normalize(spec, operatingsystemconfig.DuplicateFilePolicyReject)
+		Expect(err).To(MatchError(ContainSubstring("/etc/foo")))
+	})
+
+	It("should reject unknown policies", func() {
+		_, err := operatingsystemconfig.Normalize(spec, "FirstWins")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should not change normalized specs", func() {
+		spec.Units = spec.Units[1:2]
+		spec.Files = spec.Files[:2]
+		expected := spec.DeepCopy()
+
+		merges, err := operatingsystemconfig.Normalize(spec, operatingsystemconfig.DuplicateFilePolicyReject)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(merges).To(BeEmpty())
+		Expect(spec).To(Equal(expected))
+	})
+})
@@ -17,19 +17,23 @@ package operatingsystemconfig
 import (
 	"strings"
 
+	"github.com/gardener/gardener-extensions/pkg/controller"
+
 	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 )
 
-// TypePredicate filters the incoming OperatingSystemConfigs for ones that have the same type
-// as the given type.
-func TypePredicate(typeName string) predicate.Predicate {
+// TypePredicate filters the incoming OperatingSystemConfigs for ones that have one of the
+// given types.
+func TypePredicate(typeNames ...string) predicate.Predicate {
+	types := sets.NewString(typeNames...)
 	typeMatches := func(obj runtime.Object) bool {
 		if config, ok := obj.(*extensionsv1alpha1.OperatingSystemConfig); ok {
-			return strings.ToLower(config.Spec.Type) == typeName
+			return types.Has(strings.ToLower(config.Spec.Type))
 		}
 		return false
 	}
@@ -62,3 +66,80 @@ func (generationChangedPredicate) Update(e event.UpdateEvent) bool {
 func GenerationChangedPredicate() predicate.Predicate {
 	return generationChangedPredicate{}
 }
+
+type operationAnnotationPredicate struct {
+	predicate.Funcs
+}
+
+func (operationAnnotationPredicate) Update(e event.UpdateEvent) bool {
+	_, ok := e.MetaNew.GetAnnotations()[controller.OperationAnnotation]
+	return ok
+}
+
+// OperationAnnotationPredicate is a predicate for updates of objects that have the operation annotation.
+func OperationAnnotationPredicate() predicate.Predicate {
+	return operationAnnotationPredicate{}
+}
+
+type annotationsChangedPredicate struct {
+	predicate.Funcs
+	keys []string
+}
+
+func (p annotationsChangedPredicate) Update(e event.UpdateEvent) bool {
+	oldAnnotations, newAnnotations := e.MetaOld.GetAnnotations(), e.MetaNew.GetAnnotations()
+	for _, key := range p.keys {
+		if oldAnnotations[key] != newAnnotations[key] {
+			return true
+		}
+	}
+	return false
+}
+
+// AnnotationsChangedPredicate is a predicate for changes of the annotations with the given keys.
+func AnnotationsChangedPredicate(keys ...string) predicate.Predicate {
+	return annotationsChangedPredicate{keys: keys}
+}
+
+type orPredicate []predicate.Predicate
+
+func (o orPredicate) Create(e event.CreateEvent) bool {
+	for _, p := range o {
+		if p.Create(e) {
+			return true
+		}
+	}
+	return false
+}
+
+func (o orPredicate) Delete(e event.DeleteEvent) bool {
+	for _, p := range o {
+		if p.Delete(e) {
+			return true
+		}
+	}
+	return false
+}
+
+func (o orPredicate) Update(e event.UpdateEvent) bool {
+	for _, p := range o {
+		if p.Update(e) {
+			return true
+		}
+	}
+	return false
+}
+
+func (o orPredicate) Generic(e event.GenericEvent) bool {
+	for _, p := range o {
+		if p.Generic(e) {
+			return true
+		}
+	}
+	return false
+}
+
+// Or is a predicate that lets an event pass if any of the given predicates does.
+func Or(predicates ...predicate.Predicate) predicate.Predicate {
+	return orPredicate(predicates)
+}
@@ -0,0 +1,44 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operatingsystemconfig_test
+
+import (
+	"github.com/gardener/gardener-extensions/pkg/controller/operatingsystemconfig"
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+var _ = Describe("Predicates", func() {
+	Describe("#AnnotationsChangedPredicate", func() {
+		updateEvent := func(oldAnnotations, newAnnotations map[string]string) event.UpdateEvent {
+			oldObj := &extensionsv1alpha1.OperatingSystemConfig{ObjectMeta: metav1.ObjectMeta{Annotations: oldAnnotations}}
+			newObj := &extensionsv1alpha1.OperatingSystemConfig{ObjectMeta: metav1.ObjectMeta{Annotations: newAnnotations}}
+			return event.UpdateEvent{MetaOld: oldObj, ObjectOld: oldObj, MetaNew: newObj, ObjectNew: newObj}
+		}
+
+		It("should only accept changes of the given annotations", func() {
+			p := operatingsystemconfig.AnnotationsChangedPredicate("foo")
+
+			Expect(p.Update(updateEvent(nil, map[string]string{"foo": "bar"}))).To(BeTrue())
+			Expect(p.Update(updateEvent(map[string]string{"foo": "bar"}, map[string]string{"foo": "baz"}))).To(BeTrue())
+			Expect(p.Update(updateEvent(map[string]string{"foo": "bar"}, nil))).To(BeTrue())
+			Expect(p.Update(updateEvent(map[string]string{"foo": "bar"}, map[string]string{"foo": "bar", "other": "x"}))).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,104 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operatingsystemconfig
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"unicode"
+
+	"github.com/gardener/gardener-extensions/pkg/systemd"
+)
+
+// BaseProfile describes the operating system tweaks an extension applies in addition to the
+// units and files of the OperatingSystemConfigs, e.g. to work around problems of an image.
+type BaseProfile struct {
+	// MaskedUnits are the names of the units that are masked.
+	MaskedUnits []string `json:"maskedUnits,omitempty"`
+	// DisabledUnits are the names of the units that are disabled and stopped at bootstrap.
+	DisabledUnits []string `json:"disabledUnits,omitempty"`
+	// Files are written at bootstrap.
+	Files []BaseProfileFile `json:"files,omitempty"`
+	// Commands are shell commands that are run at bootstrap after the files have been written.
+	Commands []string `json:"commands,omitempty"`
+}
+
+// BaseProfileFile is a file of a BaseProfile.
+type BaseProfileFile struct {
+	// Path is the absolute path of the file.
+	Path string `json:"path"`
+	// Permissions are the permissions of the file, defaults to 0644.
+	Permissions *int32 `json:"permissions,omitempty"`
+	// Content is the content of the file.
+	Content string `json:"content"`
+}
+
+// Merge returns a copy of the BaseProfile with all fields set in the given override replaced.
+// Fields of the override that are set to an empty list remove the corresponding defaults.
+func (p *BaseProfile) Merge(override *BaseProfile) *BaseProfile {
+	out := *p
+	if override == nil {
+		return &out
+	}
+
+	if override.MaskedUnits != nil {
+		out.MaskedUnits = override.MaskedUnits
+	}
+	if override.DisabledUnits != nil {
+		out.DisabledUnits = override.DisabledUnits
+	}
+	if override.Files != nil {
+		out.Files = override.Files
+	}
+	if override.Commands != nil {
+		out.Commands = override.Commands
+	}
+	return &out
+}
+
+// Validate validates the BaseProfile.
+func (p *BaseProfile) Validate() error {
+	var problems []string
+
+	for _, name := range append(append([]string{}, p.MaskedUnits...), p.DisabledUnits...) {
+		if err := systemd.ValidateUnitName(name); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	for _, file := range p.Files {
+		if !path.IsAbs(file.Path) || path.Clean(file.Path) != file.Path || strings.IndexFunc(file.Path, unicode.IsControl) >= 0 {
+			problems = append(problems, fmt.Sprintf("file path %q is not absolute and clean", file.Path))
+		}
+		if file.Permissions != nil && (*file.Permissions < 0 || *file.Permissions > 07777) {
+			problems = append(problems, fmt.Sprintf("file %q has invalid permissions %o", file.Path, *file.Permissions))
+		}
+	}
+	for _, command := range p.Commands {
+		if strings.TrimSpace(command) == "" {
+			problems = append(problems, "empty command")
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid base profile:\n%s", strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// HasBootstrapActions returns true if the BaseProfile has to run any action at bootstrap.
+func (p *BaseProfile) HasBootstrapActions() bool {
+	return len(p.DisabledUnits) > 0 || len(p.Files) > 0 || len(p.Commands) > 0
+}
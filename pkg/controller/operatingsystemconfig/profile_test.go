@@ -0,0 +1,70 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operatingsystemconfig_test
+
+import (
+	"github.com/gardener/gardener-extensions/pkg/controller/operatingsystemconfig"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("BaseProfile", func() {
+	Describe("#Merge", func() {
+		defaults := &operatingsystemconfig.BaseProfile{
+			MaskedUnits:   []string{"update-engine.service"},
+			DisabledUnits: []string{"locksmithd.service"},
+			Commands:      []string{"echo foo"},
+		}
+
+		It("should keep the defaults if no override is given", func() {
+			Expect(defaults.Merge(nil)).To(Equal(defaults))
+		})
+
+		It("should replace the fields set in the override", func() {
+			Expect(defaults.Merge(&operatingsystemconfig.BaseProfile{
+				DisabledUnits: []string{},
+				Files:         []operatingsystemconfig.BaseProfileFile{{Path: "/foo"}},
+			})).To(Equal(&operatingsystemconfig.BaseProfile{
+				MaskedUnits:   []string{"update-engine.service"},
+				DisabledUnits: []string{},
+				Files:         []operatingsystemconfig.BaseProfileFile{{Path: "/foo"}},
+				Commands:      []string{"echo foo"},
+			}))
+		})
+	})
+
+	Describe("#Validate", func() {
+		It("should accept valid profiles", func() {
+			Expect((&operatingsystemconfig.BaseProfile{
+				MaskedUnits: []string{"update-engine.service"},
+				Files:       []operatingsystemconfig.BaseProfileFile{{Path: "/etc/foo", Permissions: int32Ptr(0600)}},
+				Commands:    []string{"echo foo"},
+			}).Validate()).To(Succeed())
+		})
+
+		It("should reject invalid profiles", func() {
+			for _, profile := range []*operatingsystemconfig.BaseProfile{
+				{MaskedUnits: []string{"foo"}},
+				{DisabledUnits: []string{"foo'bar.service"}},
+				{Files: []operatingsystemconfig.BaseProfileFile{{Path: "etc/foo"}}},
+				{Files: []operatingsystemconfig.BaseProfileFile{{Path: "/etc/foo", Permissions: int32Ptr(010000)}}},
+				{Commands: []string{" "}},
+			} {
+				Expect(profile.Validate()).NotTo(Succeed(), "%+v", profile)
+			}
+		})
+	})
+})
@@ -0,0 +1,274 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operatingsystemconfig
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/gardener/gardener-extensions/pkg/cloudinit"
+	"github.com/gardener/gardener-extensions/pkg/controller/memoryclient"
+	"github.com/gardener/gardener-extensions/pkg/systemd"
+
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/inject"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+	"sigs.k8s.io/yaml"
+)
+
+// DecodeObjects decodes the given multi-document YAML into objects of the ExtensionsScheme.
+func DecodeObjects(data []byte) ([]runtime.Object, error) {
+	decoder := serializer.NewCodecFactory(ExtensionsScheme).UniversalDeserializer()
+
+	var objects []runtime.Object
+	for i, document := range splitYAMLDocuments(data) {
+		if len(bytes.TrimSpace(document)) == 0 {
+			continue
+		}
+
+		jsonData, err := yaml.YAMLToJSON(document)
+		if err != nil {
+			return nil, fmt.Errorf("could not convert document %d to JSON: %v", i, err)
+		}
+		if bytes.Equal(jsonData, []byte("null")) {
+			continue
+		}
+
+		obj, _, err := decoder.Decode(jsonData, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("could not decode document %d: %v", i, err)
+		}
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
+func splitYAMLDocuments(data []byte) [][]byte {
+	var (
+		documents [][]byte
+		current   bytes.Buffer
+		scanner   = bufio.NewScanner(bytes.NewReader(data))
+	)
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	for scanner.Scan() {
+		if strings.TrimRight(scanner.Text(), " \t") == "---" {
+			documents = append(documents, append([]byte(nil), current.Bytes()...))
+			current.Reset()
+			continue
+		}
+		current.Write(scanner.Bytes())
+		current.WriteByte('\n')
+	}
+	return append(documents, current.Bytes())
+}
+
+// Render renders the single OperatingSystemConfig among the given objects without an API server.
+// The other objects, e.g. secrets referenced by files, are available to the actuator. The
+// mutators of the controller options are applied, the config is normalized and the units are
+// linted before rendering, the merges and found problems are written to the given writer. Render returns the content of the result secret.
+func Render(ctx context.Context, opts *ControllerOptions, objects []runtime.Object, problems io.Writer) ([]byte, error) {
+	var (
+		osc    *extensionsv1alpha1.OperatingSystemConfig
+		others []runtime.Object
+	)
+	for _, obj := range objects {
+		if o, ok := obj.(*extensionsv1alpha1.OperatingSystemConfig); ok {
+			if osc != nil {
+				return nil, fmt.Errorf("more than one operating system config given")
+			}
+			osc = o
+			continue
+		}
+		others = append(others, obj)
+	}
+	if osc == nil {
+		return nil, fmt.Errorf("no operating system config given")
+	}
+
+	config, err := opts.configuration()
+	if err != nil {
+		return nil, err
+	}
+	mutators, err := opts.mutators(config)
+	if err != nil {
+		return nil, err
+	}
+	filePolicy, err := opts.duplicateFilePolicy(config)
+	if err != nil {
+		return nil, err
+	}
+	log := opts.Log
+	if log == nil {
+		log = logf.Log
+	}
+	actuator, err := opts.ActuatorFactory(&ActuatorArgs{Log: log.WithName(opts.Name).WithName("actuator"), Config: config.Actuator})
+	if err != nil {
+		return nil, err
+	}
+
+	mutated, _, err := mutators.Apply(ctx, osc)
+	if err != nil {
+		return nil, fmt.Errorf("could not mutate operating system config: %v", err)
+	}
+
+	merges, err := Normalize(&mutated.Spec, filePolicy)
+	if err != nil {
+		return nil, fmt.Errorf("could not normalize operating system config: %v", err)
+	}
+	for _, merge := range merges {
+		fmt.Fprintln(problems, merge)
+	}
+
+	lintProblems := LintUnits(mutated.Spec.Units)
+	for _, problem := range lintProblems {
+		fmt.Fprintln(problems, problem.String())
+	}
+	if systemd.HasErrors(lintProblems) {
+		return nil, fmt.Errorf("units of operating system config are invalid")
+	}
+
+	c, err := memoryclient.New(ExtensionsScheme, append(others, mutated)...)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := inject.SchemeInto(ExtensionsScheme, actuator); err != nil {
+		return nil, err
+	}
+	if _, err := inject.ClientInto(c, actuator); err != nil {
+		return nil, err
+	}
+	if _, err := inject.StopChannelInto(ctx.Done(), actuator); err != nil {
+		return nil, err
+	}
+
+	if err := actuator.Create(ctx, mutated); err != nil {
+		return nil, err
+	}
+	if mutated.Status.CloudConfig == nil {
+		return nil, fmt.Errorf("actuator did not report a result secret")
+	}
+
+	secret := &corev1.Secret{}
+	ref := mutated.Status.CloudConfig.SecretRef
+	if err := c.Get(ctx, client.ObjectKey{Namespace: ref.Namespace, Name: ref.Name}, secret); err != nil {
+		return nil, err
+	}
+	return secret.Data[extensionsv1alpha1.OperatingSystemConfigSecretDataKey], nil
+}
+
+// NewRenderCommand creates a new command rendering an OperatingSystemConfig given in a file with
+// the actuator and mutators of the given controller options. The result is written to stdout,
+// problems found in the units are written to stderr.
+func NewRenderCommand(ctx context.Context, opts *ControllerOptions) *cobra.Command {
+	var (
+		file  string
+		parts bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "render",
+		Short: "Renders an operating system config without an API server",
+
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := runRender(ctx, opts, file, parts); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	fs := cmd.Flags()
+	fs.StringVarP(&file, "file", "f", "-", "Path of a YAML file containing the operating system config and the secrets it references, '-' reads from stdin.")
+	fs.BoolVar(&parts, "parts", false, "Parse the result as multipart user-data and write its decoded parts one after another.")
+	fs.StringVar(&opts.ConfigFile, "config-file", opts.ConfigFile, "Path of the controller configuration file, e.g. configuring mutators and the actuator.")
+
+	return cmd
+}
+
+func runRender(ctx context.Context, opts *ControllerOptions, file string, parts bool) error {
+	var (
+		data []byte
+		err  error
+	)
+	if file == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(file)
+	}
+	if err != nil {
+		return err
+	}
+
+	objects, err := DecodeObjects(data)
+	if err != nil {
+		return err
+	}
+
+	result, err := Render(ctx, opts, objects, os.Stderr)
+	if err != nil {
+		return err
+	}
+	if parts {
+		return WriteParts(os.Stdout, result)
+	}
+	_, err = os.Stdout.Write(result)
+	return err
+}
+
+// WriteParts parses the given user-data with cloudinit.ParseMultipart and writes the decoded parts
+// to the given writer, each preceded by a line with its file name and headers.
+func WriteParts(w io.Writer, userData []byte) error {
+	m, err := cloudinit.ParseMultipart(userData)
+	if err != nil {
+		return err
+	}
+
+	for i, part := range m.Parts {
+		header := []string{fmt.Sprintf("--- %d", i+1)}
+		if part.Filename != "" {
+			header = append(header, part.Filename)
+		}
+		header = append(header, part.ContentType)
+		if part.MergeType != "" {
+			header = append(header, "merge-type="+part.MergeType)
+		}
+		if part.Gzip {
+			header = append(header, "gzip")
+		}
+		if _, err := fmt.Fprintln(w, strings.Join(header, " ")); err != nil {
+			return err
+		}
+
+		content := part.Content
+		if !bytes.HasSuffix(content, []byte("\n")) {
+			content = append(content, '\n')
+		}
+		if _, err := w.Write(content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
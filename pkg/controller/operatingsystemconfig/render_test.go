@@ -0,0 +1,135 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operatingsystemconfig_test
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/gardener/gardener-extensions/pkg/cloudinit"
+	"github.com/gardener/gardener-extensions/pkg/controller/operatingsystemconfig"
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+var _ = Describe("Render", func() {
+	var (
+		ctx  = context.TODO()
+		opts *operatingsystemconfig.ControllerOptions
+	)
+
+	BeforeEach(func() {
+		opts = operatingsystemconfig.NewControllerOptions("test", "test", func(_ *operatingsystemconfig.ActuatorArgs) (operatingsystemconfig.Actuator, error) {
+			return &secretActuator{}, nil
+		})
+	})
+
+	Describe("#DecodeObjects", func() {
+		It("should decode all documents", func() {
+			objects, err := operatingsystemconfig.DecodeObjects([]byte(`---
+apiVersion: extensions.gardener.cloud/v1alpha1
+kind: OperatingSystemConfig
+metadata:
+  name: osc
+---
+# empty
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: secret
+`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(objects).To(HaveLen(2))
+			Expect(objects[0]).To(BeAssignableToTypeOf(&extensionsv1alpha1.OperatingSystemConfig{}))
+			Expect(objects[1]).To(BeAssignableToTypeOf(&corev1.Secret{}))
+		})
+
+		It("should fail for unknown kinds", func() {
+			_, err := operatingsystemconfig.DecodeObjects([]byte("apiVersion: v1\nkind: Unknown\n"))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	It("should render the mutated config and report unit problems", func() {
+		opts.Mutators = []operatingsystemconfig.NamedMutator{
+			{
+				Name: "ca-bundle",
+				Mutator: operatingsystemconfig.MutatorFunc(func(_ context.Context, spec *extensionsv1alpha1.OperatingSystemConfigSpec) error {
+					spec.Files = append(spec.Files, extensionsv1alpha1.File{Path: "/etc/ssl/ca.pem"})
+					return nil
+				}),
+			},
+		}
+		osc := &extensionsv1alpha1.OperatingSystemConfig{
+			Spec: extensionsv1alpha1.OperatingSystemConfigSpec{
+				Units: []extensionsv1alpha1.Unit{{Name: "foo.service", Enable: boolPtr(true), Content: strPtr("[Service]\nExecStart=/bin/foo\n")}},
+				Files: []extensionsv1alpha1.File{{Path: "/etc/foo"}},
+			},
+		}
+
+		var problems bytes.Buffer
+		result, err := operatingsystemconfig.Render(ctx, opts, []runtime.Object{osc}, &problems)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(result)).To(Equal("/etc/foo,/etc/ssl/ca.pem"))
+		Expect(problems.String()).To(Equal("Warning: foo.service: unit is enabled but has no [Install] section, enabling it has no effect\n"))
+	})
+
+	It("should not render configs with invalid units", func() {
+		osc := &extensionsv1alpha1.OperatingSystemConfig{
+			Spec: extensionsv1alpha1.OperatingSystemConfigSpec{
+				Units: []extensionsv1alpha1.Unit{{Name: "foo.service", Content: strPtr("[Service\n")}},
+			},
+		}
+
+		var problems bytes.Buffer
+		_, err := operatingsystemconfig.Render(ctx, opts, []runtime.Object{osc}, &problems)
+		Expect(err).To(HaveOccurred())
+		Expect(problems.String()).To(ContainSubstring("invalid section header"))
+	})
+
+	It("should require exactly one config", func() {
+		_, err := operatingsystemconfig.Render(ctx, opts, nil, &bytes.Buffer{})
+		Expect(err).To(HaveOccurred())
+	})
+
+	Describe("#WriteParts", func() {
+		It("should write the decoded parts of multipart user-data", func() {
+			userData, err := (&cloudinit.Multipart{Parts: []cloudinit.Part{
+				{ContentType: cloudinit.ContentTypeCloudConfig, MergeType: cloudinit.DefaultMergeType, Content: []byte("#cloud-config\nhostname: foo\n")},
+				{ContentType: cloudinit.ContentTypeShellScript, Filename: "bootstrap.sh", Content: []byte("#!/bin/bash\necho foo"), Gzip: true},
+			}}).Encode()
+			Expect(err).NotTo(HaveOccurred())
+
+			var out bytes.Buffer
+			Expect(operatingsystemconfig.WriteParts(&out, userData)).To(Succeed())
+			Expect(out.String()).To(Equal(`--- 1 part-001 text/cloud-config merge-type=list(append)+dict(no_replace,recurse_list)+str()
+#cloud-config
+hostname: foo
+--- 2 bootstrap.sh text/x-shellscript gzip
+#!/bin/bash
+echo foo
+`))
+		})
+
+		It("should fail for invalid user-data", func() {
+			Expect(operatingsystemconfig.WriteParts(&bytes.Buffer{}, []byte("foo"))).NotTo(Succeed())
+		})
+	})
+})
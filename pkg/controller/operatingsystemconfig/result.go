@@ -0,0 +1,346 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operatingsystemconfig
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/gardener/gardener-extensions/pkg/cloudinit"
+	"github.com/gardener/gardener-extensions/pkg/controller"
+	"github.com/gardener/gardener-extensions/pkg/shell"
+
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// Renderer renders the results of OperatingSystemConfigs, e.g. cloud-config documents.
+type Renderer interface {
+	// Render renders the result of the given config. The config already contains the unit and the
+	// files of its container runtime, the given files map their paths to their decoded content.
+	Render(ctx context.Context, config *extensionsv1alpha1.OperatingSystemConfig, files map[string][]byte) ([]byte, error)
+}
+
+// RendererFunc is a function that implements Renderer.
+type RendererFunc func(ctx context.Context, config *extensionsv1alpha1.OperatingSystemConfig, files map[string][]byte) ([]byte, error)
+
+// Render implements Renderer.
+func (f RendererFunc) Render(ctx context.Context, config *extensionsv1alpha1.OperatingSystemConfig, files map[string][]byte) ([]byte, error) {
+	return f(ctx, config, files)
+}
+
+// ResultActuatorOptions are options for the creation of a ResultActuator.
+type ResultActuatorOptions struct {
+	// ContainerRuntime is the container runtime of the machines, it can be overridden per config
+	// with the ContainerRuntimeAnnotation. Defaults to DefaultContainerRuntimeConfiguration.
+	ContainerRuntime *ContainerRuntimeConfiguration
+	// ReloadCommand returns the command of the status for the given path of the reload config
+	// file. If nil, no command is reported.
+	ReloadCommand func(path string) string
+}
+
+// QuotedPathReloadCommand returns a ResultActuatorOptions.ReloadCommand appending the quoted path
+// of the reload config file as last argument to the given command.
+func QuotedPathReloadCommand(command string) func(path string) string {
+	return func(path string) string {
+		return fmt.Sprintf("%s %s", command, shell.Quote(path))
+	}
+}
+
+// ResultState is the state of a ResultActuator that is exported into the status of an
+// OperatingSystemConfig when it is migrated to another seed.
+type ResultState struct {
+	// CloudConfigSecretName is the name of the secret containing the generated cloud config.
+	CloudConfigSecretName string `json:"cloudConfigSecretName,omitempty"`
+	// UnitHashes are the hashes of the effective configuration of the units, so that the target
+	// seed only reports units whose configuration changed since the migration.
+	UnitHashes map[string]string `json:"unitHashes,omitempty"`
+}
+
+// ResultActuator is a MigrationActuator that stores the result of a Renderer in a secret and
+// reports the units whose effective configuration changed in the status.
+type ResultActuator struct {
+	scheme        *runtime.Scheme
+	client        client.Client
+	logger        logr.Logger
+	renderer      Renderer
+	runtime       *ContainerRuntimeConfiguration
+	reloadCommand func(path string) string
+}
+
+var _ MigrationActuator = &ResultActuator{}
+
+// NewResultActuator creates a new ResultActuator rendering the results with the given renderer.
+func NewResultActuator(logger logr.Logger, renderer Renderer, opts ResultActuatorOptions) *ResultActuator {
+	a := &ResultActuator{
+		logger:        logger,
+		renderer:      renderer,
+		runtime:       opts.ContainerRuntime,
+		reloadCommand: opts.ReloadCommand,
+	}
+	if a.runtime == nil {
+		a.runtime = DefaultContainerRuntimeConfiguration()
+	}
+	return a
+}
+
+// InjectScheme is an implementation for getting the scheme managed by the controller-runtime.
+func (a *ResultActuator) InjectScheme(scheme *runtime.Scheme) error {
+	a.scheme = scheme
+	return nil
+}
+
+// InjectClient is an implementation for getting the client managed by the controller-runtime.
+func (a *ResultActuator) InjectClient(client client.Client) error {
+	a.client = client
+	return nil
+}
+
+// Exists implements Actuator.
+func (a *ResultActuator) Exists(ctx context.Context, config *extensionsv1alpha1.OperatingSystemConfig) (bool, error) {
+	return config.Status.CloudConfig != nil, nil
+}
+
+// Create implements Actuator.
+func (a *ResultActuator) Create(ctx context.Context, config *extensionsv1alpha1.OperatingSystemConfig) error {
+	return a.reconcile(ctx, config, extensionsv1alpha1.LastOperationTypeReconcile, nil)
+}
+
+// Update implements Actuator.
+func (a *ResultActuator) Update(ctx context.Context, config *extensionsv1alpha1.OperatingSystemConfig) error {
+	return a.reconcile(ctx, config, extensionsv1alpha1.LastOperationTypeReconcile, nil)
+}
+
+// Delete implements Actuator. The result secret is owned by the config and deleted with it.
+func (a *ResultActuator) Delete(ctx context.Context, config *extensionsv1alpha1.OperatingSystemConfig) error {
+	config.Status.ObservedGeneration = config.Generation
+	config.Status.LastOperation, config.Status.LastError = controller.ReconcileSucceeded(extensionsv1alpha1.LastOperationTypeDelete, "Successfully deleted cloud config")
+	if err := a.client.Status().Update(ctx, config); err != nil {
+		a.logger.Error(err, "Could not update operating system config status for deletion", "osc", config.Name)
+		return err
+	}
+	return nil
+}
+
+// Migrate implements MigrationActuator. It exports the name of the result secret and the unit
+// hashes into the state of the given config.
+func (a *ResultActuator) Migrate(ctx context.Context, config *extensionsv1alpha1.OperatingSystemConfig) error {
+	meta := secretObjectMetaForConfig(config)
+	secret := &corev1.Secret{}
+	if err := a.client.Get(ctx, client.ObjectKey{Namespace: meta.Namespace, Name: meta.Name}, secret); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	state, err := json.Marshal(&ResultState{
+		CloudConfigSecretName: meta.Name,
+		UnitHashes:            DecodeUnitHashes(secret.Annotations),
+	})
+	if err != nil {
+		return err
+	}
+
+	config.Status.State = string(state)
+	config.Status.ObservedGeneration = config.Generation
+	config.Status.LastOperation, config.Status.LastError = controller.ReconcileSucceeded(controller.LastOperationTypeMigrate, "Successfully exported state of cloud config")
+	if err := a.client.Status().Update(ctx, config); err != nil {
+		a.logger.Error(err, "Could not update operating system config status for migration", "osc", config.Name)
+		return err
+	}
+	return nil
+}
+
+// Restore implements MigrationActuator. It reuses the name of the result secret of the source
+// seed, so that references to it stay valid, and reconciles the given config.
+func (a *ResultActuator) Restore(ctx context.Context, config *extensionsv1alpha1.OperatingSystemConfig) error {
+	state := &ResultState{}
+	if config.Status.State != "" {
+		if err := json.Unmarshal([]byte(config.Status.State), state); err != nil {
+			config.Status.LastOperation, config.Status.LastError = controller.ReconcileError(controller.LastOperationTypeRestore, fmt.Sprintf("Could not decode state: %v", err), 0)
+			if err := a.client.Status().Update(ctx, config); err != nil {
+				a.logger.Error(err, "Could not update operating system config status after restore error", "osc", config.Name)
+			}
+			return err
+		}
+
+		if state.CloudConfigSecretName != "" {
+			config.Status.CloudConfig = &extensionsv1alpha1.CloudConfig{
+				SecretRef: corev1.SecretReference{
+					Name:      state.CloudConfigSecretName,
+					Namespace: config.Namespace,
+				},
+			}
+		}
+	}
+
+	return a.reconcile(ctx, config, controller.LastOperationTypeRestore, state.UnitHashes)
+}
+
+// reconcile renders the given config and applies the result secret. The restored hashes are used
+// as previous unit hashes if the result secret does not store any, e.g. after a restoration.
+func (a *ResultActuator) reconcile(ctx context.Context, config *extensionsv1alpha1.OperatingSystemConfig, operationType extensionsv1alpha1.LastOperationType, restoredHashes map[string]string) error {
+	result, hashes, err := a.render(ctx, config)
+	if err != nil {
+		config.Status.ObservedGeneration = config.Generation
+		config.Status.LastOperation, config.Status.LastError = controller.ReconcileError(operationType, fmt.Sprintf("Could not generate cloud config: %v", err), 50)
+		if err := a.client.Status().Update(ctx, config); err != nil {
+			a.logger.Error(err, "Could not update operating system config status after update error", "osc", config.Name)
+		}
+		return err
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: secretObjectMetaForConfig(config),
+	}
+
+	var (
+		previousHashes map[string]string
+		resultChanged  bool
+	)
+	if err := controller.CreateOrUpdate(ctx, a.client, secret, func() error {
+		if secret.Data == nil {
+			secret.Data = make(map[string][]byte)
+		}
+		resultChanged = !bytes.Equal(secret.Data[extensionsv1alpha1.OperatingSystemConfigSecretDataKey], result)
+		secret.Data[extensionsv1alpha1.OperatingSystemConfigSecretDataKey] = result
+
+		previousHashes = DecodeUnitHashes(secret.Annotations)
+		if previousHashes == nil {
+			previousHashes = restoredHashes
+		}
+
+		return controllerutil.SetControllerReference(config, secret, a.scheme)
+	}); err != nil {
+		config.Status.ObservedGeneration = config.Generation
+		config.Status.LastOperation, config.Status.LastError = controller.ReconcileError(operationType, fmt.Sprintf("Could not apply secret for generated cloud config: %v", err), 50)
+		if err := a.client.Status().Update(ctx, config); err != nil {
+			a.logger.Error(err, "Could not update operating system config status after reconcile error", "osc", config.Name)
+		}
+		return err
+	}
+
+	config.Status.CloudConfig = &extensionsv1alpha1.CloudConfig{
+		SecretRef: corev1.SecretReference{
+			Name:      secret.Name,
+			Namespace: secret.Namespace,
+		},
+	}
+	if path := config.Spec.ReloadConfigFilePath; path != nil && a.reloadCommand != nil {
+		config.Status.Command = a.reloadCommand(*path)
+	}
+	config.Status.Units = RestartUnits(config, hashes, previousHashes, resultChanged)
+	config.Status.ObservedGeneration = config.Generation
+	config.Status.LastOperation, config.Status.LastError = controller.ReconcileSucceeded(operationType, "Successfully generated cloud config")
+	if err := a.client.Status().Update(ctx, config); err != nil {
+		return err
+	}
+
+	// The unit hashes are only stored once the units to restart are part of the status. Otherwise,
+	// a failed status update would lose the restarts when the reconciliation is retried.
+	encodedHashes := EncodeUnitHashes(hashes)
+	if secret.Annotations[UnitHashesAnnotation] == encodedHashes {
+		return nil
+	}
+	return controller.CreateOrUpdate(ctx, a.client, secret, func() error {
+		if secret.Annotations == nil {
+			secret.Annotations = make(map[string]string)
+		}
+		secret.Annotations[UnitHashesAnnotation] = encodedHashes
+		return nil
+	})
+}
+
+// render renders the result of the given config with the unit and the files of its container
+// runtime. It returns the result and the hashes of the effective configuration of the units.
+func (a *ResultActuator) render(ctx context.Context, config *extensionsv1alpha1.OperatingSystemConfig) ([]byte, map[string]string, error) {
+	runtime, err := ContainerRuntimeConfigurationFor(a.runtime, config)
+	if err != nil {
+		return nil, nil, err
+	}
+	config = WithContainerRuntime(runtime, config)
+
+	files, err := FilesData(ctx, a.client, config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result, err := a.renderer.Render(ctx, config, files)
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, UnitHashes(config.Spec.Units, files), nil
+}
+
+func secretObjectMetaForConfig(config *extensionsv1alpha1.OperatingSystemConfig) metav1.ObjectMeta {
+	var (
+		name      = fmt.Sprintf("osc-result-%s", config.Name)
+		namespace = config.Namespace
+	)
+
+	if cloudConfig := config.Status.CloudConfig; cloudConfig != nil {
+		name = cloudConfig.SecretRef.Name
+		namespace = cloudConfig.SecretRef.Namespace
+	}
+
+	return metav1.ObjectMeta{
+		Name:      name,
+		Namespace: namespace,
+	}
+}
+
+// FilesData returns the decoded content of all files of the given config keyed by path. The
+// content of files referencing secrets is read with the given client.
+func FilesData(ctx context.Context, c client.Client, config *extensionsv1alpha1.OperatingSystemConfig) (map[string][]byte, error) {
+	files := make(map[string][]byte, len(config.Spec.Files))
+	for _, file := range config.Spec.Files {
+		data, err := FileContentData(ctx, c, config.Namespace, &file.Content)
+		if err != nil {
+			return nil, fmt.Errorf("could not get content of file %q: %v", file.Path, err)
+		}
+		files[file.Path] = data
+	}
+	return files, nil
+}
+
+// FileContentData returns the decoded data of the given file content. Inline contents are decoded
+// with the codecs of the cloudinit package, secrets are read from the given namespace.
+func FileContentData(ctx context.Context, c client.Client, namespace string, content *extensionsv1alpha1.FileContent) ([]byte, error) {
+	if inline := content.Inline; inline != nil {
+		if len(inline.Encoding) == 0 {
+			return []byte(inline.Data), nil
+		}
+		return cloudinit.Decode(inline.Encoding, []byte(inline.Data))
+	}
+
+	if content.SecretRef == nil {
+		return nil, nil
+	}
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: content.SecretRef.Name}, secret); err != nil {
+		return nil, err
+	}
+	data, ok := secret.Data[content.SecretRef.DataKey]
+	if !ok {
+		return nil, fmt.Errorf("could not find key %q in data of secret %q", content.SecretRef.DataKey, content.SecretRef.Name)
+	}
+	return data, nil
+}
@@ -0,0 +1,194 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operatingsystemconfig_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/gardener/gardener-extensions/pkg/controller"
+	"github.com/gardener/gardener-extensions/pkg/controller/memoryclient"
+	"github.com/gardener/gardener-extensions/pkg/controller/operatingsystemconfig"
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+var _ = Describe("ResultActuator", func() {
+	var (
+		ctx      = context.TODO()
+		c        *memoryclient.Client
+		osc      *extensionsv1alpha1.OperatingSystemConfig
+		renderer operatingsystemconfig.RendererFunc
+	)
+
+	BeforeEach(func() {
+		osc = &extensionsv1alpha1.OperatingSystemConfig{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "shoot--foo--bar", Name: "osc"},
+			Spec: extensionsv1alpha1.OperatingSystemConfigSpec{
+				ReloadConfigFilePath: strPtr("/var/lib/osc/config"),
+				Units:                []extensionsv1alpha1.Unit{{Name: "kubelet.service", Content: strPtr("[Service]\nExecStart=/opt/bin/kubelet\n")}},
+				Files: []extensionsv1alpha1.File{
+					{
+						Path:    "/opt/bin/kubelet",
+						Content: extensionsv1alpha1.FileContent{Inline: &extensionsv1alpha1.FileContentInline{Encoding: "b64", Data: "a3ViZWxldA=="}},
+					},
+				},
+			},
+		}
+		renderer = func(_ context.Context, config *extensionsv1alpha1.OperatingSystemConfig, files map[string][]byte) ([]byte, error) {
+			return []byte(fmt.Sprintf("%d units, kubelet %s", len(config.Spec.Units), files["/opt/bin/kubelet"])), nil
+		}
+	})
+
+	newActuator := func(objs ...runtime.Object) *operatingsystemconfig.ResultActuator {
+		var err error
+		c, err = memoryclient.New(operatingsystemconfig.ExtensionsScheme, append(objs, osc)...)
+		Expect(err).NotTo(HaveOccurred())
+
+		actuator := operatingsystemconfig.NewResultActuator(log.Log, renderer, operatingsystemconfig.ResultActuatorOptions{
+			ReloadCommand: func(path string) string { return "reload " + path },
+		})
+		Expect(actuator.InjectScheme(operatingsystemconfig.ExtensionsScheme)).To(Succeed())
+		Expect(actuator.InjectClient(c)).To(Succeed())
+		return actuator
+	}
+
+	result := func() string {
+		secret := &corev1.Secret{}
+		ref := osc.Status.CloudConfig.SecretRef
+		Expect(c.Get(ctx, client.ObjectKey{Namespace: ref.Namespace, Name: ref.Name}, secret)).To(Succeed())
+		return string(secret.Data[extensionsv1alpha1.OperatingSystemConfigSecretDataKey])
+	}
+
+	It("should store the rendered result of the config with its container runtime", func() {
+		Expect(newActuator().Create(ctx, osc)).To(Succeed())
+
+		Expect(result()).To(Equal("2 units, kubelet kubelet"))
+		Expect(osc.Status.CloudConfig.SecretRef.Name).To(Equal("osc-result-osc"))
+		Expect(osc.Status.Command).To(Equal("reload /var/lib/osc/config"))
+		Expect(osc.Status.Units).To(Equal([]string{"kubelet.service", "docker.service"}))
+		Expect(osc.Status.LastOperation.State).To(Equal(extensionsv1alpha1.LastOperationStateSucceeded))
+	})
+
+	It("should report render errors", func() {
+		renderer = func(context.Context, *extensionsv1alpha1.OperatingSystemConfig, map[string][]byte) ([]byte, error) {
+			return nil, fmt.Errorf("boom")
+		}
+
+		Expect(newActuator().Create(ctx, osc)).NotTo(Succeed())
+		Expect(osc.Status.CloudConfig).To(BeNil())
+		Expect(osc.Status.LastOperation.State).To(Equal(extensionsv1alpha1.LastOperationStateError))
+		Expect(osc.Status.LastOperation.Description).To(ContainSubstring("boom"))
+	})
+
+	It("should still restart the changed units if the status update failed", func() {
+		actuator := newActuator()
+		Expect(actuator.Create(ctx, osc)).To(Succeed())
+		stored := &extensionsv1alpha1.OperatingSystemConfig{}
+		Expect(c.Get(ctx, client.ObjectKey{Namespace: osc.Namespace, Name: osc.Name}, stored)).To(Succeed())
+		osc = stored
+
+		osc.Spec.Units[0].Content = strPtr("[Service]\nExecStart=/opt/bin/kubelet --v=2\n")
+		Expect(actuator.InjectClient(&failingStatusClient{Client: c})).To(Succeed())
+		Expect(actuator.Update(ctx, osc.DeepCopy())).NotTo(Succeed())
+
+		Expect(actuator.InjectClient(c)).To(Succeed())
+		Expect(actuator.Update(ctx, osc)).To(Succeed())
+		Expect(osc.Status.Units).To(Equal([]string{"kubelet.service"}))
+	})
+
+	It("should hand the result secret and the unit hashes over to the target seed", func() {
+		source := newActuator()
+		Expect(source.Create(ctx, osc)).To(Succeed())
+		Expect(source.Migrate(ctx, osc)).To(Succeed())
+		Expect(controller.IsMigrated(osc.Status.LastOperation)).To(BeTrue())
+
+		state := &operatingsystemconfig.ResultState{}
+		Expect(json.Unmarshal([]byte(osc.Status.State), state)).To(Succeed())
+		Expect(state.CloudConfigSecretName).To(Equal("osc-result-osc"))
+		Expect(state.UnitHashes).To(HaveKey("kubelet.service"))
+
+		// Only the state is handed over to the target seed.
+		osc = &extensionsv1alpha1.OperatingSystemConfig{
+			ObjectMeta: metav1.ObjectMeta{Namespace: osc.Namespace, Name: osc.Name},
+			Spec:       osc.Spec,
+			Status:     extensionsv1alpha1.OperatingSystemConfigStatus{DefaultStatus: extensionsv1alpha1.DefaultStatus{State: osc.Status.State}},
+		}
+		Expect(newActuator().Restore(ctx, osc)).To(Succeed())
+
+		Expect(osc.Status.CloudConfig.SecretRef.Name).To(Equal("osc-result-osc"))
+		Expect(result()).To(Equal("2 units, kubelet kubelet"))
+		Expect(osc.Status.Units).To(BeEmpty())
+		Expect(osc.Status.LastOperation.Type).To(Equal(controller.LastOperationTypeRestore))
+	})
+
+	It("should reject invalid states", func() {
+		osc.Status.State = "{"
+		Expect(newActuator().Restore(ctx, osc)).NotTo(Succeed())
+		Expect(osc.Status.LastOperation.State).To(Equal(extensionsv1alpha1.LastOperationStateError))
+	})
+
+	Describe("#FilesData", func() {
+		It("should decode inline contents and read secrets", func() {
+			osc.Spec.Files = append(osc.Spec.Files, extensionsv1alpha1.File{
+				Path:    "/var/lib/kubelet/ca.crt",
+				Content: extensionsv1alpha1.FileContent{SecretRef: &extensionsv1alpha1.FileContentSecretRef{Name: "ca", DataKey: "ca.crt"}},
+			})
+			newActuator(&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: osc.Namespace, Name: "ca"},
+				Data:       map[string][]byte{"ca.crt": []byte("certificate")},
+			})
+
+			Expect(operatingsystemconfig.FilesData(ctx, c, osc)).To(Equal(map[string][]byte{
+				"/opt/bin/kubelet":        []byte("kubelet"),
+				"/var/lib/kubelet/ca.crt": []byte("certificate"),
+			}))
+		})
+
+		It("should fail for missing secret keys", func() {
+			osc.Spec.Files = []extensionsv1alpha1.File{{
+				Path:    "/var/lib/kubelet/ca.crt",
+				Content: extensionsv1alpha1.FileContent{SecretRef: &extensionsv1alpha1.FileContentSecretRef{Name: "ca", DataKey: "ca.crt"}},
+			}}
+			newActuator(&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: osc.Namespace, Name: "ca"}})
+
+			_, err := operatingsystemconfig.FilesData(ctx, c, osc)
+			Expect(err).To(MatchError(ContainSubstring(`could not find key "ca.crt"`)))
+		})
+	})
+})
+
+// failingStatusClient is a client whose status updates fail.
+type failingStatusClient struct {
+	client.Client
+}
+
+func (c *failingStatusClient) Status() client.StatusWriter {
+	return &failingStatusWriter{}
+}
+
+type failingStatusWriter struct{}
+
+func (w *failingStatusWriter) Update(context.Context, runtime.Object) error {
+	return fmt.Errorf("status update failed")
+}
@@ -0,0 +1,294 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operatingsystemconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// ContainerRuntimeAnnotation is the annotation of OperatingSystemConfigs that selects the
+	// container runtime of the machines. Its value is either the name of a container runtime or a
+	// ContainerRuntimeConfiguration (as JSON or YAML) overriding the configured one.
+	ContainerRuntimeAnnotation = "operatingsystemconfig.extensions.gardener.cloud/container-runtime"
+
+	// DockerConfigPath is the path of the configuration file of dockerd.
+	DockerConfigPath = "/etc/docker/daemon.json"
+	// ContainerdConfigPath is the path of the configuration file of containerd.
+	ContainerdConfigPath = "/etc/containerd/config.toml"
+	// ContainerdDropInName is the name of the drop-in of the containerd unit that makes containerd
+	// use the configuration file at ContainerdConfigPath.
+	ContainerdDropInName = "10-gardener-config.conf"
+	// ContainerdBinaryPath is the path of the containerd binary started by the drop-in.
+	ContainerdBinaryPath = "/usr/bin/containerd"
+
+	dockerHub = "docker.io"
+)
+
+// ContainerRuntime is the name of a container runtime.
+type ContainerRuntime string
+
+const (
+	// ContainerRuntimeDocker is the docker container runtime.
+	ContainerRuntimeDocker ContainerRuntime = "docker"
+	// ContainerRuntimeContainerd is the containerd container runtime.
+	ContainerRuntimeContainerd ContainerRuntime = "containerd"
+)
+
+// ContainerRuntimeConfiguration configures the container runtime of the machines.
+type ContainerRuntimeConfiguration struct {
+	// Name is the name of the container runtime.
+	Name ContainerRuntime `json:"name,omitempty"`
+	// RegistryMirrors are the mirrors of the image registries. The docker runtime ignores all
+	// mirrors except those of `docker.io`.
+	RegistryMirrors []RegistryMirror `json:"registryMirrors,omitempty"`
+	// StorageDriver is the storage driver of the docker runtime. If not set, docker chooses one.
+	StorageDriver string `json:"storageDriver,omitempty"`
+}
+
+// RegistryMirror is a list of mirrors of an image registry.
+type RegistryMirror struct {
+	// Registry is the host of the mirrored registry, e.g. `docker.io`.
+	Registry string `json:"registry"`
+	// Endpoints are the URLs of the mirrors, in order of preference.
+	Endpoints []string `json:"endpoints"`
+}
+
+// DefaultContainerRuntimeConfiguration returns the default ContainerRuntimeConfiguration, i.e.
+// docker without any further configuration.
+func DefaultContainerRuntimeConfiguration() *ContainerRuntimeConfiguration {
+	return &ContainerRuntimeConfiguration{Name: ContainerRuntimeDocker}
+}
+
+// ParseContainerRuntimeConfiguration decodes and validates the given ContainerRuntimeConfiguration
+// (JSON or YAML). A plain container runtime name is accepted, too.
+func ParseContainerRuntimeConfiguration(data []byte) (*ContainerRuntimeConfiguration, error) {
+	config := &ContainerRuntimeConfiguration{}
+	switch name := ContainerRuntime(strings.TrimSpace(string(data))); name {
+	case ContainerRuntimeDocker, ContainerRuntimeContainerd:
+		config.Name = name
+	default:
+		if err := yaml.UnmarshalStrict(data, config); err != nil {
+			return nil, fmt.Errorf("could not decode container runtime configuration: %v", err)
+		}
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Merge returns a copy of the ContainerRuntimeConfiguration with all fields set in the given
+// override replaced. The storage driver is reset if the override selects another runtime.
+func (c *ContainerRuntimeConfiguration) Merge(override *ContainerRuntimeConfiguration) *ContainerRuntimeConfiguration {
+	out := *c
+	if override == nil {
+		return &out
+	}
+
+	if override.Name != "" && override.Name != out.Name {
+		out = ContainerRuntimeConfiguration{Name: override.Name, RegistryMirrors: out.RegistryMirrors}
+	}
+	if override.RegistryMirrors != nil {
+		out.RegistryMirrors = override.RegistryMirrors
+	}
+	if override.StorageDriver != "" {
+		out.StorageDriver = override.StorageDriver
+	}
+	return &out
+}
+
+// Validate validates the ContainerRuntimeConfiguration.
+func (c *ContainerRuntimeConfiguration) Validate() error {
+	var problems []string
+
+	switch c.Name {
+	case "", ContainerRuntimeDocker, ContainerRuntimeContainerd:
+	default:
+		problems = append(problems, fmt.Sprintf("unknown container runtime %q", c.Name))
+	}
+
+	registries := sets.NewString()
+	for _, mirror := range c.RegistryMirrors {
+		switch {
+		case mirror.Registry == "" || strings.ContainsAny(mirror.Registry, "/\" \t\n"):
+			problems = append(problems, fmt.Sprintf("invalid registry %q", mirror.Registry))
+		case registries.Has(mirror.Registry):
+			problems = append(problems, fmt.Sprintf("mirrors of registry %q are specified multiple times", mirror.Registry))
+		}
+		registries.Insert(mirror.Registry)
+
+		if len(mirror.Endpoints) == 0 {
+			problems = append(problems, fmt.Sprintf("registry %q has no mirror endpoints", mirror.Registry))
+		}
+		for _, endpoint := range mirror.Endpoints {
+			if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.ContainsAny(endpoint, "\"\n") {
+				problems = append(problems, fmt.Sprintf("invalid mirror endpoint %q of registry %q", endpoint, mirror.Registry))
+			}
+		}
+	}
+
+	if c.StorageDriver != "" && c.Name != ContainerRuntimeDocker {
+		problems = append(problems, fmt.Sprintf("the storage driver can only be set for the %q runtime", ContainerRuntimeDocker))
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid container runtime configuration:\n%s", strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// ContainerRuntimeConfigurationFor returns the given ContainerRuntimeConfiguration merged with the
+// override of the annotation of the given OperatingSystemConfig, if any.
+func ContainerRuntimeConfigurationFor(runtime *ContainerRuntimeConfiguration, config *extensionsv1alpha1.OperatingSystemConfig) (*ContainerRuntimeConfiguration, error) {
+	data, ok := config.Annotations[ContainerRuntimeAnnotation]
+	if !ok {
+		return runtime, nil
+	}
+
+	override, err := ParseContainerRuntimeConfiguration([]byte(data))
+	if err != nil {
+		return nil, fmt.Errorf("annotation %q: %v", ContainerRuntimeAnnotation, err)
+	}
+
+	merged := runtime.Merge(override)
+	if err := merged.Validate(); err != nil {
+		return nil, fmt.Errorf("annotation %q: %v", ContainerRuntimeAnnotation, err)
+	}
+	return merged, nil
+}
+
+// Files returns the configuration files of the container runtime.
+func (c *ContainerRuntimeConfiguration) Files() []BaseProfileFile {
+	if c.Name == ContainerRuntimeContainerd {
+		return []BaseProfileFile{{Path: ContainerdConfigPath, Content: c.containerdConfig()}}
+	}
+
+	daemon := map[string]interface{}{}
+	for _, mirror := range c.RegistryMirrors {
+		if mirror.Registry == dockerHub {
+			daemon["registry-mirrors"] = mirror.Endpoints
+		}
+	}
+	if c.StorageDriver != "" {
+		daemon["storage-driver"] = c.StorageDriver
+	}
+	if len(daemon) == 0 {
+		return nil
+	}
+
+	// Maps are marshalled with sorted keys, so the content is stable.
+	data, _ := json.MarshalIndent(daemon, "", "  ")
+	return []BaseProfileFile{{Path: DockerConfigPath, Content: string(data) + "\n"}}
+}
+
+// containerdConfig returns the configuration of containerd in the format understood by
+// containerd 1.2 and later.
+func (c *ContainerRuntimeConfiguration) containerdConfig() string {
+	var buf bytes.Buffer
+	// The kubelet talks to containerd via the CRI plugin, so no plugin must be disabled.
+	buf.WriteString("disabled_plugins = []\n")
+
+	mirrors := append([]RegistryMirror{}, c.RegistryMirrors...)
+	sort.Slice(mirrors, func(i, j int) bool { return mirrors[i].Registry < mirrors[j].Registry })
+	for _, mirror := range mirrors {
+		endpoints := make([]string, 0, len(mirror.Endpoints))
+		for _, endpoint := range mirror.Endpoints {
+			endpoints = append(endpoints, fmt.Sprintf("%q", endpoint))
+		}
+		fmt.Fprintf(&buf, "\n[plugins.cri.registry.mirrors.%q]\n  endpoint = [%s]\n", mirror.Registry, strings.Join(endpoints, ", "))
+	}
+	return buf.String()
+}
+
+// Unit returns the unit of the container runtime. It is enabled and started, the containerd unit
+// additionally gets a drop-in overriding its command line to pass the configuration file, as
+// containerd does not read the path of its configuration file from the environment.
+func (c *ContainerRuntimeConfiguration) Unit() extensionsv1alpha1.Unit {
+	var (
+		enable  = true
+		command = "start"
+		unit    = extensionsv1alpha1.Unit{Name: "docker.service", Enable: &enable, Command: &command}
+	)
+	if c.Name == ContainerRuntimeContainerd {
+		unit.Name = "containerd.service"
+		unit.DropIns = []extensionsv1alpha1.DropIn{{
+			Name:    ContainerdDropInName,
+			Content: fmt.Sprintf("[Service]\nExecStart=\nExecStart=%s --config %s\n", ContainerdBinaryPath, ContainerdConfigPath),
+		}}
+	}
+	return unit
+}
+
+// WithContainerRuntime returns a copy of the given config that contains the unit and the
+// configuration files of the given container runtime. Files and unit settings of the config
+// take precedence.
+func WithContainerRuntime(runtime *ContainerRuntimeConfiguration, config *extensionsv1alpha1.OperatingSystemConfig) *extensionsv1alpha1.OperatingSystemConfig {
+	config = config.DeepCopy()
+
+	paths := sets.NewString()
+	for _, file := range config.Spec.Files {
+		paths.Insert(file.Path)
+	}
+	var files []extensionsv1alpha1.File
+	for _, file := range runtime.Files() {
+		if paths.Has(file.Path) {
+			continue
+		}
+		files = append(files, extensionsv1alpha1.File{
+			Path:        file.Path,
+			Permissions: file.Permissions,
+			Content:     extensionsv1alpha1.FileContent{Inline: &extensionsv1alpha1.FileContentInline{Data: file.Content}},
+		})
+	}
+	config.Spec.Files = append(files, config.Spec.Files...)
+
+	unit := runtime.Unit()
+	for i := range config.Spec.Units {
+		existing := &config.Spec.Units[i]
+		if existing.Name != unit.Name {
+			continue
+		}
+
+		if existing.Enable == nil {
+			existing.Enable = unit.Enable
+		}
+		if existing.Command == nil {
+			existing.Command = unit.Command
+		}
+		dropIns := sets.NewString()
+		for _, dropIn := range existing.DropIns {
+			dropIns.Insert(dropIn.Name)
+		}
+		for _, dropIn := range unit.DropIns {
+			if !dropIns.Has(dropIn.Name) {
+				existing.DropIns = append(existing.DropIns, dropIn)
+			}
+		}
+		return config
+	}
+	config.Spec.Units = append([]extensionsv1alpha1.Unit{unit}, config.Spec.Units...)
+	return config
+}
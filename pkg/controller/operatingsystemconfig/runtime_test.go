@@ -0,0 +1,185 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operatingsystemconfig_test
+
+import (
+	"github.com/gardener/gardener-extensions/pkg/controller/operatingsystemconfig"
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("ContainerRuntime", func() {
+	mirrors := []operatingsystemconfig.RegistryMirror{
+		{Registry: "quay.io", Endpoints: []string{"https://quay-mirror.example.com"}},
+		{Registry: "docker.io", Endpoints: []string{"https://mirror.example.com", "http://10.0.0.1:5000"}},
+	}
+
+	Describe("#ParseContainerRuntimeConfiguration", func() {
+		It("should accept plain runtime names", func() {
+			Expect(operatingsystemconfig.ParseContainerRuntimeConfiguration([]byte("containerd\n"))).To(Equal(&operatingsystemconfig.ContainerRuntimeConfiguration{
+				Name: operatingsystemconfig.ContainerRuntimeContainerd,
+			}))
+		})
+
+		It("should accept configurations", func() {
+			Expect(operatingsystemconfig.ParseContainerRuntimeConfiguration([]byte(`{"name": "docker", "storageDriver": "overlay2"}`))).To(Equal(&operatingsystemconfig.ContainerRuntimeConfiguration{
+				Name:          operatingsystemconfig.ContainerRuntimeDocker,
+				StorageDriver: "overlay2",
+			}))
+		})
+
+		It("should reject invalid configurations", func() {
+			for _, data := range []string{
+				`rkt`,
+				`{"name": "rkt"}`,
+				`{"unknown": true}`,
+				`{"name": "containerd", "storageDriver": "overlay2"}`,
+				`{"registryMirrors": [{"registry": "docker.io", "endpoints": []}]}`,
+				`{"registryMirrors": [{"registry": "docker.io", "endpoints": ["mirror.example.com"]}]}`,
+				`{"registryMirrors": [{"registry": "docker.io/library", "endpoints": ["https://mirror.example.com"]}]}`,
+				`{"registryMirrors": [{"registry": "docker.io", "endpoints": ["https://a"]}, {"registry": "docker.io", "endpoints": ["https://b"]}]}`,
+			} {
+				_, err := operatingsystemconfig.ParseContainerRuntimeConfiguration([]byte(data))
+				Expect(err).To(HaveOccurred(), data)
+			}
+		})
+	})
+
+	Describe("#Merge", func() {
+		It("should reset the storage driver when switching the runtime", func() {
+			base := &operatingsystemconfig.ContainerRuntimeConfiguration{
+				Name:            operatingsystemconfig.ContainerRuntimeDocker,
+				StorageDriver:   "devicemapper",
+				RegistryMirrors: mirrors,
+			}
+
+			Expect(base.Merge(&operatingsystemconfig.ContainerRuntimeConfiguration{Name: operatingsystemconfig.ContainerRuntimeContainerd})).To(Equal(&operatingsystemconfig.ContainerRuntimeConfiguration{
+				Name:            operatingsystemconfig.ContainerRuntimeContainerd,
+				RegistryMirrors: mirrors,
+			}))
+			Expect(base.Merge(&operatingsystemconfig.ContainerRuntimeConfiguration{Name: operatingsystemconfig.ContainerRuntimeDocker})).To(Equal(base))
+		})
+	})
+
+	Describe("#ContainerRuntimeConfigurationFor", func() {
+		It("should apply the override of the annotation", func() {
+			config := &extensionsv1alpha1.OperatingSystemConfig{ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{operatingsystemconfig.ContainerRuntimeAnnotation: "containerd"},
+			}}
+
+			runtime, err := operatingsystemconfig.ContainerRuntimeConfigurationFor(operatingsystemconfig.DefaultContainerRuntimeConfiguration(), config)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(runtime.Name).To(Equal(operatingsystemconfig.ContainerRuntimeContainerd))
+
+			config.Annotations[operatingsystemconfig.ContainerRuntimeAnnotation] = "storageDriver: overlay2"
+			_, err = operatingsystemconfig.ContainerRuntimeConfigurationFor(&operatingsystemconfig.ContainerRuntimeConfiguration{Name: operatingsystemconfig.ContainerRuntimeContainerd}, config)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("#Files", func() {
+		It("should render the docker configuration with the mirrors of docker.io only", func() {
+			runtime := &operatingsystemconfig.ContainerRuntimeConfiguration{
+				Name:            operatingsystemconfig.ContainerRuntimeDocker,
+				StorageDriver:   "overlay2",
+				RegistryMirrors: mirrors,
+			}
+
+			Expect(runtime.Files()).To(Equal([]operatingsystemconfig.BaseProfileFile{{
+				Path: operatingsystemconfig.DockerConfigPath,
+				Content: `{
+  "registry-mirrors": [
+    "https://mirror.example.com",
+    "http://10.0.0.1:5000"
+  ],
+  "storage-driver": "overlay2"
+}
+`,
+			}}))
+		})
+
+		It("should not render an empty docker configuration", func() {
+			Expect(operatingsystemconfig.DefaultContainerRuntimeConfiguration().Files()).To(BeEmpty())
+		})
+
+		It("should render the containerd configuration", func() {
+			runtime := &operatingsystemconfig.ContainerRuntimeConfiguration{
+				Name:            operatingsystemconfig.ContainerRuntimeContainerd,
+				RegistryMirrors: mirrors,
+			}
+
+			Expect(runtime.Files()).To(Equal([]operatingsystemconfig.BaseProfileFile{{
+				Path: operatingsystemconfig.ContainerdConfigPath,
+				Content: `disabled_plugins = []
+
+[plugins.cri.registry.mirrors."docker.io"]
+  endpoint = ["https://mirror.example.com", "http://10.0.0.1:5000"]
+
+[plugins.cri.registry.mirrors."quay.io"]
+  endpoint = ["https://quay-mirror.example.com"]
+`,
+			}}))
+		})
+	})
+
+	Describe("#WithContainerRuntime", func() {
+		var config *extensionsv1alpha1.OperatingSystemConfig
+
+		BeforeEach(func() {
+			config = &extensionsv1alpha1.OperatingSystemConfig{
+				Spec: extensionsv1alpha1.OperatingSystemConfigSpec{
+					Units: []extensionsv1alpha1.Unit{
+						{Name: "docker.service", DropIns: []extensionsv1alpha1.DropIn{{Name: "10-opts.conf", Content: "[Service]\n"}}},
+					},
+				},
+			}
+		})
+
+		It("should enable the docker unit of the config", func() {
+			runtime := &operatingsystemconfig.ContainerRuntimeConfiguration{Name: operatingsystemconfig.ContainerRuntimeDocker, StorageDriver: "overlay2"}
+
+			result := operatingsystemconfig.WithContainerRuntime(runtime, config)
+			Expect(result.Spec.Units).To(Equal([]extensionsv1alpha1.Unit{{
+				Name:    "docker.service",
+				Enable:  boolPtr(true),
+				Command: strPtr("start"),
+				DropIns: []extensionsv1alpha1.DropIn{{Name: "10-opts.conf", Content: "[Service]\n"}},
+			}}))
+			Expect(result.Spec.Files).To(HaveLen(1))
+			Expect(result.Spec.Files[0].Path).To(Equal(operatingsystemconfig.DockerConfigPath))
+			Expect(config.Spec.Units[0].Enable).To(BeNil())
+		})
+
+		It("should add the containerd unit and keep the files of the config", func() {
+			config.Spec.Files = []extensionsv1alpha1.File{{Path: operatingsystemconfig.ContainerdConfigPath}}
+
+			result := operatingsystemconfig.WithContainerRuntime(&operatingsystemconfig.ContainerRuntimeConfiguration{Name: operatingsystemconfig.ContainerRuntimeContainerd}, config)
+			Expect(result.Spec.Units).To(HaveLen(2))
+			Expect(result.Spec.Units[0]).To(Equal(extensionsv1alpha1.Unit{
+				Name:    "containerd.service",
+				Enable:  boolPtr(true),
+				Command: strPtr("start"),
+				DropIns: []extensionsv1alpha1.DropIn{{
+					Name:    operatingsystemconfig.ContainerdDropInName,
+					Content: "[Service]\nExecStart=\nExecStart=/usr/bin/containerd --config /etc/containerd/config.toml\n",
+				}},
+			}))
+			Expect(result.Spec.Files).To(Equal(config.Spec.Files))
+		})
+	})
+})
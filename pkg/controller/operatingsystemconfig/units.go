@@ -0,0 +1,164 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operatingsystemconfig
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+
+	"github.com/gardener/gardener-extensions/pkg/systemd"
+
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// UnitHashesAnnotation is the annotation of result secrets that contains the hashes of the
+	// effective configuration of the units as JSON object.
+	UnitHashesAnnotation = "operatingsystemconfig.extensions.gardener.cloud/unit-hashes"
+)
+
+// unitFileKeys are the keys whose values may reference files that are part of the effective
+// configuration of a unit. Values of `Environment` reference files by assigning their path to a
+// variable, e.g. `DOCKER_OPTS_FILE=/etc/default/docker`.
+var unitFileKeys = sets.NewString(
+	"Environment", "EnvironmentFile", "ExecStart", "ExecStartPre", "ExecStartPost", "ExecReload", "ExecStop",
+	"ExecStopPre", "ExecStopPost", "ExecCondition",
+)
+
+// UnitHashes computes a hash of the effective configuration of each of the given units. The
+// effective configuration consists of the unit content, its drop-ins and the given file data of
+// all files referenced in `Environment`, `EnvironmentFile` or `Exec*` settings. The given file data is keyed
+// by path.
+func UnitHashes(units []extensionsv1alpha1.Unit, files map[string][]byte) map[string]string {
+	hashes := make(map[string]string, len(units))
+	for _, unit := range units {
+		var (
+			hash       = sha256.New()
+			referenced = sets.NewString()
+		)
+		reference := func(content string) {
+			for _, path := range referencedPaths(content) {
+				if _, ok := files[path]; ok {
+					referenced.Insert(path)
+				}
+			}
+		}
+
+		if unit.Content != nil {
+			hash.Write([]byte(*unit.Content))
+			reference(*unit.Content)
+		}
+		hash.Write([]byte{0})
+		for _, dropIn := range unit.DropIns {
+			hash.Write([]byte(dropIn.Name + "\x00" + dropIn.Content + "\x00"))
+			reference(dropIn.Content)
+		}
+		for _, path := range referenced.List() {
+			hash.Write([]byte(path + "\x00"))
+			hash.Write(files[path])
+			hash.Write([]byte{0})
+		}
+
+		hashes[unit.Name] = hex.EncodeToString(hash.Sum(nil))
+	}
+	return hashes
+}
+
+// referencedPaths returns all absolute paths in the values of the settings of the given unit
+// content that may reference files.
+func referencedPaths(content string) []string {
+	// Syntax errors are reported by the linter, the valid parts are sufficient here.
+	file, _ := systemd.Parse(content)
+
+	var paths []string
+	for _, section := range file.Sections {
+		for _, entry := range section.Entries {
+			if !unitFileKeys.Has(entry.Key) {
+				continue
+			}
+			for _, field := range strings.Fields(entry.Value) {
+				if entry.Key == "Environment" {
+					field = strings.Trim(field, `"`)
+					field = field[strings.Index(field, "=")+1:]
+				}
+				// Strip the prefixes of optional environment files and special executables.
+				field = strings.TrimLeft(field, "-@+!:")
+				if strings.HasPrefix(field, "/") {
+					paths = append(paths, field)
+				}
+			}
+		}
+	}
+	return paths
+}
+
+// ChangedUnits returns the names of the given units whose hash differs from the previous hash,
+// in order of the given units. If there are no previous hashes, all units are returned.
+func ChangedUnits(units []extensionsv1alpha1.Unit, hashes, previous map[string]string) []string {
+	names := make([]string, 0, len(units))
+	for _, unit := range units {
+		if previous == nil || previous[unit.Name] != hashes[unit.Name] {
+			names = append(names, unit.Name)
+		}
+	}
+	return names
+}
+
+// RestartUnits returns the names of the units of the given config that have to be restarted
+// after applying the result, i.e. the units whose hash differs from the previous hash. Units
+// that have a hash but are not part of the config were added by the extension, they follow the
+// units of the config in alphabetical order. If the result did not change, the units reported
+// in the status are kept as nodes might not have applied the result yet.
+func RestartUnits(config *extensionsv1alpha1.OperatingSystemConfig, hashes, previous map[string]string, resultChanged bool) []string {
+	units := append([]extensionsv1alpha1.Unit{}, config.Spec.Units...)
+	names := sets.NewString()
+	for _, unit := range units {
+		names.Insert(unit.Name)
+	}
+	for _, name := range sets.StringKeySet(hashes).Difference(names).List() {
+		units = append(units, extensionsv1alpha1.Unit{Name: name})
+	}
+
+	changed := ChangedUnits(units, hashes, previous)
+	if len(changed) == 0 && !resultChanged {
+		return config.Status.Units
+	}
+	return changed
+}
+
+// DecodeUnitHashes decodes the unit hashes stored in the given annotations. It returns nil if
+// the annotations do not contain valid unit hashes.
+func DecodeUnitHashes(annotations map[string]string) map[string]string {
+	value, ok := annotations[UnitHashesAnnotation]
+	if !ok {
+		return nil
+	}
+
+	hashes := map[string]string{}
+	if err := json.Unmarshal([]byte(value), &hashes); err != nil {
+		return nil
+	}
+	return hashes
+}
+
+// EncodeUnitHashes encodes the given unit hashes as value of the UnitHashesAnnotation.
+func EncodeUnitHashes(hashes map[string]string) string {
+	// Maps are marshalled with sorted keys, so the value is stable.
+	data, _ := json.Marshal(hashes)
+	return string(data)
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operatingsystemconfig_test
+
+import (
+	"github.com/gardener/gardener-extensions/pkg/controller/operatingsystemconfig"
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Units", func() {
+	var (
+		units []extensionsv1alpha1.Unit
+		files map[string][]byte
+	)
+
+	BeforeEach(func() {
+		units = []extensionsv1alpha1.Unit{
+			{Name: "kubelet.service", Content: strPtr("[Service]\nEnvironmentFile=-/etc/kubelet.env\nExecStart=/opt/bin/kubelet --config=/var/lib/kubelet/config\n")},
+			{Name: "monitor.service", Content: strPtr("[Service]\nExecStart=/bin/bash /opt/bin/monitor.sh\n")},
+			{Name: "docker.service", DropIns: []extensionsv1alpha1.DropIn{{Name: "10-opts.conf", Content: "[Service]\nEnvironment=FOO=bar\n"}}},
+		}
+		files = map[string][]byte{
+			"/etc/kubelet.env":      []byte("A=b"),
+			"/opt/bin/kubelet":      []byte("binary"),
+			"/opt/bin/monitor.sh":   []byte("#!/bin/bash"),
+			"/etc/sysctl.d/99.conf": []byte("vm.max_map_count = 1"),
+			"/etc/docker/opts":      []byte("--debug"),
+		}
+	})
+
+	changedAfter := func(mutate func()) []string {
+		previous := operatingsystemconfig.UnitHashes(units, files)
+		mutate()
+		return operatingsystemconfig.ChangedUnits(units, operatingsystemconfig.UnitHashes(units, files), previous)
+	}
+
+	Describe("#UnitHashes", func() {
+		It("should be stable", func() {
+			Expect(changedAfter(func() {})).To(BeEmpty())
+		})
+
+		It("should change with the content and the drop-ins", func() {
+			Expect(changedAfter(func() {
+				units[0].Content = strPtr("[Service]\nExecStart=/opt/bin/kubelet\n")
+				units[2].DropIns[0].Content = "[Service]\n"
+			})).To(Equal([]string{"kubelet.service", "docker.service"}))
+		})
+
+		It("should change with referenced files only", func() {
+			Expect(changedAfter(func() {
+				files["/etc/kubelet.env"] = []byte("A=c")
+				files["/opt/bin/monitor.sh"] = []byte("#!/bin/sh")
+				files["/etc/sysctl.d/99.conf"] = []byte("vm.max_map_count = 2")
+			})).To(Equal([]string{"kubelet.service", "monitor.service"}))
+		})
+
+		It("should change with files assigned to environment variables", func() {
+			units[2].DropIns[0].Content = "[Service]\nEnvironment=\"OPTS_FILE=/etc/docker/opts\" FOO=bar\n"
+			Expect(changedAfter(func() {
+				files["/etc/docker/opts"] = []byte("--debug=false")
+			})).To(Equal([]string{"docker.service"}))
+		})
+	})
+
+	Describe("#ChangedUnits", func() {
+		It("should return all units without previous hashes", func() {
+			Expect(operatingsystemconfig.ChangedUnits(units, operatingsystemconfig.UnitHashes(units, files), nil)).To(Equal([]string{"kubelet.service", "monitor.service", "docker.service"}))
+		})
+	})
+
+	Describe("#RestartUnits", func() {
+		It("should keep the reported units while the result is unchanged", func() {
+			hashes := operatingsystemconfig.UnitHashes(units, files)
+			config := &extensionsv1alpha1.OperatingSystemConfig{
+				Spec:   extensionsv1alpha1.OperatingSystemConfigSpec{Units: units},
+				Status: extensionsv1alpha1.OperatingSystemConfigStatus{Units: []string{"kubelet.service"}},
+			}
+
+			Expect(operatingsystemconfig.RestartUnits(config, hashes, hashes, false)).To(Equal([]string{"kubelet.service"}))
+			Expect(operatingsystemconfig.RestartUnits(config, hashes, hashes, true)).To(BeEmpty())
+		})
+
+		It("should report changed units added by the extension after the units of the config", func() {
+			hashes := operatingsystemconfig.UnitHashes(append(units, extensionsv1alpha1.Unit{Name: "containerd.service"}), files)
+			config := &extensionsv1alpha1.OperatingSystemConfig{
+				Spec: extensionsv1alpha1.OperatingSystemConfigSpec{Units: units},
+			}
+
+			Expect(operatingsystemconfig.RestartUnits(config, hashes, nil, true)).To(Equal([]string{"kubelet.service", "monitor.service", "docker.service", "containerd.service"}))
+		})
+	})
+
+	Describe("#EncodeUnitHashes", func() {
+		It("should round trip", func() {
+			hashes := map[string]string{"a.service": "1", "b.service": "2"}
+			annotations := map[string]string{operatingsystemconfig.UnitHashesAnnotation: operatingsystemconfig.EncodeUnitHashes(hashes)}
+			Expect(operatingsystemconfig.DecodeUnitHashes(annotations)).To(Equal(hashes))
+		})
+
+		It("should ignore missing and invalid annotations", func() {
+			Expect(operatingsystemconfig.DecodeUnitHashes(nil)).To(BeNil())
+			Expect(operatingsystemconfig.DecodeUnitHashes(map[string]string{operatingsystemconfig.UnitHashesAnnotation: "{"})).To(BeNil())
+		})
+	})
+})
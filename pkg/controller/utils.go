@@ -28,6 +28,30 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
+const (
+	// OperationAnnotation is the annotation used to request an operation on an extension resource.
+	OperationAnnotation = "gardener.cloud/operation"
+	// OperationMigrate is the value of the OperationAnnotation requesting the migration of an
+	// extension resource to another seed.
+	OperationMigrate = "migrate"
+	// OperationRestore is the value of the OperationAnnotation requesting the restoration of an
+	// extension resource that has been migrated from another seed.
+	OperationRestore = "restore"
+
+	// LastOperationTypeMigrate indicates a 'migrate' operation.
+	LastOperationTypeMigrate extensionsv1alpha1.LastOperationType = "Migrate"
+	// LastOperationTypeRestore indicates a 'restore' operation.
+	LastOperationTypeRestore extensionsv1alpha1.LastOperationType = "Restore"
+)
+
+// IsMigrated returns whether the last operation described by the given LastOperation is a
+// successful migration.
+func IsMigrated(lastOperation *extensionsv1alpha1.LastOperation) bool {
+	return lastOperation != nil &&
+		lastOperation.Type == LastOperationTypeMigrate &&
+		lastOperation.State == extensionsv1alpha1.LastOperationStateSucceeded
+}
+
 // ReconcileErr returns a reconcile.Result or an error, depending on whether the error is a
 // RequeueAfterError or not.
 func ReconcileErr(err error) (reconcile.Result, error) {
@@ -0,0 +1,80 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shell_test
+
+import (
+	"math/rand"
+	"os/exec"
+	"reflect"
+	"testing/quick"
+
+	. "github.com/gardener/gardener-extensions/pkg/shell"
+	"github.com/gardener/gardener-extensions/pkg/simulator"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// shellAlphabet contains the characters that have a special meaning in shell scripts, besides some
+// ordinary and non-ASCII ones.
+var shellAlphabet = []rune("ab09 \t\n'\"\\$`(){}[]|&;<>*?~!#=%^-/.:,\u00e4\u2603")
+
+// word is a random string made of characters of the shellAlphabet.
+type word string
+
+func (word) Generate(r *rand.Rand, size int) reflect.Value {
+	runes := make([]rune, r.Intn(size+1))
+	for i := range runes {
+		runes[i] = shellAlphabet[r.Intn(len(shellAlphabet))]
+	}
+	return reflect.ValueOf(word(runes))
+}
+
+var _ = Describe("Quote", func() {
+	It("should quote strings", func() {
+		Expect(Quote("")).To(Equal("''"))
+		Expect(Quote("/etc/foo")).To(Equal("'/etc/foo'"))
+		Expect(Quote("it's $(reboot)")).To(Equal(`'it'\''s $(reboot)'`))
+	})
+
+	It("should prevent expansions", func() {
+		for _, s := range []string{"$HOME", "`reboot`", "$(reboot)", "*", "~", "a b", "a;b", "a'b", `a\b`, `"`, "a|b&&c"} {
+			commands, err := simulator.ParseScript([]byte("echo " + Quote(s)))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(commands).To(Equal([]simulator.Command{{Args: []string{"echo", s}}}), s)
+		}
+	})
+
+	It("should be parsed as a single word with the original value", func() {
+		config := &quick.Config{MaxCount: 500, Rand: rand.New(rand.NewSource(1))}
+		Expect(quick.Check(func(w word) bool {
+			s := string(w)
+			commands, err := simulator.ParseScript([]byte("echo " + Quote(s) + " > " + Quote(s)))
+			return err == nil && reflect.DeepEqual(commands, []simulator.Command{{Args: []string{"echo", s}, Redirects: []string{"> " + s}}})
+		}, config)).To(Succeed())
+	})
+
+	It("should round-trip through bash", func() {
+		if _, err := exec.LookPath("bash"); err != nil {
+			Skip("bash is not available")
+		}
+
+		config := &quick.Config{MaxCount: 100, Rand: rand.New(rand.NewSource(1))}
+		Expect(quick.Check(func(w word) bool {
+			out, err := exec.Command("bash", "-c", "printf %s "+Quote(string(w))).Output()
+			return err == nil && string(out) == string(w)
+		}, config)).To(Succeed())
+	})
+})
@@ -0,0 +1,558 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type builtin func(sh *shell, args []string, stdin []byte, stdout *bytes.Buffer) (int, error)
+
+var builtins map[string]builtin
+
+func init() {
+	builtins = map[string]builtin{
+		"set":       builtinSet,
+		"export":    builtinExport,
+		"exit":      builtinExit,
+		"true":      func(*shell, []string, []byte, *bytes.Buffer) (int, error) { return 0, nil },
+		"false":     func(*shell, []string, []byte, *bytes.Buffer) (int, error) { return 1, nil },
+		"echo":      builtinEcho,
+		"mkdir":     builtinMkdir,
+		"cat":       builtinCat,
+		"base64":    builtinBase64,
+		"chmod":     builtinChmod,
+		"mv":        builtinMv,
+		"rm":        builtinRm,
+		"touch":     builtinTouch,
+		"sed":       builtinSed,
+		"curl":      builtinCurl,
+		"sha256sum": builtinSha256sum,
+		"systemctl": builtinSystemctl,
+	}
+}
+
+func (sh *shell) execBuiltin(args []string, stdin []byte, stdout *bytes.Buffer) (int, error) {
+	name := args[0]
+	if strings.HasPrefix(name, "/") {
+		name = filepath.Base(name)
+	}
+
+	b, ok := builtins[name]
+	if !ok {
+		sh.failure("%s: command not found", args[0])
+		return 127, nil
+	}
+	return b(sh, args[1:], stdin, stdout)
+}
+
+func (s *Simulator) readFile(p string) ([]byte, error) {
+	return ioutil.ReadFile(s.hostPath(p))
+}
+
+func (s *Simulator) redirectOutput(p string, data []byte, appendData bool) error {
+	hostPath := s.hostPath(p)
+	if _, err := os.Stat(filepath.Dir(hostPath)); err != nil {
+		return err
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appendData {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+
+	f, err := os.OpenFile(hostPath, flags, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// splitFlags separates leading flags from the operands of a command.
+func splitFlags(args []string) (flags []string, operands []string) {
+	for i, arg := range args {
+		if arg == "--" {
+			return flags, args[i+1:]
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			return flags, args[i:]
+		}
+		flags = append(flags, arg)
+	}
+	return flags, nil
+}
+
+func hasFlag(flags []string, short byte, long string) bool {
+	for _, flag := range flags {
+		if flag == long {
+			return true
+		}
+		if !strings.HasPrefix(flag, "--") && strings.IndexByte(flag[1:], short) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func builtinSet(sh *shell, args []string, _ []byte, _ *bytes.Buffer) (int, error) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if len(arg) < 2 || (arg[0] != '-' && arg[0] != '+') {
+			return 2, fmt.Errorf("set: unsupported argument %q", arg)
+		}
+
+		enable := arg[0] == '-'
+		for _, opt := range arg[1:] {
+			switch opt {
+			case 'e':
+				sh.errexit = enable
+			case 'u':
+				sh.nounset = enable
+			case 'x':
+			case 'o':
+				if i+1 >= len(args) {
+					return 2, fmt.Errorf("set: option name missing")
+				}
+				i++
+				switch args[i] {
+				case "pipefail":
+					sh.pipefail = enable
+				case "errexit":
+					sh.errexit = enable
+				case "nounset":
+					sh.nounset = enable
+				default:
+					return 2, fmt.Errorf("set: unsupported option %q", args[i])
+				}
+			default:
+				return 2, fmt.Errorf("set: unsupported option %q", string(opt))
+			}
+		}
+	}
+	return 0, nil
+}
+
+func builtinExport(sh *shell, args []string, _ []byte, _ *bytes.Buffer) (int, error) {
+	for _, arg := range args {
+		if idx := strings.IndexByte(arg, '='); idx > 0 {
+			sh.vars[arg[:idx]] = arg[idx+1:]
+		}
+	}
+	return 0, nil
+}
+
+func builtinExit(sh *shell, args []string, _ []byte, _ *bytes.Buffer) (int, error) {
+	status := sh.status
+	if len(args) > 0 {
+		s, err := strconv.Atoi(args[0])
+		if err != nil {
+			return 2, fmt.Errorf("exit: numeric argument required: %q", args[0])
+		}
+		status = s
+	}
+	return status, &exitError{status: status}
+}
+
+func builtinEcho(_ *shell, args []string, _ []byte, stdout *bytes.Buffer) (int, error) {
+	newline := true
+	if len(args) > 0 && args[0] == "-n" {
+		newline, args = false, args[1:]
+	}
+	stdout.WriteString(strings.Join(args, " "))
+	if newline {
+		stdout.WriteByte('\n')
+	}
+	return 0, nil
+}
+
+func builtinMkdir(sh *shell, args []string, _ []byte, _ *bytes.Buffer) (int, error) {
+	flags, dirs := splitFlags(args)
+	parents := hasFlag(flags, 'p', "--parents")
+
+	for _, dir := range dirs {
+		hostPath := sh.sim.hostPath(dir)
+		if parents {
+			if err := os.MkdirAll(hostPath, 0755); err != nil {
+				sh.failure("mkdir: %v", err)
+				return 1, nil
+			}
+			continue
+		}
+		if err := os.Mkdir(hostPath, 0755); err != nil {
+			sh.failure("mkdir: %v", err)
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func builtinCat(sh *shell, args []string, stdin []byte, stdout *bytes.Buffer) (int, error) {
+	_, files := splitFlags(args)
+	if len(files) == 0 {
+		stdout.Write(stdin)
+		return 0, nil
+	}
+
+	for _, file := range files {
+		if file == "-" {
+			stdout.Write(stdin)
+			continue
+		}
+		data, err := sh.sim.readFile(file)
+		if err != nil {
+			sh.failure("cat: %v", err)
+			return 1, nil
+		}
+		stdout.Write(data)
+	}
+	return 0, nil
+}
+
+func builtinBase64(sh *shell, args []string, stdin []byte, stdout *bytes.Buffer) (int, error) {
+	flags, files := splitFlags(args)
+
+	input := stdin
+	if len(files) > 0 {
+		data, err := sh.sim.readFile(files[0])
+		if err != nil {
+			sh.failure("base64: %v", err)
+			return 1, nil
+		}
+		input = data
+	}
+
+	if hasFlag(flags, 'd', "--decode") {
+		data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(input)), ""))
+		if err != nil {
+			sh.failure("base64: invalid input: %v", err)
+			return 1, nil
+		}
+		stdout.Write(data)
+		return 0, nil
+	}
+
+	stdout.WriteString(base64.StdEncoding.EncodeToString(input))
+	stdout.WriteByte('\n')
+	return 0, nil
+}
+
+func builtinChmod(sh *shell, args []string, _ []byte, _ *bytes.Buffer) (int, error) {
+	_, operands := splitFlags(args)
+	if len(operands) < 2 {
+		sh.failure("chmod: missing operand")
+		return 1, nil
+	}
+
+	mode, err := strconv.ParseUint(operands[0], 8, 32)
+	if err != nil {
+		sh.failure("chmod: invalid mode %q", operands[0])
+		return 1, nil
+	}
+
+	for _, file := range operands[1:] {
+		if err := os.Chmod(sh.sim.hostPath(file), os.FileMode(mode)); err != nil {
+			sh.failure("chmod: %v", err)
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func builtinMv(sh *shell, args []string, _ []byte, _ *bytes.Buffer) (int, error) {
+	_, operands := splitFlags(args)
+	if len(operands) != 2 {
+		sh.failure("mv: unsupported operands %q", operands)
+		return 1, nil
+	}
+
+	if err := os.Rename(sh.sim.hostPath(operands[0]), sh.sim.hostPath(operands[1])); err != nil {
+		sh.failure("mv: %v", err)
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func builtinRm(sh *shell, args []string, _ []byte, _ *bytes.Buffer) (int, error) {
+	flags, files := splitFlags(args)
+	force := hasFlag(flags, 'f', "--force")
+	recursive := hasFlag(flags, 'r', "--recursive")
+
+	for _, file := range files {
+		hostPath := sh.sim.hostPath(file)
+		if _, err := os.Lstat(hostPath); err != nil {
+			if force && os.IsNotExist(err) {
+				continue
+			}
+			sh.failure("rm: %v", err)
+			return 1, nil
+		}
+
+		remove := os.Remove
+		if recursive {
+			remove = os.RemoveAll
+		}
+		if err := remove(hostPath); err != nil {
+			sh.failure("rm: %v", err)
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+// builtinTouch creates the given files if they do not exist. Timestamps are not simulated.
+func builtinTouch(sh *shell, args []string, _ []byte, _ *bytes.Buffer) (int, error) {
+	_, files := splitFlags(args)
+	for _, file := range files {
+		f, err := os.OpenFile(sh.sim.hostPath(file), os.O_WRONLY|os.O_CREATE, 0644)
+		if err != nil {
+			sh.failure("touch: %v", err)
+			return 1, nil
+		}
+		if err := f.Close(); err != nil {
+			return 1, err
+		}
+	}
+	return 0, nil
+}
+
+var (
+	sedDeletion        = regexp.MustCompile(`^/((?:[^/\\]|\\.)*)/d$`)
+	sedExpression      = regexp.MustCompile(`^(?:/((?:[^/\\]|\\.)*)/)?s/((?:[^/\\]|\\.)*)/((?:[^/\\]|\\.)*)/([gp]*)$`)
+	sedReplacementRefs = regexp.MustCompile(`\\[0-9&]|&`)
+)
+
+// builtinSed supports substitutions of the form `[/address/]s/regexp/replacement/[g][p]` and
+// deletions of the form `/address/d`, either in-place or on stdin. Expressions are evaluated as Go regular expressions, `-E` is ignored.
+// With `-n`, only the lines printed by the `p` flag are written.
+func builtinSed(sh *shell, args []string, stdin []byte, stdout *bytes.Buffer) (int, error) {
+	flags, operands := splitFlags(args)
+	inPlace := hasFlag(flags, 'i', "--in-place")
+	if (inPlace && len(operands) != 2) || (!inPlace && len(operands) != 1) {
+		return 2, fmt.Errorf("sed: only substitutions and deletions in-place or on stdin are supported, got %q", args)
+	}
+
+	// Deletions reuse the address of the substitutions, their lines are dropped instead.
+	deletion := false
+	match := sedExpression.FindStringSubmatch(operands[0])
+	if m := sedDeletion.FindStringSubmatch(operands[0]); m != nil {
+		deletion, match = true, []string{m[0], m[1], "^", "", ""}
+	}
+	if match == nil {
+		return 2, fmt.Errorf("sed: unsupported expression %q", operands[0])
+	}
+
+	var address *regexp.Regexp
+	if match[1] != "" {
+		a, err := regexp.Compile(match[1])
+		if err != nil {
+			return 2, fmt.Errorf("sed: invalid address %q: %v", match[1], err)
+		}
+		address = a
+	}
+	pattern, err := regexp.Compile(match[2])
+	if err != nil {
+		return 2, fmt.Errorf("sed: invalid expression %q: %v", match[2], err)
+	}
+	replacement := sedReplacementRefs.ReplaceAllStringFunc(match[3], func(ref string) string {
+		switch ref {
+		case "&":
+			return "${0}"
+		case `\&`:
+			return "&"
+		}
+		return "${" + ref[1:] + "}"
+	})
+	global, print, quiet := strings.Contains(match[4], "g"), strings.Contains(match[4], "p"), hasFlag(flags, 'n', "--quiet")
+
+	data := stdin
+	if inPlace {
+		if data, err = sh.sim.readFile(operands[1]); err != nil {
+			sh.failure("sed: %v", err)
+			return 2, nil
+		}
+	}
+
+	var out bytes.Buffer
+	lines := strings.SplitAfter(string(data), "\n")
+	for _, line := range lines {
+		text := strings.TrimSuffix(line, "\n")
+		if text == "" && line == "" {
+			continue
+		}
+
+		if deletion && address.MatchString(text) {
+			continue
+		}
+
+		substituted := false
+		if address == nil || address.MatchString(text) {
+			if loc := pattern.FindStringSubmatchIndex(text); loc != nil {
+				substituted = true
+				if global {
+					text = pattern.ReplaceAllString(text, replacement)
+				} else {
+					var dst []byte
+					dst = pattern.ExpandString(dst, replacement, text, loc)
+					text = text[:loc[0]] + string(dst) + text[loc[1]:]
+				}
+			}
+		}
+
+		newline := text + line[len(strings.TrimSuffix(line, "\n")):]
+		if !quiet {
+			out.WriteString(newline)
+		}
+		if substituted && print {
+			out.WriteString(text + "\n")
+		}
+	}
+
+	if !inPlace {
+		stdout.Write(out.Bytes())
+		return 0, nil
+	}
+	info, err := os.Stat(sh.sim.hostPath(operands[1]))
+	if err != nil {
+		return 2, err
+	}
+	if err := ioutil.WriteFile(sh.sim.hostPath(operands[1]), out.Bytes(), info.Mode()); err != nil {
+		return 2, err
+	}
+	return 0, nil
+}
+
+// builtinCurl fetches the given URL using the simulator's HTTPGet function or HTTPClient. Only
+// the URL operand, `--header` and `--retry` are evaluated, all other flags are ignored.
+func builtinCurl(sh *shell, args []string, _ []byte, stdout *bytes.Buffer) (int, error) {
+	var (
+		url     string
+		headers []string
+		retries int
+	)
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case strings.HasPrefix(arg, "http://") || strings.HasPrefix(arg, "https://"):
+			url = arg
+		case (arg == "-H" || arg == "--header") && i+1 < len(args):
+			headers = append(headers, args[i+1])
+			i++
+		case arg == "--retry" && i+1 < len(args):
+			// With `--retry N`, failed requests are retried up to N times.
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				return 2, fmt.Errorf("curl: invalid retry count %q", args[i+1])
+			}
+			retries = n
+			i++
+		}
+	}
+	if url == "" {
+		sh.failure("curl: no URL specified")
+		return 2, nil
+	}
+
+	if sh.sim.HTTPGet == nil && sh.sim.HTTPClient == nil {
+		sh.failure("curl: (7) failed to connect to %s", url)
+		return 7, nil
+	}
+
+	var err error
+	for attempt := 0; attempt <= retries; attempt++ {
+		var data []byte
+		if data, err = sh.sim.httpGet(url, headers); err == nil {
+			stdout.Write(data)
+			return 0, nil
+		}
+	}
+	sh.failure("curl: %v", err)
+	return 22, nil
+}
+
+// builtinSha256sum prints the SHA-256 checksums of the given files or of stdin. With `--check`,
+// it reads lines of the form `<checksum>  <path>` from stdin instead and fails if a file is
+// missing or has another checksum. Paths of lines starting with `\` are unescaped.
+func builtinSha256sum(sh *shell, args []string, stdin []byte, stdout *bytes.Buffer) (int, error) {
+	flags, files := splitFlags(args)
+	sum := func(data []byte) string {
+		s := sha256.Sum256(data)
+		return hex.EncodeToString(s[:])
+	}
+
+	if !hasFlag(flags, 'c', "--check") {
+		if len(files) == 0 {
+			fmt.Fprintf(stdout, "%s  -\n", sum(stdin))
+			return 0, nil
+		}
+		for _, file := range files {
+			data, err := sh.sim.readFile(file)
+			if err != nil {
+				sh.failure("sha256sum: %s: %v", file, err)
+				return 1, nil
+			}
+			fmt.Fprintf(stdout, "%s  %s\n", sum(data), file)
+		}
+		return 0, nil
+	}
+
+	status := 0
+	for _, line := range strings.Split(strings.TrimSuffix(string(stdin), "\n"), "\n") {
+		escaped := strings.HasPrefix(line, "\\")
+		if escaped {
+			line = line[1:]
+		}
+		fields := strings.SplitN(line, "  ", 2)
+		if len(fields) != 2 {
+			sh.failure("sha256sum: no properly formatted checksum lines found")
+			return 1, nil
+		}
+		checksum, file := fields[0], fields[1]
+		if escaped {
+			file = strings.NewReplacer("\\\\", "\\", "\\n", "\n").Replace(file)
+		}
+
+		data, err := sh.sim.readFile(file)
+		if err != nil || sum(data) != checksum {
+			status = 1
+		}
+	}
+	return status, nil
+}
+
+func builtinSystemctl(sh *shell, args []string, _ []byte, _ *bytes.Buffer) (int, error) {
+	_, operands := splitFlags(args)
+	if len(operands) == 0 {
+		sh.failure("systemctl: missing verb")
+		return 1, nil
+	}
+
+	if err := sh.sim.systemctl(operands[0], operands[1:]...); err != nil {
+		return 1, err
+	}
+	return 0, nil
+}
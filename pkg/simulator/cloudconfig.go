@@ -0,0 +1,250 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+const cloudConfigHeader = "#cloud-config"
+
+// The following types mirror the subset of the coreos-cloudinit configuration that is understood
+// by the simulator. They are deliberately independent of the renderers' types.
+
+type cloudConfig struct {
+	CoreOS     cloudConfigCoreOS `yaml:"coreos"`
+	WriteFiles []cloudConfigFile `yaml:"write_files"`
+}
+
+type cloudConfigCoreOS struct {
+	Update    cloudConfigUpdate    `yaml:"update"`
+	Locksmith cloudConfigLocksmith `yaml:"locksmith"`
+	Units     []cloudConfigUnit    `yaml:"units"`
+}
+
+type cloudConfigLocksmith struct {
+	WindowStart  string `yaml:"window_start"`
+	WindowLength string `yaml:"window_length"`
+}
+
+type cloudConfigUpdate struct {
+	RebootStrategy string `yaml:"reboot_strategy"`
+	Group          string `yaml:"group"`
+	Server         string `yaml:"server"`
+}
+
+type cloudConfigUnit struct {
+	Name    string                  `yaml:"name"`
+	Mask    bool                    `yaml:"mask"`
+	Enable  bool                    `yaml:"enable"`
+	Runtime bool                    `yaml:"runtime"`
+	Content string                  `yaml:"content"`
+	Command string                  `yaml:"command"`
+	DropIns []cloudConfigUnitDropIn `yaml:"drop_ins"`
+}
+
+type cloudConfigUnitDropIn struct {
+	Name    string `yaml:"name"`
+	Content string `yaml:"content"`
+}
+
+type cloudConfigFile struct {
+	Encoding           string `yaml:"encoding"`
+	Content            string `yaml:"content"`
+	Owner              string `yaml:"owner"`
+	Path               string `yaml:"path"`
+	RawFilePermissions string `yaml:"permissions"`
+}
+
+// decodeCloudConfigContent decodes the given content the way coreos-cloudinit does.
+func decodeCloudConfigContent(encoding, content string) ([]byte, error) {
+	switch encoding {
+	case "":
+		return []byte(content), nil
+	case "b64", "base64":
+		return base64.StdEncoding.DecodeString(content)
+	case "gz", "gzip":
+		return gunzip([]byte(content))
+	case "gz+base64", "gzip+base64", "gz+b64", "gzip+b64":
+		data, err := base64.StdEncoding.DecodeString(content)
+		if err != nil {
+			return nil, err
+		}
+		return gunzip(data)
+	}
+	return nil, fmt.Errorf("unsupported encoding %q", encoding)
+}
+
+func gunzip(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = r.Close() }()
+	return ioutil.ReadAll(r)
+}
+
+func parseFilePermissions(raw string) (os.FileMode, error) {
+	if raw == "" {
+		return 0644, nil
+	}
+	perm, err := strconv.ParseUint(raw, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid file permissions %q: %v", raw, err)
+	}
+	return os.FileMode(perm), nil
+}
+
+// ApplyCloudConfig applies the given CoreOS `#cloud-config` document the way coreos-cloudinit
+// does: files are written first, then units and drop-ins are written or masked, systemd is reloaded
+// and finally the units are enabled and their commands are executed.
+func (s *Simulator) ApplyCloudConfig(data []byte) error {
+	if !strings.HasPrefix(string(data), cloudConfigHeader) {
+		return fmt.Errorf("cloud config does not start with %q", cloudConfigHeader)
+	}
+
+	config := &cloudConfig{}
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return err
+	}
+
+	if err := s.ensureDefaultDirectories(); err != nil {
+		return err
+	}
+
+	for _, file := range config.WriteFiles {
+		if file.Path == "" {
+			return fmt.Errorf("file without path")
+		}
+
+		content, err := decodeCloudConfigContent(file.Encoding, file.Content)
+		if err != nil {
+			return fmt.Errorf("could not decode file %q: %v", file.Path, err)
+		}
+
+		perm, err := parseFilePermissions(file.RawFilePermissions)
+		if err != nil {
+			return fmt.Errorf("file %q: %v", file.Path, err)
+		}
+
+		if err := s.writeFile(file.Path, content, perm); err != nil {
+			return err
+		}
+	}
+
+	if update := config.CoreOS.Update; update != (cloudConfigUpdate{}) {
+		if err := s.writeFile("/etc/coreos/update.conf", updateConf(update), 0644); err != nil {
+			return err
+		}
+	}
+
+	if locksmith := config.CoreOS.Locksmith; locksmith != (cloudConfigLocksmith{}) {
+		if err := s.writeFile("/run/systemd/system/locksmithd.service.d/20-cloudinit.conf", locksmithDropIn(locksmith), 0644); err != nil {
+			return err
+		}
+	}
+
+	for _, unit := range config.CoreOS.Units {
+		if unit.Name == "" {
+			return fmt.Errorf("unit without name")
+		}
+
+		unitsPath := s.unitsPath()
+		if unit.Runtime {
+			unitsPath = "/run/systemd/system"
+		}
+
+		if unit.Mask {
+			if err := s.systemctl("mask", unit.Name); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if unit.Content != "" {
+			if err := s.writeFile(path.Join(unitsPath, unit.Name), []byte(unit.Content), 0644); err != nil {
+				return err
+			}
+		}
+
+		for _, dropIn := range unit.DropIns {
+			if dropIn.Name == "" {
+				return fmt.Errorf("drop-in of unit %q without name", unit.Name)
+			}
+			if err := s.writeFile(path.Join(unitsPath, unit.Name+".d", dropIn.Name), []byte(dropIn.Content), 0644); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := s.systemctl("daemon-reload"); err != nil {
+		return err
+	}
+
+	for _, unit := range config.CoreOS.Units {
+		if unit.Mask {
+			continue
+		}
+		if unit.Enable {
+			if err := s.systemctl("enable", unit.Name); err != nil {
+				return err
+			}
+		}
+		if unit.Command != "" {
+			if err := s.systemctl(unit.Command, unit.Name); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func updateConf(update cloudConfigUpdate) []byte {
+	var buf bytes.Buffer
+	if update.Group != "" {
+		fmt.Fprintf(&buf, "GROUP=%s\n", update.Group)
+	}
+	if update.Server != "" {
+		fmt.Fprintf(&buf, "SERVER=%s\n", update.Server)
+	}
+	if update.RebootStrategy != "" {
+		fmt.Fprintf(&buf, "REBOOT_STRATEGY=%s\n", update.RebootStrategy)
+	}
+	return buf.Bytes()
+}
+
+// locksmithDropIn returns the drop-in coreos-cloudinit configures locksmithd with.
+func locksmithDropIn(locksmith cloudConfigLocksmith) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("[Service]\n")
+	if locksmith.WindowStart != "" {
+		fmt.Fprintf(&buf, "Environment=LOCKSMITHD_REBOOT_WINDOW_START=%s\n", locksmith.WindowStart)
+	}
+	if locksmith.WindowLength != "" {
+		fmt.Fprintf(&buf, "Environment=LOCKSMITHD_REBOOT_WINDOW_LENGTH=%s\n", locksmith.WindowLength)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,194 @@
+package simulator_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	. "github.com/gardener/gardener-extensions/pkg/simulator"
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CloudConfig", func() {
+	var (
+		root string
+		sim  *Simulator
+	)
+
+	BeforeEach(func() {
+		var err error
+		root, err = ioutil.TempDir("", "simulator")
+		Expect(err).NotTo(HaveOccurred())
+		sim = New(root)
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(root)).To(Succeed())
+	})
+
+	It("should apply files, units and drop-ins", func() {
+		Expect(sim.ApplyCloudConfig([]byte(`#cloud-config
+
+coreos:
+  update:
+    reboot_strategy: "off"
+  units:
+  - name: update-engine.service
+    mask: true
+  - name: docker-monitor.service
+    enable: true
+    command: start
+    content: |
+      [Service]
+      ExecStart=/opt/bin/health-monitor
+  - name: docker.service
+    drop_ins:
+    - name: 10-docker-opts.conf
+      content: override
+write_files:
+- encoding: b64
+  content: YmFy
+  path: /foo
+  permissions: "600"
+- content: plain
+  path: /etc/plain
+- encoding: gzip+b64
+  content: H4sIAAAAAAAAA0vLz+cCAKhlMn4EAAAA
+  path: /etc/gzipped
+  permissions: "755"
+`))).To(Succeed())
+
+		manifest, err := sim.Manifest()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(manifest.Files).To(Equal(map[string]File{
+			"/foo":                    {Content: "bar", Permissions: 0600},
+			"/etc/plain":              {Content: "plain", Permissions: 0644},
+			"/etc/gzipped":            {Content: "foo\n", Permissions: 0755},
+			"/etc/coreos/update.conf": {Content: "REBOOT_STRATEGY=off\n", Permissions: 0644},
+		}))
+
+		Expect(manifest.UnitNames()).To(Equal([]string{"docker-monitor.service", "docker.service", "update-engine.service"}))
+		Expect(manifest.Units["update-engine.service"].Masked).To(BeTrue())
+		Expect(*manifest.Units["docker-monitor.service"].Content).To(Equal("[Service]\nExecStart=/opt/bin/health-monitor\n"))
+		Expect(manifest.Units["docker-monitor.service"].Enabled).To(BeTrue())
+		Expect(manifest.Units["docker-monitor.service"].Active).To(BeTrue())
+		Expect(manifest.Units["docker.service"].Content).To(BeNil())
+		Expect(manifest.Units["docker.service"].DropIns).To(Equal(map[string]string{"10-docker-opts.conf": "override"}))
+
+		Expect(manifest.Actions).To(Equal([]Action{
+			{Verb: "mask", Units: []string{"update-engine.service"}},
+			{Verb: "daemon-reload"},
+			{Verb: "enable", Units: []string{"docker-monitor.service"}},
+			{Verb: "start", Units: []string{"docker-monitor.service"}},
+		}))
+	})
+
+	It("should configure the reboot window of locksmithd", func() {
+		Expect(sim.ApplyCloudConfig([]byte(`#cloud-config
+
+coreos:
+  locksmith:
+    window_start: Sun 03:00
+    window_length: 2h
+`))).To(Succeed())
+
+		manifest, err := sim.Manifest()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(manifest.Files).To(HaveKeyWithValue("/run/systemd/system/locksmithd.service.d/20-cloudinit.conf", File{
+			Content:     "[Service]\nEnvironment=LOCKSMITHD_REBOOT_WINDOW_START=Sun 03:00\nEnvironment=LOCKSMITHD_REBOOT_WINDOW_LENGTH=2h\n",
+			Permissions: 0644,
+		}))
+	})
+
+	It("should fail for documents without header", func() {
+		Expect(sim.ApplyCloudConfig([]byte("coreos: {}\n"))).NotTo(Succeed())
+	})
+
+	It("should fail for unknown encodings", func() {
+		Expect(sim.ApplyCloudConfig([]byte(`#cloud-config
+write_files:
+- encoding: rot13
+  content: bar
+  path: /foo
+`))).NotTo(Succeed())
+	})
+
+	It("should fail for unknown keys", func() {
+		Expect(sim.ApplyCloudConfig([]byte(`#cloud-config
+write_file:
+- path: /foo
+`))).NotTo(Succeed())
+	})
+
+	Describe("#Verify", func() {
+		var (
+			content = "[Service]\nExecStart=/bin/true\n"
+			enable  = true
+			perm    = int32(0600)
+			spec    *extensionsv1alpha1.OperatingSystemConfigSpec
+		)
+
+		BeforeEach(func() {
+			spec = &extensionsv1alpha1.OperatingSystemConfigSpec{
+				Units: []extensionsv1alpha1.Unit{
+					{Name: "foo.service", Enable: &enable, Content: &content},
+				},
+				Files: []extensionsv1alpha1.File{
+					{
+						Path:        "/foo",
+						Permissions: &perm,
+						Content: extensionsv1alpha1.FileContent{
+							Inline: &extensionsv1alpha1.FileContentInline{Encoding: "b64", Data: "YmFy"},
+						},
+					},
+				},
+			}
+		})
+
+		It("should succeed if the manifest matches", func() {
+			Expect(sim.ApplyCloudConfig([]byte(`#cloud-config
+coreos:
+  units:
+  - name: foo.service
+    enable: true
+    content: |
+      [Service]
+      ExecStart=/bin/true
+write_files:
+- path: /foo
+  content: bar
+  permissions: "0600"
+`))).To(Succeed())
+
+			manifest, err := sim.Manifest()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(manifest.Verify(spec, nil)).To(Succeed())
+			Expect(manifest.VerifyUnitStates(spec)).To(Succeed())
+		})
+
+		It("should report all mismatches", func() {
+			Expect(sim.ApplyCloudConfig([]byte(`#cloud-config
+coreos:
+  units:
+  - name: foo.service
+    content: other
+write_files:
+- path: /foo
+  content: baz
+`))).To(Succeed())
+
+			manifest, err := sim.Manifest()
+			Expect(err).NotTo(HaveOccurred())
+
+			err = manifest.Verify(spec, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring(`file "/foo" has content "baz", expected "bar"`))
+			Expect(err.Error()).To(ContainSubstring(`file "/foo" has permissions 0644, expected 0600`))
+			Expect(err.Error()).To(ContainSubstring(`unit "foo.service" does not have the expected content`))
+			Expect(manifest.VerifyUnitStates(spec)).To(MatchError(ContainSubstring(`unit "foo.service" is not enabled`)))
+		})
+	})
+})
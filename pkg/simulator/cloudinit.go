@@ -0,0 +1,153 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/gardener/gardener-extensions/pkg/cloudinit"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// cloudInitConfig mirrors the subset of the standard cloud-init configuration that is understood
+// by the simulator.
+type cloudInitConfig struct {
+	WriteFiles []cloudConfigFile `yaml:"write_files"`
+	RunCmd     []interface{}     `yaml:"runcmd"`
+}
+
+// runCmdScript returns the shell script cloud-init generates for the given `runcmd` entries. Strings
+// are taken as they are, the arguments of lists are quoted.
+func runCmdScript(entries []interface{}) (string, error) {
+	var script bytes.Buffer
+	script.WriteString("#!/bin/sh\n")
+	for i, entry := range entries {
+		switch e := entry.(type) {
+		case string:
+			script.WriteString(e)
+		case []interface{}:
+			args := make([]string, 0, len(e))
+			for _, arg := range e {
+				s, ok := arg.(string)
+				if !ok {
+					return "", fmt.Errorf("runcmd entry %d: argument %v is not a string", i, arg)
+				}
+				args = append(args, "'"+strings.Replace(s, "'", `'\''`, -1)+"'")
+			}
+			script.WriteString(strings.Join(args, " "))
+		default:
+			return "", fmt.Errorf("runcmd entry %d: unsupported type %T", i, entry)
+		}
+		script.WriteString("\n")
+	}
+	return script.String(), nil
+}
+
+// ApplyCloudInit applies the given standard cloud-init user-data the way cloud-init does. It is
+// either a `#cloud-config` document or multipart user-data as parsed by cloudinit.ParseMultipart.
+// The boothooks are run first, afterwards the files of `write_files` of all `#cloud-config` parts
+// are written, then the `runcmd` entries and the shell scripts are executed. Commands run without
+// `set -e`, i.e. failing commands do not abort the scripts. The lists of multiple `#cloud-config`
+// parts are appended as done by cloudinit.DefaultMergeType.
+func (s *Simulator) ApplyCloudInit(data []byte) error {
+	var parts []cloudinit.Part
+	if strings.HasPrefix(string(data), cloudConfigHeader) {
+		parts = []cloudinit.Part{{ContentType: cloudinit.ContentTypeCloudConfig, Content: data}}
+	} else {
+		m, err := cloudinit.ParseMultipart(data)
+		if err != nil {
+			return err
+		}
+		parts = m.Parts
+	}
+
+	var (
+		boothooks [][]byte
+		scripts   [][]byte
+		config    = &cloudInitConfig{}
+	)
+	for i, part := range parts {
+		switch part.ContentType {
+		case cloudinit.ContentTypeBoothook:
+			// cloud-init removes the `#cloud-boothook` line before executing the remaining content.
+			content := part.Content
+			if n := bytes.IndexByte(content, '\n'); n >= 0 {
+				content = content[n+1:]
+			}
+			boothooks = append(boothooks, content)
+		case cloudinit.ContentTypeCloudConfig:
+			if !strings.HasPrefix(string(part.Content), cloudConfigHeader) {
+				return fmt.Errorf("cloud config does not start with %q", cloudConfigHeader)
+			}
+			c := &cloudInitConfig{}
+			if err := yaml.UnmarshalStrict(part.Content, c); err != nil {
+				return err
+			}
+			config.WriteFiles = append(config.WriteFiles, c.WriteFiles...)
+			config.RunCmd = append(config.RunCmd, c.RunCmd...)
+		case cloudinit.ContentTypeShellScript:
+			scripts = append(scripts, part.Content)
+		default:
+			return fmt.Errorf("part %d: unsupported content type %q", i, part.ContentType)
+		}
+	}
+
+	if err := s.ensureDefaultDirectories(); err != nil {
+		return err
+	}
+
+	for _, boothook := range boothooks {
+		if err := s.ApplyScript(boothook); err != nil {
+			return err
+		}
+	}
+
+	for _, file := range config.WriteFiles {
+		if file.Path == "" {
+			return fmt.Errorf("file without path")
+		}
+
+		content, err := decodeCloudConfigContent(file.Encoding, file.Content)
+		if err != nil {
+			return fmt.Errorf("could not decode file %q: %v", file.Path, err)
+		}
+
+		perm, err := parseFilePermissions(file.RawFilePermissions)
+		if err != nil {
+			return fmt.Errorf("file %q: %v", file.Path, err)
+		}
+
+		if err := s.writeFile(file.Path, content, perm); err != nil {
+			return err
+		}
+	}
+
+	if len(config.RunCmd) > 0 {
+		script, err := runCmdScript(config.RunCmd)
+		if err != nil {
+			return err
+		}
+		scripts = append([][]byte{[]byte(script)}, scripts...)
+	}
+	for _, script := range scripts {
+		if err := s.ApplyScript(script); err != nil {
+			return err
+		}
+	}
+	return nil
+}
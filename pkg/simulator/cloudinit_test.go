@@ -0,0 +1,125 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/gardener/gardener-extensions/pkg/cloudinit"
+	. "github.com/gardener/gardener-extensions/pkg/simulator"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CloudInit", func() {
+	var (
+		root string
+		sim  *Simulator
+	)
+
+	BeforeEach(func() {
+		var err error
+		root, err = ioutil.TempDir("", "simulator")
+		Expect(err).NotTo(HaveOccurred())
+		sim = New(root)
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(root)).To(Succeed())
+	})
+
+	It("should write the files before running the commands", func() {
+		Expect(sim.Apply(FormatCloudInit, []byte(`#cloud-config
+write_files:
+- path: /etc/systemd/system/foo.service
+  content: |
+    [Service]
+    ExecStart=/bin/foo
+- path: /opt/bin/foo
+  encoding: b64
+  content: YmFy
+  permissions: "0755"
+runcmd:
+- systemctl daemon-reload
+- [systemctl, enable, foo.service]
+- [systemctl, start, "it's.service"]
+- cat /opt/bin/foo > /tmp/foo
+`))).To(Succeed())
+
+		manifest, err := sim.Manifest()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(manifest.Files).To(Equal(map[string]File{
+			"/opt/bin/foo": {Content: "bar", Permissions: 0755},
+			"/tmp/foo":     {Content: "bar", Permissions: 0644},
+		}))
+		Expect(*manifest.Units["foo.service"].Content).To(Equal("[Service]\nExecStart=/bin/foo\n"))
+		Expect(manifest.Units["foo.service"].Enabled).To(BeTrue())
+		Expect(manifest.Actions).To(Equal([]Action{
+			{Verb: "daemon-reload"},
+			{Verb: "enable", Units: []string{"foo.service"}},
+			{Verb: "start", Units: []string{"it's.service"}},
+		}))
+	})
+
+	It("should not abort if a command fails", func() {
+		Expect(sim.ApplyCloudInit([]byte(`#cloud-config
+runcmd:
+- cat /does/not/exist
+- systemctl daemon-reload
+`))).To(Succeed())
+
+		Expect(sim.Failures()).To(HaveLen(1))
+		Expect(sim.Actions()).To(Equal([]Action{{Verb: "daemon-reload"}}))
+	})
+
+	It("should fail for unknown keys", func() {
+		Expect(sim.ApplyCloudInit([]byte("#cloud-config\ncoreos: {}\n"))).NotTo(Succeed())
+	})
+
+	It("should fail for unsupported commands", func() {
+		Expect(sim.ApplyCloudInit([]byte("#cloud-config\nruncmd:\n- {foo: bar}\n"))).NotTo(Succeed())
+	})
+
+	Describe("multipart", func() {
+		apply := func(parts ...cloudinit.Part) error {
+			data, err := (&cloudinit.Multipart{Parts: parts}).Encode()
+			Expect(err).NotTo(HaveOccurred())
+			return sim.Apply(FormatCloudInit, data)
+		}
+
+		It("should run boothooks, merge the cloud-configs and run the scripts afterwards", func() {
+			Expect(apply(
+				cloudinit.Part{ContentType: cloudinit.ContentTypeShellScript, Content: []byte("#!/bin/sh\ncat /opt/bin/foo /opt/bin/bar > /tmp/foo\n")},
+				cloudinit.Part{ContentType: cloudinit.ContentTypeCloudConfig, Content: []byte("#cloud-config\nwrite_files:\n- path: /opt/bin/foo\n  content: foo\n")},
+				cloudinit.Part{ContentType: cloudinit.ContentTypeBoothook, Content: []byte("#cloud-boothook\n#!/bin/sh\nsystemctl mask foo.service\n")},
+				cloudinit.Part{ContentType: cloudinit.ContentTypeCloudConfig, Content: []byte("#cloud-config\nwrite_files:\n- path: /opt/bin/bar\n  content: bar\nruncmd:\n- systemctl daemon-reload\n")},
+			)).To(Succeed())
+
+			manifest, err := sim.Manifest()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(manifest.Files).To(HaveKeyWithValue("/tmp/foo", File{Content: "foobar", Permissions: 0644}))
+			Expect(manifest.Actions).To(Equal([]Action{
+				{Verb: "mask", Units: []string{"foo.service"}},
+				{Verb: "daemon-reload"},
+			}))
+		})
+
+		It("should fail for unsupported parts", func() {
+			Expect(apply(cloudinit.Part{ContentType: "text/x-include-url", Content: []byte("https://example.com\n")})).NotTo(Succeed())
+		})
+	})
+})
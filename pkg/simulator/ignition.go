@@ -0,0 +1,160 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// The following types mirror the subset of the Ignition specification v2.x that is understood
+// by the simulator. They are deliberately independent of the renderers' types.
+
+type ignitionConfig struct {
+	Ignition struct {
+		Version string `json:"version"`
+	} `json:"ignition"`
+	Storage struct {
+		Files []ignitionFile `json:"files"`
+	} `json:"storage"`
+	Systemd struct {
+		Units []ignitionUnit `json:"units"`
+	} `json:"systemd"`
+}
+
+type ignitionFile struct {
+	Filesystem string `json:"filesystem"`
+	Path       string `json:"path"`
+	Contents   struct {
+		Source string `json:"source"`
+	} `json:"contents"`
+	Mode *int `json:"mode"`
+}
+
+type ignitionUnit struct {
+	Name     string           `json:"name"`
+	Enabled  *bool            `json:"enabled"`
+	Mask     bool             `json:"mask"`
+	Contents string           `json:"contents"`
+	Dropins  []ignitionDropin `json:"dropins"`
+}
+
+type ignitionDropin struct {
+	Name     string `json:"name"`
+	Contents string `json:"contents"`
+}
+
+// decodeDataURL decodes the given data URL (RFC 2397).
+func decodeDataURL(dataURL string) ([]byte, error) {
+	if !strings.HasPrefix(dataURL, "data:") {
+		return nil, fmt.Errorf("unsupported source %q", dataURL)
+	}
+	i := strings.Index(dataURL, ",")
+	if i < 0 {
+		return nil, fmt.Errorf("data URL without data")
+	}
+
+	if strings.HasSuffix(dataURL[:i], ";base64") {
+		return base64.StdEncoding.DecodeString(dataURL[i+1:])
+	}
+	data, err := url.PathUnescape(dataURL[i+1:])
+	return []byte(data), err
+}
+
+// ApplyIgnition applies the given Ignition config the way Ignition does during the first boot:
+// files, units and drop-ins are written, units are masked and enabled. As systemd starts all
+// enabled units afterwards, they are started, too.
+func (s *Simulator) ApplyIgnition(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	config := &ignitionConfig{}
+	if err := decoder.Decode(config); err != nil {
+		return err
+	}
+	if !strings.HasPrefix(config.Ignition.Version, "2.") {
+		return fmt.Errorf("unsupported ignition version %q", config.Ignition.Version)
+	}
+
+	if err := s.ensureDefaultDirectories(); err != nil {
+		return err
+	}
+
+	for _, file := range config.Storage.Files {
+		if file.Filesystem != "root" {
+			return fmt.Errorf("file %q is on unsupported filesystem %q", file.Path, file.Filesystem)
+		}
+
+		content, err := decodeDataURL(file.Contents.Source)
+		if err != nil {
+			return fmt.Errorf("could not decode file %q: %v", file.Path, err)
+		}
+
+		mode := 0644
+		if file.Mode != nil {
+			mode = *file.Mode
+		}
+
+		if err := s.writeFile(file.Path, content, os.FileMode(mode)); err != nil {
+			return err
+		}
+	}
+
+	var enabled []string
+	for _, unit := range config.Systemd.Units {
+		if unit.Name == "" {
+			return fmt.Errorf("unit without name")
+		}
+
+		if unit.Mask {
+			if err := s.systemctl("mask", unit.Name); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if unit.Contents != "" {
+			if err := s.writeFile(path.Join(s.unitsPath(), unit.Name), []byte(unit.Contents), 0644); err != nil {
+				return err
+			}
+		}
+		for _, dropin := range unit.Dropins {
+			if err := s.writeFile(path.Join(s.unitsPath(), unit.Name+".d", dropin.Name), []byte(dropin.Contents), 0644); err != nil {
+				return err
+			}
+		}
+
+		if unit.Enabled != nil && *unit.Enabled {
+			if err := s.systemctl("enable", unit.Name); err != nil {
+				return err
+			}
+			enabled = append(enabled, unit.Name)
+		}
+	}
+
+	for _, unit := range enabled {
+		if err := s.systemctl("start", unit); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	. "github.com/gardener/gardener-extensions/pkg/simulator"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Ignition", func() {
+	var (
+		root string
+		sim  *Simulator
+	)
+
+	BeforeEach(func() {
+		var err error
+		root, err = ioutil.TempDir("", "simulator")
+		Expect(err).NotTo(HaveOccurred())
+		sim = New(root)
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(root)).To(Succeed())
+	})
+
+	It("should apply files, units and drop-ins", func() {
+		Expect(sim.ApplyIgnition([]byte(`{
+  "ignition": {"version": "2.2.0"},
+  "storage": {
+    "files": [
+      {"filesystem": "root", "path": "/foo", "contents": {"source": "data:;base64,YmFy"}, "mode": 384},
+      {"filesystem": "root", "path": "/etc/plain", "contents": {"source": "data:,plain%20text"}}
+    ]
+  },
+  "systemd": {
+    "units": [
+      {"name": "update-engine.service", "mask": true},
+      {"name": "docker-monitor.service", "enabled": true, "contents": "[Service]"},
+      {"name": "docker.service", "dropins": [{"name": "10-docker-opts.conf", "contents": "override"}]}
+    ]
+  }
+}`))).To(Succeed())
+
+		manifest, err := sim.Manifest()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(manifest.Files).To(Equal(map[string]File{
+			"/foo":       {Content: "bar", Permissions: 0600},
+			"/etc/plain": {Content: "plain text", Permissions: 0644},
+		}))
+		Expect(manifest.UnitNames()).To(Equal([]string{"docker-monitor.service", "docker.service", "update-engine.service"}))
+		Expect(manifest.Units["update-engine.service"].Masked).To(BeTrue())
+		Expect(*manifest.Units["docker-monitor.service"].Content).To(Equal("[Service]"))
+		Expect(manifest.Units["docker-monitor.service"].Enabled).To(BeTrue())
+		Expect(manifest.Units["docker-monitor.service"].Active).To(BeTrue())
+		Expect(manifest.Units["docker.service"].DropIns).To(Equal(map[string]string{"10-docker-opts.conf": "override"}))
+	})
+
+	It("should reject unknown fields and versions", func() {
+		Expect(sim.ApplyIgnition([]byte(`{"ignition": {"version": "2.2.0"}, "passwd": {}}`))).To(HaveOccurred())
+		Expect(sim.ApplyIgnition([]byte(`{"ignition": {"version": "3.0.0"}}`))).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,118 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Command is a simple command of a parsed script.
+type Command struct {
+	// Args are the words of the command after quote removal.
+	Args []string
+	// Redirects are the redirections of the command in the form `<operator> <target>`, with quotes
+	// removed from the target.
+	Redirects []string
+	// HereDoc is the body of the here-document of the command, if any.
+	HereDoc string
+	// Expands is true if any word or redirection target of the command is subject to parameter
+	// expansion, command substitution or pathname expansion, i.e. if Args and Redirects are not
+	// the values the shell passes to the command.
+	Expands bool
+}
+
+// String returns the command as it would be written in a shell script without quoting.
+func (c Command) String() string {
+	return strings.Join(append(append([]string{}, c.Args...), c.Redirects...), " ")
+}
+
+// ParseScript parses the given bash script into the list of its simple commands, in order of
+// appearance. Commands of pipelines and and-or lists are flattened. The same subset of the shell
+// language as by ApplyScript is supported.
+func ParseScript(data []byte) ([]Command, error) {
+	var commands []Command
+	err := scanScript(string(data), func(_ int, items []andOrItem) error {
+		for _, item := range items {
+			for _, cmd := range item.pipeline.commands {
+				commands = append(commands, newCommand(cmd))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return commands, nil
+}
+
+func newCommand(cmd *simpleCommand) Command {
+	var out Command
+	for _, w := range cmd.words {
+		arg, expands := removeQuotes(w)
+		out.Args = append(out.Args, arg)
+		out.Expands = out.Expands || expands
+	}
+	for _, r := range cmd.redirects {
+		target, expands := removeQuotes(r.target)
+		if r.doc != nil {
+			out.HereDoc = r.doc.body
+			// The delimiter of here-documents is not expanded, but an unquoted one causes the body
+			// to be expanded.
+			expands = !r.doc.quoted && strings.ContainsAny(r.doc.body, "$`\\")
+		}
+		out.Redirects = append(out.Redirects, fmt.Sprintf("%s %s", r.op, target))
+		out.Expands = out.Expands || expands
+	}
+	return out
+}
+
+// removeQuotes returns the value of the given word after quote removal and whether it is subject
+// to expansions.
+func removeQuotes(w word) (string, bool) {
+	var (
+		out     strings.Builder
+		expands bool
+	)
+	for _, seg := range w {
+		switch seg.kind {
+		case segmentSingleQuoted:
+			out.WriteString(seg.text)
+		case segmentDoubleQuoted:
+			for i := 0; i < len(seg.text); i++ {
+				c := seg.text[i]
+				switch {
+				case c == '\\' && i+1 < len(seg.text) && seg.text[i+1] == '\n':
+					// A backslash-newline is removed within double quotes.
+					i++
+				case c == '\\' && i+1 < len(seg.text) && strings.IndexByte("$`\"\\\n", seg.text[i+1]) >= 0:
+					out.WriteByte(seg.text[i+1])
+					i++
+				case c == '$' || c == '`':
+					expands = true
+					out.WriteByte(c)
+				default:
+					out.WriteByte(c)
+				}
+			}
+		default:
+			if strings.ContainsAny(seg.text, "$`*?[~") {
+				expands = true
+			}
+			out.WriteString(seg.text)
+		}
+	}
+	return out.String(), expands
+}
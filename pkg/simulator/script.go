@@ -0,0 +1,831 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// The script interpreter understands the subset of the POSIX shell language that is used by the
+// bash scripts rendered in this repository: simple commands with single- and double-quoted words,
+// parameter expansion, command substitution, pipelines, `&&`, `||`, `;`, redirections and
+// here-documents. Compound commands (if, for, while, case, functions) are not supported. All
+// commands are built-ins of the interpreter operating on the simulator's root directory.
+
+type segmentKind int
+
+const (
+	segmentUnquoted segmentKind = iota
+	segmentSingleQuoted
+	segmentDoubleQuoted
+)
+
+type segment struct {
+	kind segmentKind
+	text string
+}
+
+type word []segment
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenOperator
+)
+
+type token struct {
+	kind tokenKind
+	op   string
+	word word
+}
+
+type hereDoc struct {
+	delimiter string
+	quoted    bool
+	stripTabs bool
+	body      string
+}
+
+type redirect struct {
+	fd     int
+	op     string
+	target word
+	doc    *hereDoc
+}
+
+type simpleCommand struct {
+	words     []word
+	redirects []redirect
+}
+
+type pipeline struct {
+	negate   bool
+	commands []*simpleCommand
+}
+
+type andOrItem struct {
+	// op is the operator connecting this item with the previous one (`&&`, `||` or `;`).
+	op       string
+	pipeline *pipeline
+}
+
+var operators = []string{"&&", "||", ">>", "<<-", "<<", "2>>", "2>", "&>", ">", "<", "|", ";", "&"}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t'
+}
+
+func isNameChar(c byte, first bool) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (!first && c >= '0' && c <= '9')
+}
+
+// scanSubstitution returns the index after the closing parenthesis of a `$(` substitution that
+// starts at s[i] (pointing behind the opening parenthesis).
+func scanSubstitution(s string, i int) (int, error) {
+	depth := 1
+	for i < len(s) {
+		switch s[i] {
+		case '\\':
+			i++
+		case '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return 0, fmt.Errorf("unterminated single quote")
+			}
+			i += end + 1
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i + 1, nil
+			}
+		}
+		i++
+	}
+	return 0, fmt.Errorf("unterminated command substitution")
+}
+
+// incompleteLineError is returned by lex for lines that continue on the next line, i.e. lines
+// ending with an unquoted backslash or with an unterminated quote.
+type incompleteLineError struct {
+	// continuation is true if the line ends with an unquoted backslash.
+	continuation bool
+	message      string
+}
+
+func (e *incompleteLineError) Error() string {
+	return e.message
+}
+
+// lex splits the given line into tokens.
+func lex(line string) ([]token, error) {
+	var (
+		tokens []token
+		cur    word
+		inWord bool
+		i      int
+	)
+
+	flush := func() {
+		if inWord {
+			tokens = append(tokens, token{kind: tokenWord, word: cur})
+		}
+		cur, inWord = nil, false
+	}
+	appendText := func(kind segmentKind, text string) {
+		inWord = true
+		if n := len(cur); n > 0 && cur[n-1].kind == kind {
+			cur[n-1].text += text
+			return
+		}
+		cur = append(cur, segment{kind: kind, text: text})
+	}
+
+	for i < len(line) {
+		c := line[i]
+		switch {
+		case isSpace(c):
+			flush()
+			i++
+
+		case c == '#' && !inWord:
+			return tokens, nil
+
+		case c == '\'':
+			end := strings.IndexByte(line[i+1:], '\'')
+			if end < 0 {
+				return nil, &incompleteLineError{message: fmt.Sprintf("unterminated single quote in %q", line)}
+			}
+			appendText(segmentSingleQuoted, line[i+1:i+1+end])
+			i += end + 2
+
+		case c == '"':
+			j := i + 1
+			var buf strings.Builder
+			for ; j < len(line) && line[j] != '"'; j++ {
+				switch {
+				case line[j] == '\\' && j+1 < len(line):
+					buf.WriteByte(line[j])
+					buf.WriteByte(line[j+1])
+					j++
+				case line[j] == '$' && j+1 < len(line) && line[j+1] == '(':
+					end, err := scanSubstitution(line, j+2)
+					if err != nil {
+						return nil, err
+					}
+					buf.WriteString(line[j:end])
+					j = end - 1
+				case line[j] == '`':
+					end := strings.IndexByte(line[j+1:], '`')
+					if end < 0 {
+						return nil, fmt.Errorf("unterminated backquote in %q", line)
+					}
+					buf.WriteString(line[j : j+end+2])
+					j += end + 1
+				default:
+					buf.WriteByte(line[j])
+				}
+			}
+			if j >= len(line) {
+				return nil, &incompleteLineError{message: fmt.Sprintf("unterminated double quote in %q", line)}
+			}
+			appendText(segmentDoubleQuoted, buf.String())
+			i = j + 1
+
+		case c == '\\':
+			if i+1 == len(line) {
+				return nil, &incompleteLineError{continuation: true, message: fmt.Sprintf("line continuation at the end of %q", line)}
+			}
+			appendText(segmentSingleQuoted, line[i+1:i+2])
+			i += 2
+
+		case c == '$' && i+1 < len(line) && line[i+1] == '(':
+			end, err := scanSubstitution(line, i+2)
+			if err != nil {
+				return nil, err
+			}
+			appendText(segmentUnquoted, line[i:end])
+			i = end
+
+		case c == '`':
+			end := strings.IndexByte(line[i+1:], '`')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated backquote in %q", line)
+			}
+			appendText(segmentUnquoted, line[i:i+end+2])
+			i += end + 2
+
+		default:
+			op := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(line[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			// File descriptor redirections are only operators at the beginning of a word.
+			if op != "" && !(inWord && (op == "2>" || op == "2>>")) {
+				flush()
+				tokens = append(tokens, token{kind: tokenOperator, op: op})
+				i += len(op)
+				continue
+			}
+			appendText(segmentUnquoted, string(c))
+			i++
+		}
+	}
+
+	flush()
+	return tokens, nil
+}
+
+// literal returns the literal value of a word if it does not contain any expansions.
+func (w word) literal() string {
+	var out strings.Builder
+	for _, seg := range w {
+		out.WriteString(seg.text)
+	}
+	return out.String()
+}
+
+func (w word) quoted() bool {
+	for _, seg := range w {
+		if seg.kind != segmentUnquoted {
+			return true
+		}
+	}
+	return false
+}
+
+var unsupportedKeywords = map[string]bool{
+	"if": true, "then": true, "else": true, "elif": true, "fi": true,
+	"for": true, "while": true, "until": true, "do": true, "done": true,
+	"case": true, "esac": true, "function": true, "{": true, "}": true,
+	"(": true, ")": true,
+}
+
+// parse parses the given tokens into an and-or list.
+func parse(tokens []token) ([]andOrItem, []*hereDoc, error) {
+	var (
+		items    []andOrItem
+		docs     []*hereDoc
+		nextOp   = ";"
+		pipe     = &pipeline{}
+		cmd      = &simpleCommand{}
+		finalize = func() error {
+			if len(cmd.words) == 0 && len(cmd.redirects) == 0 {
+				return fmt.Errorf("syntax error: empty command")
+			}
+			pipe.commands = append(pipe.commands, cmd)
+			cmd = &simpleCommand{}
+			return nil
+		}
+	)
+
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		if tok.kind == tokenWord {
+			if len(cmd.words) == 0 && len(pipe.commands) == 0 && !tok.word.quoted() && tok.word.literal() == "!" {
+				pipe.negate = true
+				continue
+			}
+			if len(cmd.words) == 0 && !tok.word.quoted() && unsupportedKeywords[tok.word.literal()] {
+				return nil, nil, fmt.Errorf("unsupported shell construct %q", tok.word.literal())
+			}
+			if len(cmd.words) == 0 && strings.HasSuffix(tok.word.literal(), "()") {
+				return nil, nil, fmt.Errorf("unsupported shell construct: function definition")
+			}
+			cmd.words = append(cmd.words, tok.word)
+			continue
+		}
+
+		switch tok.op {
+		case ">", ">>", "<", "2>", "2>>", "&>", "<<", "<<-":
+			if i+1 >= len(tokens) || tokens[i+1].kind != tokenWord {
+				return nil, nil, fmt.Errorf("syntax error: missing target for %q", tok.op)
+			}
+			target := tokens[i+1].word
+			i++
+
+			r := redirect{fd: 1, op: tok.op, target: target}
+			switch tok.op {
+			case "<", "<<", "<<-":
+				r.fd = 0
+			case "2>", "2>>":
+				r.fd = 2
+			}
+			if tok.op == "<<" || tok.op == "<<-" {
+				r.doc = &hereDoc{delimiter: target.literal(), quoted: target.quoted(), stripTabs: tok.op == "<<-"}
+				docs = append(docs, r.doc)
+			}
+			cmd.redirects = append(cmd.redirects, r)
+
+		case "|":
+			if err := finalize(); err != nil {
+				return nil, nil, err
+			}
+
+		case "&&", "||", ";":
+			if err := finalize(); err != nil {
+				return nil, nil, err
+			}
+			items = append(items, andOrItem{op: nextOp, pipeline: pipe})
+			pipe, nextOp = &pipeline{}, tok.op
+
+		case "&":
+			return nil, nil, fmt.Errorf("unsupported shell construct: background job")
+		}
+	}
+
+	if len(cmd.words) > 0 || len(cmd.redirects) > 0 {
+		if err := finalize(); err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(pipe.commands) > 0 {
+		items = append(items, andOrItem{op: nextOp, pipeline: pipe})
+	} else if nextOp == "&&" || nextOp == "||" {
+		return nil, nil, fmt.Errorf("syntax error: unexpected end of line after %q", nextOp)
+	}
+
+	return items, docs, nil
+}
+
+// ApplyScript executes the given bash script against the simulator's root directory.
+func (s *Simulator) ApplyScript(data []byte) error {
+	if err := s.ensureDefaultDirectories(); err != nil {
+		return err
+	}
+	return newShell(s).run(string(data))
+}
+
+type exitError struct {
+	status int
+}
+
+func (e *exitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.status)
+}
+
+type shell struct {
+	sim  *Simulator
+	vars map[string]string
+
+	errexit  bool
+	nounset  bool
+	pipefail bool
+	status   int
+
+	substitutionStatus int
+}
+
+func newShell(sim *Simulator) *shell {
+	vars := make(map[string]string, len(sim.Env))
+	for k, v := range sim.Env {
+		vars[k] = v
+	}
+	return &shell{sim: sim, vars: vars}
+}
+
+func (sh *shell) subshell() *shell {
+	vars := make(map[string]string, len(sh.vars))
+	for k, v := range sh.vars {
+		vars[k] = v
+	}
+	return &shell{sim: sh.sim, vars: vars, errexit: sh.errexit, nounset: sh.nounset, pipefail: sh.pipefail}
+}
+
+// run executes the given script. It returns an error if the script could not be parsed, if
+// it exited with a non-zero status or if a command failed while `set -e` is active.
+func (sh *shell) run(script string) error {
+	if _, err := sh.execScript(script, nil); err != nil {
+		if exit, ok := err.(*exitError); ok {
+			if exit.status == 0 {
+				return nil
+			}
+			return fmt.Errorf("script exited with status %d", exit.status)
+		}
+		return err
+	}
+	return nil
+}
+
+// execScript executes the given script, writing the output of all commands that are not
+// redirected to stdout (if non-nil). An *exitError is returned if the script exits early.
+func (sh *shell) execScript(script string, stdout *bytes.Buffer) (int, error) {
+	err := scanScript(script, func(lineNo int, items []andOrItem) error {
+		if err := sh.execList(items, stdout); err != nil {
+			if _, ok := err.(*exitError); ok {
+				return err
+			}
+			return fmt.Errorf("line %d: %v", lineNo, err)
+		}
+		return nil
+	})
+	if err != nil {
+		if _, ok := err.(*syntaxError); ok {
+			return 2, err
+		}
+		return sh.status, err
+	}
+	return sh.status, nil
+}
+
+// syntaxError is returned by scanScript if the script could not be parsed.
+type syntaxError struct {
+	lineNo int
+	err    error
+}
+
+func (e *syntaxError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.lineNo, e.err)
+}
+
+// scanScript parses the given script line by line and calls fn with the and-or list of each line
+// whose here-documents have been read. Parsing stops at the first error returned by fn.
+func scanScript(script string, fn func(lineNo int, items []andOrItem) error) error {
+	lines := strings.Split(script, "\n")
+	for i := 0; i < len(lines); {
+		lineNo := i + 1
+		line := lines[i]
+		i++
+
+		// Lines ending with an unquoted backslash are joined with the next line, quoted words
+		// spanning several lines keep their newlines. A backslash at the end of the script is
+		// removed.
+		tokens, err := lex(line)
+		for err != nil {
+			incomplete, ok := err.(*incompleteLineError)
+			switch {
+			case !ok:
+			case incomplete.continuation && i < len(lines):
+				line = line[:len(line)-1] + lines[i]
+				i++
+			case incomplete.continuation:
+				line = line[:len(line)-1]
+			case i < len(lines):
+				line += "\n" + lines[i]
+				i++
+			default:
+				ok = false
+			}
+			if !ok {
+				return &syntaxError{lineNo, err}
+			}
+			tokens, err = lex(line)
+		}
+		if len(tokens) == 0 {
+			continue
+		}
+
+		items, docs, err := parse(tokens)
+		if err != nil {
+			return &syntaxError{lineNo, err}
+		}
+
+		for _, doc := range docs {
+			var body []string
+			found := false
+			for i < len(lines) {
+				l := lines[i]
+				i++
+				if doc.stripTabs {
+					l = strings.TrimLeft(l, "\t")
+				}
+				if l == doc.delimiter {
+					found = true
+					break
+				}
+				body = append(body, l)
+			}
+			if !found {
+				return &syntaxError{lineNo, fmt.Errorf("here-document delimited by %q not terminated", doc.delimiter)}
+			}
+			if len(body) > 0 {
+				doc.body = strings.Join(body, "\n") + "\n"
+			}
+		}
+
+		if err := fn(lineNo, items); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// execList executes the given list. With `set -e`, the shell exits if the last pipeline of
+// an and-or list fails and is not negated.
+func (sh *shell) execList(items []andOrItem, stdout *bytes.Buffer) error {
+	groupEnd := func(i int) bool { return i == len(items)-1 || items[i+1].op == ";" }
+
+	for i, item := range items {
+		switch item.op {
+		case "&&":
+			if sh.status != 0 {
+				continue
+			}
+		case "||":
+			if sh.status == 0 {
+				continue
+			}
+		}
+
+		status, err := sh.execPipeline(item.pipeline, stdout)
+		if err != nil {
+			return err
+		}
+		sh.status = status
+
+		if groupEnd(i) && sh.errexit && sh.status != 0 && !item.pipeline.negate {
+			return &exitError{status: sh.status}
+		}
+	}
+	return nil
+}
+
+func (sh *shell) execPipeline(p *pipeline, stdout *bytes.Buffer) (int, error) {
+	var (
+		input  []byte
+		status int
+	)
+
+	for i, cmd := range p.commands {
+		out := &bytes.Buffer{}
+		if i == len(p.commands)-1 && stdout != nil {
+			out = stdout
+		}
+
+		cmdStatus, err := sh.execCommand(cmd, input, out)
+		if err != nil {
+			return cmdStatus, err
+		}
+		switch {
+		case sh.pipefail && cmdStatus != 0:
+			status = cmdStatus
+		case !sh.pipefail && i == len(p.commands)-1:
+			status = cmdStatus
+		}
+		input = out.Bytes()
+	}
+
+	if p.negate {
+		if status == 0 {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return status, nil
+}
+
+func (sh *shell) failure(format string, args ...interface{}) {
+	sh.sim.failures = append(sh.sim.failures, fmt.Sprintf(format, args...))
+}
+
+func (sh *shell) execCommand(cmd *simpleCommand, stdin []byte, stdout *bytes.Buffer) (int, error) {
+	var (
+		args        []string
+		assignments []string
+	)
+	sh.substitutionStatus = 0
+	for _, w := range cmd.words {
+		value, err := sh.expand(w)
+		if err != nil {
+			return 1, err
+		}
+		if len(args) == 0 && w.isAssignment() {
+			assignments = append(assignments, value)
+			continue
+		}
+		args = append(args, value)
+	}
+
+	if len(args) == 0 {
+		for _, assignment := range assignments {
+			idx := strings.IndexByte(assignment, '=')
+			sh.vars[assignment[:idx]] = assignment[idx+1:]
+		}
+		return sh.substitutionStatus, nil
+	}
+
+	var (
+		out       = &bytes.Buffer{}
+		outTarget string
+		outAppend bool
+	)
+	for _, r := range cmd.redirects {
+		if r.doc != nil {
+			body := r.doc.body
+			if !r.doc.quoted {
+				expanded, err := sh.expandText(body, true)
+				if err != nil {
+					return 1, err
+				}
+				body = expanded
+			}
+			stdin = []byte(body)
+			continue
+		}
+
+		target, err := sh.expand(r.target)
+		if err != nil {
+			return 1, err
+		}
+
+		switch {
+		case r.op == "<":
+			data, err := sh.sim.readFile(target)
+			if err != nil {
+				sh.failure("%s: %v", target, err)
+				return 1, nil
+			}
+			stdin = data
+		case r.fd == 2:
+			// stderr is discarded
+		default:
+			outTarget, outAppend = target, r.op == ">>"
+			if r.op == "&>" {
+				outAppend = false
+			}
+		}
+	}
+
+	status, err := sh.execBuiltin(args, stdin, out)
+	if err != nil {
+		return status, err
+	}
+
+	if outTarget != "" && outTarget != "/dev/null" {
+		if err := sh.sim.redirectOutput(outTarget, out.Bytes(), outAppend); err != nil {
+			sh.failure("%s: %v", outTarget, err)
+			return 1, nil
+		}
+	} else if outTarget == "" {
+		stdout.Write(out.Bytes())
+	}
+	return status, nil
+}
+
+// isAssignment returns true if the word starts with an unquoted `NAME=`, the value may be quoted.
+func (w word) isAssignment() bool {
+	if len(w) == 0 || w[0].kind != segmentUnquoted {
+		return false
+	}
+	s := w[0].text
+	idx := strings.IndexByte(s, '=')
+	if idx <= 0 {
+		return false
+	}
+	for i := 0; i < idx; i++ {
+		if !isNameChar(s[i], i == 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// expand expands all parameters and command substitutions of the given word. Field splitting and
+// pathname expansion are not performed.
+func (sh *shell) expand(w word) (string, error) {
+	var out strings.Builder
+	for _, seg := range w {
+		if seg.kind == segmentSingleQuoted {
+			out.WriteString(seg.text)
+			continue
+		}
+		expanded, err := sh.expandText(seg.text, seg.kind == segmentDoubleQuoted)
+		if err != nil {
+			return "", err
+		}
+		out.WriteString(expanded)
+	}
+	return out.String(), nil
+}
+
+func (sh *shell) expandText(s string, quoted bool) (string, error) {
+	var out strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && quoted && i+1 < len(s) && strings.IndexByte("$`\"\\\n", s[i+1]) >= 0:
+			out.WriteByte(s[i+1])
+			i++
+
+		case c == '`':
+			end := strings.IndexByte(s[i+1:], '`')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated backquote")
+			}
+			result, err := sh.substitute(s[i+1 : i+1+end])
+			if err != nil {
+				return "", err
+			}
+			out.WriteString(result)
+			i += end + 1
+
+		case c == '$' && i+1 < len(s) && s[i+1] == '(':
+			end, err := scanSubstitution(s, i+2)
+			if err != nil {
+				return "", err
+			}
+			result, err := sh.substitute(s[i+2 : end-1])
+			if err != nil {
+				return "", err
+			}
+			out.WriteString(result)
+			i = end - 1
+
+		case c == '$' && i+1 < len(s) && s[i+1] == '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("bad substitution")
+			}
+			expr := s[i+2 : i+end]
+			value, err := sh.parameter(expr)
+			if err != nil {
+				return "", err
+			}
+			out.WriteString(value)
+			i += end
+
+		case c == '$' && i+1 < len(s) && s[i+1] == '?':
+			fmt.Fprintf(&out, "%d", sh.status)
+			i++
+
+		case c == '$' && i+1 < len(s) && isNameChar(s[i+1], true):
+			j := i + 1
+			for j < len(s) && isNameChar(s[j], false) {
+				j++
+			}
+			value, err := sh.parameter(s[i+1 : j])
+			if err != nil {
+				return "", err
+			}
+			out.WriteString(value)
+			i = j - 1
+
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return out.String(), nil
+}
+
+// parameter evaluates a parameter expression of the form `NAME`, `NAME:-default` or
+// `NAME:?message`.
+func (sh *shell) parameter(expr string) (string, error) {
+	name, def, hasDefault := expr, "", false
+	if idx := strings.Index(expr, ":-"); idx >= 0 {
+		name, def, hasDefault = expr[:idx], expr[idx+2:], true
+	}
+	message, required := "", false
+	if idx := strings.Index(expr, ":?"); idx >= 0 && !hasDefault {
+		name, message, required = expr[:idx], expr[idx+2:], true
+	}
+
+	value, ok := sh.vars[name]
+	if hasDefault && value == "" {
+		return sh.expandText(def, true)
+	}
+	if required && value == "" {
+		if message == "" {
+			message = "parameter null or not set"
+		}
+		return "", fmt.Errorf("%s: %s", name, message)
+	}
+	if !ok && sh.nounset {
+		return "", fmt.Errorf("%s: unbound variable", name)
+	}
+	return value, nil
+}
+
+// substitute executes the given command in a subshell and returns its output without trailing
+// newlines.
+func (sh *shell) substitute(command string) (string, error) {
+	var out bytes.Buffer
+	status, err := sh.subshell().execScript(command, &out)
+	if err != nil {
+		exit, ok := err.(*exitError)
+		if !ok {
+			return "", err
+		}
+		status = exit.status
+	}
+	sh.substitutionStatus = status
+	return strings.TrimRight(out.String(), "\n"), nil
+}
@@ -0,0 +1,268 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+
+	. "github.com/gardener/gardener-extensions/pkg/simulator"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Script", func() {
+	var (
+		root string
+		sim  *Simulator
+	)
+
+	BeforeEach(func() {
+		var err error
+		root, err = ioutil.TempDir("", "simulator")
+		Expect(err).NotTo(HaveOccurred())
+		sim = New(root)
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(root)).To(Succeed())
+	})
+
+	manifest := func() *Manifest {
+		m, err := sim.Manifest()
+		Expect(err).NotTo(HaveOccurred())
+		return m
+	}
+
+	It("should write files from here-documents", func() {
+		Expect(sim.ApplyScript([]byte(`#!/bin/bash
+mkdir -p '/etc/foo'
+cat << EOF | base64 -d > '/etc/foo/bar'
+YmFy
+EOF
+chmod '0600' '/etc/foo/bar'
+cat <<'EOF' > /etc/foo/raw
+$NOT_EXPANDED
+EOF
+`))).To(Succeed())
+
+		Expect(manifest().Files).To(Equal(map[string]File{
+			"/etc/foo/bar": {Content: "bar", Permissions: 0600},
+			"/etc/foo/raw": {Content: "$NOT_EXPANDED\n", Permissions: 0644},
+		}))
+	})
+
+	It("should write units and drop-ins and record systemctl calls", func() {
+		Expect(sim.ApplyScript([]byte(`
+cat << EOF | base64 -d > '/etc/systemd/system/docker.service'
+dW5pdA==
+EOF
+mkdir -p '/etc/systemd/system/docker.service.d'
+cat << EOF | base64 -d > '/etc/systemd/system/docker.service.d/10-docker-opts.conf'
+b3ZlcnJpZGU=
+EOF
+systemctl disable locksmithd
+systemctl daemon-reload
+systemctl enable 'docker.service' && systemctl restart 'docker.service'
+`))).To(Succeed())
+
+		m := manifest()
+		Expect(m.UnitNames()).To(Equal([]string{"docker.service"}))
+		Expect(*m.Units["docker.service"].Content).To(Equal("unit"))
+		Expect(m.Units["docker.service"].DropIns).To(Equal(map[string]string{"10-docker-opts.conf": "override"}))
+		Expect(m.Units["docker.service"].Enabled).To(BeTrue())
+		Expect(m.Units["docker.service"].Active).To(BeTrue())
+		Expect(m.Actions).To(Equal([]Action{
+			{Verb: "disable", Units: []string{"locksmithd"}},
+			{Verb: "daemon-reload"},
+			{Verb: "enable", Units: []string{"docker.service"}},
+			{Verb: "restart", Units: []string{"docker.service"}},
+		}))
+	})
+
+	It("should expand variables and command substitutions", func() {
+		sim.Env["DOWNLOAD_MAIN_PATH"] = "/var/lib/downloader"
+		sim.HTTPGet = func(url string) ([]byte, error) {
+			return []byte(fmt.Sprintf("<%s>\n", url)), nil
+		}
+
+		Expect(sim.ApplyScript([]byte(`
+mkdir -p $DOWNLOAD_MAIN_PATH
+META_EP=http://metadata/latest
+PROVIDER_ID=` + "`curl -s $META_EP/region-id`" + `.$(curl -s "$META_EP/instance-id")
+echo PROVIDER_ID=$PROVIDER_ID > $DOWNLOAD_MAIN_PATH/provider-id
+echo "second" >> "${DOWNLOAD_MAIN_PATH}/provider-id"
+`))).To(Succeed())
+
+		Expect(manifest().Files["/var/lib/downloader/provider-id"].Content).To(Equal(
+			"PROVIDER_ID=<http://metadata/latest/region-id>.<http://metadata/latest/instance-id>\nsecond\n"))
+	})
+
+	It("should apply sed substitutions", func() {
+		Expect(sim.ApplyScript([]byte(`
+mkdir -p /run/systemd/system
+echo 'Environment=DOCKER_SELINUX=--selinux-enabled=true' > /run/systemd/system/docker.service
+sed -i '/Environment=DOCKER_SELINUX=--selinux-enabled=true/s/^/#/g' /run/systemd/system/docker.service
+`))).To(Succeed())
+
+		Expect(manifest().Files["/run/systemd/system/docker.service"].Content).To(Equal("#Environment=DOCKER_SELINUX=--selinux-enabled=true\n"))
+	})
+
+	It("should delete lines with sed", func() {
+		Expect(sim.ApplyScript([]byte(`
+touch /etc/environment
+echo 'PATH=/usr/bin' >> /etc/environment
+echo 'PROVIDER_ID=old' >> /etc/environment
+sed -i '/^PROVIDER_ID=/d' /etc/environment
+echo 'foo' | sed '/^f/d' > /empty
+`))).To(Succeed())
+
+		m := manifest()
+		Expect(m.Files["/etc/environment"].Content).To(Equal("PATH=/usr/bin\n"))
+		Expect(m.Files["/empty"].Content).To(BeEmpty())
+	})
+
+	It("should filter stdin with sed", func() {
+		Expect(sim.ApplyScript([]byte(`
+echo 'projects/123/zones/europe-west1-b' | sed -n -E 's/^.*\/([^\/]+)$/\1/p' > /zone
+echo 'foo' | sed -n -E 's/^bar$/\1/p' > /empty
+echo 'foo' | sed 's/o/0/g' > /all
+`))).To(Succeed())
+
+		m := manifest()
+		Expect(m.Files["/zone"].Content).To(Equal("europe-west1-b\n"))
+		Expect(m.Files["/empty"].Content).To(BeEmpty())
+		Expect(m.Files["/all"].Content).To(Equal("f00\n"))
+	})
+
+	It("should send curl requests with headers to the HTTP client", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Metadata-Flavor") != "Google" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			fmt.Fprint(w, r.URL.Path)
+		}))
+		defer server.Close()
+		sim.HTTPClient = server.Client()
+
+		Expect(sim.ApplyScript([]byte(`set -e
+curl --fail -H 'Metadata-Flavor: Google' ` + server.URL + `/foo > /foo
+curl --fail ` + server.URL + `/bar > /bar
+`))).To(MatchError("script exited with status 22"))
+
+		m := manifest()
+		Expect(m.Files["/foo"].Content).To(Equal("/foo"))
+		Expect(m.Files["/bar"].Content).To(BeEmpty())
+	})
+
+	It("should record failures and continue without errexit", func() {
+		Expect(sim.ApplyScript([]byte(`
+cat /does/not/exist > /foo
+false && echo skipped > /skipped
+echo continued > /continued
+`))).To(Succeed())
+
+		m := manifest()
+		Expect(m.Files).NotTo(HaveKey("/skipped"))
+		Expect(m.Files).To(HaveKey("/continued"))
+		Expect(sim.Failures()).To(HaveLen(1))
+	})
+
+	It("should stop on failures with errexit", func() {
+		err := sim.ApplyScript([]byte(`set -eo pipefail
+false || echo handled > /handled
+curl -s http://metadata | cat > /foo
+echo unreachable > /unreachable
+`))
+		Expect(err).To(MatchError("script exited with status 7"))
+
+		m := manifest()
+		Expect(m.Files).To(HaveKey("/handled"))
+		Expect(m.Files).NotTo(HaveKey("/unreachable"))
+	})
+
+	It("should fail on unbound variables with nounset", func() {
+		Expect(sim.ApplyScript([]byte("set -u\necho $UNSET\n"))).To(HaveOccurred())
+	})
+
+	It("should fail on null parameters with a message", func() {
+		sim.Env["EMPTY"] = ""
+		Expect(sim.ApplyScript([]byte(`FOO="${EMPTY:?is empty}"` + "\n"))).To(MatchError(ContainSubstring("EMPTY: is empty")))
+
+		sim.Env["SET"] = "foo"
+		Expect(sim.ApplyScript([]byte(`echo "${SET:?is empty}" > /set` + "\n"))).To(Succeed())
+		Expect(manifest().Files["/set"].Content).To(Equal("foo\n"))
+	})
+
+	It("should retry curl requests", func() {
+		calls := 0
+		sim.HTTPGet = func(url string) ([]byte, error) {
+			calls++
+			if calls < 3 {
+				return nil, fmt.Errorf("transient failure")
+			}
+			return []byte("ok"), nil
+		}
+
+		Expect(sim.ApplyScript([]byte("set -e\nRESULT=$(curl --fail --retry 2 http://metadata)\necho $RESULT > /result\n"))).To(Succeed())
+		Expect(manifest().Files["/result"].Content).To(Equal("ok\n"))
+		Expect(calls).To(Equal(3))
+
+		calls = 0
+		Expect(sim.ApplyScript([]byte("set -e\nRESULT=$(curl --fail --retry 1 http://metadata)\n"))).To(MatchError("script exited with status 22"))
+		Expect(calls).To(Equal(2))
+	})
+
+	It("should compute and check sha256 sums", func() {
+		Expect(sim.ApplyScript([]byte(`
+echo foo > /foo
+sha256sum /foo > /sums
+echo 'b5bb9d8014a0f9b1d61e21e796d78dccdf1352f23cd32812f4850b878ae4944c  /foo' | sha256sum --check --status && echo match > /match
+echo 'b5bb9d8014a0f9b1d61e21e796d78dccdf1352f23cd32812f4850b878ae4944c  /bar' | sha256sum --check --status || echo missing > /missing
+echo '0000000000000000000000000000000000000000000000000000000000000000  /foo' | sha256sum -c --status || echo mismatch > /mismatch
+`))).To(Succeed())
+
+		m := manifest()
+		Expect(m.Files["/sums"].Content).To(Equal("b5bb9d8014a0f9b1d61e21e796d78dccdf1352f23cd32812f4850b878ae4944c  /foo\n"))
+		Expect(m.Files).To(HaveKey("/match"))
+		Expect(m.Files).To(HaveKey("/missing"))
+		Expect(m.Files).To(HaveKey("/mismatch"))
+	})
+
+	It("should honour explicit exits", func() {
+		Expect(sim.ApplyScript([]byte("exit 0\nfalse\n"))).To(Succeed())
+		Expect(sim.ApplyScript([]byte("exit 3\n"))).To(MatchError("script exited with status 3"))
+	})
+
+	It("should parse quoted words spanning several lines and line continuations", func() {
+		commands, err := ParseScript([]byte("echo 'a\nb' \"c\\\nd\ne\" \\\n  f\necho g\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(commands).To(Equal([]Command{
+			{Args: []string{"echo", "a\nb", "cd\ne", "f"}},
+			{Args: []string{"echo", "g"}},
+		}))
+	})
+
+	It("should reject unsupported constructs", func() {
+		Expect(sim.ApplyScript([]byte("if true; then echo foo; fi\n"))).To(HaveOccurred())
+		Expect(sim.ApplyScript([]byte("echo 'unterminated\n"))).To(HaveOccurred())
+		Expect(sim.ApplyScript([]byte("cat << EOF\nfoo\n"))).To(HaveOccurred())
+	})
+})
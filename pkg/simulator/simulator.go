@@ -0,0 +1,350 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package simulator applies the output of the operating system config renderers to a temporary
+// root directory instead of a real machine. It understands CoreOS and standard cloud-init
+// `#cloud-config` documents, Ignition configs as well as the generated bash scripts, records all
+// systemctl calls and produces a normalised Manifest of the resulting files and units. Neither
+// root privileges nor a running systemd are required.
+package simulator
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DefaultUnitsPath is the default path systemd units are stored at.
+const DefaultUnitsPath = "/etc/systemd/system"
+
+// Format is the format of a rendered operating system config.
+type Format string
+
+const (
+	// FormatCloudConfig is the format of CoreOS `#cloud-config` documents.
+	FormatCloudConfig Format = "cloud-config"
+	// FormatScript is the format of bash scripts.
+	FormatScript Format = "script"
+	// FormatIgnition is the format of Ignition (spec v2.x) JSON configs.
+	FormatIgnition Format = "ignition"
+	// FormatCloudInit is the format of standard cloud-init `#cloud-config` documents.
+	FormatCloudInit Format = "cloud-init"
+)
+
+// Action is a recorded systemctl invocation.
+type Action struct {
+	// Verb is the systemctl sub command, e.g. `restart`.
+	Verb string
+	// Units are the units the sub command was invoked with.
+	Units []string
+}
+
+// String returns the command line representation of the Action.
+func (a Action) String() string {
+	return strings.TrimSpace(fmt.Sprintf("systemctl %s %s", a.Verb, strings.Join(a.Units, " ")))
+}
+
+// Simulator applies rendered operating system configs to a root directory.
+type Simulator struct {
+	// Root is the directory all absolute paths are resolved against.
+	Root string
+	// UnitsPath is the path (relative to Root) systemd units are read from. Defaults to DefaultUnitsPath.
+	UnitsPath string
+	// Env contains the initial environment variables of executed scripts.
+	Env map[string]string
+	// HTTPGet is called for every `curl` invocation of a script. If nil, HTTPClient is used.
+	HTTPGet func(url string) ([]byte, error)
+	// HTTPClient performs the requests of `curl` invocations of a script, including their
+	// headers, e.g. against a local stub server. Responses with an error status fail like with
+	// `curl --fail`. If both HTTPGet and HTTPClient are nil, `curl` fails.
+	HTTPClient *http.Client
+
+	actions  []Action
+	enabled  map[string]bool
+	active   map[string]bool
+	failures []string
+}
+
+// New creates a new Simulator for the given root directory.
+func New(root string) *Simulator {
+	return &Simulator{
+		Root:      root,
+		UnitsPath: DefaultUnitsPath,
+		Env:       map[string]string{},
+		enabled:   map[string]bool{},
+		active:    map[string]bool{},
+	}
+}
+
+// Apply applies the given data of the given format.
+func (s *Simulator) Apply(format Format, data []byte) error {
+	switch format {
+	case FormatCloudConfig:
+		return s.ApplyCloudConfig(data)
+	case FormatScript:
+		return s.ApplyScript(data)
+	case FormatIgnition:
+		return s.ApplyIgnition(data)
+	case FormatCloudInit:
+		return s.ApplyCloudInit(data)
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+// httpGet fetches the given URL with HTTPGet or HTTPClient, sending the given headers of the form
+// `Name: value` with the latter.
+func (s *Simulator) httpGet(url string, headers []string) ([]byte, error) {
+	if s.HTTPGet != nil {
+		return s.HTTPGet(url)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, header := range headers {
+		idx := strings.IndexByte(header, ':')
+		if idx < 0 {
+			return nil, fmt.Errorf("invalid header %q", header)
+		}
+		req.Header.Add(strings.TrimSpace(header[:idx]), strings.TrimSpace(header[idx+1:]))
+	}
+
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("the requested URL returned error: %d", resp.StatusCode)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// Actions returns all systemctl invocations recorded so far.
+func (s *Simulator) Actions() []Action {
+	return s.actions
+}
+
+// Failures returns the non-fatal command failures that occurred while executing scripts.
+func (s *Simulator) Failures() []string {
+	return s.failures
+}
+
+// hostPath resolves the given path of the simulated machine to a path below the root directory.
+func (s *Simulator) hostPath(p string) string {
+	return filepath.Join(s.Root, filepath.FromSlash(path.Clean("/"+p)))
+}
+
+func (s *Simulator) writeFile(p string, data []byte, perm os.FileMode) error {
+	hostPath := s.hostPath(p)
+	if err := os.MkdirAll(filepath.Dir(hostPath), 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(hostPath, data, perm); err != nil {
+		return err
+	}
+	return os.Chmod(hostPath, perm)
+}
+
+// defaultDirectories are directories that exist on every simulated machine.
+var defaultDirectories = []string{"/etc", "/opt", "/run/systemd/system", "/tmp", "/var/lib"}
+
+func (s *Simulator) ensureDefaultDirectories() error {
+	for _, dir := range append(defaultDirectories, s.unitsPath()) {
+		if err := os.MkdirAll(s.hostPath(dir), 0755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Simulator) unitsPath() string {
+	if s.UnitsPath == "" {
+		return DefaultUnitsPath
+	}
+	return s.UnitsPath
+}
+
+func (s *Simulator) systemctl(verb string, units ...string) error {
+	if len(units) == 0 {
+		units = nil
+	}
+	s.actions = append(s.actions, Action{Verb: verb, Units: units})
+
+	for _, unit := range units {
+		switch verb {
+		case "enable":
+			s.enabled[unit] = true
+		case "disable":
+			s.enabled[unit] = false
+		case "start", "restart", "reload-or-restart", "try-restart", "reload":
+			s.active[unit] = true
+		case "stop":
+			s.active[unit] = false
+		case "mask":
+			p := s.hostPath(path.Join(s.unitsPath(), unit))
+			if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+				return err
+			}
+			if err := os.RemoveAll(p); err != nil {
+				return err
+			}
+			if err := os.Symlink(os.DevNull, p); err != nil {
+				return err
+			}
+		case "unmask":
+			p := s.hostPath(path.Join(s.unitsPath(), unit))
+			if target, err := os.Readlink(p); err == nil && target == os.DevNull {
+				if err := os.Remove(p); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// File is a regular file of the Manifest.
+type File struct {
+	// Content is the content of the file.
+	Content string
+	// Permissions are the permission bits of the file.
+	Permissions os.FileMode
+}
+
+// Unit is a systemd unit of the Manifest.
+type Unit struct {
+	// Content is the content of the unit file, nil if there is none.
+	Content *string
+	// DropIns maps drop-in names to their content.
+	DropIns map[string]string
+	// Masked is whether the unit is masked.
+	Masked bool
+	// Enabled is whether the unit has been enabled.
+	Enabled bool
+	// Active is whether the unit has been started or restarted and not stopped afterwards.
+	Active bool
+}
+
+// Manifest is a normalised description of the state of a simulated machine.
+type Manifest struct {
+	// Files maps absolute paths to all regular files outside the units path.
+	Files map[string]File
+	// Units maps unit names to the state of the unit.
+	Units map[string]*Unit
+	// Actions are all recorded systemctl invocations, in order.
+	Actions []Action
+}
+
+// UnitNames returns the sorted names of all units in the manifest.
+func (m *Manifest) UnitNames() []string {
+	names := make([]string, 0, len(m.Units))
+	for name := range m.Units {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (m *Manifest) unit(name string) *Unit {
+	u, ok := m.Units[name]
+	if !ok {
+		u = &Unit{DropIns: map[string]string{}}
+		m.Units[name] = u
+	}
+	return u
+}
+
+// Manifest computes the Manifest of the current state of the simulated machine.
+func (s *Simulator) Manifest() (*Manifest, error) {
+	m := &Manifest{
+		Files:   map[string]File{},
+		Units:   map[string]*Unit{},
+		Actions: append([]Action(nil), s.actions...),
+	}
+
+	unitsPath := path.Clean(s.unitsPath())
+	if err := filepath.Walk(s.Root, func(hostPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(s.Root, hostPath)
+		if err != nil {
+			return err
+		}
+		p := path.Join("/", filepath.ToSlash(rel))
+
+		dir, name := path.Dir(p), path.Base(p)
+		if dir == unitsPath {
+			if info.Mode()&os.ModeSymlink != 0 {
+				if target, err := os.Readlink(hostPath); err == nil && target == os.DevNull {
+					m.unit(name).Masked = true
+				}
+				return nil
+			}
+			if info.Mode().IsRegular() {
+				data, err := ioutil.ReadFile(hostPath)
+				if err != nil {
+					return err
+				}
+				content := string(data)
+				m.unit(name).Content = &content
+			}
+			return nil
+		}
+
+		if path.Dir(dir) == unitsPath && strings.HasSuffix(dir, ".d") {
+			if info.Mode().IsRegular() {
+				data, err := ioutil.ReadFile(hostPath)
+				if err != nil {
+					return err
+				}
+				m.unit(strings.TrimSuffix(path.Base(dir), ".d")).DropIns[name] = string(data)
+			}
+			return nil
+		}
+
+		if info.Mode().IsRegular() {
+			data, err := ioutil.ReadFile(hostPath)
+			if err != nil {
+				return err
+			}
+			m.Files[p] = File{Content: string(data), Permissions: info.Mode().Perm()}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	for name, enabled := range s.enabled {
+		if enabled {
+			m.unit(name).Enabled = true
+		}
+	}
+	for name, active := range s.active {
+		if active {
+			m.unit(name).Active = true
+		}
+	}
+
+	return m, nil
+}
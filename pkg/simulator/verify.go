@@ -0,0 +1,131 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	extensionsv1alpha1 "github.com/gardener/gardener/pkg/apis/extensions/v1alpha1"
+)
+
+// ContentResolver returns the expected data of the given file content.
+type ContentResolver func(content *extensionsv1alpha1.FileContent) ([]byte, error)
+
+// InlineContentResolver resolves inline file contents. It fails for secret references.
+func InlineContentResolver(content *extensionsv1alpha1.FileContent) ([]byte, error) {
+	if inline := content.Inline; inline != nil {
+		return decodeCloudConfigContent(inline.Encoding, inline.Data)
+	}
+	return nil, fmt.Errorf("cannot resolve file content without inline data")
+}
+
+// Verify checks that all files, units and drop-ins of the given OperatingSystemConfig spec are
+// contained in the manifest with the expected content and permissions. The returned error lists
+// all mismatches.
+func (m *Manifest) Verify(spec *extensionsv1alpha1.OperatingSystemConfigSpec, resolve ContentResolver) error {
+	if resolve == nil {
+		resolve = InlineContentResolver
+	}
+
+	var problems []string
+	for _, file := range spec.Files {
+		expected, err := resolve(&file.Content)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("file %q: %v", file.Path, err))
+			continue
+		}
+
+		actual, ok := m.Files[file.Path]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("file %q is missing", file.Path))
+			continue
+		}
+		if actual.Content != string(expected) {
+			problems = append(problems, fmt.Sprintf("file %q has content %q, expected %q", file.Path, actual.Content, expected))
+		}
+
+		permissions := extensionsv1alpha1.OperatingSystemConfigDefaultFilePermission
+		if file.Permissions != nil {
+			permissions = *file.Permissions
+		}
+		if actual.Permissions != os.FileMode(permissions) {
+			problems = append(problems, fmt.Sprintf("file %q has permissions %04o, expected %04o", file.Path, actual.Permissions, permissions))
+		}
+	}
+
+	for _, unit := range spec.Units {
+		actual, ok := m.Units[unit.Name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("unit %q is missing", unit.Name))
+			continue
+		}
+
+		if unit.Content != nil && (actual.Content == nil || *actual.Content != *unit.Content) {
+			problems = append(problems, fmt.Sprintf("unit %q does not have the expected content", unit.Name))
+		}
+		for _, dropIn := range unit.DropIns {
+			content, ok := actual.DropIns[dropIn.Name]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("drop-in %q of unit %q is missing", dropIn.Name, unit.Name))
+				continue
+			}
+			if content != dropIn.Content {
+				problems = append(problems, fmt.Sprintf("drop-in %q of unit %q does not have the expected content", dropIn.Name, unit.Name))
+			}
+		}
+	}
+
+	return problemsToError(problems)
+}
+
+// VerifyUnitStates checks that the units of the given OperatingSystemConfig spec have been
+// enabled, started or stopped as requested by their `enable` and `command` fields.
+func (m *Manifest) VerifyUnitStates(spec *extensionsv1alpha1.OperatingSystemConfigSpec) error {
+	var problems []string
+	for _, unit := range spec.Units {
+		actual, ok := m.Units[unit.Name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("unit %q is missing", unit.Name))
+			continue
+		}
+
+		if unit.Enable != nil && *unit.Enable && !actual.Enabled {
+			problems = append(problems, fmt.Sprintf("unit %q is not enabled", unit.Name))
+		}
+		if unit.Command != nil {
+			switch *unit.Command {
+			case "start", "restart", "reload", "try-restart", "reload-or-restart":
+				if !actual.Active {
+					problems = append(problems, fmt.Sprintf("unit %q has not been started", unit.Name))
+				}
+			case "stop":
+				if actual.Active {
+					problems = append(problems, fmt.Sprintf("unit %q has not been stopped", unit.Name))
+				}
+			}
+		}
+	}
+
+	return problemsToError(problems)
+}
+
+func problemsToError(problems []string) error {
+	if len(problems) > 0 {
+		return fmt.Errorf("manifest does not match operating system config:\n%s", strings.Join(problems, "\n"))
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package systemd
+
+import (
+	"path"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+var (
+	unitKeys = sets.NewString(
+		"Description", "Documentation", "Wants", "Requires", "Requisite", "BindsTo", "PartOf", "Conflicts",
+		"Before", "After", "OnFailure", "PropagatesReloadTo", "ReloadPropagatedFrom", "JoinsNamespaceOf",
+		"RequiresMountsFor", "OnFailureJobMode", "IgnoreOnIsolate", "StopWhenUnneeded", "RefuseManualStart",
+		"RefuseManualStop", "AllowIsolate", "DefaultDependencies", "CollectMode", "FailureAction",
+		"SuccessAction", "FailureActionExitStatus", "SuccessActionExitStatus", "JobTimeoutSec",
+		"JobRunningTimeoutSec", "JobTimeoutAction", "JobTimeoutRebootArgument", "StartLimitIntervalSec",
+		"StartLimitBurst", "StartLimitAction", "RebootArgument", "SourcePath",
+	)
+	installKeys = sets.NewString("Alias", "WantedBy", "RequiredBy", "Also", "DefaultInstance")
+
+	// execKeys are the keys shared by services, sockets, mounts and swaps to configure the
+	// execution environment, resource control and the way processes are killed.
+	execKeys = sets.NewString(
+		"WorkingDirectory", "RootDirectory", "RootImage", "User", "Group", "DynamicUser",
+		"SupplementaryGroups", "Environment", "EnvironmentFile", "PassEnvironment", "UnsetEnvironment",
+		"StandardInput", "StandardOutput", "StandardError", "SyslogIdentifier", "SyslogFacility",
+		"SyslogLevel", "LogLevelMax", "TTYPath", "UMask", "Nice", "OOMScoreAdjust", "IOSchedulingClass",
+		"IOSchedulingPriority", "CPUSchedulingPolicy", "CPUSchedulingPriority", "CPUAffinity",
+		"LimitCPU", "LimitFSIZE", "LimitDATA", "LimitSTACK", "LimitCORE", "LimitRSS", "LimitNOFILE",
+		"LimitAS", "LimitNPROC", "LimitMEMLOCK", "LimitLOCKS", "LimitSIGPENDING", "LimitMSGQUEUE",
+		"LimitNICE", "LimitRTPRIO", "LimitRTTIME", "CapabilityBoundingSet", "AmbientCapabilities",
+		"NoNewPrivileges", "SecureBits", "ProtectSystem", "ProtectHome", "ProtectKernelTunables",
+		"ProtectKernelModules", "ProtectControlGroups", "PrivateTmp", "PrivateDevices", "PrivateNetwork",
+		"PrivateUsers", "ReadWritePaths", "ReadOnlyPaths", "InaccessiblePaths", "BindPaths",
+		"BindReadOnlyPaths", "MountFlags", "RuntimeDirectory", "RuntimeDirectoryMode",
+		"RuntimeDirectoryPreserve", "StateDirectory", "CacheDirectory", "LogsDirectory",
+		"ConfigurationDirectory", "SystemCallFilter", "SystemCallArchitectures", "RestrictNamespaces",
+		"RestrictRealtime", "RestrictAddressFamilies", "LockPersonality", "MemoryDenyWriteExecute",
+		"RemoveIPC", "KeyringMode", "TimerSlackNSec", "Personality", "IgnoreSIGPIPE", "UtmpIdentifier",
+		"UtmpMode", "SELinuxContext", "AppArmorProfile",
+		"CPUAccounting", "CPUWeight", "StartupCPUWeight", "CPUShares", "StartupCPUShares", "CPUQuota",
+		"MemoryAccounting", "MemoryLow", "MemoryHigh", "MemoryMax", "MemorySwapMax", "MemoryLimit",
+		"TasksAccounting", "TasksMax", "IOAccounting", "IOWeight", "BlockIOAccounting", "BlockIOWeight",
+		"IPAccounting", "IPAddressAllow", "IPAddressDeny", "DeviceAllow", "DevicePolicy", "Slice",
+		"Delegate",
+		"KillMode", "KillSignal", "SendSIGHUP", "SendSIGKILL", "FinalKillSignal", "WatchdogSignal",
+	)
+
+	serviceKeys = execKeys.Union(sets.NewString(
+		"Type", "RemainAfterExit", "GuessMainPID", "PIDFile", "BusName", "ExecStart", "ExecStartPre",
+		"ExecStartPost", "ExecReload", "ExecStop", "ExecStopPost", "RestartSec", "TimeoutStartSec",
+		"TimeoutStopSec", "TimeoutSec", "RuntimeMaxSec", "WatchdogSec", "Restart", "SuccessExitStatus",
+		"RestartPreventExitStatus", "RestartForceExitStatus", "PermissionsStartOnly", "RootDirectoryStartOnly",
+		"NonBlocking", "NotifyAccess", "Sockets", "FileDescriptorStoreMax", "USBFunctionDescriptors",
+		"USBFunctionStrings", "StartLimitInterval",
+	))
+	socketKeys = execKeys.Union(sets.NewString(
+		"ListenStream", "ListenDatagram", "ListenSequentialPacket", "ListenFIFO", "ListenSpecial",
+		"ListenNetlink", "ListenMessageQueue", "ListenUSBFunction", "SocketProtocol", "BindIPv6Only",
+		"Backlog", "BindToDevice", "SocketUser", "SocketGroup", "DirectoryMode", "SocketMode", "Accept",
+		"Writable", "MaxConnections", "MaxConnectionsPerSource", "KeepAlive", "KeepAliveTimeSec",
+		"KeepAliveIntervalSec", "KeepAliveProbes", "NoDelay", "Priority", "DeferAcceptSec",
+		"ReceiveBuffer", "SendBuffer", "IPTOS", "IPTTL", "Mark", "ReusePort", "PipeSize", "FreeBind",
+		"Transparent", "Broadcast", "PassCredentials", "PassSecurity", "RemoveOnStop", "Symlinks",
+		"FileDescriptorName", "TriggerLimitIntervalSec", "TriggerLimitBurst", "ExecStartPre",
+		"ExecStartPost", "ExecStopPre", "ExecStopPost", "TimeoutSec", "Service",
+	))
+	mountKeys = execKeys.Union(sets.NewString(
+		"What", "Where", "Type", "Options", "SloppyOptions", "LazyUnmount", "ForceUnmount",
+		"DirectoryMode", "TimeoutSec",
+	))
+	automountKeys = sets.NewString("Where", "DirectoryMode", "TimeoutIdleSec")
+	swapKeys      = execKeys.Union(sets.NewString("What", "Priority", "Options", "TimeoutSec"))
+	timerKeys     = sets.NewString(
+		"OnActiveSec", "OnBootSec", "OnStartupSec", "OnUnitActiveSec", "OnUnitInactiveSec", "OnCalendar",
+		"AccuracySec", "RandomizedDelaySec", "Unit", "Persistent", "WakeSystem", "RemainAfterElapse",
+	)
+	pathKeys = sets.NewString(
+		"PathExists", "PathExistsGlob", "PathChanged", "PathModified", "DirectoryNotEmpty", "Unit",
+		"MakeDirectory", "DirectoryMode",
+	)
+	sliceKeys = execKeys
+
+	// listKeys are keys that may be assigned multiple times, each assignment adds to a list.
+	listKeys = sets.NewString(
+		"Documentation", "Wants", "Requires", "Requisite", "BindsTo", "PartOf", "Conflicts", "Before",
+		"After", "OnFailure", "PropagatesReloadTo", "ReloadPropagatedFrom", "JoinsNamespaceOf",
+		"RequiresMountsFor", "Alias", "WantedBy", "RequiredBy", "Also",
+		"Environment", "EnvironmentFile", "PassEnvironment", "UnsetEnvironment", "SupplementaryGroups",
+		"ReadWritePaths", "ReadOnlyPaths", "InaccessiblePaths", "BindPaths", "BindReadOnlyPaths",
+		"CapabilityBoundingSet", "AmbientCapabilities", "SystemCallFilter", "RestrictAddressFamilies",
+		"DeviceAllow", "IPAddressAllow", "IPAddressDeny", "ExecStartPre", "ExecStartPost", "ExecStop",
+		"ExecStopPost", "ExecStopPre", "ExecReload", "ExecStart", "SuccessExitStatus", "RestartPreventExitStatus",
+		"RestartForceExitStatus", "Sockets", "ListenStream", "ListenDatagram", "ListenSequentialPacket",
+		"ListenFIFO", "ListenSpecial", "ListenNetlink", "ListenMessageQueue", "Symlinks",
+		"OnActiveSec", "OnBootSec", "OnStartupSec", "OnUnitActiveSec", "OnUnitInactiveSec", "OnCalendar",
+		"PathExists", "PathExistsGlob", "PathChanged", "PathModified", "DirectoryNotEmpty",
+	)
+)
+
+// knownSections returns the sections and their known keys for a unit with the given name. A nil
+// key set means that the keys of the section are not checked.
+func knownSections(unitName string) map[string]sets.String {
+	sections := map[string]sets.String{
+		"Unit":    unitKeys,
+		"Install": installKeys,
+	}
+
+	switch path.Ext(unitName) {
+	case ".service":
+		sections["Service"] = serviceKeys
+	case ".socket":
+		sections["Socket"] = socketKeys
+	case ".mount":
+		sections["Mount"] = mountKeys
+	case ".automount":
+		sections["Automount"] = automountKeys
+	case ".swap":
+		sections["Swap"] = swapKeys
+	case ".timer":
+		sections["Timer"] = timerKeys
+	case ".path":
+		sections["Path"] = pathKeys
+	case ".slice":
+		sections["Slice"] = sliceKeys
+	case ".scope":
+		sections["Scope"] = sliceKeys
+	}
+	return sections
+}
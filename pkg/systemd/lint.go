@@ -0,0 +1,281 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package systemd
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// Severity is the severity of a Problem.
+type Severity string
+
+const (
+	// SeverityError marks problems that break the unit.
+	SeverityError Severity = "Error"
+	// SeverityWarning marks problems that are likely mistakes.
+	SeverityWarning Severity = "Warning"
+)
+
+// Problem is a problem found by Lint.
+type Problem struct {
+	// Severity is the severity of the problem.
+	Severity Severity
+	// Unit is the name of the affected unit.
+	Unit string
+	// DropIn is the name of the affected drop-in, if any.
+	DropIn string
+	// Line is the affected line of the unit or drop-in content, if any.
+	Line int
+	// Message describes the problem.
+	Message string
+}
+
+func (p Problem) String() string {
+	location := p.Unit
+	if p.DropIn != "" {
+		location += "/" + p.DropIn
+	}
+	if p.Line > 0 {
+		location += fmt.Sprintf(":%d", p.Line)
+	}
+	return fmt.Sprintf("%s: %s: %s", p.Severity, location, p.Message)
+}
+
+// HasErrors returns true if the given problems contain at least one error.
+func HasErrors(problems []Problem) bool {
+	for _, problem := range problems {
+		if problem.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Unit is a unit as specified in an operating system config.
+type Unit struct {
+	// Name is the name of the unit.
+	Name string
+	// Enable defines whether the unit is enabled.
+	Enable bool
+	// Content is the content of the unit file. If not set, the unit is provided by the operating system.
+	Content *string
+	// DropIns are the drop-ins of the unit.
+	DropIns []DropIn
+}
+
+// DropIn is a drop-in of a unit.
+type DropIn struct {
+	// Name is the name of the drop-in.
+	Name string
+	// Content is the content of the drop-in.
+	Content string
+}
+
+type parsedUnit struct {
+	Unit
+	files []*UnitFile
+}
+
+// Lint validates the names of the given units and their drop-ins, parses their content and checks
+// them for unknown sections and keys, keys assigned multiple times, enabled units without `[Install]` section and ordering or
+// requirement cycles among the given units.
+func Lint(units []Unit) []Problem {
+	var (
+		problems []Problem
+		parsed   []parsedUnit
+	)
+
+	for _, unit := range units {
+		p := parsedUnit{Unit: unit}
+
+		if err := ValidateUnitName(unit.Name); err != nil {
+			problems = append(problems, Problem{Severity: SeverityError, Unit: unit.Name, Message: err.Error()})
+		}
+
+		if unit.Content != nil {
+			file, problemsOfFile := lintFile(unit.Name, "", *unit.Content)
+			problems = append(problems, problemsOfFile...)
+			p.files = append(p.files, file)
+		}
+		for _, dropIn := range unit.DropIns {
+			if err := ValidateDropInName(dropIn.Name); err != nil {
+				problems = append(problems, Problem{Severity: SeverityError, Unit: unit.Name, DropIn: dropIn.Name, Message: err.Error()})
+			}
+			file, problemsOfFile := lintFile(unit.Name, dropIn.Name, dropIn.Content)
+			problems = append(problems, problemsOfFile...)
+			p.files = append(p.files, file)
+		}
+
+		if unit.Enable && unit.Content != nil && !p.hasInstallSection() {
+			problems = append(problems, Problem{Severity: SeverityWarning, Unit: unit.Name, Message: "unit is enabled but has no [Install] section, enabling it has no effect"})
+		}
+
+		parsed = append(parsed, p)
+	}
+
+	problems = append(problems, lintCycles(parsed, SeverityError, "ordering", func(u parsedUnit) []string {
+		return u.values("Unit", "After")
+	}, func(u parsedUnit) []string {
+		return u.values("Unit", "Before")
+	})...)
+	problems = append(problems, lintCycles(parsed, SeverityWarning, "requirement", func(u parsedUnit) []string {
+		return u.values("Unit", "Requires")
+	}, nil)...)
+
+	return problems
+}
+
+func (u parsedUnit) hasInstallSection() bool {
+	for _, file := range u.files {
+		if file.HasSection("Install") {
+			return true
+		}
+	}
+	return false
+}
+
+func (u parsedUnit) values(section, key string) []string {
+	var values []string
+	for _, file := range u.files {
+		for _, value := range file.Values(section, key) {
+			values = append(values, strings.Fields(value)...)
+		}
+	}
+	return values
+}
+
+func lintFile(unitName, dropInName, content string) (*UnitFile, []Problem) {
+	var problems []Problem
+	report := func(severity Severity, line int, format string, args ...interface{}) {
+		problems = append(problems, Problem{Severity: severity, Unit: unitName, DropIn: dropInName, Line: line, Message: fmt.Sprintf(format, args...)})
+	}
+
+	file, err := Parse(content)
+	if errs, ok := err.(ParseErrors); ok {
+		for _, e := range errs {
+			report(SeverityError, e.Line, "%s", e.Message)
+		}
+	}
+
+	sections := knownSections(unitName)
+	for _, section := range file.Sections {
+		if strings.HasPrefix(section.Name, "X-") {
+			continue
+		}
+		keys, ok := sections[section.Name]
+		if !ok {
+			report(SeverityWarning, section.Line, "unknown section [%s] for unit type %s", section.Name, path.Ext(unitName))
+			continue
+		}
+
+		assigned := map[string]int{}
+		for _, entry := range section.Entries {
+			if keys != nil && !keys.Has(entry.Key) && !isConditionKey(section.Name, entry.Key) {
+				report(SeverityWarning, entry.Line, "unknown key %q in section [%s]", entry.Key, section.Name)
+			}
+
+			if entry.Value == "" {
+				delete(assigned, entry.Key)
+				continue
+			}
+			if line, ok := assigned[entry.Key]; ok && !listKeys.Has(entry.Key) && !isConditionKey(section.Name, entry.Key) {
+				report(SeverityWarning, entry.Line, "key %q is already assigned in line %d, the last assignment wins", entry.Key, line)
+			}
+			assigned[entry.Key] = entry.Line
+		}
+	}
+
+	return file, problems
+}
+
+func isConditionKey(section, key string) bool {
+	return section == "Unit" && (strings.HasPrefix(key, "Condition") || strings.HasPrefix(key, "Assert"))
+}
+
+// lintCycles reports cycles in the graph among the given units whose edges are given by the
+// forward function and the reversed edges of the backward function.
+func lintCycles(units []parsedUnit, severity Severity, kind string, forward, backward func(parsedUnit) []string) []Problem {
+	var (
+		names = sets.NewString()
+		edges = map[string]sets.String{}
+	)
+	for _, unit := range units {
+		names.Insert(unit.Name)
+		edges[unit.Name] = sets.NewString()
+	}
+	for _, unit := range units {
+		for _, target := range forward(unit) {
+			if names.Has(target) {
+				edges[unit.Name].Insert(target)
+			}
+		}
+		if backward == nil {
+			continue
+		}
+		for _, source := range backward(unit) {
+			if names.Has(source) {
+				edges[source].Insert(unit.Name)
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	var (
+		problems []Problem
+		state    = map[string]int{}
+		stack    []string
+		visit    func(name string)
+	)
+	visit = func(name string) {
+		state[name] = visiting
+		stack = append(stack, name)
+		for _, next := range edges[name].List() {
+			switch state[next] {
+			case unvisited:
+				visit(next)
+			case visiting:
+				var cycle []string
+				for i := len(stack) - 1; i >= 0; i-- {
+					cycle = append([]string{stack[i]}, cycle...)
+					if stack[i] == next {
+						break
+					}
+				}
+				cycle = append(cycle, next)
+				problems = append(problems, Problem{Severity: severity, Unit: next, Message: fmt.Sprintf("%s cycle: %s", kind, strings.Join(cycle, " -> "))})
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[name] = visited
+	}
+
+	sorted := names.List()
+	sort.Strings(sorted)
+	for _, name := range sorted {
+		if state[name] == unvisited {
+			visit(name)
+		}
+	}
+	return problems
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package systemd_test
+
+import (
+	. "github.com/gardener/gardener-extensions/pkg/systemd"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Lint", func() {
+	strPtr := func(s string) *string { return &s }
+
+	messages := func(problems []Problem) []string {
+		var out []string
+		for _, problem := range problems {
+			out = append(out, problem.String())
+		}
+		return out
+	}
+
+	It("should accept valid units", func() {
+		problems := Lint([]Unit{
+			{
+				Name:    "kubelet.service",
+				Enable:  true,
+				Content: strPtr("[Unit]\nDescription=kubelet\nAfter=docker.service\nConditionPathExists=/var/lib/kubelet\n[Install]\nWantedBy=multi-user.target\n[Service]\nExecStartPre=/bin/true\nExecStartPre=/bin/true\nExecStart=/opt/bin/kubelet\n"),
+			},
+			{
+				Name:    "docker.service",
+				DropIns: []DropIn{{Name: "10-opts.conf", Content: "[Service]\nExecStart=\nExecStart=/usr/bin/dockerd\n"}},
+			},
+			{Name: "cleanup.timer", Enable: true, Content: strPtr("[Timer]\nOnCalendar=daily\n[Install]\nWantedBy=timers.target\n")},
+		})
+		Expect(problems).To(BeEmpty())
+	})
+
+	It("should report syntax errors, unknown sections and keys and duplicated keys", func() {
+		problems := Lint([]Unit{
+			{
+				Name:    "foo.service",
+				Content: strPtr("[Unit]\nDescripton=foo\nDescription=foo\nDescription=bar\n[Servce]\nExecStart=/bin/foo\n[X-Custom]\nAnything=goes\n"),
+				DropIns: []DropIn{{Name: "10-foo", Content: "[Service\n"}},
+			},
+		})
+		Expect(messages(problems)).To(Equal([]string{
+			`Warning: foo.service:2: unknown key "Descripton" in section [Unit]`,
+			`Warning: foo.service:4: key "Description" is already assigned in line 3, the last assignment wins`,
+			`Warning: foo.service:5: unknown section [Servce] for unit type .service`,
+			`Error: foo.service/10-foo: invalid drop-in name "10-foo": drop-in names must end with .conf`,
+			`Error: foo.service/10-foo:1: invalid section header "[Service"`,
+		}))
+		Expect(HasErrors(problems)).To(BeTrue())
+	})
+
+	It("should report invalid unit and drop-in names", func() {
+		problems := Lint([]Unit{
+			{Name: "foo bar.service", DropIns: []DropIn{{Name: "10-$(reboot).conf", Content: "[Service]\n"}}},
+			{Name: "foo.unknown"},
+		})
+		Expect(messages(problems)).To(Equal([]string{
+			`Error: foo bar.service: invalid unit name "foo bar.service": character ' ' is not allowed`,
+			`Error: foo bar.service/10-$(reboot).conf: invalid drop-in name "10-$(reboot).conf": character '$' is not allowed`,
+			`Error: foo.unknown: invalid unit name "foo.unknown": unknown unit type ".unknown"`,
+		}))
+		Expect(HasErrors(problems)).To(BeTrue())
+	})
+
+	It("should warn about enabled units without [Install] section", func() {
+		problems := Lint([]Unit{
+			{Name: "foo.service", Enable: true, Content: strPtr("[Service]\nExecStart=/bin/foo\n")},
+			{Name: "bar.service", Enable: true, Content: strPtr("[Service]\nExecStart=/bin/bar\n"), DropIns: []DropIn{{Name: "10-install.conf", Content: "[Install]\nWantedBy=multi-user.target\n"}}},
+			{Name: "docker.service", Enable: true},
+		})
+		Expect(messages(problems)).To(Equal([]string{
+			"Warning: foo.service: unit is enabled but has no [Install] section, enabling it has no effect",
+		}))
+		Expect(HasErrors(problems)).To(BeFalse())
+	})
+
+	It("should detect ordering and requirement cycles among the given units", func() {
+		problems := Lint([]Unit{
+			{Name: "a.service", Content: strPtr("[Unit]\nAfter=b.service network.target\nRequires=b.service\n")},
+			{Name: "b.service", Content: strPtr("[Unit]\nRequires=a.service\n")},
+			{Name: "c.service", Content: strPtr("[Unit]\nBefore=b.service\n"), DropIns: []DropIn{{Name: "10-after.conf", Content: "[Unit]\nAfter=a.service\n"}}},
+			{Name: "d.service", Content: strPtr("[Unit]\nAfter=c.service\n")},
+		})
+		Expect(messages(problems)).To(Equal([]string{
+			"Error: a.service: ordering cycle: a.service -> b.service -> c.service -> a.service",
+			"Warning: a.service: requirement cycle: a.service -> b.service -> a.service",
+		}))
+	})
+})
@@ -0,0 +1,74 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package systemd
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// maxNameLength is the maximum length of unit names accepted by systemd.
+const maxNameLength = 255
+
+// unitTypes are the suffixes of the unit types known to systemd.
+var unitTypes = []string{".service", ".socket", ".device", ".mount", ".automount", ".swap", ".target", ".path", ".timer", ".slice", ".scope"}
+
+// validNameChar returns true if the given character may be part of a unit name. systemd allows
+// ASCII letters and digits, `:`, `-`, `_`, `.` and `\`, as well as `@` to separate the instance
+// of template units.
+func validNameChar(c rune) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || strings.ContainsRune(`:-_.\@`, c)
+}
+
+// ValidateUnitName returns an error if the given name is not a valid unit name: it must consist of
+// the characters allowed by systemd only and end with the suffix of a unit type.
+func ValidateUnitName(name string) error {
+	if err := validateName(name); err != nil {
+		return fmt.Errorf("invalid unit name %q: %v", name, err)
+	}
+
+	ext := path.Ext(name)
+	for _, unitType := range unitTypes {
+		if ext == unitType && len(name) > len(ext) && !strings.HasPrefix(name, "@") {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid unit name %q: unknown unit type %q", name, ext)
+}
+
+// ValidateDropInName returns an error if the given name is not a valid drop-in name: it must
+// consist of the characters allowed in unit names only and end with `.conf`.
+func ValidateDropInName(name string) error {
+	if err := validateName(name); err != nil {
+		return fmt.Errorf("invalid drop-in name %q: %v", name, err)
+	}
+	if !strings.HasSuffix(name, ".conf") || name == ".conf" {
+		return fmt.Errorf("invalid drop-in name %q: drop-in names must end with .conf", name)
+	}
+	return nil
+}
+
+func validateName(name string) error {
+	if len(name) > maxNameLength {
+		return fmt.Errorf("must not be longer than %d characters", maxNameLength)
+	}
+	for _, c := range name {
+		if !validNameChar(c) {
+			return fmt.Errorf("character %q is not allowed", c)
+		}
+	}
+	return nil
+}
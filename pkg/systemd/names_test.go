@@ -0,0 +1,42 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package systemd_test
+
+import (
+	"strings"
+
+	. "github.com/gardener/gardener-extensions/pkg/systemd"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Names", func() {
+	It("should validate unit names", func() {
+		for _, name := range []string{"kubelet.service", "getty@tty1.service", `var-lib-docker\x2dfoo.mount`, "cleanup.timer"} {
+			Expect(ValidateUnitName(name)).To(Succeed(), name)
+		}
+		for _, name := range []string{"", ".service", "@foo.service", "kubelet.conf", "foo bar.service", "foo'.service", "../foo.service", "foo\n.service", strings.Repeat("a", 256) + ".service"} {
+			Expect(ValidateUnitName(name)).NotTo(Succeed(), name)
+		}
+	})
+
+	It("should validate drop-in names", func() {
+		Expect(ValidateDropInName("10-env.conf")).To(Succeed())
+		for _, name := range []string{"10-env", ".conf", "$(reboot).conf", "../10-env.conf"} {
+			Expect(ValidateDropInName(name)).NotTo(Succeed(), name)
+		}
+	})
+})
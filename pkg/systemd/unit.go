@@ -0,0 +1,185 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package systemd
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+)
+
+// Entry is an assignment of a value to a key in a section of a unit file.
+type Entry struct {
+	// Key is the key of the assignment.
+	Key string
+	// Value is the assigned value, continuation lines are joined with a space.
+	Value string
+	// Line is the line number the assignment starts at.
+	Line int
+}
+
+// Section is a section of a unit file.
+type Section struct {
+	// Name is the name of the section without brackets, e.g. `Unit`.
+	Name string
+	// Line is the line number of the section header.
+	Line int
+	// Entries are the assignments of the section in order of appearance.
+	Entries []Entry
+}
+
+// UnitFile is a parsed unit file or drop-in.
+type UnitFile struct {
+	// Sections are the sections of the unit file in order of appearance. A section name may
+	// appear multiple times.
+	Sections []*Section
+}
+
+// ParseError is an error in the syntax of a unit file.
+type ParseError struct {
+	// Line is the line number the error occurred at.
+	Line int
+	// Message describes the error.
+	Message string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// ParseErrors is a list of ParseError.
+type ParseErrors []*ParseError
+
+func (e ParseErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Parse parses the given INI-style unit file content the way systemd does: comments start with
+// `#` or `;`, lines ending with a backslash are continued on the next line and keys may be
+// assigned multiple times. The returned UnitFile contains all valid parts of the content, even
+// if ParseErrors are returned.
+func Parse(content string) (*UnitFile, error) {
+	var (
+		file    = &UnitFile{}
+		errs    ParseErrors
+		section *Section
+
+		pending     *Entry
+		pendingText string
+	)
+
+	addAssignment := func(line int, text string) {
+		i := strings.Index(text, "=")
+		if i < 0 {
+			errs = append(errs, &ParseError{Line: line, Message: fmt.Sprintf("assignment %q is missing '='", text)})
+			return
+		}
+		key := strings.TrimSpace(text[:i])
+		if key == "" {
+			errs = append(errs, &ParseError{Line: line, Message: "assignment without key"})
+			return
+		}
+		if section == nil {
+			errs = append(errs, &ParseError{Line: line, Message: fmt.Sprintf("assignment of %q outside of any section", key)})
+			return
+		}
+		section.Entries = append(section.Entries, Entry{Key: key, Value: strings.TrimSpace(text[i+1:]), Line: line})
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(content)+1)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		if pending != nil {
+			// Comments within continued assignments are ignored.
+			if strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+				continue
+			}
+			if strings.HasSuffix(line, "\\") {
+				pendingText = joinContinuation(pendingText, strings.TrimSuffix(line, "\\"))
+				continue
+			}
+			addAssignment(pending.Line, joinContinuation(pendingText, line))
+			pending = nil
+			continue
+		}
+
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+		case strings.HasPrefix(line, "["):
+			if !strings.HasSuffix(line, "]") || len(line) < 3 {
+				errs = append(errs, &ParseError{Line: lineNumber, Message: fmt.Sprintf("invalid section header %q", line)})
+				section = nil
+				continue
+			}
+			section = &Section{Name: line[1 : len(line)-1], Line: lineNumber}
+			file.Sections = append(file.Sections, section)
+		case strings.HasSuffix(line, "\\"):
+			pending = &Entry{Line: lineNumber}
+			pendingText = strings.TrimSuffix(line, "\\")
+		default:
+			addAssignment(lineNumber, line)
+		}
+	}
+	if pending != nil {
+		addAssignment(pending.Line, pendingText)
+	}
+
+	if len(errs) > 0 {
+		return file, errs
+	}
+	return file, nil
+}
+
+func joinContinuation(text, continuation string) string {
+	return strings.TrimRight(text, " \t") + " " + strings.TrimSpace(continuation)
+}
+
+// HasSection returns true if the unit file contains a section with the given name.
+func (f *UnitFile) HasSection(name string) bool {
+	for _, section := range f.Sections {
+		if section.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Values returns the effective values of the given key in all sections with the given name.
+// Assigning the empty string resets the list of values, as systemd does for list settings.
+func (f *UnitFile) Values(section, key string) []string {
+	var values []string
+	for _, s := range f.Sections {
+		if s.Name != section {
+			continue
+		}
+		for _, entry := range s.Entries {
+			if entry.Key != key {
+				continue
+			}
+			if entry.Value == "" {
+				values = nil
+				continue
+			}
+			values = append(values, entry.Value)
+		}
+	}
+	return values
+}
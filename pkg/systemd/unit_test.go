@@ -0,0 +1,74 @@
+// Copyright (c) 2019 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package systemd_test
+
+import (
+	. "github.com/gardener/gardener-extensions/pkg/systemd"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Unit", func() {
+	Describe("#Parse", func() {
+		It("should parse sections, comments, continuations and repeated keys", func() {
+			file, err := Parse(`# comment
+[Unit]
+Description=foo
+After=a.service
+; another comment
+After=b.service \
+  c.service
+
+[Service]
+ExecStart=/bin/foo \
+# comment within continuation
+  --bar
+Environment=
+`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(file.Sections).To(HaveLen(2))
+			Expect(file.Sections[0].Name).To(Equal("Unit"))
+			Expect(file.Sections[0].Line).To(Equal(2))
+			Expect(file.Sections[1].Entries).To(Equal([]Entry{
+				{Key: "ExecStart", Value: "/bin/foo --bar", Line: 10},
+				{Key: "Environment", Value: "", Line: 13},
+			}))
+			Expect(file.Values("Unit", "After")).To(Equal([]string{"a.service", "b.service c.service"}))
+			Expect(file.HasSection("Service")).To(BeTrue())
+			Expect(file.HasSection("Install")).To(BeFalse())
+		})
+
+		It("should reset list values on empty assignments", func() {
+			file, err := Parse("[Unit]\nAfter=a.service\n[Unit]\nAfter=\nAfter=b.service\n")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(file.Values("Unit", "After")).To(Equal([]string{"b.service"}))
+		})
+
+		It("should return the valid parts and all syntax errors", func() {
+			file, err := Parse("Description=foo\n[Unit\n[Unit]\nfoo\n=bar\nDescription=bar\n")
+			Expect(err).To(HaveOccurred())
+			errs, ok := err.(ParseErrors)
+			Expect(ok).To(BeTrue())
+
+			var lines []int
+			for _, e := range errs {
+				lines = append(lines, e.Line)
+			}
+			Expect(lines).To(Equal([]int{1, 2, 4, 5}))
+			Expect(file.Values("Unit", "Description")).To(Equal([]string{"bar"}))
+		})
+	})
+})